@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configOutput  string
+	convertOutput string
+	convertFrom   string
+	configForce   bool
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Generate config file",
+	}
+	configInitCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Generate config file interactively",
+		Long:  `This command asks for asn, anycast ip, neighbors and health check url, suggesting loopback addresses and gateways of default routes found in kernel, and writes valid config file`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			checkConfigOutput(configOutput)
+			candidates, err := speaker.DetectConfigCandidates()
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Failed to detect defaults from kernel: %s\n", err)
+			}
+			config, err := askConfig(bufio.NewReader(os.Stdin), candidates)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			writeConfig(configOutput, config)
+		},
+	}
+	configConvertCmd = &cobra.Command{
+		Use:   "convert file",
+		Short: "Convert bgp config of another daemon to config file",
+		Long:  `This command converts router bgp section of frr config (asn, neighbors, peer groups and network used as anycast ip) to config file, printing warnings about statements which are not converted`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if convertFrom != "frr" {
+				_, _ = fmt.Fprintf(os.Stderr, "Unsupported format %q, only frr is supported\n", convertFrom)
+				os.Exit(1)
+			}
+			checkConfigOutput(convertOutput)
+			f, err := os.Open(args[0])
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			config, warnings, err := speaker.ConvertFRRConfig(f)
+			for _, w := range warnings {
+				_, _ = fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Failed to convert %s:\n%s\n", args[0], err)
+				os.Exit(1)
+			}
+			writeConfig(convertOutput, config)
+		},
+	}
+)
+
+// Функция askConfig спрашивает значения конфигурации, пока ответ не станет корректным.
+func askConfig(r *bufio.Reader, candidates speaker.ConfigCandidates) (speaker.ConfigTemplate, error) {
+	config := speaker.ConfigTemplate{}
+	asn, err := askASN(r, "ASN of speaker")
+	if err != nil {
+		return config, err
+	}
+	config.ASN = asn
+	anycastIP := ""
+	if len(candidates.AnycastIPs) > 0 {
+		anycastIP = candidates.AnycastIPs[0]
+	}
+	for {
+		if config.AnycastIP, err = ask(r, "Anycast IP", anycastIP); err != nil {
+			return config, err
+		}
+		if ip := net.ParseIP(config.AnycastIP); ip != nil && ip.To4() != nil {
+			break
+		}
+		fmt.Printf("%q is not a valid ipv4 address\n", config.AnycastIP)
+	}
+	for len(config.Neighbors) == 0 {
+		answer, err := ask(r, "Neighbors, comma separated", strings.Join(candidates.Neighbors, ","))
+		if err != nil {
+			return config, err
+		}
+		for _, address := range strings.Split(answer, ",") {
+			if address = strings.TrimSpace(address); address == "" {
+				continue
+			}
+			if net.ParseIP(address) == nil {
+				fmt.Printf("%q is not a valid ip address\n", address)
+				config.Neighbors = nil
+				break
+			}
+			config.Neighbors = append(config.Neighbors, speaker.NeighborTemplate{Address: address})
+		}
+	}
+	for i := range config.Neighbors {
+		if config.Neighbors[i].ASN, err = askASN(r, "ASN of neighbor "+config.Neighbors[i].Address); err != nil {
+			return config, err
+		}
+	}
+	for {
+		if config.HealthCheckURL, err = ask(r, "Health check URL (empty to advertise without health check)", ""); err != nil {
+			return config, err
+		}
+		if config.HealthCheckURL == "" {
+			break
+		}
+		err := speaker.ValidateHealthCheckURL(config.HealthCheckURL)
+		if err == nil {
+			break
+		}
+		fmt.Printf("%q is not a valid health check url: %s\n", config.HealthCheckURL, err)
+	}
+	return config, nil
+}
+
+func askASN(r *bufio.Reader, question string) (uint32, error) {
+	for {
+		answer, err := ask(r, question, "")
+		if err != nil {
+			return 0, err
+		}
+		asn, err := strconv.ParseUint(answer, 10, 32)
+		if err == nil && asn != 0 {
+			return uint32(asn), nil
+		}
+		fmt.Printf("%q is not a valid asn\n", answer)
+	}
+}
+
+// Функция ask печатает вопрос и возвращает ответ или def, если ответ пустой.
+func ask(r *bufio.Reader, question, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, err := r.ReadString('\n')
+	if errors.Is(err, io.EOF) && answer == "" {
+		return "", errors.New("no answer, input is closed")
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// Функция checkConfigOutput завершает команду, если файл path уже есть, а --force не задан.
+func checkConfigOutput(path string) {
+	if _, err := os.Stat(path); path != "" && err == nil && !configForce {
+		_, _ = fmt.Fprintf(os.Stderr, "%s already exists, use --force to overwrite\n", path)
+		os.Exit(1)
+	}
+}
+
+// Функция writeConfig проверяет конфигурацию и записывает ее в path или в stdout, если path пустой.
+func writeConfig(path string, config speaker.ConfigTemplate) {
+	b, err := config.Marshal()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if path == "" {
+		_, _ = os.Stdout.Write(b)
+		return
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Failed to write config: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is written\n", path)
+}
+
+func init() {
+	configCmd.PersistentFlags().BoolVar(&configForce, "force", false, "overwrite existing config file")
+	configInitCmd.Flags().StringVarP(&configOutput, "output", "o", defaults.ConfigPath, "path of generated config file")
+	configConvertCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "path of generated config file, stdout if empty")
+	configConvertCmd.Flags().StringVar(&convertFrom, "from", "frr", "format of file: frr")
+	configCmd.AddCommand(configInitCmd, configConvertCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugCmd = &cobra.Command{
+		Use:   "debug",
+		Short: "Debug running daemon",
+	}
+	debugPathCmd = &cobra.Command{
+		Use:   "path",
+		Short: "Show announced anycast path",
+		Long:  `This command prints attributes of announced anycast path as sent to gobgp (anypb) and as encoded on the wire (decoded and hex) for global rib and adj-rib-out of every neighbor`,
+		Run: func(cmd *cobra.Command, args []string) {
+			dump, err := speaker.NewAdminClient(adminAddress).AnnouncedPath()
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(dump)
+		},
+	}
+	debugHealthCmd = &cobra.Command{
+		Use:   "health",
+		Short: "Show health check history",
+		Long:  `This command prints time, latency and error of the last health checks of anycast ip and of every health check from health_checks, to see when and why anycast ip was withdrawn`,
+		Run: func(cmd *cobra.Command, args []string) {
+			history, err := speaker.NewAdminClient(adminAddress).HealthHistory()
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(history)
+		},
+	}
+	debugAuditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Show advertisement decisions",
+		Long:  `This command prints time, prefix and cause (startup, health check, maintenance, leader election, hooks, admin api, kubernetes, shutdown) of the last advertise and withdraw decisions, for post-incident review`,
+		Run: func(cmd *cobra.Command, args []string) {
+			entries, err := speaker.NewAdminClient(adminAddress).Audit()
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(entries)
+		},
+	}
+	debugBundleCmd = &cobra.Command{
+		Use:   "bundle",
+		Short: "Collect support bundle",
+		Long:  `This command saves a tarball with config (secrets redacted), rib, policies, peer states, kernel routes, recent logs, health check history and advertisement decisions of running daemon, to attach it to an incident ticket`,
+		Run: func(cmd *cobra.Command, args []string) {
+			output := debugBundleOutput
+			if output == "" {
+				output = fmt.Sprintf("bgp-speaker-bundle-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			err = speaker.NewAdminClient(adminAddress).DebugBundle(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(output)
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			fmt.Printf("bundle saved to %s\n", output)
+		},
+	}
+	debugCaptureCmd = &cobra.Command{
+		Use:   "capture",
+		Short: "Capture BGP messages to pcap file",
+		Long:  `This command starts, stops or shows capture of BGP sessions with neighbors into pcap file in message_capture.directory of running daemon, to inspect BGP messages in wireshark`,
+	}
+	debugCaptureStartCmd = &cobra.Command{
+		Use:   "start [neighbor...]",
+		Short: "Start capture of BGP sessions with neighbors, all neighbors if omitted",
+		Run: func(cmd *cobra.Command, args []string) {
+			req := speaker.CaptureRequest{
+				Enabled:         true,
+				Neighbors:       args,
+				DurationSeconds: uint32(debugCaptureDuration.Seconds()),
+			}
+			status, err := speaker.NewAdminClient(adminAddress).Capture(req)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(status)
+		},
+	}
+	debugCaptureStopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop running capture",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := speaker.NewAdminClient(adminAddress).Capture(speaker.CaptureRequest{Enabled: false})
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(status)
+		},
+	}
+	debugCaptureStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show running or last capture",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := speaker.NewAdminClient(adminAddress).CaptureStatus()
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(status)
+		},
+	}
+	debugBundleOutput    string
+	debugCaptureDuration time.Duration
+)
+
+func init() {
+	debugCmd.PersistentFlags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	debugCmd.AddCommand(debugPathCmd)
+	debugCmd.AddCommand(debugHealthCmd)
+	debugCmd.AddCommand(debugAuditCmd)
+	debugBundleCmd.Flags().StringVarP(&debugBundleOutput, "output", "o", "", "path of bundle file, by default bgp-speaker-bundle-<time>.tar.gz in current directory")
+	debugCmd.AddCommand(debugBundleCmd)
+	debugCaptureStartCmd.Flags().DurationVarP(&debugCaptureDuration, "duration", "d", time.Minute, "duration of capture, limited by message_capture.max_duration_seconds")
+	debugCaptureCmd.AddCommand(debugCaptureStartCmd)
+	debugCaptureCmd.AddCommand(debugCaptureStopCmd)
+	debugCaptureCmd.AddCommand(debugCaptureStatusCmd)
+	debugCmd.AddCommand(debugCaptureCmd)
+	rootCmd.AddCommand(debugCmd)
+}
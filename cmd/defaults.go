@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/spf13/cobra"
+)
+
+var (
+	defaultsJSON bool
+
+	defaultsCmd = &cobra.Command{
+		Use:   "defaults",
+		Short: "Print built-in defaults",
+		Long:  `This command prints built-in default values, including ones overridden at build time via -ldflags`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := defaults.FIBMetric(); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if defaultsJSON {
+				printJSON(defaults.List())
+				return
+			}
+			for _, d := range defaults.List() {
+				value := d.Value
+				if value == "" {
+					value = "<unset>"
+				}
+				fmt.Printf("%-20s %s\n", d.Name, value)
+			}
+		},
+	}
+)
+
+func init() {
+	defaultsCmd.Flags().BoolVar(&defaultsJSON, "json", false, "print defaults as json")
+	rootCmd.AddCommand(defaultsCmd)
+}
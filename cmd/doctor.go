@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/netlink"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorConfigPath string
+	doctorNetns      string
+
+	doctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that host is ready to run daemon with config file",
+		Long:  `This command checks privileges, rtnetlink, tcp reachability of neighbors, conflicting routes with speaker metric and free grpc port, printing pass or fail for each check`,
+		Args:  cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return netlink.ExecInNetNS(doctorNetns)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			app, err := speaker.NewAppCfg(doctorConfigPath, logLevel)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error in application initialization: %s\n", err)
+				os.Exit(1)
+			}
+			if err := app.Doctor(context.Background(), os.Stdout); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Doctor: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	doctorCmd.Flags().StringVarP(&doctorConfigPath, "config", "c", defaults.ConfigPath, "config file")
+	doctorCmd.Flags().StringVar(&doctorNetns, "netns", "", "run in network namespace from /var/run/netns")
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/sir-sukhov/bgp-speaker/internal/netlink"
 	"github.com/spf13/cobra"
@@ -13,8 +16,74 @@ var (
 		Use:   "fib",
 		Short: "Work with routing table",
 		Long:  `This command similar to 'iproute2', was added just to play around with netlink`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return netlink.ExecInNetNS(netns)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filter, err := netlink.ParseRouteFilter(fibTable, fibProtocol, fibFamily)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if err := netlink.PrintRoutes(filter, fibJSON); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	fibJSON     bool
+	fibTable    uint32
+	fibProtocol string
+	fibFamily   string
+	watchCmd    = &cobra.Command{
+		Use:   "watch",
+		Short: "Print route changes in real time",
+		Long:  `This is like 'ip monitor route', accepts the same filter flags as fib command`,
+		Run: func(cmd *cobra.Command, args []string) {
+			filter, err := netlink.ParseRouteFilter(fibTable, fibProtocol, fibFamily)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+			defer stop()
+			if err := netlink.PrintRouteEvents(ctx, filter, fibJSON); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	getCmd = &cobra.Command{
+		Use:   "get <destination>",
+		Short: "Show route which kernel uses for destination",
+		Long:  `This is like 'ip route get'`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := netlink.PrintRouteLookup(args[0], fibJSON); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	routePrefix string
+	routeMetric uint32
+	setRouteCmd = &cobra.Command{
+		Use:   "set-route",
+		Short: "Add or replace route to prefix via gateway",
+		Long:  `This is like 'ip route replace...', comma separated gateways make multipath route`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := netlink.SetRoute(routePrefix, gateway, routeMetric); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+	deleteRouteCmd = &cobra.Command{
+		Use:   "delete-route",
+		Short: "Delete route to prefix",
+		Long:  `This is like 'ip route del...'`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := netlink.PrintRoutes(); err != nil {
+			if err := netlink.DeleteRoute(routePrefix, routeMetric); err != nil {
 				fmt.Println(err.Error())
 				os.Exit(1)
 			}
@@ -26,7 +95,7 @@ var (
 		Short: "Update default route to gateway",
 		Long:  `This is like templated 'ip route add...'`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := netlink.SetDefaultRoute(gateway); err != nil {
+			if err := netlink.SetDefaultRoute(gateway, routeMetric); err != nil {
 				fmt.Println(err.Error())
 				os.Exit(1)
 			}
@@ -37,7 +106,7 @@ var (
 		Short: "Delete default route to gateway",
 		Long:  `This is like templated 'ip route del...'`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if err := netlink.DeleteDefaultRoute(); err != nil {
+			if err := netlink.DeleteDefaultRoute(routeMetric); err != nil {
 				fmt.Println(err.Error())
 				os.Exit(1)
 			}
@@ -45,12 +114,37 @@ var (
 	}
 )
 
-const gatewayFlagName = "gateway"
+const (
+	gatewayFlagName = "gateway"
+	prefixFlagName  = "prefix"
+)
 
 func init() {
 	setDefaultRouteCmd.Flags().StringVarP(&gateway, gatewayFlagName, "g", "", "IP address of default gateway")
 	_ = setDefaultRouteCmd.MarkFlagRequired(gatewayFlagName)
+	for _, c := range []*cobra.Command{fibCmd, watchCmd, getCmd} {
+		c.Flags().BoolVar(&fibJSON, "json", false, "print routes as json")
+	}
+	for _, c := range []*cobra.Command{fibCmd, watchCmd} {
+		c.Flags().Uint32Var(&fibTable, "table", 0, "show only routes from table id")
+		c.Flags().StringVar(&fibProtocol, "protocol", "", "show only routes of protocol, e.g. bgp, kernel, static or protocol id")
+		c.Flags().StringVar(&fibFamily, "family", "", "show only routes of family inet or inet6")
+	}
+	for _, c := range []*cobra.Command{setRouteCmd, deleteRouteCmd, setDefaultRouteCmd, deleteDefaultRouteCmd} {
+		c.Flags().Uint32VarP(&routeMetric, "metric", "m", netlink.RoutePriority, "priority of route, use a value different from update_fib_metric to coexist with daemon routes")
+	}
+	for _, c := range []*cobra.Command{setRouteCmd, deleteRouteCmd} {
+		c.Flags().StringVarP(&routePrefix, prefixFlagName, "p", "", "prefix of route, e.g. 10.0.0.0/24")
+		_ = c.MarkFlagRequired(prefixFlagName)
+	}
+	setRouteCmd.Flags().StringVarP(&gateway, gatewayFlagName, "g", "", "IP address of gateway, comma separated for multipath")
+	_ = setRouteCmd.MarkFlagRequired(gatewayFlagName)
+	fibCmd.PersistentFlags().StringVar(&netns, "netns", "", "run in network namespace from /var/run/netns")
 	fibCmd.AddCommand(setDefaultRouteCmd)
 	fibCmd.AddCommand(deleteDefaultRouteCmd)
+	fibCmd.AddCommand(setRouteCmd)
+	fibCmd.AddCommand(deleteRouteCmd)
+	fibCmd.AddCommand(watchCmd)
+	fibCmd.AddCommand(getCmd)
 	rootCmd.AddCommand(fibCmd)
 }
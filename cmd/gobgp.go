@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/netlink"
 	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
 	"github.com/spf13/cobra"
 )
@@ -11,17 +13,29 @@ import (
 var (
 	configPath string
 	logLevel   speaker.LogLevel
+	dryRun     bool
+	netns      string
 
 	gobgpCmd = &cobra.Command{
 		Use:   "gobgp",
 		Short: "Run gobgp daemon",
 		Long:  `This command start gobgp daemon as native library and performs it's setup for anycast advertisement`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return netlink.ExecInNetNS(netns)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			app, err := speaker.NewAppCfg(configPath, logLevel)
 			if err != nil {
 				_, _ = fmt.Fprintf(os.Stderr, "Error in application initialization: %s\n", err)
 				os.Exit(1)
 			}
+			if dryRun {
+				if err := app.DryRun(os.Stdout); err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "Dry run failed: %s\n", err)
+					os.Exit(1)
+				}
+				return
+			}
 			if err := app.Run(); err != nil {
 				_, _ = fmt.Fprintf(os.Stderr, "Exiting: %s\n", err)
 				os.Exit(1)
@@ -31,7 +45,9 @@ var (
 )
 
 func init() {
-	gobgpCmd.Flags().StringVarP(&configPath, "config", "c", "config.yaml", "config file (default is config.yaml)")
+	gobgpCmd.PersistentFlags().StringVarP(&configPath, "config", "c", defaults.ConfigPath, "config file")
+	gobgpCmd.PersistentFlags().StringVar(&netns, "netns", "", "run in network namespace from /var/run/netns")
 	gobgpCmd.Flags().VarP(&logLevel, "log-level", "l", "log level")
+	gobgpCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print gobgp requests and fib changes without executing them")
 	rootCmd.AddCommand(gobgpCmd)
 }
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	adminAddress string
+
+	maintenanceCmd = &cobra.Command{
+		Use:       "maintenance on|off",
+		Short:     "Toggle maintenance mode of running daemon",
+		Long:      `This command withdraws ('on') or re-advertises ('off') anycast ip regardless of health check state, same as sending SIGUSR1/SIGUSR2 to the daemon`,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"on", "off"},
+		Run: func(cmd *cobra.Command, args []string) {
+			status, err := speaker.NewAdminClient(adminAddress).SetMaintenance(args[0] == "on")
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(status)
+		},
+	}
+)
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func init() {
+	maintenanceCmd.Flags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	rootCmd.AddCommand(maintenanceCmd)
+}
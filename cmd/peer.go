@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	peerDirection     string
+	peerCommunication string
+	peerSoftIn        bool
+	peerSoftOut       bool
+)
+
+var (
+	peerCmd = &cobra.Command{
+		Use:     "peer",
+		Aliases: []string{"neighbor"},
+		Short:   "Manage BGP neighbors of running daemon",
+	}
+	peerDisableCmd = &cobra.Command{
+		Use:   "disable neighbor",
+		Short: "Administratively shut down session with neighbor",
+		Long:  `This command shuts down session with neighbor without removing it from config, e.g. for maintenance of single uplink, until enable or restart of daemon`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := speaker.NewAdminClient(adminAddress).DisablePeer(speaker.PeerAdminRequest{Neighbor: args[0], Communication: peerCommunication})
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(resp)
+		},
+	}
+	peerResetCmd = &cobra.Command{
+		Use:   "reset neighbor",
+		Short: "Reset session with neighbor or refresh its routes",
+		Long:  `This command clears session with neighbor, which is established again, or with --soft-in or --soft-out refreshes routes of neighbor without resetting session like soft-refresh command`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client := speaker.NewAdminClient(adminAddress)
+			var resp any
+			var err error
+			switch {
+			case peerSoftIn && peerSoftOut:
+				resp, err = client.SoftRefresh(speaker.SoftRefreshRequest{Neighbor: args[0], Direction: "both"})
+			case peerSoftIn:
+				resp, err = client.SoftRefresh(speaker.SoftRefreshRequest{Neighbor: args[0], Direction: "in"})
+			case peerSoftOut:
+				resp, err = client.SoftRefresh(speaker.SoftRefreshRequest{Neighbor: args[0], Direction: "out"})
+			default:
+				resp, err = client.ResetPeer(speaker.PeerAdminRequest{Neighbor: args[0], Communication: peerCommunication})
+			}
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(resp)
+		},
+	}
+	peerEnableCmd = &cobra.Command{
+		Use:   "enable neighbor",
+		Short: "Enable session with administratively disabled neighbor",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := speaker.NewAdminClient(adminAddress).EnablePeer(speaker.PeerAdminRequest{Neighbor: args[0]})
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(resp)
+		},
+	}
+	peerSoftRefreshCmd = &cobra.Command{
+		Use:   "soft-refresh [neighbor]",
+		Short: "Apply policies to routes of neighbor again without resetting session",
+		Long:  `This command re-applies import policy to stored adj-rib-in (direction in) or resends adj-rib-out (direction out) of neighbor or of all neighbors if neighbor is omitted`,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			req := speaker.SoftRefreshRequest{Direction: peerDirection}
+			if len(args) > 0 {
+				req.Neighbor = args[0]
+			}
+			resp, err := speaker.NewAdminClient(adminAddress).SoftRefresh(req)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(resp)
+		},
+	}
+)
+
+func init() {
+	peerCmd.PersistentFlags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	peerSoftRefreshCmd.Flags().StringVar(&peerDirection, "direction", "in", "direction of refresh: in, out or both")
+	peerDisableCmd.Flags().StringVarP(&peerCommunication, "message", "m", "", "shutdown communication sent to neighbor in NOTIFICATION")
+	peerResetCmd.Flags().StringVarP(&peerCommunication, "message", "m", "", "reset communication sent to neighbor in NOTIFICATION")
+	peerResetCmd.Flags().BoolVar(&peerSoftIn, "soft-in", false, "re-apply import policy to routes received from neighbor instead of reset")
+	peerResetCmd.Flags().BoolVar(&peerSoftOut, "soft-out", false, "resend routes to neighbor instead of reset")
+	peerResetCmd.MarkFlagsMutuallyExclusive("soft-in", "message")
+	peerResetCmd.MarkFlagsMutuallyExclusive("soft-out", "message")
+	peerCmd.AddCommand(peerSoftRefreshCmd, peerResetCmd, peerDisableCmd, peerEnableCmd)
+	rootCmd.AddCommand(peerCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyCmd = &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect BGP policies of running daemon",
+	}
+	policyShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show effective policies",
+		Long:  `This command prints defined sets, policies and global import and export assignments with default actions as they are configured in gobgp of running daemon, for audit of what policy_mode, policies and default_action were compiled into`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			dump, err := speaker.NewAdminClient(adminAddress).Policies()
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			printJSON(dump)
+		},
+	}
+)
+
+func init() {
+	policyCmd.PersistentFlags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	policyCmd.AddCommand(policyShowCmd)
+	rootCmd.AddCommand(policyCmd)
+}
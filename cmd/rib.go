@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ribQuery speaker.RIBQuery
+	ribJSON  bool
+
+	ribCmd = &cobra.Command{
+		Use:   "rib [prefix]",
+		Short: "Show global rib of running daemon",
+		Long:  `This command prints global rib of running daemon, optionally only the given prefix or, with --longer-prefixes, prefixes covered by it`,
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 {
+				ribQuery.Prefix = args[0]
+			}
+			rib, err := speaker.NewAdminClient(adminAddress).RIB(ribQuery)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+			if ribJSON {
+				printJSON(rib)
+				return
+			}
+			printRIB(rib)
+		},
+	}
+)
+
+func printRIB(rib []speaker.RIBDestination) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNETWORK\tNEXT HOP\tAS_PATH\tAGE\tCOMMUNITIES")
+	for _, d := range rib {
+		for _, p := range d.Paths {
+			best := ""
+			if p.Best {
+				best = "*>"
+			}
+			asPath := make([]string, 0, len(p.ASPath))
+			for _, asn := range p.ASPath {
+				asPath = append(asPath, fmt.Sprint(asn))
+			}
+			age := (time.Duration(p.AgeSeconds) * time.Second).String()
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", best, d.Prefix, p.NextHop, strings.Join(asPath, " "), age, strings.Join(p.Communities, " "))
+		}
+	}
+	_ = w.Flush()
+}
+
+func init() {
+	ribCmd.Flags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	ribCmd.Flags().StringVarP(&ribQuery.Family, "family", "f", "ipv4", "address family: ipv4 or ipv6")
+	ribCmd.Flags().BoolVar(&ribQuery.Longer, "longer-prefixes", false, "show prefixes covered by the given prefix")
+	ribCmd.Flags().BoolVar(&ribJSON, "json", false, "print rib as json")
+	rootCmd.AddCommand(ribCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var routeNextHop string
+
+var (
+	routeCmd = &cobra.Command{
+		Use:   "route",
+		Short: "Manage additional prefixes of running daemon",
+		Long:  `This command advertises or withdraws additional /32 prefixes besides anycast ip, e.g. for migrations`,
+	}
+	routeListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List additional advertised prefixes",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			printRoutes(speaker.NewAdminClient(adminAddress).Routes())
+		},
+	}
+	routeAdvertiseCmd = &cobra.Command{
+		Use:   "advertise <prefix>",
+		Short: "Advertise additional /32 prefix",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			printRoutes(speaker.NewAdminClient(adminAddress).AdvertiseRoute(args[0], routeNextHop))
+		},
+	}
+	routeWithdrawCmd = &cobra.Command{
+		Use:   "withdraw <prefix>",
+		Short: "Withdraw additional /32 prefix",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			printRoutes(speaker.NewAdminClient(adminAddress).WithdrawRoute(args[0]))
+		},
+	}
+)
+
+func printRoutes(routes *speaker.RoutesResponse, err error) {
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	printJSON(routes)
+}
+
+func init() {
+	routeCmd.PersistentFlags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	routeAdvertiseCmd.Flags().StringVar(&routeNextHop, "next-hop", "", "advertise prefix on behalf of another host with this next-hop")
+	routeCmd.AddCommand(routeListCmd)
+	routeCmd.AddCommand(routeAdvertiseCmd)
+	routeCmd.AddCommand(routeWithdrawCmd)
+	rootCmd.AddCommand(routeCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/routeserver"
+	"github.com/spf13/cobra"
+)
+
+var (
+	routeServerConfigPath string
+
+	routeServerCmd = &cobra.Command{
+		Use:   "route-server",
+		Short: "Run simple BGP peer emulator",
+		Long:  `This command starts gobgp as a passive peer for lab testing: it advertises configured prefixes and records received advertisements and withdrawals to a file`,
+		Run: func(cmd *cobra.Command, args []string) {
+			rs, err := routeserver.NewRouteServer(routeServerConfigPath, logLevel)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error in application initialization: %s\n", err)
+				os.Exit(1)
+			}
+			if err := rs.Run(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Exiting: %s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	routeServerCmd.Flags().StringVarP(&routeServerConfigPath, "config", "c", "route-server.yaml", "config file")
+	routeServerCmd.Flags().VarP(&logLevel, "log-level", "l", "log level")
+	rootCmd.AddCommand(routeServerCmd)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/netlink"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	// clearScreen переводит курсор в начало терминала и очищает его.
+	clearScreen = "\033[H\033[2J"
+	// topAuditEntries это сколько последних решений об анонсе показывает top.
+	topAuditEntries = 8
+)
+
+var (
+	topInterval time.Duration
+
+	topCmd = &cobra.Command{
+		Use:   "top",
+		Short: "Show live state of running daemon",
+		Long:  `This command refreshes peer states, advertised prefixes, health status, recent advertise and withdraw decisions from admin api and default routes from kernel until interrupted`,
+		Args:  cobra.NoArgs,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return netlink.ExecInNetNS(netns)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if topInterval <= 0 {
+				fmt.Println("interval must be positive")
+				os.Exit(1)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+			defer stop()
+			client := speaker.NewAdminClient(adminAddress)
+			ticker := time.NewTicker(topInterval)
+			defer ticker.Stop()
+			for {
+				var buf bytes.Buffer
+				renderTop(&buf, client)
+				fmt.Print(clearScreen + buf.String())
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+)
+
+// Функция renderTop печатает в w один экран top, ошибка запроса печатается вместо его секции.
+func renderTop(w io.Writer, client *speaker.AdminClient) {
+	fmt.Fprintf(w, "bgp-speaker top: admin api %s, %s, refresh %s, ctrl-c to quit\n\n", adminAddress, time.Now().Format(time.TimeOnly), topInterval)
+
+	status, err := client.Status()
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+		return
+	}
+	advertised := "withdrawn"
+	if status.Advertised {
+		advertised = "advertised"
+	}
+	fmt.Fprintf(w, "anycast ip %s: %s, healthy %s, maintenance %s, degraded %s, leader %s",
+		status.AnycastIP, advertised, yesNo(status.Healthy), yesNo(status.Maintenance), yesNo(status.Degraded), yesNo(status.Leader))
+	if status.Reachable != nil {
+		fmt.Fprintf(w, ", reachable %s", yesNo(*status.Reachable))
+	}
+	fmt.Fprintln(w)
+	names := maps.Keys(status.HealthChecks)
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  health check %s: %s\n", name, passFail(status.HealthChecks[name]))
+	}
+
+	fmt.Fprintln(w, "\nPEERS")
+	if peers, err := client.Peers(); err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NEIGHBOR\tASN\tSTATE\tUPTIME\tRECEIVED\tACCEPTED\tADVERTISED")
+		for _, p := range peers {
+			state := p.State
+			if p.AdminDown {
+				state += " (admin down)"
+			}
+			uptime := time.Duration(p.UptimeSeconds) * time.Second
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%d\t%d\n", p.Neighbor, p.ASN, state, uptime, p.Received, p.Accepted, p.Advertised)
+		}
+		_ = tw.Flush()
+	}
+
+	fmt.Fprintln(w, "\nPREFIXES")
+	if routes, err := client.Routes(); err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+	} else if len(routes.Prefixes) == 0 {
+		fmt.Fprintln(w, "no prefixes advertised besides anycast ip")
+	} else {
+		for _, prefix := range routes.Prefixes {
+			if nextHop, ok := routes.NextHops[prefix]; ok {
+				fmt.Fprintf(w, "%s advertised via %s\n", prefix, nextHop)
+			} else {
+				fmt.Fprintf(w, "%s advertised\n", prefix)
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "\nRECENT DECISIONS")
+	if entries, err := client.Audit(); err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, e := range entries[max(0, len(entries)-topAuditEntries):] {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.Time.Format(time.DateTime), e.Action, e.Prefix, e.Cause, e.Detail)
+		}
+		_ = tw.Flush()
+	}
+
+	fmt.Fprintln(w, "\nKERNEL DEFAULT ROUTES")
+	if routes, err := netlink.ListRoutes(netlink.RouteFilter{}); err != nil {
+		fmt.Fprintf(w, "error: %s\n", err)
+	} else {
+		found := false
+		for _, r := range routes {
+			if r.Dst == "default" {
+				fmt.Fprintln(w, r.String())
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintln(w, "no default route")
+		}
+	}
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
+
+func passFail(v bool) string {
+	if v {
+		return "pass"
+	}
+	return "fail"
+}
+
+func init() {
+	topCmd.Flags().StringVarP(&adminAddress, "admin-address", "a", defaults.AdminAddress, "address of daemon admin api")
+	topCmd.Flags().StringVar(&netns, "netns", "", "read kernel routes in network namespace from /var/run/netns")
+	topCmd.Flags().DurationVarP(&topInterval, "interval", "i", time.Second, "refresh interval")
+	rootCmd.AddCommand(topCmd)
+}
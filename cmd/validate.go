@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate config file",
+	Long:  `This command strictly parses config file and checks it without starting bgp or touching netlink`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := speaker.ValidateConfigFile(configPath); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s is invalid:\n%s\n", configPath, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", configPath)
+	},
+}
+
+func init() {
+	gobgpCmd.AddCommand(validateCmd)
+}
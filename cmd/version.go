@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	versionJSON bool
+
+	versionCmd = &cobra.Command{
+		Use:   "version",
+		Short: "Print version and build metadata",
+		Long:  `This command prints version, git commit, build date, go version and version of embedded gobgp library, set at build time via -ldflags`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			info := version.Get()
+			if versionJSON {
+				printJSON(info)
+				return
+			}
+			for _, field := range [][2]string{
+				{"version", info.Version},
+				{"commit", info.Commit},
+				{"build_date", info.BuildDate},
+				{"go_version", info.GoVersion},
+				{"gobgp_version", info.GoBGPVersion},
+			} {
+				value := field[1]
+				if value == "" {
+					value = "<unknown>"
+				}
+				fmt.Printf("%-20s %s\n", field[0], value)
+			}
+		},
+	}
+)
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print version as json")
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,127 @@
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// DryRun это реализация [Server], которая ничего не выполняет, а печатает каждый
+// изменяющий вызов с запросом в формате protojson. Методы List* ничего не находят,
+// WatchEvent не присылает событий.
+type DryRun struct {
+	w io.Writer
+}
+
+func NewDryRun(w io.Writer) *DryRun {
+	return &DryRun{w: w}
+}
+
+func (d *DryRun) print(method string, r proto.Message) error {
+	b, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	_, err = fmt.Fprintf(d.w, "gobgp %s %s\n", method, b)
+	return err
+}
+
+func (d *DryRun) StartBgp(_ context.Context, r *api.StartBgpRequest) error {
+	return d.print("StartBgp", r)
+}
+
+func (d *DryRun) StopBgp(_ context.Context, r *api.StopBgpRequest) error {
+	return d.print("StopBgp", r)
+}
+
+func (d *DryRun) AddPeer(_ context.Context, r *api.AddPeerRequest) error {
+	return d.print("AddPeer", r)
+}
+
+func (d *DryRun) DeletePeer(_ context.Context, r *api.DeletePeerRequest) error {
+	return d.print("DeletePeer", r)
+}
+
+func (d *DryRun) ShutdownPeer(_ context.Context, r *api.ShutdownPeerRequest) error {
+	return d.print("ShutdownPeer", r)
+}
+
+func (d *DryRun) EnablePeer(_ context.Context, r *api.EnablePeerRequest) error {
+	return d.print("EnablePeer", r)
+}
+
+func (d *DryRun) DisablePeer(_ context.Context, r *api.DisablePeerRequest) error {
+	return d.print("DisablePeer", r)
+}
+
+func (d *DryRun) ResetPeer(_ context.Context, r *api.ResetPeerRequest) error {
+	return d.print("ResetPeer", r)
+}
+
+func (d *DryRun) ListPeer(context.Context, *api.ListPeerRequest, func(*api.Peer)) error {
+	return nil
+}
+
+func (d *DryRun) AddPath(_ context.Context, r *api.AddPathRequest) (*api.AddPathResponse, error) {
+	return &api.AddPathResponse{}, d.print("AddPath", r)
+}
+
+func (d *DryRun) DeletePath(_ context.Context, r *api.DeletePathRequest) error {
+	return d.print("DeletePath", r)
+}
+
+func (d *DryRun) ListPath(context.Context, *api.ListPathRequest, func(*api.Destination)) error {
+	return nil
+}
+
+func (d *DryRun) AddDefinedSet(_ context.Context, r *api.AddDefinedSetRequest) error {
+	return d.print("AddDefinedSet", r)
+}
+
+func (d *DryRun) DeleteDefinedSet(_ context.Context, r *api.DeleteDefinedSetRequest) error {
+	return d.print("DeleteDefinedSet", r)
+}
+
+func (d *DryRun) ListDefinedSet(context.Context, *api.ListDefinedSetRequest, func(*api.DefinedSet)) error {
+	return nil
+}
+
+func (d *DryRun) AddRpki(_ context.Context, r *api.AddRpkiRequest) error {
+	return d.print("AddRpki", r)
+}
+
+func (d *DryRun) AddBmp(_ context.Context, r *api.AddBmpRequest) error {
+	return d.print("AddBmp", r)
+}
+
+func (d *DryRun) DeleteBmp(_ context.Context, r *api.DeleteBmpRequest) error {
+	return d.print("DeleteBmp", r)
+}
+
+func (d *DryRun) EnableZebra(_ context.Context, r *api.EnableZebraRequest) error {
+	return d.print("EnableZebra", r)
+}
+
+func (d *DryRun) AddPolicy(_ context.Context, r *api.AddPolicyRequest) error {
+	return d.print("AddPolicy", r)
+}
+
+func (d *DryRun) ListPolicy(context.Context, *api.ListPolicyRequest, func(*api.Policy)) error {
+	return nil
+}
+
+func (d *DryRun) AddPolicyAssignment(_ context.Context, r *api.AddPolicyAssignmentRequest) error {
+	return d.print("AddPolicyAssignment", r)
+}
+
+func (d *DryRun) ListPolicyAssignment(context.Context, *api.ListPolicyAssignmentRequest, func(*api.PolicyAssignment)) error {
+	return nil
+}
+
+func (d *DryRun) WatchEvent(_ context.Context, r *api.WatchEventRequest, _ func(*api.WatchEventResponse)) error {
+	return d.print("WatchEvent", r)
+}
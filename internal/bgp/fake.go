@@ -0,0 +1,457 @@
+package bgp
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/apiutil"
+)
+
+// Fake это реализация [Server] в памяти:
+//   - добавленные соседи сразу считаются established, состояние можно поменять через [Fake.SetPeerState]
+//   - ListPath отдает пути, добавленные через AddPath, только для глобальной таблицы
+//   - политики и defined sets только запоминаются и не применяются к путям,
+//     statements политик с одинаковым именем объединяются, как в gobgp
+//   - события WatchEvent отправляются подписчикам через [Fake.Emit]
+type Fake struct {
+	mu          sync.Mutex
+	started     *api.Global
+	peers       map[string]*api.Peer
+	paths       map[string][]*api.Path
+	definedSets map[string]*api.DefinedSet
+	policies    map[string]*api.Policy
+	assignments []*api.PolicyAssignment
+	rpki        []*api.AddRpkiRequest
+	bmp         []*api.AddBmpRequest
+	zebra       *api.EnableZebraRequest
+	watchers    map[int]func(*api.WatchEventResponse)
+	nextID      int
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		peers:       map[string]*api.Peer{},
+		paths:       map[string][]*api.Path{},
+		definedSets: map[string]*api.DefinedSet{},
+		policies:    map[string]*api.Policy{},
+		watchers:    map[int]func(*api.WatchEventResponse){},
+	}
+}
+
+func (f *Fake) StartBgp(_ context.Context, r *api.StartBgpRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.started != nil {
+		return fmt.Errorf("gobgp is already started")
+	}
+	f.started = r.Global
+	return nil
+}
+
+func (f *Fake) StopBgp(context.Context, *api.StopBgpRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started = nil
+	f.peers = map[string]*api.Peer{}
+	f.paths = map[string][]*api.Path{}
+	return nil
+}
+
+func (f *Fake) AddPeer(_ context.Context, r *api.AddPeerRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	address := r.Peer.GetConf().GetNeighborAddress()
+	if _, ok := f.peers[address]; ok {
+		return fmt.Errorf("can't overwrite the existing peer: %s", address)
+	}
+	f.peers[address] = &api.Peer{
+		Conf:     r.Peer.Conf,
+		AfiSafis: r.Peer.AfiSafis,
+		State: &api.PeerState{
+			NeighborAddress: address,
+			PeerAsn:         r.Peer.GetConf().GetPeerAsn(),
+			SessionState:    api.PeerState_ESTABLISHED,
+			AdminState:      api.PeerState_UP,
+		},
+	}
+	return nil
+}
+
+func (f *Fake) DeletePeer(_ context.Context, r *api.DeletePeerRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.peers[r.Address]; !ok {
+		return fmt.Errorf("neighbor that has %v doesn't exist", r.Address)
+	}
+	delete(f.peers, r.Address)
+	return nil
+}
+
+func (f *Fake) ShutdownPeer(_ context.Context, r *api.ShutdownPeerRequest) error {
+	return f.SetPeerState(r.Address, api.PeerState_DOWN, api.PeerState_IDLE)
+}
+
+func (f *Fake) EnablePeer(_ context.Context, r *api.EnablePeerRequest) error {
+	return f.SetPeerState(r.Address, api.PeerState_UP, api.PeerState_ESTABLISHED)
+}
+
+func (f *Fake) DisablePeer(_ context.Context, r *api.DisablePeerRequest) error {
+	return f.SetPeerState(r.Address, api.PeerState_DOWN, api.PeerState_IDLE)
+}
+
+// ResetPeer проверяет, что сосед существует: в Fake нет политик, которые нужно применять заново.
+func (f *Fake) ResetPeer(_ context.Context, r *api.ResetPeerRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.peers[r.Address]; !ok {
+		return fmt.Errorf("neighbor that has %v doesn't exist", r.Address)
+	}
+	return nil
+}
+
+// SetPeerState меняет состояние соседа, например, чтобы имитировать срабатывание prefix limit.
+func (f *Fake) SetPeerState(address string, admin api.PeerState_AdminState, session api.PeerState_SessionState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	peer, ok := f.peers[address]
+	if !ok {
+		return fmt.Errorf("neighbor that has %v doesn't exist", address)
+	}
+	peer.State.AdminState = admin
+	peer.State.SessionState = session
+	return nil
+}
+
+func (f *Fake) ListPeer(_ context.Context, r *api.ListPeerRequest, fn func(*api.Peer)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, address := range sortedKeys(f.peers) {
+		if r.Address != "" && r.Address != address {
+			continue
+		}
+		fn(f.peers[address])
+	}
+	return nil
+}
+
+func (f *Fake) AddPath(_ context.Context, r *api.AddPathRequest) (*api.AddPathResponse, error) {
+	prefix, err := pathPrefix(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	uuid := make([]byte, 16)
+	binary.BigEndian.PutUint64(uuid[8:], uint64(f.nextID))
+	path := &api.Path{
+		Nlri:   r.Path.Nlri,
+		Pattrs: r.Path.Pattrs,
+		Family: r.Path.Family,
+		Uuid:   uuid,
+		Best:   true,
+	}
+	// Как и gobgp, новый локальный путь для префикса заменяет предыдущий.
+	f.paths[prefix] = []*api.Path{path}
+	return &api.AddPathResponse{Uuid: uuid}, nil
+}
+
+func (f *Fake) DeletePath(_ context.Context, r *api.DeletePathRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Path == nil {
+		f.paths = map[string][]*api.Path{}
+		return nil
+	}
+	prefix, err := pathPrefix(r.Path)
+	if err != nil {
+		return err
+	}
+	delete(f.paths, prefix)
+	return nil
+}
+
+func (f *Fake) ListPath(_ context.Context, r *api.ListPathRequest, fn func(*api.Destination)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.TableType != api.TableType_GLOBAL {
+		return nil
+	}
+	for _, prefix := range sortedKeys(f.paths) {
+		if !matchPrefix(r.Prefixes, prefix) {
+			continue
+		}
+		fn(&api.Destination{Prefix: prefix, Paths: f.paths[prefix]})
+	}
+	return nil
+}
+
+// AddPaths добавляет в глобальную таблицу пути, полученные как будто от соседей.
+func (f *Fake) AddPaths(paths ...*api.Path) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, path := range paths {
+		prefix, err := pathPrefix(path)
+		if err != nil {
+			return err
+		}
+		f.paths[prefix] = append(f.paths[prefix], path)
+	}
+	return nil
+}
+
+func (f *Fake) AddDefinedSet(_ context.Context, r *api.AddDefinedSetRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	set, ok := f.definedSets[r.DefinedSet.Name]
+	if !ok {
+		f.definedSets[r.DefinedSet.Name] = r.DefinedSet
+		return nil
+	}
+	set.Prefixes = append(set.Prefixes, r.DefinedSet.Prefixes...)
+	return nil
+}
+
+func (f *Fake) DeleteDefinedSet(_ context.Context, r *api.DeleteDefinedSetRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	set, ok := f.definedSets[r.DefinedSet.Name]
+	if !ok {
+		return fmt.Errorf("not found defined-set: %s", r.DefinedSet.Name)
+	}
+	if r.All {
+		delete(f.definedSets, r.DefinedSet.Name)
+		return nil
+	}
+	prefixes := set.Prefixes[:0]
+	for _, p := range set.Prefixes {
+		if !containsPrefix(r.DefinedSet.Prefixes, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	set.Prefixes = prefixes
+	return nil
+}
+
+// ListDefinedSet, как и gobgp, отдает defined sets только заданного типа.
+func (f *Fake) ListDefinedSet(_ context.Context, r *api.ListDefinedSetRequest, fn func(*api.DefinedSet)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range sortedKeys(f.definedSets) {
+		set := f.definedSets[name]
+		if set.DefinedType != r.DefinedType || r.Name != "" && r.Name != name {
+			continue
+		}
+		fn(set)
+	}
+	return nil
+}
+
+func (f *Fake) AddPolicy(_ context.Context, r *api.AddPolicyRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Как и gobgp, statements политики с уже существующим именем добавляются к ней,
+	// а имена statements должны быть уникальны среди всех политик.
+	for _, st := range r.Policy.Statements {
+		for _, p := range f.policies {
+			for _, existing := range p.Statements {
+				if existing.Name == st.Name {
+					return fmt.Errorf("statement %s already defined", st.Name)
+				}
+			}
+		}
+	}
+	if p, ok := f.policies[r.Policy.Name]; ok {
+		p.Statements = append(p.Statements, r.Policy.Statements...)
+		return nil
+	}
+	f.policies[r.Policy.Name] = &api.Policy{
+		Name:       r.Policy.Name,
+		Statements: append([]*api.Statement{}, r.Policy.Statements...),
+	}
+	return nil
+}
+
+func (f *Fake) ListPolicy(_ context.Context, r *api.ListPolicyRequest, fn func(*api.Policy)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range sortedKeys(f.policies) {
+		if r.Name != "" && r.Name != name {
+			continue
+		}
+		fn(f.policies[name])
+	}
+	return nil
+}
+
+func (f *Fake) AddPolicyAssignment(_ context.Context, r *api.AddPolicyAssignmentRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.assignments = append(f.assignments, r.Assignment)
+	return nil
+}
+
+func (f *Fake) ListPolicyAssignment(_ context.Context, r *api.ListPolicyAssignmentRequest, fn func(*api.PolicyAssignment)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, a := range f.assignments {
+		if r.Name != "" && r.Name != a.Name || r.Direction != api.PolicyDirection_UNKNOWN && r.Direction != a.Direction {
+			continue
+		}
+		fn(a)
+	}
+	return nil
+}
+
+func (f *Fake) AddRpki(_ context.Context, r *api.AddRpkiRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rpki = append(f.rpki, r)
+	return nil
+}
+
+func (f *Fake) AddBmp(_ context.Context, r *api.AddBmpRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bmp = append(f.bmp, r)
+	return nil
+}
+
+func (f *Fake) DeleteBmp(_ context.Context, r *api.DeleteBmpRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.IndexFunc(f.bmp, func(b *api.AddBmpRequest) bool {
+		return b.Address == r.Address && b.Port == r.Port
+	})
+	if i < 0 {
+		return fmt.Errorf("bmp server not found: %s:%d", r.Address, r.Port)
+	}
+	f.bmp = slices.Delete(f.bmp, i, i+1)
+	return nil
+}
+
+func (f *Fake) EnableZebra(_ context.Context, r *api.EnableZebraRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.zebra != nil {
+		return fmt.Errorf("already connected to Zebra")
+	}
+	f.zebra = r
+	return nil
+}
+
+func (f *Fake) WatchEvent(ctx context.Context, _ *api.WatchEventRequest, fn func(*api.WatchEventResponse)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	id := f.nextID
+	f.watchers[id] = fn
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.watchers, id)
+	}()
+	return nil
+}
+
+// Emit отправляет событие всем подписчикам WatchEvent.
+func (f *Fake) Emit(event *api.WatchEventResponse) {
+	f.mu.Lock()
+	watchers := make([]func(*api.WatchEventResponse), 0, len(f.watchers))
+	for _, fn := range f.watchers {
+		watchers = append(watchers, fn)
+	}
+	f.mu.Unlock()
+	for _, fn := range watchers {
+		fn(event)
+	}
+}
+
+// DefinedSet возвращает defined set по имени или nil.
+func (f *Fake) DefinedSet(name string) *api.DefinedSet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.definedSets[name]
+}
+
+// Policy возвращает политику по имени или nil.
+func (f *Fake) Policy(name string) *api.Policy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.policies[name]
+}
+
+// PolicyAssignments возвращает все назначенные политики.
+func (f *Fake) PolicyAssignments() []*api.PolicyAssignment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*api.PolicyAssignment{}, f.assignments...)
+}
+
+// Zebra возвращает запрос подключения к zebra или nil.
+func (f *Fake) Zebra() *api.EnableZebraRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.zebra
+}
+
+// Rpki возвращает все добавленные RPKI кэши.
+func (f *Fake) Rpki() []*api.AddRpkiRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*api.AddRpkiRequest{}, f.rpki...)
+}
+
+// Bmp возвращает все добавленные BMP станции.
+func (f *Fake) Bmp() []*api.AddBmpRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*api.AddBmpRequest{}, f.bmp...)
+}
+
+func pathPrefix(path *api.Path) (string, error) {
+	if path == nil {
+		return "", fmt.Errorf("path is nil")
+	}
+	nlri, err := apiutil.GetNativeNlri(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode nlri: %w", err)
+	}
+	return nlri.String(), nil
+}
+
+func matchPrefix(lookup []*api.TableLookupPrefix, prefix string) bool {
+	if len(lookup) == 0 {
+		return true
+	}
+	for _, l := range lookup {
+		if l.Prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPrefix(prefixes []*api.Prefix, p *api.Prefix) bool {
+	for _, other := range prefixes {
+		if other.IpPrefix == p.IpPrefix && other.MaskLengthMin == p.MaskLengthMin && other.MaskLengthMax == p.MaskLengthMax {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
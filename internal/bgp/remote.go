@@ -0,0 +1,346 @@
+package bgp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Remote это реализация [Server] поверх gRPC API уже запущенного gobgpd:
+//   - StartBgp не перезапускает gobgpd, а только проверяет, что он запущен с тем же ASN
+//   - соседи, пути, defined sets, политики и их назначения, а также RPKI кэши и BMP станции,
+//     созданные через Remote, запоминаются, и StopBgp удаляет их вместо остановки gobgpd,
+//     чтобы speaker можно было перезапустить и не мешать другим пользователям gobgpd
+type Remote struct {
+	client api.GobgpApiClient
+	conn   *grpc.ClientConn
+
+	mu          sync.Mutex
+	peers       []string
+	paths       []*api.Path
+	definedSets []*api.DefinedSet
+	policies    []string
+	assignments []*api.PolicyAssignment
+	rpki        []*api.AddRpkiRequest
+	bmp         []*api.AddBmpRequest
+}
+
+func NewRemote(conn *grpc.ClientConn) *Remote {
+	return &Remote{client: api.NewGobgpApiClient(conn), conn: conn}
+}
+
+// Close закрывает gRPC соединение с gobgpd.
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+func (r *Remote) StartBgp(ctx context.Context, req *api.StartBgpRequest) error {
+	resp, err := r.client.GetBgp(ctx, &api.GetBgpRequest{})
+	if err != nil {
+		return err
+	}
+	if asn := resp.GetGlobal().GetAsn(); asn != 0 {
+		if asn != req.GetGlobal().GetAsn() {
+			return fmt.Errorf("remote gobgpd is running with asn %d instead of %d", asn, req.GetGlobal().GetAsn())
+		}
+		return nil
+	}
+	_, err = r.client.StartBgp(ctx, req)
+	return err
+}
+
+// StopBgp удаляет из gobgpd все, что было создано через Remote, в обратном порядке.
+func (r *Remote) StopBgp(ctx context.Context, _ *api.StopBgpRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := []error{}
+	for _, a := range r.assignments {
+		if _, err := r.client.DeletePolicyAssignment(ctx, &api.DeletePolicyAssignmentRequest{Assignment: a}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting policy assignment %q: %w", a.Name, err))
+		}
+	}
+	for _, name := range r.policies {
+		if _, err := r.client.DeletePolicy(ctx, &api.DeletePolicyRequest{Policy: &api.Policy{Name: name}, All: true}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting policy %q: %w", name, err))
+		}
+	}
+	for _, s := range r.definedSets {
+		if _, err := r.client.DeleteDefinedSet(ctx, &api.DeleteDefinedSetRequest{DefinedSet: s, All: true}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting defined-set %q: %w", s.Name, err))
+		}
+	}
+	for _, address := range r.peers {
+		if _, err := r.client.DeletePeer(ctx, &api.DeletePeerRequest{Address: address}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting peer %s: %w", address, err))
+		}
+	}
+	for _, path := range r.paths {
+		if _, err := r.client.DeletePath(ctx, &api.DeletePathRequest{Family: path.Family, Path: path}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting path: %w", err))
+		}
+	}
+	for _, req := range r.rpki {
+		if _, err := r.client.DeleteRpki(ctx, &api.DeleteRpkiRequest{Address: req.Address, Port: req.Port}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting rpki cache %s: %w", req.Address, err))
+		}
+	}
+	for _, req := range r.bmp {
+		if _, err := r.client.DeleteBmp(ctx, &api.DeleteBmpRequest{Address: req.Address, Port: req.Port}); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting bmp server %s: %w", req.Address, err))
+		}
+	}
+	r.assignments, r.policies, r.definedSets, r.peers, r.paths, r.rpki, r.bmp = nil, nil, nil, nil, nil, nil, nil
+	return errors.Join(errs...)
+}
+
+func (r *Remote) AddPeer(ctx context.Context, req *api.AddPeerRequest) error {
+	if _, err := r.client.AddPeer(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers = append(r.peers, req.GetPeer().GetConf().GetNeighborAddress())
+	return nil
+}
+
+func (r *Remote) DeletePeer(ctx context.Context, req *api.DeletePeerRequest) error {
+	if _, err := r.client.DeletePeer(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers = slices.DeleteFunc(r.peers, func(address string) bool { return address == req.Address })
+	return nil
+}
+
+func (r *Remote) ShutdownPeer(ctx context.Context, req *api.ShutdownPeerRequest) error {
+	_, err := r.client.ShutdownPeer(ctx, req)
+	return err
+}
+
+func (r *Remote) EnablePeer(ctx context.Context, req *api.EnablePeerRequest) error {
+	_, err := r.client.EnablePeer(ctx, req)
+	return err
+}
+
+func (r *Remote) DisablePeer(ctx context.Context, req *api.DisablePeerRequest) error {
+	_, err := r.client.DisablePeer(ctx, req)
+	return err
+}
+
+func (r *Remote) ResetPeer(ctx context.Context, req *api.ResetPeerRequest) error {
+	_, err := r.client.ResetPeer(ctx, req)
+	return err
+}
+
+func (r *Remote) ListPeer(ctx context.Context, req *api.ListPeerRequest, fn func(*api.Peer)) error {
+	stream, err := r.client.ListPeer(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(resp.Peer)
+	}
+}
+
+// AddPath запоминает путь, заменяя ранее добавленный путь с тем же NLRI.
+func (r *Remote) AddPath(ctx context.Context, req *api.AddPathRequest) (*api.AddPathResponse, error) {
+	resp, err := r.client.AddPath(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(slices.DeleteFunc(r.paths, func(p *api.Path) bool { return proto.Equal(p.Nlri, req.Path.Nlri) }), req.Path)
+	return resp, nil
+}
+
+func (r *Remote) DeletePath(ctx context.Context, req *api.DeletePathRequest) error {
+	if req.Family == nil && req.Path != nil {
+		req.Family = req.Path.Family
+	}
+	if _, err := r.client.DeletePath(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = slices.DeleteFunc(r.paths, func(p *api.Path) bool { return proto.Equal(p.Nlri, req.GetPath().GetNlri()) })
+	return nil
+}
+
+func (r *Remote) ListPath(ctx context.Context, req *api.ListPathRequest, fn func(*api.Destination)) error {
+	stream, err := r.client.ListPath(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(resp.Destination)
+	}
+}
+
+func (r *Remote) AddDefinedSet(ctx context.Context, req *api.AddDefinedSetRequest) error {
+	if _, err := r.client.AddDefinedSet(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.ContainsFunc(r.definedSets, func(s *api.DefinedSet) bool { return s.Name == req.DefinedSet.Name }) {
+		r.definedSets = append(r.definedSets, &api.DefinedSet{DefinedType: req.DefinedSet.DefinedType, Name: req.DefinedSet.Name})
+	}
+	return nil
+}
+
+func (r *Remote) DeleteDefinedSet(ctx context.Context, req *api.DeleteDefinedSetRequest) error {
+	_, err := r.client.DeleteDefinedSet(ctx, req)
+	return err
+}
+
+func (r *Remote) ListDefinedSet(ctx context.Context, req *api.ListDefinedSetRequest, fn func(*api.DefinedSet)) error {
+	stream, err := r.client.ListDefinedSet(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(resp.DefinedSet)
+	}
+}
+
+func (r *Remote) AddPolicy(ctx context.Context, req *api.AddPolicyRequest) error {
+	if _, err := r.client.AddPolicy(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.policies, req.Policy.Name) {
+		r.policies = append(r.policies, req.Policy.Name)
+	}
+	return nil
+}
+
+func (r *Remote) ListPolicy(ctx context.Context, req *api.ListPolicyRequest, fn func(*api.Policy)) error {
+	stream, err := r.client.ListPolicy(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(resp.Policy)
+	}
+}
+
+func (r *Remote) AddPolicyAssignment(ctx context.Context, req *api.AddPolicyAssignmentRequest) error {
+	if _, err := r.client.AddPolicyAssignment(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.assignments = append(r.assignments, req.Assignment)
+	return nil
+}
+
+func (r *Remote) ListPolicyAssignment(ctx context.Context, req *api.ListPolicyAssignmentRequest, fn func(*api.PolicyAssignment)) error {
+	stream, err := r.client.ListPolicyAssignment(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(resp.Assignment)
+	}
+}
+
+func (r *Remote) AddRpki(ctx context.Context, req *api.AddRpkiRequest) error {
+	if _, err := r.client.AddRpki(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rpki = append(r.rpki, req)
+	return nil
+}
+
+func (r *Remote) AddBmp(ctx context.Context, req *api.AddBmpRequest) error {
+	if _, err := r.client.AddBmp(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bmp = append(r.bmp, req)
+	return nil
+}
+
+func (r *Remote) DeleteBmp(ctx context.Context, req *api.DeleteBmpRequest) error {
+	if _, err := r.client.DeleteBmp(ctx, req); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bmp = slices.DeleteFunc(r.bmp, func(b *api.AddBmpRequest) bool {
+		return b.Address == req.Address && b.Port == req.Port
+	})
+	return nil
+}
+
+func (r *Remote) EnableZebra(ctx context.Context, req *api.EnableZebraRequest) error {
+	_, err := r.client.EnableZebra(ctx, req)
+	return err
+}
+
+// WatchEvent, как и [server.BgpServer.WatchEvent], возвращается сразу и вызывает fn
+// в отдельной горутине, пока не завершится ctx или поток событий.
+func (r *Remote) WatchEvent(ctx context.Context, req *api.WatchEventRequest, fn func(*api.WatchEventResponse)) error {
+	stream, err := r.client.WatchEvent(ctx, req)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			fn(resp)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,45 @@
+// Package bgp описывает подмножество API [server.BgpServer], которое использует speaker,
+// чтобы логику speaker можно было проверять без настоящего BGP сервера, см. [Fake].
+package bgp
+
+import (
+	"context"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/server"
+)
+
+// Server это методы [server.BgpServer], которые вызывает speaker.
+type Server interface {
+	StartBgp(ctx context.Context, r *api.StartBgpRequest) error
+	StopBgp(ctx context.Context, r *api.StopBgpRequest) error
+
+	AddPeer(ctx context.Context, r *api.AddPeerRequest) error
+	DeletePeer(ctx context.Context, r *api.DeletePeerRequest) error
+	ShutdownPeer(ctx context.Context, r *api.ShutdownPeerRequest) error
+	EnablePeer(ctx context.Context, r *api.EnablePeerRequest) error
+	DisablePeer(ctx context.Context, r *api.DisablePeerRequest) error
+	ResetPeer(ctx context.Context, r *api.ResetPeerRequest) error
+	ListPeer(ctx context.Context, r *api.ListPeerRequest, fn func(*api.Peer)) error
+
+	AddPath(ctx context.Context, r *api.AddPathRequest) (*api.AddPathResponse, error)
+	DeletePath(ctx context.Context, r *api.DeletePathRequest) error
+	ListPath(ctx context.Context, r *api.ListPathRequest, fn func(*api.Destination)) error
+
+	AddDefinedSet(ctx context.Context, r *api.AddDefinedSetRequest) error
+	DeleteDefinedSet(ctx context.Context, r *api.DeleteDefinedSetRequest) error
+	ListDefinedSet(ctx context.Context, r *api.ListDefinedSetRequest, fn func(*api.DefinedSet)) error
+	AddPolicy(ctx context.Context, r *api.AddPolicyRequest) error
+	ListPolicy(ctx context.Context, r *api.ListPolicyRequest, fn func(*api.Policy)) error
+	AddPolicyAssignment(ctx context.Context, r *api.AddPolicyAssignmentRequest) error
+	ListPolicyAssignment(ctx context.Context, r *api.ListPolicyAssignmentRequest, fn func(*api.PolicyAssignment)) error
+
+	AddRpki(ctx context.Context, r *api.AddRpkiRequest) error
+	AddBmp(ctx context.Context, r *api.AddBmpRequest) error
+	DeleteBmp(ctx context.Context, r *api.DeleteBmpRequest) error
+	EnableZebra(ctx context.Context, r *api.EnableZebraRequest) error
+
+	WatchEvent(ctx context.Context, r *api.WatchEventRequest, fn func(*api.WatchEventResponse)) error
+}
+
+var _ Server = (*server.BgpServer)(nil)
@@ -0,0 +1,51 @@
+// Package defaults содержит встроенные значения по-умолчанию, которые при сборке пакета
+// для конкретного окружения можно переопределить через -ldflags, например:
+//
+//	go build -ldflags "-X github.com/sir-sukhov/bgp-speaker/internal/defaults.ConfigPath=/etc/bgp-speaker/config.yaml"
+package defaults
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ConfigPath это путь к конфигурации команды gobgp.
+	ConfigPath = "config.yaml"
+	// GRPCAddress это адрес gRPC API gobgp.
+	GRPCAddress = "localhost:6061"
+	// AdminAddress это адрес admin API, если в конфигурации не задан admin_address.
+	AdminAddress = "localhost:6062"
+	// UpdateFIBMetric используется, если в конфигурации не задан ни update_fib_metric, ни fib_metrics.
+	// Пустая строка означает, что FIB по-умолчанию не обновляется.
+	UpdateFIBMetric = ""
+)
+
+// Default это одно встроенное значение для вывода командой defaults.
+type Default struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FIBMetric возвращает UpdateFIBMetric как число или nil, если он не задан.
+func FIBMetric() (*uint32, error) {
+	if UpdateFIBMetric == "" {
+		return nil, nil
+	}
+	metric, err := strconv.ParseUint(UpdateFIBMetric, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid built-in update_fib_metric %q: %w", UpdateFIBMetric, err)
+	}
+	m := uint32(metric)
+	return &m, nil
+}
+
+// List возвращает все встроенные значения в порядке объявления.
+func List() []Default {
+	return []Default{
+		{Name: "config_path", Value: ConfigPath},
+		{Name: "grpc_address", Value: GRPCAddress},
+		{Name: "admin_address", Value: AdminAddress},
+		{Name: "update_fib_metric", Value: UpdateFIBMetric},
+	}
+}
@@ -0,0 +1,175 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	clientTimeout     = 10 * time.Second
+)
+
+// Client это минимальный клиент Kubernetes API, который использует учетные данные
+// service account пода (in-cluster конфигурацию).
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in kubernetes: KUBERNETES_SERVICE_HOST or KUBERNETES_SERVICE_PORT is not set")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("failed to parse service account ca")
+	}
+	return &Client{
+		baseURL: "https://" + net.JoinHostPort(host, port),
+		token:   strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Timeout: clientTimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// StatusError возвращается, если Kubernetes API ответил кодом, отличным от 2xx.
+type StatusError struct {
+	Path    string
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes api %s: unexpected status code %d: %s", e.Path, e.Code, e.Message)
+}
+
+// IsStatus возвращает true, если err это [StatusError] с кодом code.
+func IsStatus(err error, code int) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == code
+}
+
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	return c.do(ctx, http.MethodGet, path, nil, v)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, reqBody, respBody any) error {
+	var body io.Reader
+	if reqBody != nil {
+		b, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kubernetes api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{Path: path, Code: resp.StatusCode, Message: string(msg)}
+	}
+	if respBody == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
+
+// Namespace возвращает namespace пода, в котором запущен speaker.
+func Namespace() (string, error) {
+	namespace, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account namespace: %w", err)
+	}
+	return strings.TrimSpace(string(namespace)), nil
+}
+
+// Service содержит только используемые поля объекта Service.
+type Service struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		Type              string   `json:"type"`
+		LoadBalancerClass *string  `json:"loadBalancerClass"`
+		ExternalIPs       []string `json:"externalIPs"`
+	} `json:"spec"`
+	Status struct {
+		LoadBalancer struct {
+			Ingress []struct {
+				IP string `json:"ip"`
+			} `json:"ingress"`
+		} `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// EndpointSlice содержит только используемые поля объекта EndpointSlice.
+type EndpointSlice struct {
+	Metadata struct {
+		Namespace string            `json:"namespace"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+}
+
+func (c *Client) ListServices(ctx context.Context) ([]Service, error) {
+	var list struct {
+		Items []Service `json:"items"`
+	}
+	if err := c.get(ctx, "/api/v1/services", &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (c *Client) ListEndpointSlices(ctx context.Context) ([]EndpointSlice, error) {
+	var list struct {
+		Items []EndpointSlice `json:"items"`
+	}
+	if err := c.get(ctx, "/apis/discovery.k8s.io/v1/endpointslices", &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,166 @@
+// Package kubernetes реализует контроллер, который анонсирует внешние адреса сервисов
+// типа LoadBalancer, по аналогии с MetalLB в режиме BGP.
+package kubernetes
+
+import (
+	"context"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	serviceTypeLoadBalancer = "LoadBalancer"
+	serviceNameLabel        = "kubernetes.io/service-name"
+	defaultIntervalSeconds  = 5
+	withdrawTimeout         = 5 * time.Second
+)
+
+// Announcer анонсирует и отзывает префиксы /32.
+type Announcer interface {
+	AdvertisePrefix(ctx context.Context, ip string) error
+	WithdrawPrefix(ctx context.Context, ip string) error
+}
+
+type Config struct {
+	// LoadBalancerClass это значение spec.loadBalancerClass сервисов, которые обслуживает контроллер.
+	// Если не задано, обслуживаются сервисы без loadBalancerClass.
+	LoadBalancerClass string `yaml:"load_balancer_class"`
+	// IntervalSeconds это период опроса API, по-умолчанию 5 секунд. Контроллер не использует watch,
+	// поэтому анонс или отзыв адреса после изменения сервиса или его endpoint происходит с задержкой
+	// до IntervalSeconds.
+	IntervalSeconds uint32 `yaml:"interval_seconds"`
+}
+
+// Controller раз в interval_seconds получает список сервисов и EndpointSlice и анонсирует
+// внешние адреса тех сервисов, у которых есть хотя бы один готовый endpoint.
+// При остановке контроллер отзывает все анонсированные им адреса.
+type Controller struct {
+	config    Config
+	client    *Client
+	announcer Announcer
+	logger    log.Logger
+	announced map[string]struct{}
+}
+
+func NewController(config Config, announcer Announcer, logger log.Logger) (*Controller, error) {
+	client, err := NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	if config.IntervalSeconds == 0 {
+		config.IntervalSeconds = defaultIntervalSeconds
+	}
+	return &Controller{
+		config:    config,
+		client:    client,
+		announcer: announcer,
+		logger:    logger,
+		announced: map[string]struct{}{},
+	}, nil
+}
+
+func (c *Controller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second * time.Duration(c.config.IntervalSeconds))
+	defer ticker.Stop()
+	for {
+		if err := c.sync(ctx); err != nil {
+			c.logger.Error("kubernetes sync failed", log.Fields{"error": err.Error()})
+		}
+		select {
+		case <-ctx.Done():
+			c.logger.Info("stop watching kubernetes services", nil)
+			c.withdrawAll(context.WithoutCancel(ctx))
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Controller) sync(ctx context.Context) error {
+	services, err := c.client.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+	slices, err := c.client.ListEndpointSlices(ctx)
+	if err != nil {
+		return err
+	}
+	ready := map[string]bool{}
+	for _, s := range slices {
+		key := s.Metadata.Namespace + "/" + s.Metadata.Labels[serviceNameLabel]
+		for _, e := range s.Endpoints {
+			// Согласно API, отсутствие ready означает, что endpoint готов.
+			if e.Conditions.Ready == nil || *e.Conditions.Ready {
+				ready[key] = true
+			}
+		}
+	}
+	desired := map[string]string{}
+	for _, s := range services {
+		if !c.matches(s) {
+			continue
+		}
+		key := s.Metadata.Namespace + "/" + s.Metadata.Name
+		if !ready[key] {
+			continue
+		}
+		for _, ip := range serviceIPs(s) {
+			desired[ip] = key
+		}
+	}
+	for ip := range c.announced {
+		if _, ok := desired[ip]; ok {
+			continue
+		}
+		if err := c.announcer.WithdrawPrefix(ctx, ip); err != nil {
+			c.logger.Error("failed to withdraw service ip", log.Fields{"ip": ip, "error": err.Error()})
+			continue
+		}
+		delete(c.announced, ip)
+	}
+	for ip, service := range desired {
+		if _, ok := c.announced[ip]; ok {
+			continue
+		}
+		if err := c.announcer.AdvertisePrefix(ctx, ip); err != nil {
+			c.logger.Error("failed to advertise service ip", log.Fields{"ip": ip, "service": service, "error": err.Error()})
+			continue
+		}
+		c.announced[ip] = struct{}{}
+	}
+	return nil
+}
+
+// Метод withdrawAll отзывает все адреса, анонсированные контроллером.
+func (c *Controller) withdrawAll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, withdrawTimeout)
+	defer cancel()
+	for ip := range c.announced {
+		if err := c.announcer.WithdrawPrefix(ctx, ip); err != nil {
+			c.logger.Error("failed to withdraw service ip", log.Fields{"ip": ip, "error": err.Error()})
+			continue
+		}
+		delete(c.announced, ip)
+	}
+}
+
+func (c *Controller) matches(s Service) bool {
+	if s.Spec.Type != serviceTypeLoadBalancer {
+		return false
+	}
+	if s.Spec.LoadBalancerClass == nil {
+		return c.config.LoadBalancerClass == ""
+	}
+	return *s.Spec.LoadBalancerClass == c.config.LoadBalancerClass
+}
+
+func serviceIPs(s Service) []string {
+	ips := append([]string{}, s.Spec.ExternalIPs...)
+	for _, ingress := range s.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			ips = append(ips, ingress.IP)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,249 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	microTimeLayout             = "2006-01-02T15:04:05.000000Z07:00"
+	defaultLeaseDurationSeconds = 15
+	defaultRenewIntervalSeconds = 5
+)
+
+// LeaderElection задает объект Lease, через который реплики speaker выбирают лидера.
+type LeaderElection struct {
+	// Namespace по-умолчанию это namespace пода.
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	// Identity по-умолчанию это hostname, то есть имя пода.
+	Identity             string `yaml:"identity"`
+	LeaseDurationSeconds uint32 `yaml:"lease_duration_seconds"`
+	RenewIntervalSeconds uint32 `yaml:"renew_interval_seconds"`
+}
+
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       *string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds *int32  `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          *string `json:"acquireTime,omitempty"`
+		RenewTime            *string `json:"renewTime,omitempty"`
+		LeaseTransitions     *int32  `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// LeaderElector захватывает и продлевает Lease и сообщает о смене лидерства через callback.
+//
+// Конкурентные обновления Lease разрешаются через resourceVersion: проигравшая реплика
+// получает 409 Conflict и остается standby.
+//
+// Как и в client-go, истечение чужого Lease считается по локальным часам от момента, когда реплика
+// увидела последнее изменение его resourceVersion, а не по renewTime, записанному другим узлом,
+// поэтому расхождение часов узлов не приводит к двум лидерам.
+type LeaderElector struct {
+	config   LeaderElection
+	client   *Client
+	logger   log.Logger
+	callback func(ctx context.Context, leader bool) error
+	leader   bool
+	renewed  time.Time
+	// observedVersion это resourceVersion Lease при последнем чтении, observedTime это локальное
+	// время, когда реплика увидела его изменение.
+	observedVersion string
+	observedTime    time.Time
+}
+
+func NewLeaderElector(config LeaderElection, callback func(ctx context.Context, leader bool) error, logger log.Logger) (*LeaderElector, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("leader election lease name is not set")
+	}
+	client, err := NewInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	if config.Namespace == "" {
+		if config.Namespace, err = Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Identity == "" {
+		if config.Identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to get hostname for leader election identity: %w", err)
+		}
+	}
+	if config.LeaseDurationSeconds == 0 {
+		config.LeaseDurationSeconds = defaultLeaseDurationSeconds
+	}
+	if config.RenewIntervalSeconds == 0 {
+		config.RenewIntervalSeconds = defaultRenewIntervalSeconds
+	}
+	if config.RenewIntervalSeconds >= config.LeaseDurationSeconds {
+		return nil, fmt.Errorf("renew_interval_seconds must be less than lease_duration_seconds")
+	}
+	return &LeaderElector{
+		config:   config,
+		client:   client,
+		logger:   logger,
+		callback: callback,
+	}, nil
+}
+
+func (le *LeaderElector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second * time.Duration(le.config.RenewIntervalSeconds))
+	defer ticker.Stop()
+	for {
+		leader, err := le.tryAcquireOrRenew(ctx)
+		if err != nil {
+			le.logger.Error("leader election failed", log.Fields{"lease": le.config.Name, "error": err.Error()})
+			// Без успешного продления лидер должен отказаться от анонса раньше,
+			// чем lease истечет и его захватит другая реплика.
+			leader = le.leader && time.Since(le.renewed) < le.leaseDuration()-le.renewInterval()
+		}
+		if leader != le.leader {
+			le.logger.Warn("leadership changed", log.Fields{"lease": le.config.Name, "identity": le.config.Identity, "leader": leader})
+			if err := le.callback(ctx, leader); err != nil {
+				le.logger.Error("leadership callback failed", log.Fields{"error": err.Error()})
+			} else {
+				le.leader = leader
+			}
+		}
+		select {
+		case <-ctx.Done():
+			le.release(context.WithoutCancel(ctx))
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Метод release при остановке отказывается от лидерства и освобождает Lease, очищая holderIdentity,
+// чтобы другая реплика захватила его сразу, не дожидаясь истечения lease_duration_seconds.
+func (le *LeaderElector) release(ctx context.Context) {
+	if !le.leader {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, le.renewInterval())
+	defer cancel()
+	if err := le.callback(ctx, false); err != nil {
+		le.logger.Error("leadership callback failed", log.Fields{"error": err.Error()})
+	}
+	le.leader = false
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases/%s", le.config.Namespace, le.config.Name)
+	var current lease
+	if err := le.client.get(ctx, path, &current); err != nil {
+		le.logger.Error("failed to release lease", log.Fields{"lease": le.config.Name, "error": err.Error()})
+		return
+	}
+	if current.Spec.HolderIdentity == nil || *current.Spec.HolderIdentity != le.config.Identity {
+		return
+	}
+	l := le.newLease(time.Now())
+	l.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+	holder := ""
+	duration := int32(1)
+	l.Spec.HolderIdentity = &holder
+	l.Spec.LeaseDurationSeconds = &duration
+	l.Spec.LeaseTransitions = current.Spec.LeaseTransitions
+	if err := le.client.do(ctx, http.MethodPut, path, l, nil); err != nil {
+		le.logger.Error("failed to release lease", log.Fields{"lease": le.config.Name, "error": err.Error()})
+		return
+	}
+	le.logger.Info("lease released", log.Fields{"lease": le.config.Name, "identity": le.config.Identity})
+}
+
+func (le *LeaderElector) tryAcquireOrRenew(ctx context.Context) (bool, error) {
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases/%s", le.config.Namespace, le.config.Name)
+	now := time.Now()
+	var current lease
+	err := le.client.get(ctx, path, &current)
+	if IsStatus(err, http.StatusNotFound) {
+		l := le.newLease(now)
+		createPath := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", le.config.Namespace)
+		if err := le.client.do(ctx, http.MethodPost, createPath, l, nil); err != nil {
+			if IsStatus(err, http.StatusConflict) {
+				return false, nil
+			}
+			return false, err
+		}
+		le.renewed = now
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if current.Metadata.ResourceVersion != le.observedVersion {
+		le.observedVersion = current.Metadata.ResourceVersion
+		le.observedTime = now
+	}
+	holder := ""
+	if current.Spec.HolderIdentity != nil {
+		holder = *current.Spec.HolderIdentity
+	}
+	if holder != le.config.Identity && holder != "" && !le.leaseExpired(current, now) {
+		return false, nil
+	}
+	l := le.newLease(now)
+	l.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+	transitions := int32(0)
+	if current.Spec.LeaseTransitions != nil {
+		transitions = *current.Spec.LeaseTransitions
+	}
+	if holder == le.config.Identity {
+		l.Spec.AcquireTime = current.Spec.AcquireTime
+	} else {
+		transitions++
+	}
+	l.Spec.LeaseTransitions = &transitions
+	if err := le.client.do(ctx, http.MethodPut, path, l, nil); err != nil {
+		if IsStatus(err, http.StatusConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+	le.renewed = now
+	return true, nil
+}
+
+func (le *LeaderElector) newLease(now time.Time) *lease {
+	l := &lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+	l.Metadata.Name = le.config.Name
+	l.Metadata.Namespace = le.config.Namespace
+	identity := le.config.Identity
+	duration := int32(le.config.LeaseDurationSeconds)
+	timestamp := now.UTC().Format(microTimeLayout)
+	l.Spec.HolderIdentity = &identity
+	l.Spec.LeaseDurationSeconds = &duration
+	l.Spec.AcquireTime = &timestamp
+	l.Spec.RenewTime = &timestamp
+	return l
+}
+
+func (le *LeaderElector) leaseDuration() time.Duration {
+	return time.Second * time.Duration(le.config.LeaseDurationSeconds)
+}
+
+func (le *LeaderElector) renewInterval() time.Duration {
+	return time.Second * time.Duration(le.config.RenewIntervalSeconds)
+}
+
+// Метод leaseExpired возвращает true, если Lease не менялся дольше своего leaseDurationSeconds
+// с момента, когда реплика увидела его последнее изменение.
+func (le *LeaderElector) leaseExpired(l lease, now time.Time) bool {
+	duration := le.leaseDuration()
+	if l.Spec.LeaseDurationSeconds != nil {
+		duration = time.Second * time.Duration(*l.Spec.LeaseDurationSeconds)
+	}
+	return now.After(le.observedTime.Add(duration))
+}
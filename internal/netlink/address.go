@@ -0,0 +1,100 @@
+package netlink
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/sys/unix"
+)
+
+// EnsureDummyLink возвращает index интерфейса name. Если интерфейса нет и create равен true,
+// создается dummy интерфейс (ip link add NAME type dummy); интерфейс переводится в состояние up.
+func EnsureDummyLink(name string, create bool) (uint32, error) {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	link, err := findLink(c, name)
+	if errors.Is(err, ErrLinkNotFound) && create {
+		if err := c.Link.New(&rtnetlink.LinkMessage{
+			Family: unix.AF_UNSPEC,
+			Attributes: &rtnetlink.LinkAttributes{
+				Name: name,
+				Info: &rtnetlink.LinkInfo{Kind: "dummy"},
+			},
+		}); err != nil {
+			return 0, fmt.Errorf("failed to create dummy link %s: %w", name, err)
+		}
+		link, err = findLink(c, name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if link.Flags&unix.IFF_UP == 0 {
+		if err := c.Link.Set(&rtnetlink.LinkMessage{
+			Family: link.Family,
+			Type:   link.Type,
+			Index:  link.Index,
+			Flags:  unix.IFF_UP,
+			Change: unix.IFF_UP,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to set link %s up: %w", name, err)
+		}
+	}
+	return link.Index, nil
+}
+
+func findLink(c *rtnetlink.Conn, name string) (rtnetlink.LinkMessage, error) {
+	links, err := c.Link.List()
+	if err != nil {
+		return rtnetlink.LinkMessage{}, fmt.Errorf("failed to list links: %w", err)
+	}
+	for _, link := range links {
+		if link.Attributes != nil && link.Attributes.Name == name {
+			return link, nil
+		}
+	}
+	return rtnetlink.LinkMessage{}, fmt.Errorf("link %s: %w", name, ErrLinkNotFound)
+}
+
+// AddHostAddress добавляет адрес ip/32 на интерфейс index, уже назначенный адрес не считается ошибкой.
+func AddHostAddress(index uint32, ip net.IP) error {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Address.New(hostAddress(index, ip)); err != nil && !errors.Is(err, unix.EEXIST) {
+		return fmt.Errorf("failed to add address %s: %w", ip, err)
+	}
+	return nil
+}
+
+// DeleteHostAddress удаляет адрес ip/32 с интерфейса index, отсутствующий адрес не считается ошибкой.
+func DeleteHostAddress(index uint32, ip net.IP) error {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Address.Delete(hostAddress(index, ip)); err != nil && !errors.Is(err, unix.EADDRNOTAVAIL) {
+		return fmt.Errorf("failed to delete address %s: %w", ip, err)
+	}
+	return nil
+}
+
+func hostAddress(index uint32, ip net.IP) *rtnetlink.AddressMessage {
+	return &rtnetlink.AddressMessage{
+		Family:       unix.AF_INET,
+		PrefixLength: 32,
+		Scope:        unix.RT_SCOPE_UNIVERSE,
+		Index:        index,
+		Attributes: &rtnetlink.AddressAttributes{
+			Address: ip.To4(),
+			Local:   ip.To4(),
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	etherTypeARP  = 0x0806
+	etherTypeIPv4 = 0x0800
+	arpRequest    = 1
+	arpHWEther    = 1
+)
+
+// SendGratuitousARP отправляет в интерфейс ifName широковещательный gratuitous ARP request
+// для ip (sender и target ip равны ip), чтобы соседи на L2 сразу обновили ARP кэш.
+func SendGratuitousARP(ifName string, ip net.IP) error {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return fmt.Errorf("gratuitous arp: %s is not ipv4", ip)
+	}
+	ifi, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return fmt.Errorf("gratuitous arp: %w", err)
+	}
+	if len(ifi.HardwareAddr) != 6 {
+		return fmt.Errorf("gratuitous arp: interface %s has no ethernet address", ifName)
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(etherTypeARP)))
+	if err != nil {
+		return fmt.Errorf("gratuitous arp: %w", err)
+	}
+	defer unix.Close(fd)
+	broadcast := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	frame := make([]byte, 0, 42)
+	frame = append(frame, broadcast...)
+	frame = append(frame, ifi.HardwareAddr...)
+	frame = binary.BigEndian.AppendUint16(frame, etherTypeARP)
+	frame = binary.BigEndian.AppendUint16(frame, arpHWEther)
+	frame = binary.BigEndian.AppendUint16(frame, etherTypeIPv4)
+	frame = append(frame, 6, 4)
+	frame = binary.BigEndian.AppendUint16(frame, arpRequest)
+	frame = append(frame, ifi.HardwareAddr...)
+	frame = append(frame, ip4...)
+	frame = append(frame, 0, 0, 0, 0, 0, 0)
+	frame = append(frame, ip4...)
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(etherTypeARP),
+		Ifindex:  ifi.Index,
+		Halen:    6,
+	}
+	copy(addr.Addr[:], broadcast)
+	if err := unix.Sendto(fd, frame, 0, addr); err != nil {
+		return fmt.Errorf("gratuitous arp: send to %s failed: %w", ifName, err)
+	}
+	return nil
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,18 @@
+package netlink
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// HasCapability проверяет, что capability, например, [unix.CAP_NET_ADMIN], есть
+// в effective наборе текущего процесса. У root без ограничений есть все capabilities.
+func HasCapability(capability int) (bool, error) {
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{}
+	if err := unix.Capget(&header, &data[0]); err != nil {
+		return false, fmt.Errorf("capget failed: %w", err)
+	}
+	return data[capability/32].Effective&(1<<(capability%32)) != 0, nil
+}
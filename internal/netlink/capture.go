@@ -0,0 +1,221 @@
+package netlink
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	pcapMagic   = 0xa1b2c3d4
+	pcapSnapLen = 65535
+	// pcapLinkTypeRaw это LINKTYPE_RAW: пакеты IPv4 и IPv6 без заголовка канального уровня.
+	pcapLinkTypeRaw    = 101
+	ipProtoTCP         = 6
+	capturePollTime    = time.Second
+	maxCaptureTCPPorts = 32
+)
+
+// TCPCapture записывает пакеты TCP сессий с заданными адресами и портами со всех интерфейсов.
+type TCPCapture struct {
+	fd       int
+	hosts    []netip.Addr
+	ports    []uint16
+	loopback map[int]bool
+}
+
+// NewTCPCapture открывает packet socket для пакетов TCP, у которых порт отправителя или
+// получателя входит в ports, а адрес отправителя или получателя входит в hosts. Если hosts
+// пуст, адреса не проверяются. Порты проверяет фильтр BPF в ядре, поэтому остальной трафик
+// не копируется в speaker. Требуется CAP_NET_RAW.
+func NewTCPCapture(hosts []netip.Addr, ports []uint16) (*TCPCapture, error) {
+	if len(ports) == 0 || len(ports) > maxCaptureTCPPorts {
+		return nil, fmt.Errorf("capture: expected from 1 to %d ports, got %d", maxCaptureTCPPorts, len(ports))
+	}
+	filter, err := bpf.Assemble(tcpPortFilter(ports))
+	if err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	sockFilter := make([]unix.SockFilter, 0, len(filter))
+	for _, ins := range filter {
+		sockFilter = append(sockFilter, unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K})
+	}
+	links, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	loopback := map[int]bool{}
+	for _, link := range links {
+		if link.Flags&net.FlagLoopback != 0 {
+			loopback[link.Index] = true
+		}
+	}
+	// SOCK_DGRAM отдает пакеты без заголовка канального уровня, как и нужно для LINKTYPE_RAW.
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	prog := &unix.SockFprog{Len: uint16(len(sockFilter)), Filter: &sockFilter[0]}
+	if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, prog); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("capture: attach filter failed: %w", err)
+	}
+	// Таймаут чтения нужен, чтобы периодически проверять ctx.
+	tv := unix.NsecToTimeval(capturePollTime.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+	return &TCPCapture{fd: fd, hosts: hosts, ports: ports, loopback: loopback}, nil
+}
+
+// WritePcap пишет пакеты в w в формате pcap, пока не завершится ctx, и возвращает число
+// записанных пакетов.
+func (c *TCPCapture) WritePcap(ctx context.Context, w io.Writer) (int, error) {
+	header := make([]byte, 0, 24)
+	header = binary.LittleEndian.AppendUint32(header, pcapMagic)
+	header = binary.LittleEndian.AppendUint16(header, 2)
+	header = binary.LittleEndian.AppendUint16(header, 4)
+	header = binary.LittleEndian.AppendUint32(header, 0)
+	header = binary.LittleEndian.AppendUint32(header, 0)
+	header = binary.LittleEndian.AppendUint32(header, pcapSnapLen)
+	header = binary.LittleEndian.AppendUint32(header, pcapLinkTypeRaw)
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	packets := 0
+	buf := make([]byte, pcapSnapLen)
+	record := make([]byte, 0, 16)
+	for {
+		if ctx.Err() != nil {
+			return packets, nil
+		}
+		// С MSG_TRUNC возвращается полная длина пакета, даже если он не поместился в buf.
+		n, from, err := unix.Recvfrom(c.fd, buf, unix.MSG_TRUNC)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return packets, fmt.Errorf("capture: receive failed: %w", err)
+		}
+		// На loopback пакет виден дважды: при отправке и при приеме.
+		if ll, ok := from.(*unix.SockaddrLinklayer); ok && ll.Pkttype == unix.PACKET_OUTGOING && c.loopback[ll.Ifindex] {
+			continue
+		}
+		packet := buf[:min(n, len(buf))]
+		if !c.match(packet) {
+			continue
+		}
+		now := time.Now()
+		record = record[:0]
+		record = binary.LittleEndian.AppendUint32(record, uint32(now.Unix()))
+		record = binary.LittleEndian.AppendUint32(record, uint32(now.Nanosecond()/1000))
+		record = binary.LittleEndian.AppendUint32(record, uint32(len(packet)))
+		record = binary.LittleEndian.AppendUint32(record, uint32(n))
+		if _, err := w.Write(record); err != nil {
+			return packets, err
+		}
+		if _, err := w.Write(packet); err != nil {
+			return packets, err
+		}
+		packets++
+	}
+}
+
+// Close закрывает packet socket.
+func (c *TCPCapture) Close() error {
+	return unix.Close(c.fd)
+}
+
+// Метод match проверяет адреса и порты пакета: до установки фильтра в socket могли попасть
+// любые пакеты, а адреса фильтр не проверяет.
+func (c *TCPCapture) match(packet []byte) bool {
+	var src, dst netip.Addr
+	var tcp []byte
+	if len(packet) == 0 {
+		return false
+	}
+	switch packet[0] >> 4 {
+	case 4:
+		headerLen := int(packet[0]&0x0f) * 4
+		if len(packet) < 20 || len(packet) < headerLen+4 || packet[9] != ipProtoTCP {
+			return false
+		}
+		src, dst = netip.AddrFrom4([4]byte(packet[12:16])), netip.AddrFrom4([4]byte(packet[16:20]))
+		tcp = packet[headerLen:]
+	case 6:
+		if len(packet) < 44 || packet[6] != ipProtoTCP {
+			return false
+		}
+		src, dst = netip.AddrFrom16([16]byte(packet[8:24])), netip.AddrFrom16([16]byte(packet[24:40]))
+		tcp = packet[40:]
+	default:
+		return false
+	}
+	srcPort, dstPort := binary.BigEndian.Uint16(tcp[0:2]), binary.BigEndian.Uint16(tcp[2:4])
+	portMatched := false
+	for _, port := range c.ports {
+		if srcPort == port || dstPort == port {
+			portMatched = true
+			break
+		}
+	}
+	if !portMatched {
+		return false
+	}
+	if len(c.hosts) == 0 {
+		return true
+	}
+	for _, host := range c.hosts {
+		host = host.WithZone("")
+		if host == src || host == dst {
+			return true
+		}
+	}
+	return false
+}
+
+// Функция tcpPortFilter собирает фильтр BPF для пакетов без заголовка канального уровня:
+// сначала в X загружается смещение заголовка TCP в IPv4 (без фрагментов) или IPv6
+// (без extension headers), затем порты отправителя и получателя сравниваются с ports.
+func tcpPortFilter(ports []uint16) []bpf.Instruction {
+	const portsStart = 13
+	drop := portsStart + 2 + 2*len(ports)
+	accept := drop + 1
+	skip := func(from, to int) uint8 {
+		return uint8(to - from - 1)
+	}
+	prog := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: 0, Size: 1},
+		bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xf0},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x60, SkipTrue: skip(2, 10)},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x40, SkipFalse: skip(3, drop)},
+		bpf.LoadAbsolute{Off: 9, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: ipProtoTCP, SkipFalse: skip(5, drop)},
+		bpf.LoadAbsolute{Off: 6, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x1fff, SkipTrue: skip(7, drop)},
+		bpf.LoadMemShift{Off: 0},
+		bpf.Jump{Skip: uint32(skip(9, portsStart))},
+		bpf.LoadAbsolute{Off: 6, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: ipProtoTCP, SkipFalse: skip(11, drop)},
+		bpf.LoadConstant{Dst: bpf.RegX, Val: 40},
+	}
+	for _, off := range []uint32{0, 2} {
+		prog = append(prog, bpf.LoadIndirect{Off: off, Size: 2})
+		for _, port := range ports {
+			prog = append(prog, bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(port), SkipTrue: skip(len(prog), accept)})
+		}
+	}
+	return append(prog,
+		bpf.RetConstant{Val: 0},
+		bpf.RetConstant{Val: pcapSnapLen},
+	)
+}
@@ -1,8 +1,10 @@
 package netlink
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 
 	"github.com/jsimonetti/rtnetlink"
@@ -15,116 +17,120 @@ const (
 	rtTableMain   = 254
 	protoBgp      = 186
 	typeUnicast   = 1
-	routePriority = 50
 	newRoute      = 0x18
 	deleteRoute   = 0x19
+	defaultPrefix = "0.0.0.0/0"
 )
 
-// PrintRoutes печатает все маршруты, полученные с помощью [rtnl].
+// RoutePriority это priority маршрутов, которые ставит команда fib, если metric не задан.
+const RoutePriority = 50
+
+// PrintRoutes печатает маршруты, полученные с помощью [rtnl] и отобранные filter,
+// в формате, похожем на "ip route", или в JSON, если asJSON равен true.
 //
 // [rtnl]: https://pkg.go.dev/github.com/jsimonetti/rtnetlink/rtnl
-func PrintRoutes() error {
-	c, err := rtnl.Dial(nil)
-	if err != nil {
-		return err
-	}
-	defer c.Close()
-	links, err := c.Conn.Link.List()
+func PrintRoutes(filter RouteFilter, asJSON bool) error {
+	routes, err := ListRoutes(filter)
 	if err != nil {
 		return err
 	}
-	linksMap := make(map[int]string)
-	for _, link := range links {
-		linksMap[int(link.Index)] = link.Attributes.Name
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(routes)
 	}
-	messages, err := c.Conn.Route.List()
-	if err != nil {
-		return err
-	}
-	for i, rt := range messages {
-		ifindex := int(rt.Attributes.OutIface)
-		ifName, ok := linksMap[ifindex]
-		if !ok {
-			tryPrintMultipathRoute(i, linksMap, rt)
+	for i, rt := range routes {
+		if len(rt.Multipath) == 0 {
+			var gateway string
+			if rt.Gateway != "" {
+				gateway = fmt.Sprintf("via %s ", rt.Gateway)
+			}
+			fmt.Printf("%02d. %s %sdev %s table id %d\n", i, rt.Dst, gateway, rt.Dev, rt.Table)
 			continue
 		}
-		var dst string
-		if rt.Attributes.Dst == nil {
-			dst = "default"
-		} else {
-			dst = fmt.Sprintf("%s/%d", rt.Attributes.Dst.String(), rt.DstLength)
+		sb := strings.Builder{}
+		sb.WriteString(fmt.Sprintf("%02d. %s proto id %d table id %d priority %d\n", i, rt.Dst, rt.Protocol, rt.Table, rt.Priority))
+		for j, path := range rt.Multipath {
+			sb.WriteString(fmt.Sprintf("\tpath %d: via %s dev %s\n", j, path.Gateway, path.Dev))
 		}
-		var gateway string
-		if rt.Attributes.Gateway == nil {
-			gateway = ""
-		} else {
-			gateway = fmt.Sprintf("via %s ", rt.Attributes.Gateway.String())
-		}
-		fmt.Printf("%02d. %s %sdev %s table id %d\n", i, dst, gateway, ifName, rt.Table)
+		fmt.Print(sb.String())
 	}
 	return nil
 }
 
-func tryPrintMultipathRoute(i int, linksMap map[int]string, rt rtnetlink.RouteMessage) {
-	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("%02d. ", i))
-	if rt.Attributes.Dst == nil {
-		sb.WriteString("default ")
-	} else {
-		sb.WriteString(fmt.Sprintf("%s/%d ", rt.Attributes.Dst.String(), rt.DstLength))
+// SetDefaultRoute добавляет или заменяет маршрут по-умолчанию с priority metric.
+func SetDefaultRoute(gateway string, metric uint32) error {
+	return SetRoute(defaultPrefix, gateway, metric)
+}
+
+// SetRoute добавляет или заменяет маршрут до prefix с priority metric через gateway,
+// несколько адресов gateway через запятую задают multipath маршрут.
+func SetRoute(prefix, gateway string, metric uint32) error {
+	dst, err := parsePrefix(prefix)
+	if err != nil {
+		return err
 	}
-	sb.WriteString(fmt.Sprintf("proto id %d table id %d priority %d\n", rt.Protocol, rt.Table, rt.Attributes.Priority))
-	for i, path := range rt.Attributes.Multipath {
-		nextHop := path.Gateway.String()
-		ifName, ok := linksMap[int(path.Hop.IfIndex)]
-		if !ok {
-			sb.WriteString(fmt.Sprintf("\tERROR: failed to determine ifName for nextHop %s\n", nextHop))
-			fmt.Print(sb.String())
-			return
+	gwIps := []net.IP{}
+	for _, gwString := range strings.Split(gateway, ",") {
+		gwIp := net.ParseIP(gwString)
+		if gwIp.To4() == nil {
+			return fmt.Errorf("gateway %q is not a valid ipv4 address", gwString)
 		}
-		sb.WriteString(fmt.Sprintf("\tpath %d: via %s dev %s\n", i, nextHop, ifName))
+		gwIps = append(gwIps, gwIp)
 	}
-	fmt.Print(sb.String())
+	if len(gwIps) > 1 {
+		return setMultipathRoute(dst, gwIps, metric)
+	}
+	return setSinglepathRoute(dst, gwIps[0], metric)
 }
 
-// SetDefaultRoute добавляет или заменяет маршрут по-умолчанию.
-func SetDefaultRoute(gateway string) error {
-	if strings.Contains(gateway, ",") {
-		gwIps := []net.IP{}
-		for _, gwString := range strings.Split(gateway, ",") {
-			gwIps = append(gwIps, net.ParseIP(gwString))
-		}
-		return setMultipathDefaultRoute(gwIps)
-	} else {
-		gwIp := net.ParseIP(gateway)
-		return setSinglepathDefaultRoute(gwIp)
+// Функция parsePrefix разбирает IPv4 префикс маршрута.
+func parsePrefix(prefix string) (*net.IPNet, error) {
+	_, dst, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if dst.IP.To4() == nil {
+		return nil, fmt.Errorf("prefix %q is not ipv4", prefix)
+	}
+	return dst, nil
+}
+
+// Функция routeMessage возвращает маршрут bgp до dst в таблице main.
+func routeMessage(dst *net.IPNet, metric uint32) *rtnetlink.RouteMessage {
+	ones, _ := dst.Mask.Size()
+	routeMessage := &rtnetlink.RouteMessage{
+		Family:    familyAfInet,
+		DstLength: uint8(ones),
+		Table:     rtTableMain,
+		Protocol:  protoBgp,
+		Type:      typeUnicast,
+		Attributes: rtnetlink.RouteAttributes{
+			Priority: metric,
+		},
 	}
+	if ones > 0 {
+		routeMessage.Attributes.Dst = dst.IP
+	}
+	return routeMessage
 }
 
-// Функция setSinglepathDefaultRoute добавляет default route.
-func setSinglepathDefaultRoute(gateway net.IP) error {
+// Функция setSinglepathRoute добавляет маршрут через один gateway.
+func setSinglepathRoute(dst *net.IPNet, gateway net.IP, metric uint32) error {
 	c, err := rtnl.Dial(nil)
 	if err != nil {
 		return err
 	}
 	defer c.Close()
-	routeMessage := &rtnetlink.RouteMessage{
-		Family:   familyAfInet,
-		Table:    rtTableMain,
-		Protocol: protoBgp,
-		Type:     typeUnicast,
-		Attributes: rtnetlink.RouteAttributes{
-			Gateway:  gateway,
-			Priority: routePriority,
-		},
-	}
+	routeMessage := routeMessage(dst, metric)
+	routeMessage.Attributes.Gateway = gateway
 	return c.Conn.Route.Replace(routeMessage)
 }
 
-// Функция setMultipathDefaultRoute добавляет т.н. [multipath route].
+// Функция setMultipathRoute добавляет т.н. [multipath route].
 //
 // [multipath route]: https://codecave.cc/multipath-routing-in-linux-part-1.html
-func setMultipathDefaultRoute(gateways []net.IP) error {
+func setMultipathRoute(dst *net.IPNet, gateways []net.IP, metric uint32) error {
 	c, err := rtnetlink.Dial(nil)
 	if err != nil {
 		return err
@@ -136,38 +142,30 @@ func setMultipathDefaultRoute(gateways []net.IP) error {
 			Gateway: gw,
 		})
 	}
-	routeMessage := &rtnetlink.RouteMessage{
-		Family:   familyAfInet,
-		Table:    rtTableMain,
-		Protocol: protoBgp,
-		Type:     typeUnicast,
-		Attributes: rtnetlink.RouteAttributes{
-			Priority:  routePriority,
-			Multipath: nextHops,
-		},
-	}
+	routeMessage := routeMessage(dst, metric)
+	routeMessage.Attributes.Multipath = nextHops
 	flags := netlink.Request | netlink.Create | netlink.Replace | netlink.Acknowledge
 	_, err = c.Execute(routeMessage, newRoute, flags)
 	return err
 }
 
-// DeleteDefaultRoute удаляет маршрут по-умолчанию.
-func DeleteDefaultRoute() error {
+// DeleteDefaultRoute удаляет маршрут по-умолчанию с priority metric.
+func DeleteDefaultRoute(metric uint32) error {
+	return DeleteRoute(defaultPrefix, metric)
+}
+
+// DeleteRoute удаляет маршрут bgp до prefix с priority metric.
+func DeleteRoute(prefix string, metric uint32) error {
+	dst, err := parsePrefix(prefix)
+	if err != nil {
+		return err
+	}
 	c, err := rtnetlink.Dial(nil)
 	if err != nil {
 		return err
 	}
 	defer c.Close()
-	routeMessage := &rtnetlink.RouteMessage{
-		Family:   familyAfInet,
-		Table:    rtTableMain,
-		Protocol: protoBgp,
-		Type:     typeUnicast,
-		Attributes: rtnetlink.RouteAttributes{
-			Priority: routePriority,
-		},
-	}
 	flags := netlink.Request | netlink.Acknowledge
-	_, err = c.Execute(routeMessage, deleteRoute, flags)
+	_, err = c.Execute(routeMessage(dst, metric), deleteRoute, flags)
 	return err
 }
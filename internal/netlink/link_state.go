@@ -0,0 +1,84 @@
+package netlink
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// LinkUp возвращает true, если интерфейс поднят и у него есть carrier (IFF_UP и IFF_RUNNING).
+func LinkUp(link rtnetlink.LinkMessage) bool {
+	return link.Flags&unix.IFF_UP != 0 && link.Flags&unix.IFF_RUNNING != 0
+}
+
+// WatchLinkState следит за состоянием интерфейсов names по уведомлениям RTM_NEWLINK и RTM_DELLINK
+// и вызывает fn при каждом изменении состояния, в том числе при старте для текущего состояния.
+// Удаленный или отсутствующий интерфейс считается выключенным. Метод работает, пока не завершится ctx.
+func WatchLinkState(ctx context.Context, names []string, fn func(name string, up bool)) error {
+	events, err := rtnetlink.Dial(&netlink.Config{Groups: unix.RTMGRP_LINK})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to link notifications: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		events.Close()
+	}()
+	state := map[string]bool{}
+	set := func(name string, up bool) {
+		if old, ok := state[name]; ok && old == up {
+			return
+		}
+		state[name] = up
+		fn(name, up)
+	}
+	// Состояние загружается после подписки, чтобы не потерять изменения между ними.
+	load := func() error {
+		links, err := events.Link.List()
+		if err != nil {
+			return fmt.Errorf("failed to list links: %w", err)
+		}
+		current := map[string]bool{}
+		for _, link := range links {
+			if link.Attributes != nil {
+				current[link.Attributes.Name] = LinkUp(link)
+			}
+		}
+		for _, name := range names {
+			set(name, current[name])
+		}
+		return nil
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	tracked := map[string]bool{}
+	for _, name := range names {
+		tracked[name] = true
+	}
+	for {
+		_, msgs, err := events.Receive()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			// Часть уведомлений могла потеряться, поэтому состояние загружается заново.
+			if err := load(); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.RTM_NEWLINK && m.Header.Type != unix.RTM_DELLINK {
+				continue
+			}
+			var link rtnetlink.LinkMessage
+			if err := link.UnmarshalBinary(m.Data); err != nil || link.Attributes == nil || !tracked[link.Attributes.Name] {
+				continue
+			}
+			set(link.Attributes.Name, m.Header.Type == unix.RTM_NEWLINK && LinkUp(link))
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package netlink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// LinkCache хранит таблицу интерфейсов (index <-> имя и атрибуты) и поддерживает ее
+// в актуальном состоянии по уведомлениям RTNLGRP_LINK, чтобы не запрашивать список
+// интерфейсов у ядра при каждом обращении.
+type LinkCache struct {
+	mu      sync.RWMutex
+	byIndex map[uint32]rtnetlink.LinkMessage
+	byName  map[string]uint32
+	err     error
+}
+
+// StartLinkCache подписывается на уведомления об изменении интерфейсов, загружает
+// текущую таблицу интерфейсов и обновляет ее в фоне, пока не завершится ctx.
+func StartLinkCache(ctx context.Context) (*LinkCache, error) {
+	events, err := rtnetlink.Dial(&netlink.Config{Groups: unix.RTMGRP_LINK})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to link notifications: %w", err)
+	}
+	lc := &LinkCache{}
+	// Таблица загружается после подписки, чтобы не потерять изменения между ними.
+	if err := lc.reload(); err != nil {
+		events.Close()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		events.Close()
+	}()
+	go lc.receive(ctx, events)
+	return lc, nil
+}
+
+const (
+	linkReceiveBackoff    = 100 * time.Millisecond
+	linkReceiveMaxBackoff = 10 * time.Second
+)
+
+// Метод receive применяет уведомления об интерфейсах к таблице. При ENOBUFS таблица загружается
+// заново, при других ошибках чтения receive ждет с экспоненциальным backoff до linkReceiveMaxBackoff,
+// чтобы постоянная ошибка не превращалась в цикл перезагрузок, а после закрытия сокета завершается.
+func (lc *LinkCache) receive(ctx context.Context, events *rtnetlink.Conn) {
+	backoff := linkReceiveBackoff
+	for {
+		_, msgs, err := events.Receive()
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, unix.ENOBUFS) {
+			// Переполнился буфер сокета: часть уведомлений потеряна,
+			// поэтому таблица загружается заново.
+			lc.setErr(lc.reload())
+			continue
+		}
+		if errors.Is(err, unix.EBADF) || errors.Is(err, net.ErrClosed) || errors.Is(err, os.ErrClosed) {
+			lc.setErr(fmt.Errorf("link notifications stopped: %w", err))
+			return
+		}
+		if err != nil {
+			lc.setErr(fmt.Errorf("failed to receive link notifications: %w", err))
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			backoff = min(backoff*2, linkReceiveMaxBackoff)
+			// Уведомления, пришедшие до ошибки, могли потеряться.
+			if err := lc.reload(); err != nil {
+				lc.setErr(err)
+			}
+			continue
+		}
+		backoff = linkReceiveBackoff
+		for _, m := range msgs {
+			lc.apply(m)
+		}
+	}
+}
+
+func (lc *LinkCache) apply(m netlink.Message) {
+	if m.Header.Type != unix.RTM_NEWLINK && m.Header.Type != unix.RTM_DELLINK {
+		return
+	}
+	var link rtnetlink.LinkMessage
+	if err := link.UnmarshalBinary(m.Data); err != nil {
+		lc.setErr(fmt.Errorf("failed to decode link notification: %w", err))
+		return
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if old, ok := lc.byIndex[link.Index]; ok && old.Attributes != nil {
+		delete(lc.byName, old.Attributes.Name)
+	}
+	if m.Header.Type == unix.RTM_DELLINK {
+		delete(lc.byIndex, link.Index)
+		return
+	}
+	lc.byIndex[link.Index] = link
+	if link.Attributes != nil {
+		lc.byName[link.Attributes.Name] = link.Index
+	}
+}
+
+func (lc *LinkCache) reload() error {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	links, err := c.Link.List()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %w", err)
+	}
+	byIndex := make(map[uint32]rtnetlink.LinkMessage, len(links))
+	byName := make(map[string]uint32, len(links))
+	for _, link := range links {
+		byIndex[link.Index] = link
+		if link.Attributes != nil {
+			byName[link.Attributes.Name] = link.Index
+		}
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.byIndex = byIndex
+	lc.byName = byName
+	lc.err = nil
+	return nil
+}
+
+func (lc *LinkCache) setErr(err error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.err = err
+}
+
+// Err возвращает последнюю ошибку обновления таблицы, если она не была исправлена перезагрузкой.
+func (lc *LinkCache) Err() error {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	return lc.err
+}
+
+// Name возвращает имя интерфейса по его index.
+func (lc *LinkCache) Name(index uint32) (string, bool) {
+	link, ok := lc.Link(index)
+	if !ok {
+		return "", false
+	}
+	return link.Attributes.Name, true
+}
+
+// Index возвращает index интерфейса по его имени.
+func (lc *LinkCache) Index(name string) (uint32, bool) {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	index, ok := lc.byName[name]
+	return index, ok
+}
+
+// Link возвращает последнее известное состояние интерфейса.
+func (lc *LinkCache) Link(index uint32) (rtnetlink.LinkMessage, bool) {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+	link, ok := lc.byIndex[index]
+	if !ok || link.Attributes == nil {
+		return rtnetlink.LinkMessage{}, false
+	}
+	return link, true
+}
+
+// ErrLinkNotFound возвращается, если интерфейса нет в таблице.
+var ErrLinkNotFound = errors.New("link not found")
+
+// MTU возвращает MTU интерфейса по его index.
+func (lc *LinkCache) MTU(index uint32) (uint32, error) {
+	link, ok := lc.Link(index)
+	if !ok {
+		return 0, fmt.Errorf("link %d: %w", index, ErrLinkNotFound)
+	}
+	return link.Attributes.MTU, nil
+}
@@ -0,0 +1,131 @@
+package netlink
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	etherTypeLLDP       = 0x88cc
+	lldpTLVEnd          = 0
+	lldpTLVChassisID    = 1
+	lldpTLVPortID       = 2
+	lldpTLVSystemName   = 5
+	lldpTLVMgmtAddress  = 8
+	lldpChassisIDMAC    = 4
+	lldpAddrFamilyIPv4  = 1
+	lldpAddrFamilyIPv6  = 2
+	lldpReceivePollTime = time.Second
+)
+
+// lldpNearestBridge это multicast адрес, на который коммутаторы отправляют LLDP.
+var lldpNearestBridge = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+// LLDPNeighbor это сведения о соседе из LLDPDU.
+type LLDPNeighbor struct {
+	ChassisID  string
+	PortID     string
+	SystemName string
+	// ManagementAddresses это адреса из TLV Management Address в порядке их следования.
+	ManagementAddresses []net.IP
+}
+
+// ReceiveLLDP ждет на интерфейсе ifName первый LLDPDU с management адресом и возвращает
+// сведения о соседе. Ожидание прерывается завершением ctx. Требуется CAP_NET_RAW.
+func ReceiveLLDP(ctx context.Context, ifName string) (LLDPNeighbor, error) {
+	ifi, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return LLDPNeighbor{}, fmt.Errorf("lldp: %w", err)
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(htons(etherTypeLLDP)))
+	if err != nil {
+		return LLDPNeighbor{}, fmt.Errorf("lldp: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(etherTypeLLDP), Ifindex: ifi.Index}); err != nil {
+		return LLDPNeighbor{}, fmt.Errorf("lldp: bind to %s failed: %w", ifName, err)
+	}
+	mreq := &unix.PacketMreq{Ifindex: int32(ifi.Index), Type: unix.PACKET_MR_MULTICAST, Alen: 6}
+	copy(mreq.Address[:], lldpNearestBridge)
+	if err := unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq); err != nil {
+		return LLDPNeighbor{}, fmt.Errorf("lldp: join multicast group on %s failed: %w", ifName, err)
+	}
+	// Таймаут чтения нужен, чтобы периодически проверять ctx.
+	tv := unix.NsecToTimeval(lldpReceivePollTime.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return LLDPNeighbor{}, fmt.Errorf("lldp: %w", err)
+	}
+	buf := make([]byte, 9000)
+	for {
+		if err := ctx.Err(); err != nil {
+			return LLDPNeighbor{}, fmt.Errorf("lldp: no lldpdu with management address received on %s: %w", ifName, err)
+		}
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return LLDPNeighbor{}, fmt.Errorf("lldp: receive on %s failed: %w", ifName, err)
+		}
+		// Заголовок ethernet: dst, src, ethertype.
+		if n < 14 || binary.BigEndian.Uint16(buf[12:14]) != etherTypeLLDP {
+			continue
+		}
+		neighbor, err := parseLLDPDU(buf[14:n])
+		if err != nil || len(neighbor.ManagementAddresses) == 0 {
+			continue
+		}
+		return neighbor, nil
+	}
+}
+
+// Функция parseLLDPDU разбирает TLV из LLDPDU: 7 бит типа и 9 бит длины, затем значение.
+func parseLLDPDU(data []byte) (LLDPNeighbor, error) {
+	neighbor := LLDPNeighbor{}
+	for len(data) >= 2 {
+		header := binary.BigEndian.Uint16(data[0:2])
+		typ, length := header>>9, int(header&0x1ff)
+		if len(data) < 2+length {
+			return neighbor, errors.New("lldp: truncated tlv")
+		}
+		value := data[2 : 2+length]
+		data = data[2+length:]
+		switch typ {
+		case lldpTLVEnd:
+			return neighbor, nil
+		case lldpTLVChassisID:
+			if len(value) > 1 {
+				if value[0] == lldpChassisIDMAC && len(value) == 7 {
+					neighbor.ChassisID = net.HardwareAddr(value[1:]).String()
+				} else {
+					neighbor.ChassisID = string(value[1:])
+				}
+			}
+		case lldpTLVPortID:
+			if len(value) > 1 {
+				neighbor.PortID = string(value[1:])
+			}
+		case lldpTLVSystemName:
+			neighbor.SystemName = string(value)
+		case lldpTLVMgmtAddress:
+			// Длина адреса включает байт семейства адреса, поэтому она не меньше 1.
+			if len(value) < 2 || value[0] < 1 || 1+int(value[0]) > len(value) {
+				continue
+			}
+			addrLen := int(value[0])
+			addr := value[2 : 1+addrLen]
+			switch {
+			case value[1] == lldpAddrFamilyIPv4 && len(addr) == net.IPv4len,
+				value[1] == lldpAddrFamilyIPv6 && len(addr) == net.IPv6len:
+				neighbor.ManagementAddresses = append(neighbor.ManagementAddresses, net.IP(addr))
+			}
+		}
+	}
+	return neighbor, nil
+}
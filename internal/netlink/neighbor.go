@@ -0,0 +1,101 @@
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// DiscoverLinkLocalNeighbor находит IPv6 link-local адрес соседа на p2p интерфейсе ifName:
+// отправляет ICMPv6 echo request на ff02::1, чтобы сосед появился в таблице соседей
+// (туда же попадают маршрутизаторы, приславшие RA), и ищет в ней единственный link-local адрес,
+// который не принадлежит хосту. Ожидание прерывается завершением ctx. Требуется CAP_NET_RAW.
+func DiscoverLinkLocalNeighbor(ctx context.Context, ifName string, timeout time.Duration) (net.IP, error) {
+	ifi, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return nil, err
+	}
+	if err := pingAllNodes(ifi); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		ip, err := linkLocalNeighbor(ifi)
+		if err == nil || time.Now().After(deadline) {
+			return ip, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func pingAllNodes(ifi *net.Interface) error {
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return fmt.Errorf("failed to open icmpv6 socket: %w", err)
+	}
+	defer conn.Close()
+	msg, err := (&icmp.Message{
+		Type: ipv6.ICMPTypeEchoRequest,
+		Body: &icmp.Echo{ID: unix.Getpid() & 0xffff, Seq: 1},
+	}).Marshal(nil)
+	if err != nil {
+		return err
+	}
+	dst := &net.IPAddr{IP: net.IPv6linklocalallnodes, Zone: ifi.Name}
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		return fmt.Errorf("failed to send icmpv6 echo request to %s: %w", dst, err)
+	}
+	return nil
+}
+
+// Функция linkLocalNeighbor ищет единственный чужой link-local адрес в таблице соседей интерфейса.
+func linkLocalNeighbor(ifi *net.Interface) (net.IP, error) {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	neighs, err := c.Neigh.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list neighbors: %w", err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	local := func(ip net.IP) bool {
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	found := []net.IP{}
+	for _, n := range neighs {
+		if n.Index != uint32(ifi.Index) || n.Family != unix.AF_INET6 || n.Attributes == nil ||
+			n.State&(unix.NUD_FAILED|unix.NUD_INCOMPLETE) != 0 ||
+			!n.Attributes.Address.IsLinkLocalUnicast() || local(n.Attributes.Address) {
+			continue
+		}
+		found = append(found, n.Attributes.Address)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no ipv6 link-local neighbor found on %s", ifi.Name)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("found %d ipv6 link-local neighbors on %s, only point-to-point links are supported", len(found), ifi.Name)
+	}
+}
@@ -0,0 +1,51 @@
+package netlink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	netnsDir = "/var/run/netns"
+	// netnsEnv отмечает процесс, уже перезапущенный в network namespace.
+	netnsEnv = "BGP_SPEAKER_NETNS"
+)
+
+// ExecInNetNS перезапускает текущую программу с теми же аргументами внутри network namespace
+// name из /var/run/netns, как "ip netns exec". Network namespace в Linux задается для потока,
+// а Go создает потоки сам, поэтому переключить уже запущенный процесс целиком нельзя:
+// поток переключается через setns и сразу выполняет exec, и новый процесс, его BGP сессии
+// и netlink сокеты целиком живут в namespace.
+//
+// Если процесс уже перезапущен в name, ничего не делает. При успехе не возвращает управление.
+func ExecInNetNS(name string) error {
+	if name == "" || os.Getenv(netnsEnv) == name {
+		return nil
+	}
+	if name != filepath.Base(name) {
+		return fmt.Errorf("invalid netns name %q", name)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("netns %s: %w", name, err)
+	}
+	f, err := os.Open(filepath.Join(netnsDir, name))
+	if err != nil {
+		return fmt.Errorf("netns %s: %w", name, err)
+	}
+	defer f.Close()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := unix.Setns(int(f.Fd()), unix.CLONE_NEWNET); err != nil {
+		return fmt.Errorf("netns %s: setns failed: %w", name, err)
+	}
+	env := append(os.Environ(), netnsEnv+"="+name)
+	if err := unix.Exec(exe, os.Args, env); err != nil {
+		return fmt.Errorf("netns %s: exec failed: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package netlink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const getRoute = 0x1a
+
+// LookupRoute возвращает маршрут, которым ядро отправит пакет на destination, как "ip route get".
+// В ответе Table это таблица, в которой найден маршрут (RTM_F_LOOKUP_TABLE).
+func LookupRoute(destination string) (Route, error) {
+	dst := net.ParseIP(destination)
+	if dst == nil {
+		return Route{}, fmt.Errorf("%q is not a valid ip address", destination)
+	}
+	family, length := uint8(unix.AF_INET), uint8(32)
+	if dst.To4() == nil {
+		family, length = unix.AF_INET6, 128
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	links, err := StartLinkCache(ctx)
+	if err != nil {
+		return Route{}, err
+	}
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return Route{}, err
+	}
+	defer c.Close()
+	msgs, err := c.Execute(&rtnetlink.RouteMessage{
+		Family:    family,
+		DstLength: length,
+		Flags:     unix.RTM_F_LOOKUP_TABLE,
+		Attributes: rtnetlink.RouteAttributes{
+			Dst: dst,
+		},
+	}, getRoute, netlink.Request)
+	if err != nil {
+		return Route{}, fmt.Errorf("route lookup failed: %w", err)
+	}
+	if len(msgs) != 1 {
+		return Route{}, fmt.Errorf("unexpected number of routes to %s: %d", dst, len(msgs))
+	}
+	rt, ok := msgs[0].(*rtnetlink.RouteMessage)
+	if !ok {
+		return Route{}, fmt.Errorf("unexpected rtnetlink message")
+	}
+	return toRoute(*rt, links), nil
+}
+
+// PrintRouteLookup печатает маршрут до destination, см. [LookupRoute].
+func PrintRouteLookup(destination string, asJSON bool) error {
+	route, err := LookupRoute(destination)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(route)
+	}
+	fmt.Println(route)
+	return nil
+}
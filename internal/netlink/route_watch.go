@@ -0,0 +1,121 @@
+package netlink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	RouteAdded   = "add"
+	RouteChanged = "change"
+	RouteDeleted = "delete"
+)
+
+// RouteEvent это изменение маршрута в ядре.
+type RouteEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Route  Route     `json:"route"`
+}
+
+func (r Route) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(r.Dst)
+	if r.Gateway != "" {
+		sb.WriteString(" via " + r.Gateway)
+	}
+	if r.Dev != "" {
+		sb.WriteString(" dev " + r.Dev)
+	}
+	if r.Src != "" {
+		sb.WriteString(" src " + r.Src)
+	}
+	sb.WriteString(fmt.Sprintf(" proto %d table %d metric %d", r.Protocol, r.Table, r.Priority))
+	for _, nh := range r.Multipath {
+		sb.WriteString(fmt.Sprintf(" nexthop via %s dev %s weight %d", nh.Gateway, nh.Dev, nh.Weight))
+	}
+	return sb.String()
+}
+
+// routeKey это ключ маршрута в ядре: замена маршрута с тем же ключом это изменение, а не добавление.
+func routeKey(rt rtnetlink.RouteMessage) string {
+	return fmt.Sprintf("%d %s/%d %d %d %d", rt.Family, rt.Attributes.Dst, rt.DstLength, messageTable(rt), rt.Attributes.Priority, rt.Tos)
+}
+
+// WatchRoutes подписывается на уведомления RTNLGRP_IPV4_ROUTE и RTNLGRP_IPV6_ROUTE и вызывает fn
+// для каждого добавления, изменения и удаления маршрута, отобранного filter, пока не завершится ctx.
+func WatchRoutes(ctx context.Context, filter RouteFilter, fn func(RouteEvent)) error {
+	events, err := rtnetlink.Dial(&netlink.Config{Groups: unix.RTMGRP_IPV4_ROUTE | unix.RTMGRP_IPV6_ROUTE})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to route notifications: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		events.Close()
+	}()
+	links, err := StartLinkCache(ctx)
+	if err != nil {
+		return err
+	}
+	// Текущие маршруты загружаются после подписки, чтобы отличать изменения от добавлений.
+	known := map[string]bool{}
+	routes, err := events.Route.List()
+	if err != nil {
+		return fmt.Errorf("failed to list routes: %w", err)
+	}
+	for _, rt := range routes {
+		known[routeKey(rt)] = true
+	}
+	for {
+		_, msgs, err := events.Receive()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive route notifications: %w", err)
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.RTM_NEWROUTE && m.Header.Type != unix.RTM_DELROUTE {
+				continue
+			}
+			var rt rtnetlink.RouteMessage
+			if err := rt.UnmarshalBinary(m.Data); err != nil {
+				return fmt.Errorf("failed to decode route notification: %w", err)
+			}
+			key := routeKey(rt)
+			action := RouteAdded
+			if m.Header.Type == unix.RTM_DELROUTE {
+				action = RouteDeleted
+				delete(known, key)
+			} else if known[key] {
+				action = RouteChanged
+			} else {
+				known[key] = true
+			}
+			if filter.match(rt) {
+				fn(RouteEvent{Time: time.Now(), Action: action, Route: toRoute(rt, links)})
+			}
+		}
+	}
+}
+
+// PrintRouteEvents печатает изменения маршрутов, отобранных filter, построчно или в JSON
+// по объекту на строку, пока не завершится ctx.
+func PrintRouteEvents(ctx context.Context, filter RouteFilter, asJSON bool) error {
+	enc := json.NewEncoder(os.Stdout)
+	return WatchRoutes(ctx, filter, func(e RouteEvent) {
+		if asJSON {
+			_ = enc.Encode(e)
+			return
+		}
+		fmt.Printf("%s %-6s %s\n", e.Time.Format(time.RFC3339Nano), e.Action, e.Route)
+	})
+}
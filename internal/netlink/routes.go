@@ -0,0 +1,151 @@
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/jsimonetti/rtnetlink/rtnl"
+	"golang.org/x/sys/unix"
+)
+
+// routeProtocols это имена протоколов маршрутов, как в /etc/iproute2/rt_protos.
+var routeProtocols = map[string]uint8{
+	"redirect": unix.RTPROT_REDIRECT,
+	"kernel":   unix.RTPROT_KERNEL,
+	"boot":     unix.RTPROT_BOOT,
+	"static":   unix.RTPROT_STATIC,
+	"dhcp":     unix.RTPROT_DHCP,
+	"zebra":    unix.RTPROT_ZEBRA,
+	"bird":     unix.RTPROT_BIRD,
+	"bgp":      protoBgp,
+}
+
+// RouteFilter отбирает маршруты в [ListRoutes], нулевые поля не фильтруют.
+type RouteFilter struct {
+	Table    uint32
+	Protocol uint8
+	Family   uint8
+}
+
+// ParseRouteFilter разбирает значения флагов: protocol это имя (bgp, kernel, static, ...)
+// или номер протокола, family это inet или inet6 (ipv4 или ipv6).
+func ParseRouteFilter(table uint32, protocol, family string) (RouteFilter, error) {
+	filter := RouteFilter{Table: table}
+	if protocol != "" {
+		if p, ok := routeProtocols[protocol]; ok {
+			filter.Protocol = p
+		} else if p, err := strconv.ParseUint(protocol, 10, 8); err == nil {
+			filter.Protocol = uint8(p)
+		} else {
+			return RouteFilter{}, fmt.Errorf("unknown route protocol %q", protocol)
+		}
+	}
+	switch family {
+	case "":
+	case "inet", "ipv4":
+		filter.Family = unix.AF_INET
+	case "inet6", "ipv6":
+		filter.Family = unix.AF_INET6
+	default:
+		return RouteFilter{}, fmt.Errorf("unknown family %q, use inet or inet6", family)
+	}
+	return filter, nil
+}
+
+func (f RouteFilter) match(rt rtnetlink.RouteMessage) bool {
+	return (f.Table == 0 || messageTable(rt) == f.Table) &&
+		(f.Protocol == 0 || rt.Protocol == f.Protocol) &&
+		(f.Family == 0 || rt.Family == f.Family)
+}
+
+// Route это маршрут ядра в виде, удобном для вывода.
+type Route struct {
+	// Dst это префикс или "default".
+	Dst       string         `json:"dst"`
+	Family    string         `json:"family"`
+	Gateway   string         `json:"gateway,omitempty"`
+	Dev       string         `json:"dev,omitempty"`
+	Src       string         `json:"src,omitempty"`
+	Protocol  uint8          `json:"protocol"`
+	Table     uint32         `json:"table"`
+	Priority  uint32         `json:"priority"`
+	Multipath []RouteNextHop `json:"multipath,omitempty"`
+}
+
+// RouteNextHop это next-hop multipath маршрута, Weight это rtnh_hops плюс один.
+type RouteNextHop struct {
+	Gateway string `json:"gateway"`
+	Dev     string `json:"dev,omitempty"`
+	Weight  int    `json:"weight"`
+}
+
+// ListRoutes возвращает маршруты ядра всех таблиц, отобранные filter.
+func ListRoutes(filter RouteFilter) ([]Route, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	links, err := StartLinkCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := rtnl.Dial(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	messages, err := c.Conn.Route.List()
+	if err != nil {
+		return nil, err
+	}
+	routes := []Route{}
+	for _, rt := range messages {
+		if !filter.match(rt) {
+			continue
+		}
+		route := toRoute(rt, links)
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// Функция toRoute преобразует маршрут из rtnetlink в Route, имена интерфейсов берутся из links.
+func toRoute(rt rtnetlink.RouteMessage, links *LinkCache) Route {
+	route := Route{
+		Dst:      "default",
+		Family:   "inet",
+		Protocol: rt.Protocol,
+		Table:    messageTable(rt),
+		Priority: rt.Attributes.Priority,
+	}
+	if rt.Family == unix.AF_INET6 {
+		route.Family = "inet6"
+	}
+	if rt.Attributes.Dst != nil {
+		route.Dst = fmt.Sprintf("%s/%d", rt.Attributes.Dst, rt.DstLength)
+	}
+	if rt.Attributes.Gateway != nil {
+		route.Gateway = rt.Attributes.Gateway.String()
+	}
+	if rt.Attributes.Src != nil {
+		route.Src = rt.Attributes.Src.String()
+	}
+	route.Dev, _ = links.Name(rt.Attributes.OutIface)
+	for _, nh := range rt.Attributes.Multipath {
+		dev, _ := links.Name(nh.Hop.IfIndex)
+		route.Multipath = append(route.Multipath, RouteNextHop{
+			Gateway: nh.Gateway.String(),
+			Dev:     dev,
+			Weight:  int(nh.Hop.Hops) + 1,
+		})
+	}
+	return route
+}
+
+// Функция messageTable возвращает номер таблицы: для таблиц больше 255 он есть только в RTA_TABLE.
+func messageTable(rt rtnetlink.RouteMessage) uint32 {
+	if rt.Attributes.Table != 0 {
+		return rt.Attributes.Table
+	}
+	return uint32(rt.Table)
+}
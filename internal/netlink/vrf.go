@@ -0,0 +1,50 @@
+package netlink
+
+import (
+	"fmt"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// VRF это интерфейс VRF (ip link add NAME type vrf table TABLE).
+type VRF struct {
+	Index uint32
+	Table uint32
+}
+
+// LookupVRF возвращает index и таблицу маршрутизации интерфейса VRF с именем name.
+func LookupVRF(name string) (VRF, error) {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return VRF{}, err
+	}
+	defer c.Close()
+	links, err := c.Link.List()
+	if err != nil {
+		return VRF{}, fmt.Errorf("failed to list links: %w", err)
+	}
+	for _, link := range links {
+		if link.Attributes == nil || link.Attributes.Name != name {
+			continue
+		}
+		if link.Attributes.Info == nil || link.Attributes.Info.Kind != "vrf" {
+			return VRF{}, fmt.Errorf("link %s is not a vrf", name)
+		}
+		ad, err := netlink.NewAttributeDecoder(link.Attributes.Info.Data)
+		if err != nil {
+			return VRF{}, fmt.Errorf("vrf %s: %w", name, err)
+		}
+		for ad.Next() {
+			if ad.Type() == unix.IFLA_VRF_TABLE {
+				return VRF{Index: link.Index, Table: ad.Uint32()}, nil
+			}
+		}
+		if err := ad.Err(); err != nil {
+			return VRF{}, fmt.Errorf("vrf %s: %w", name, err)
+		}
+		return VRF{}, fmt.Errorf("vrf %s: table is not set", name)
+	}
+	return VRF{}, fmt.Errorf("vrf %s: %w", name, ErrLinkNotFound)
+}
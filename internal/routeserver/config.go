@@ -0,0 +1,23 @@
+package routeserver
+
+type Config struct {
+	ASN           uint32     `yaml:"asn"`
+	RouterID      string     `yaml:"router_id"`
+	ListenAddress string     `yaml:"listen_address"`
+	ListenPort    int32      `yaml:"listen_port"`
+	Neighbors     []Neighbor `yaml:"neighbors"`
+	Prefixes      []Prefix   `yaml:"prefixes"`
+	RecordFile    string     `yaml:"record_file"`
+}
+
+type Neighbor struct {
+	Address string `yaml:"address"`
+	ASN     uint32 `yaml:"asn"`
+}
+
+// Prefix описывает префикс, который route server анонсирует всем соседям.
+// Если NextHop не задан, gobgp подставит адрес локального конца сессии.
+type Prefix struct {
+	Prefix  string `yaml:"prefix"`
+	NextHop string `yaml:"next_hop"`
+}
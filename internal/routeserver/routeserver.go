@@ -0,0 +1,198 @@
+// Package routeserver реализует простой эмулятор BGP-соседа для лабораторных стендов:
+// он принимает сессии от настоящего speaker, анонсирует ему заданные префиксы и
+// записывает в файл все полученные анонсы и отзывы.
+package routeserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/server"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultListenPort = 179
+
+type RouteServer struct {
+	configPath string
+	logger     *speaker.Logger
+	config     Config
+	s          *server.BgpServer
+	mu         sync.Mutex
+	record     *json.Encoder
+}
+
+// Record это одна строка файла record_file.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Neighbor string    `json:"neighbor"`
+	Action   string    `json:"action"`
+	Prefix   string    `json:"prefix"`
+	NextHop  string    `json:"next_hop,omitempty"`
+}
+
+func NewRouteServer(configPath string, logLevel speaker.LogLevel) (*RouteServer, error) {
+	rs := &RouteServer{
+		configPath: configPath,
+		logger:     speaker.NewLogger(logLevel.LrLevel()),
+	}
+	configBytes, err := os.ReadFile(rs.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(configBytes, &rs.config); err != nil {
+		return nil, err
+	}
+	if rs.config.ListenPort == 0 {
+		rs.config.ListenPort = defaultListenPort
+	}
+	return rs, nil
+}
+
+func (rs *RouteServer) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if rs.config.RecordFile != "" {
+		f, err := os.OpenFile(rs.config.RecordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("error opening record file: %w", err)
+		}
+		defer f.Close()
+		rs.record = json.NewEncoder(f)
+	}
+
+	rs.s = server.NewBgpServer(server.LoggerOption(rs.logger))
+	go rs.s.Serve()
+	defer rs.s.Stop()
+
+	global := &api.Global{
+		Asn:        rs.config.ASN,
+		RouterId:   rs.config.RouterID,
+		ListenPort: rs.config.ListenPort,
+	}
+	if rs.config.ListenAddress != "" {
+		global.ListenAddresses = []string{rs.config.ListenAddress}
+	}
+	if err := rs.s.StartBgp(ctx, &api.StartBgpRequest{Global: global}); err != nil {
+		return fmt.Errorf("error starting bgp: %w", err)
+	}
+	if err := rs.s.WatchEvent(ctx, &api.WatchEventRequest{
+		Table: &api.WatchEventRequest_Table{
+			Filters: []*api.WatchEventRequest_Table_Filter{
+				{Type: api.WatchEventRequest_Table_Filter_ADJIN},
+			},
+		},
+	}, rs.recordPaths); err != nil {
+		return fmt.Errorf("error watching adj-rib-in: %w", err)
+	}
+	for _, n := range rs.config.Neighbors {
+		peer := &api.Peer{
+			Conf: &api.PeerConf{
+				NeighborAddress: n.Address,
+				PeerAsn:         n.ASN,
+			},
+			Transport: &api.Transport{
+				PassiveMode: true,
+			},
+		}
+		if err := rs.s.AddPeer(ctx, &api.AddPeerRequest{Peer: peer}); err != nil {
+			return fmt.Errorf("error adding neighbor %s: %w", n.Address, err)
+		}
+	}
+	for _, p := range rs.config.Prefixes {
+		path, err := prefixPath(p)
+		if err != nil {
+			return err
+		}
+		if _, err := rs.s.AddPath(ctx, &api.AddPathRequest{Path: path}); err != nil {
+			return fmt.Errorf("error advertising %s: %w", p.Prefix, err)
+		}
+		rs.logger.Info("advertising prefix", log.Fields{"prefix": p.Prefix, "next_hop": p.NextHop})
+	}
+
+	<-ctx.Done()
+	rs.logger.Info("shutting down route server", nil)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return rs.s.StopBgp(timeoutCtx, &api.StopBgpRequest{})
+}
+
+func (rs *RouteServer) recordPaths(resp *api.WatchEventResponse) {
+	for _, path := range resp.GetTable().GetPaths() {
+		prefix := new(api.IPAddressPrefix)
+		if err := path.Nlri.UnmarshalTo(prefix); err != nil {
+			rs.logger.Warn("unsupported nlri received", log.Fields{"neighbor": path.NeighborIp, "error": err.Error()})
+			continue
+		}
+		r := Record{
+			Time:     time.Now(),
+			Neighbor: path.NeighborIp,
+			Action:   "advertise",
+			Prefix:   fmt.Sprintf("%s/%d", prefix.Prefix, prefix.PrefixLen),
+		}
+		if path.IsWithdraw {
+			r.Action = "withdraw"
+		}
+		nextHopAttr := new(api.NextHopAttribute)
+		for _, attr := range path.Pattrs {
+			if attr.MessageIs(nextHopAttr) && attr.UnmarshalTo(nextHopAttr) == nil {
+				r.NextHop = nextHopAttr.NextHop
+			}
+		}
+		rs.logger.Info("route received", log.Fields{"neighbor": r.Neighbor, "action": r.Action, "prefix": r.Prefix, "next_hop": r.NextHop})
+		if rs.record == nil {
+			continue
+		}
+		rs.mu.Lock()
+		if err := rs.record.Encode(r); err != nil {
+			rs.logger.Error("error writing record file", log.Fields{"error": err.Error()})
+		}
+		rs.mu.Unlock()
+	}
+}
+
+func prefixPath(p Prefix) (*api.Path, error) {
+	_, ipNet, err := net.ParseCIDR(p.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix %s: %w", p.Prefix, err)
+	}
+	if ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("prefix %s is not ipv4", p.Prefix)
+	}
+	prefixLen, _ := ipNet.Mask.Size()
+	nlri, err := anypb.New(&api.IPAddressPrefix{
+		Prefix:    ipNet.IP.String(),
+		PrefixLen: uint32(prefixLen),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating network layer reachability information: %s", err)
+	}
+	nextHop := p.NextHop
+	if nextHop == "" {
+		nextHop = "0.0.0.0"
+	}
+	a1, _ := anypb.New(&api.OriginAttribute{
+		Origin: uint32(bgp.BGP_ORIGIN_ATTR_TYPE_IGP),
+	})
+	a2, _ := anypb.New(&api.NextHopAttribute{
+		NextHop: nextHop,
+	})
+	return &api.Path{
+		Family: &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
+		Nlri:   nlri,
+		Pattrs: []*anypb.Any{a1, a2},
+	}, nil
+}
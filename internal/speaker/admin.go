@@ -0,0 +1,133 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	adminShutdownTimeout   = time.Second
+	adminReadHeaderTimeout = 5 * time.Second
+	maintenancePath        = "/maintenance"
+	statusPath             = "/status"
+	contentTypeHeader      = "Content-Type"
+	contentTypeJSON        = "application/json"
+)
+
+// AdminStatus это ответ admin API с текущим состоянием анонса.
+type AdminStatus struct {
+	AnycastIP   string `json:"anycast_ip"`
+	Healthy     bool   `json:"healthy"`
+	Maintenance bool   `json:"maintenance"`
+	Advertised  bool   `json:"advertised"`
+	Degraded    bool   `json:"degraded"`
+	Leader      bool   `json:"leader"`
+	// Reachable это результат проверки доступности снаружи, если настроен verification.
+	Reachable *bool `json:"reachable,omitempty"`
+	// HealthChecks это статус проверок из health_checks, от которых зависят prefixes.
+	HealthChecks map[string]bool `json:"health_checks,omitempty"`
+}
+
+// MaintenanceRequest это тело запроса POST /maintenance.
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ServeAdmin запускает admin API и останавливает его по завершению ctx.
+func (sp *Speaker) ServeAdmin(ctx context.Context) error {
+	addr := sp.config.AdminAddress
+	if addr == "" {
+		addr = defaults.AdminAddress
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+statusPath, sp.handleStatus)
+	mux.HandleFunc("GET "+healthHistoryPath, sp.handleHealthHistory)
+	mux.HandleFunc("GET "+auditPath, sp.handleAudit)
+	mux.HandleFunc("POST "+maintenancePath, sp.handleMaintenance)
+	mux.HandleFunc("GET "+debugPathPath, sp.handleDebugPath)
+	mux.HandleFunc("GET "+debugBundlePath, sp.handleDebugBundle)
+	mux.HandleFunc("GET "+capturePath, sp.handleCaptureStatus)
+	mux.HandleFunc("POST "+capturePath, sp.handleCapture)
+	mux.HandleFunc("GET "+metricsPath, sp.handleMetrics)
+	mux.HandleFunc("GET "+ribPath, sp.handleRIB)
+	mux.HandleFunc("GET "+routesPath, sp.handleListRoutes)
+	mux.HandleFunc("POST "+routesPath, sp.handleAdvertiseRoute)
+	mux.HandleFunc("DELETE "+routesPath, sp.handleWithdrawRoute)
+	mux.HandleFunc("GET "+peersPath, sp.handlePeers)
+	mux.HandleFunc("POST "+disablePeerPath, sp.handleDisablePeer)
+	mux.HandleFunc("POST "+enablePeerPath, sp.handleEnablePeer)
+	mux.HandleFunc("POST "+resetPeerPath, sp.handleResetPeer)
+	mux.HandleFunc("POST "+softRefreshPath, sp.handleSoftRefresh)
+	mux.HandleFunc("GET "+policiesPath, sp.handlePolicies)
+	sp.registerProbes(mux)
+	sp.logger.Info("starting admin api", log.Fields{"address": addr})
+	if err := serveHTTP(ctx, addr, mux); err != nil {
+		return fmt.Errorf("admin api: %w", err)
+	}
+	return nil
+}
+
+// Функция serveHTTP обслуживает запросы на addr, пока не завершится ctx.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: adminReadHeaderTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), adminShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (sp *Speaker) adminStatus() AdminStatus {
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	return AdminStatus{
+		AnycastIP:    sp.config.AnycastIP,
+		Healthy:      sp.healthy,
+		Maintenance:  sp.maintenance,
+		Advertised:   sp.advertised.Load(),
+		Degraded:     sp.degraded.Load(),
+		Leader:       sp.leader,
+		Reachable:    sp.verified.Load(),
+		HealthChecks: maps.Clone(sp.prefixChecks),
+	}
+}
+
+func (sp *Speaker) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sp.adminStatus())
+}
+
+func (sp *Speaker) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := sp.SetMaintenance(withAuditCause(r.Context(), auditCauseMaintenance, auditCauseAdminAPI), req.Enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, sp.adminStatus())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,216 @@
+package speaker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const adminClientTimeout = 10 * time.Second
+
+// AdminClient обращается к admin API запущенного speaker.
+type AdminClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewAdminClient(address string) *AdminClient {
+	return &AdminClient{
+		baseURL: "http://" + address,
+		client: &http.Client{
+			Timeout: adminClientTimeout,
+		},
+	}
+}
+
+func (c *AdminClient) Status() (*AdminStatus, error) {
+	status := new(AdminStatus)
+	if err := c.do(http.MethodGet, statusPath, nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *AdminClient) HealthHistory() (*HealthHistoryResponse, error) {
+	history := new(HealthHistoryResponse)
+	if err := c.do(http.MethodGet, healthHistoryPath, nil, history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (c *AdminClient) Audit() ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	if err := c.do(http.MethodGet, auditPath, nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (c *AdminClient) SetMaintenance(enabled bool) (*AdminStatus, error) {
+	status := new(AdminStatus)
+	if err := c.do(http.MethodPost, maintenancePath, MaintenanceRequest{Enabled: enabled}, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *AdminClient) AnnouncedPath() (*AnnouncedPathDump, error) {
+	dump := new(AnnouncedPathDump)
+	if err := c.do(http.MethodGet, debugPathPath, nil, dump); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+func (c *AdminClient) RIB(q RIBQuery) ([]RIBDestination, error) {
+	rib := []RIBDestination{}
+	path := ribPath
+	if v := q.values(); len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+	if err := c.do(http.MethodGet, path, nil, &rib); err != nil {
+		return nil, err
+	}
+	return rib, nil
+}
+
+func (c *AdminClient) Routes() (*RoutesResponse, error) {
+	routes := new(RoutesResponse)
+	if err := c.do(http.MethodGet, routesPath, nil, routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+func (c *AdminClient) AdvertiseRoute(prefix, nextHop string) (*RoutesResponse, error) {
+	routes := new(RoutesResponse)
+	if err := c.do(http.MethodPost, routesPath, RouteRequest{Prefix: prefix, NextHop: nextHop}, routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+func (c *AdminClient) WithdrawRoute(prefix string) (*RoutesResponse, error) {
+	routes := new(RoutesResponse)
+	if err := c.do(http.MethodDelete, routesPath, RouteRequest{Prefix: prefix}, routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+func (c *AdminClient) Peers() ([]PeerStatus, error) {
+	peers := []PeerStatus{}
+	if err := c.do(http.MethodGet, peersPath, nil, &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+func (c *AdminClient) DisablePeer(req PeerAdminRequest) (*PeerAdminResponse, error) {
+	resp := new(PeerAdminResponse)
+	if err := c.do(http.MethodPost, disablePeerPath, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *AdminClient) EnablePeer(req PeerAdminRequest) (*PeerAdminResponse, error) {
+	resp := new(PeerAdminResponse)
+	if err := c.do(http.MethodPost, enablePeerPath, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *AdminClient) ResetPeer(req PeerAdminRequest) (*PeerAdminResponse, error) {
+	resp := new(PeerAdminResponse)
+	if err := c.do(http.MethodPost, resetPeerPath, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *AdminClient) SoftRefresh(req SoftRefreshRequest) (*SoftRefreshResponse, error) {
+	resp := new(SoftRefreshResponse)
+	if err := c.do(http.MethodPost, softRefreshPath, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *AdminClient) Policies() (*PolicyDump, error) {
+	dump := new(PolicyDump)
+	if err := c.do(http.MethodGet, policiesPath, nil, dump); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+func (c *AdminClient) CaptureStatus() (*CaptureStatus, error) {
+	status := new(CaptureStatus)
+	if err := c.do(http.MethodGet, capturePath, nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *AdminClient) Capture(req CaptureRequest) (*CaptureStatus, error) {
+	status := new(CaptureStatus)
+	if err := c.do(http.MethodPost, capturePath, req, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// DebugBundle пишет в w архив tar.gz с состоянием speaker, который собирает admin API.
+func (c *AdminClient) DebugBundle(w io.Writer) error {
+	resp, err := c.client.Get(c.baseURL + debugBundlePath)
+	if err != nil {
+		return fmt.Errorf("admin api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *AdminClient) do(method, path string, reqBody, respBody any) error {
+	var body io.Reader
+	if reqBody != nil {
+		b, err := json.Marshal(reqBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if reqBody != nil {
+		req.Header.Set(contentTypeHeader, contentTypeJSON)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("admin api: unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
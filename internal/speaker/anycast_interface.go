@@ -0,0 +1,49 @@
+package speaker
+
+import (
+	"net"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+// AnycastInterface задает интерфейс, на который speaker сам назначает anycast ip перед анонсом
+// и с которого снимает его после отзыва, например, dummy интерфейс или lo.
+type AnycastInterface struct {
+	Name string `yaml:"name"`
+	// Create создает dummy интерфейс Name, если его нет.
+	Create bool `yaml:"create"`
+}
+
+// Метод setupAnycastInterface находит или создает интерфейс из anycast_interface.
+func (sp *Speaker) setupAnycastInterface() error {
+	if sp.config.AnycastInterface == nil {
+		return nil
+	}
+	index, err := nl.EnsureDummyLink(sp.config.AnycastInterface.Name, sp.config.AnycastInterface.Create)
+	if err != nil {
+		return err
+	}
+	sp.anycastIfIndex = index
+	return nil
+}
+
+// Метод addAnycastAddress назначает anycast ip на anycast_interface, чтобы хост принимал трафик,
+// который придет после анонса.
+func (sp *Speaker) addAnycastAddress() error {
+	if sp.anycastIfIndex == 0 {
+		return nil
+	}
+	return nl.AddHostAddress(sp.anycastIfIndex, net.ParseIP(sp.config.AnycastIP))
+}
+
+// Метод removeAnycastAddress снимает anycast ip с anycast_interface. Маршрут к этому моменту
+// уже отозван, поэтому ошибка только пишется в лог.
+func (sp *Speaker) removeAnycastAddress() {
+	if sp.anycastIfIndex == 0 {
+		return
+	}
+	if err := nl.DeleteHostAddress(sp.anycastIfIndex, net.ParseIP(sp.config.AnycastIP)); err != nil {
+		sp.logger.Error("failed to remove anycast ip from interface", log.Fields{"interface": sp.config.AnycastInterface.Name, "error": err.Error()})
+	}
+}
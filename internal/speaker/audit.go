@@ -0,0 +1,151 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	auditPath = "/audit"
+	// auditHistorySize это сколько последних решений хранится для admin API.
+	auditHistorySize = 256
+	auditFileMode    = 0o640
+	auditWriteError  = "failed to write audit log"
+
+	auditActionAdvertise        = "advertise"
+	auditActionWithdraw         = "withdraw"
+	auditActionDegrade          = "degrade"
+	auditActionGracefulShutdown = "graceful_shutdown"
+
+	auditCauseStartup     = "startup"
+	auditCauseShutdown    = "shutdown"
+	auditCauseHealthCheck = "health_check"
+	auditCauseMaintenance = "maintenance"
+	auditCauseLeader      = "leader_election"
+	auditCauseHooks       = "hooks"
+	auditCauseAdminAPI    = "admin_api"
+	auditCauseKubernetes  = "kubernetes"
+	auditCauseUnknown     = "unknown"
+)
+
+// AuditLog задает файл, в который дописываются решения об анонсе и отзыве, см. [AuditEntry].
+type AuditLog struct {
+	// File это путь файла, записи пишутся по одной на строку в JSON.
+	File string `yaml:"file"`
+}
+
+func (a *AuditLog) validate() error {
+	if a.File == "" {
+		return errors.New("audit_log.file: is required")
+	}
+	return nil
+}
+
+// AuditEntry это одно решение об анонсе или отзыве anycast ip или дополнительного префикса.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// Action это advertise, withdraw, degrade (soft_fail) или graceful_shutdown.
+	Action  string `json:"action"`
+	Prefix  string `json:"prefix"`
+	NextHop string `json:"next_hop,omitempty"`
+	// Cause это причина решения: startup, shutdown, health_check, maintenance, leader_election,
+	// hooks, admin_api, kubernetes или unknown для вызовов через пакет pkg/speaker.
+	Cause string `json:"cause"`
+	// Detail уточняет причину, например, имя проверки из health_checks или сигнал.
+	Detail string `json:"detail,omitempty"`
+}
+
+type auditCause struct {
+	cause  string
+	detail string
+}
+
+type auditCauseKey struct{}
+
+// Функция withAuditCause задает причину решений, принятых с ctx, если она еще не задана:
+// причина, заданная ближе к источнику события, не перезаписывается.
+func withAuditCause(ctx context.Context, cause, detail string) context.Context {
+	if _, ok := ctx.Value(auditCauseKey{}).(auditCause); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, auditCauseKey{}, auditCause{cause: cause, detail: detail})
+}
+
+// Метод audit запоминает решение action для prefix с причиной из ctx и дописывает его в audit_log.
+func (sp *Speaker) audit(ctx context.Context, action, prefix, nextHop string) {
+	cause, ok := ctx.Value(auditCauseKey{}).(auditCause)
+	if !ok {
+		cause.cause = auditCauseUnknown
+	}
+	entry := AuditEntry{
+		Time:    time.Now(),
+		Action:  action,
+		Prefix:  prefix,
+		NextHop: nextHop,
+		Cause:   cause.cause,
+		Detail:  cause.detail,
+	}
+	sp.auditMu.Lock()
+	defer sp.auditMu.Unlock()
+	if len(sp.auditHistory) < auditHistorySize {
+		sp.auditHistory = append(sp.auditHistory, entry)
+	} else {
+		sp.auditHistory[sp.auditNext] = entry
+		sp.auditNext = (sp.auditNext + 1) % auditHistorySize
+	}
+	if sp.config.AuditLog == nil {
+		return
+	}
+	if err := appendAuditEntry(sp.config.AuditLog.File, entry); err != nil {
+		sp.logs.Error(sp.logger, auditWriteError, log.Fields{"file": sp.config.AuditLog.File, "error": err.Error()})
+		return
+	}
+	sp.logs.Resolved(sp.logger, auditWriteError)
+}
+
+// Функция appendAuditEntry открывает файл на каждую запись, чтобы не мешать ротации.
+func appendAuditEntry(file string, entry AuditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, auditFileMode)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Метод auditEntries возвращает последние решения (не более 256), от старых к новым.
+func (sp *Speaker) auditEntries() []AuditEntry {
+	sp.auditMu.Lock()
+	defer sp.auditMu.Unlock()
+	entries := append([]AuditEntry{}, sp.auditHistory[sp.auditNext:]...)
+	return append(entries, sp.auditHistory[:sp.auditNext]...)
+}
+
+// Метод auditShutdown записывает отзыв всех анонсированных префиксов при остановке BGP.
+func (sp *Speaker) auditShutdown(ctx context.Context) {
+	ctx = withAuditCause(ctx, auditCauseShutdown, "")
+	if sp.advertised.Load() {
+		sp.audit(ctx, auditActionWithdraw, sp.config.AnycastIP, "")
+	}
+	nextHops := sp.prefixNextHops()
+	for _, ip := range sp.AdvertisedPrefixes() {
+		sp.audit(ctx, auditActionWithdraw, ip, nextHops[ip])
+	}
+}
+
+func (sp *Speaker) handleAudit(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sp.auditEntries())
+}
@@ -2,19 +2,380 @@ package speaker
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/kubernetes"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	AnycastIP string `yaml:"anycast_ip"`
-	ASN       uint32 `yaml:"asn"`
-	Neighbors []struct {
-		Address string `yaml:"address"`
-		ASN     uint32 `yaml:"asn"`
-	} `yaml:"neighbors"`
-	HealthCheckURL  string  `yaml:"health_check_url"`
-	UpdateFIBMetric *uint32 `yaml:"update_fib_metric"`
+	AnycastIP string     `yaml:"anycast_ip"`
+	ASN       uint32     `yaml:"asn"`
+	Neighbors []Neighbor `yaml:"neighbors"`
+	// GoBGPConfig дополняет конфигурацию настройками из файла конфигурации gobgpd.
+	GoBGPConfig *GoBGPConfig `yaml:"gobgp_config"`
+	// RemoteGoBGP включает управление внешним gobgpd вместо встроенного BGP сервера.
+	RemoteGoBGP *RemoteGoBGP `yaml:"remote_gobgp"`
+	// LLDPDiscovery дополняет neighbors соседями, найденными по LLDP на uplink интерфейсах.
+	LLDPDiscovery   *LLDPDiscovery `yaml:"lldp_discovery"`
+	HealthCheckURL  string         `yaml:"health_check_url"`
+	UpdateFIBMetric *uint32        `yaml:"update_fib_metric"`
+	// FIBMetrics задает priority маршрутов в ядре по семейству или префиксу, например, {ipv4: 70}
+	// или {"0.0.0.0/0": 70}, и заменяет update_fib_metric. В FIB синхронизируется только маршрут
+	// по-умолчанию IPv4, поэтому ipv6 и другие префиксы не принимаются.
+	FIBMetrics map[string]uint32 `yaml:"fib_metrics"`
+	// NextHopWeights задает вес next-hop в multipath маршруте по адресу next-hop,
+	// имеет приоритет над weight соседа.
+	NextHopWeights map[string]uint32 `yaml:"next_hop_weights"`
+	// HealthSource выбирает источник статуса здоровья, по-умолчанию http (health_check_url).
+	HealthSource HealthSource `yaml:"health_source"`
+	// Consul задает сервис Consul для health_source: consul.
+	Consul *Consul `yaml:"consul"`
+	// GRPCHealthCheck задает сервис для health_source: grpc.
+	GRPCHealthCheck *GRPCHealthCheck `yaml:"grpc_health_check"`
+	// ICMPHealthCheck задает хост для health_source: icmp.
+	ICMPHealthCheck *ICMPHealthCheck `yaml:"icmp_health_check"`
+	// FileHealthCheck задает файл-флаг для health_source: file.
+	FileHealthCheck *FileHealthCheck `yaml:"file_health_check"`
+	// MinUpSeconds это сколько секунд проверка здоровья должна непрерывно проходить,
+	// прежде чем anycast ip будет анонсирован после старта или восстановления сервиса.
+	MinUpSeconds uint32 `yaml:"min_up_seconds"`
+	// HealthCheckSchedule задает jitter и backoff проверок здоровья, которые по-умолчанию
+	// выполняются ровно раз в секунду.
+	HealthCheckSchedule *HealthCheckSchedule `yaml:"health_check_schedule"`
+	// HealthCheck задает дополнительные параметры запросов к health_check_url.
+	HealthCheck *HealthCheckConfig `yaml:"health_check"`
+	// FIBMTU задает RTAX_MTU для маршрута по-умолчанию: число или "auto",
+	// чтобы взять MTU интерфейса, через который доступен next-hop.
+	FIBMTU *RouteMTU `yaml:"fib_mtu"`
+	// FIBSrc задает адрес источника (prefsrc) маршрута по-умолчанию в ядре, например, anycast ip
+	// или адрес loopback. Адрес должен быть назначен на интерфейс хоста.
+	FIBSrc string `yaml:"fib_src"`
+	// FIBNexthopGroups включает установку multipath маршрута по-умолчанию через группу nexthop
+	// объектов, чтобы смена next-hop и весов применялась атомарно. На ядрах без nexthop объектов
+	// (старше 5.3) speaker сам возвращается к RTA_MULTIPATH.
+	FIBNexthopGroups bool `yaml:"fib_nexthop_groups"`
+	// RouteFlapDampening включает подавление нестабильных next-hop маршрута по-умолчанию в FIB.
+	RouteFlapDampening *RouteFlapDampening `yaml:"route_flap_dampening"`
+	// FIBPreference ставит в FIB только предпочтительные пути маршрута по-умолчанию вместо ECMP по всем.
+	FIBPreference *FIBPreference `yaml:"fib_preference"`
+	// MaxECMPPaths ограничивает число next-hop multipath маршрута по-умолчанию в ядре,
+	// по-умолчанию не ограничено.
+	MaxECMPPaths uint32 `yaml:"max_ecmp_paths"`
+	// NextHopTracking включает проверку next-hop маршрута по-умолчанию по маршрутам в ядре
+	// и состоянию интерфейсов, см. [Speaker.trackNextHops].
+	NextHopTracking bool `yaml:"next_hop_tracking"`
+	// FIBOpsPerSecond ограничивает число записей маршрутов и next-hop в ядро в секунду,
+	// по-умолчанию не ограничено. Записи, на которые ядро ответило EBUSY или ENOBUFS, повторяются с backoff.
+	FIBOpsPerSecond uint32 `yaml:"fib_ops_per_second"`
+	// VRF задает устройство VRF: маршрут по-умолчанию ставится в его таблицу, а не в main.
+	VRF *VRF `yaml:"vrf"`
+	// FIBHoldSeconds задает, сколько секунд держать маршрут по-умолчанию в ядре после того,
+	// как все соседи его отозвали. Если не задан, маршрут удаляется только при остановке.
+	FIBHoldSeconds *uint32 `yaml:"fib_hold_seconds"`
+	// PreserveFIBOnExit оставляет маршрут по-умолчанию в ядре при остановке speaker.
+	PreserveFIBOnExit *PreserveFIBOnExit `yaml:"preserve_fib_on_exit"`
+	// Handoff включает передачу управления новому процессу speaker при обновлении бинарника.
+	Handoff *Handoff `yaml:"handoff"`
+	// FIBConflictMode определяет поведение при старте, если в ядре уже есть маршруты speaker.
+	FIBConflictMode FIBConflictMode `yaml:"fib_conflict_mode"`
+	// PeerFlapDampening задает подавление соседей, сессия с которыми часто разрывается.
+	PeerFlapDampening *PeerFlapDampening `yaml:"peer_flap_dampening"`
+	// PeerEvents включает журнал установления и разрыва сессий с причиной разрыва.
+	PeerEvents *PeerEvents `yaml:"peer_events"`
+	// MessageCapture разрешает запись сообщений BGP в pcap через admin API.
+	MessageCapture *MessageCapture `yaml:"message_capture"`
+	// NeighborResolveIntervalSeconds это как часто заново разрешаются имена и SRV записи соседей, по-умолчанию 60.
+	NeighborResolveIntervalSeconds uint32 `yaml:"neighbor_resolve_interval_seconds"`
+	// GracefulShutdownSeconds задает, сколько секунд перед отзывом anycast ip при остановке
+	// и включении режима обслуживания анонсировать его с community GRACEFUL_SHUTDOWN (RFC 8326).
+	GracefulShutdownSeconds uint32 `yaml:"graceful_shutdown_seconds"`
+	// ShutdownMessage передается соседям в NOTIFICATION при остановке (RFC 9003).
+	ShutdownMessage string `yaml:"shutdown_message"`
+	// PolicyMode выбирает набор политик gobgp, по-умолчанию strict, а если задан policies, то custom.
+	PolicyMode PolicyMode `yaml:"policy_mode"`
+	// Policies описывает политики policy_mode: custom поверх политик strict.
+	Policies *Policies `yaml:"policies"`
+	// DefaultAction задает действие для маршрутов, которые политики strict или policies
+	// не приняли и не отклонили, по-умолчанию reject в обоих направлениях.
+	DefaultAction *DefaultAction `yaml:"default_action"`
+	// SoftFail включает режим "degraded": при неудачной проверке здоровья маршрут не отзывается,
+	// а анонсируется заново с prepend и communities.
+	SoftFail *SoftFail `yaml:"soft_fail"`
+	// AdminAddress это адрес HTTP API для управления speaker, по-умолчанию localhost:6062.
+	AdminAddress string `yaml:"admin_address"`
+	// ProbeAddress это адрес, на котором дополнительно отдаются только /healthz и /readyz,
+	// например, для probe в Kubernetes. Admin API отдает их всегда.
+	ProbeAddress string `yaml:"probe_address"`
+	// LogOutput выбирает, куда пишется лог: stderr (по-умолчанию), syslog или journald.
+	LogOutput LogOutput `yaml:"log_output"`
+	// Syslog задает адрес, facility и tag для log_output: syslog и tag для journald.
+	Syslog *SyslogOutput `yaml:"syslog"`
+	// AuditLog задает файл для записи решений об анонсе и отзыве, они также доступны через admin API.
+	AuditLog *AuditLog `yaml:"audit_log"`
+	// Hooks задает выражения, которые вычисляются раз в секунду и влияют на анонс anycast ip.
+	Hooks *Hooks `yaml:"hooks"`
+	// Kubernetes включает анонс внешних адресов сервисов типа LoadBalancer.
+	Kubernetes *kubernetes.Config `yaml:"kubernetes"`
+	// LeaderElection включает выбор лидера через Kubernetes Lease: сессии BGP поднимают все реплики,
+	// но anycast ip анонсирует только лидер.
+	LeaderElection *kubernetes.LeaderElection `yaml:"leader_election"`
+	// Verification включает проверку доступности anycast ip снаружи после анонса.
+	Verification *Verification `yaml:"verification"`
+	// LatencyBudgetMs это допустимое время от смены статуса до обновления adj-rib-out
+	// всех соседей, по-умолчанию 1000. При превышении speaker пишет предупреждение
+	// с длительностью каждого этапа.
+	LatencyBudgetMs uint32 `yaml:"latency_budget_ms"`
+	// AnycastInterface включает назначение anycast ip на интерфейс при анонсе и снятие при отзыве.
+	AnycastInterface *AnycastInterface `yaml:"anycast_interface"`
+	// GratuitousARP включает рассылку gratuitous ARP для anycast ip после его анонса.
+	GratuitousARP *GratuitousARP `yaml:"gratuitous_arp"`
+	// NextHop задает next-hop анонса anycast ip, чтобы анонсировать адрес от имени другого хоста,
+	// например, VIP на соседней машине. Если не задан, next-hop это адрес speaker.
+	NextHop string `yaml:"next_hop"`
+	// RPKI включает проверку полученных префиксов по ROA из RTR кэшей.
+	RPKI *RPKI `yaml:"rpki"`
+	// Advertise, если false, включает режим слушателя: speaker поднимает сессии и программирует
+	// полученные маршруты в ядро, но не анонсирует ни anycast ip, ни другие префиксы. По-умолчанию true.
+	Advertise *bool `yaml:"advertise"`
+	// ManageFIB, если false, включает режим только анонса: speaker не обращается к netlink
+	// и zebra, а маршруты в ядре программирует другой демон. По-умолчанию true.
+	ManageFIB *bool `yaml:"manage_fib"`
+	// FIBBackend выбирает, кто программирует маршруты в ядро, по-умолчанию netlink.
+	FIBBackend FIBBackend `yaml:"fib_backend"`
+	// Zebra задает подключение к zebra для fib_backend: zebra.
+	Zebra *Zebra `yaml:"zebra"`
+	// HealthChecks задает именованные проверки здоровья, на которые ссылаются prefixes.
+	HealthChecks map[string]HealthCheckSpec `yaml:"health_checks"`
+	// Prefixes это дополнительные префиксы со своими проверками здоровья: неудача проверки
+	// отзывает только зависящие от нее префиксы, anycast ip и остальные префиксы остаются.
+	Prefixes []AnycastPrefix `yaml:"prefixes"`
+
+	// customPolicies это политики policy_mode: custom.
+	customPolicies *customPolicies
+	// goBGPConfigImported задается, когда gobgp_config перенесен в конфигурацию, чтобы конфигурация
+	// из [LoadConfig], переданная в [New], не импортировалась дважды.
+	goBGPConfigImported bool
+}
+
+// Метод applyDefaults подставляет встроенные значения по-умолчанию (см. пакет defaults)
+// для полей, которые не заданы в конфигурации.
+func (c *Config) applyDefaults() error {
+	if c.PolicyMode == "" && c.Policies != nil {
+		c.PolicyMode = PolicyModeCustom
+	}
+	if c.PolicyMode == "" {
+		c.PolicyMode = PolicyModeStrict
+	}
+	if c.HealthSource == "" {
+		c.HealthSource = HealthSourceHTTP
+	}
+	if c.FIBBackend == "" {
+		c.FIBBackend = FIBBackendNetlink
+	}
+	if c.Advertise == nil {
+		advertise := true
+		c.Advertise = &advertise
+	}
+	if c.ManageFIB == nil {
+		manageFIB := c.RemoteGoBGP == nil
+		c.ManageFIB = &manageFIB
+	}
+	if c.FIBConflictMode == "" {
+		c.FIBConflictMode = FIBConflictModeAdopt
+	}
+	if c.AdminAddress == "" {
+		c.AdminAddress = defaults.AdminAddress
+	}
+	if c.LogOutput == "" {
+		c.LogOutput = LogOutputStderr
+	}
+	if c.LogOutput != LogOutputStderr && c.Syslog == nil {
+		c.Syslog = &SyslogOutput{}
+	}
+	if c.Syslog != nil {
+		c.Syslog.applyDefaults()
+	}
+	if c.LatencyBudgetMs == 0 {
+		c.LatencyBudgetMs = defaultLatencyBudgetMs
+	}
+	if c.RouteFlapDampening != nil {
+		c.RouteFlapDampening.applyDefaults()
+	}
+	if c.PreserveFIBOnExit != nil {
+		c.PreserveFIBOnExit.applyDefaults()
+	}
+	if c.Handoff != nil {
+		c.Handoff.applyDefaults()
+	}
+	if c.PeerEvents != nil {
+		c.PeerEvents.applyDefaults()
+	}
+	if c.MessageCapture != nil {
+		c.MessageCapture.applyDefaults()
+	}
+	if c.LLDPDiscovery != nil {
+		c.LLDPDiscovery.applyDefaults()
+	}
+	if c.NeighborResolveIntervalSeconds == 0 {
+		c.NeighborResolveIntervalSeconds = defaultNeighborResolveIntervalSeconds
+	}
+	if c.UpdateFIBMetric == nil && len(c.FIBMetrics) == 0 {
+		metric, err := defaults.FIBMetric()
+		if err != nil {
+			return err
+		}
+		c.UpdateFIBMetric = metric
+	}
+	return nil
+}
+
+// HealthCheckSchedule задает расписание проверок здоровья.
+type HealthCheckSchedule struct {
+	// JitterMs это верхняя граница случайной задержки, добавляемой к каждому интервалу.
+	JitterMs uint32 `yaml:"jitter_ms"`
+	// MaxBackoffSeconds включает экспоненциальный backoff: после каждой неудачной проверки
+	// подряд интервал удваивается, но не больше MaxBackoffSeconds. Отзыв anycast ip
+	// происходит после первой неудачной проверки, поэтому backoff замедляет только возврат.
+	MaxBackoffSeconds uint32 `yaml:"max_backoff_seconds"`
+}
+
+// Hooks это выражения на языке [text/template], которым доступны только данные [HookData]
+// и встроенные функции шаблонов, поэтому они не могут выполнить произвольный код:
+//   - AnnounceIf должно вычисляться в "true" или "false"; при "false" anycast ip не анонсируется
+//   - Communities должно вычисляться в список communities через пробел, они добавляются к анонсу
+//
+// Например, AnnounceIf: '{{ and (ge .Now.Hour 6) (ne .Health.status "degraded") }}'.
+type Hooks struct {
+	AnnounceIf  string `yaml:"announce_if"`
+	Communities string `yaml:"communities"`
+}
+
+// SoftFail задает атрибуты анонса anycast ip в режиме "degraded".
+type SoftFail struct {
+	Prepend     uint32      `yaml:"prepend"`
+	Communities []Community `yaml:"communities"`
+}
+
+// Community это BGP community в формате "ASN:VALUE".
+type Community uint32
+
+func (c *Community) UnmarshalYAML(node *yaml.Node) error {
+	community, err := ParseCommunity(node.Value)
+	if err != nil {
+		return err
+	}
+	*c = community
+	return nil
+}
+
+func ParseCommunity(s string) (Community, error) {
+	asn, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("community must be in ASN:VALUE format: %s", s)
+	}
+	hi, err := strconv.ParseUint(asn, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid community %s: %w", s, err)
+	}
+	lo, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid community %s: %w", s, err)
+	}
+	return Community(hi<<16 | lo), nil
+}
+
+func (c Community) String() string {
+	return fmt.Sprintf("%d:%d", uint32(c)>>16, uint32(c)&0xffff)
+}
+
+type Neighbor struct {
+	// Address это ip адрес соседа или имя хоста: каждый адрес имени становится отдельным соседом,
+	// см. [Speaker.ResolveNeighbors].
+	Address string `yaml:"address"`
+	// SRV задает соседей SRV записью вместо address, например, _bgp._tcp.tor.example.com:
+	// соседями становятся адреса target записей, а port используется как порт соседа.
+	SRV string `yaml:"srv"`
+	// Interface задает unnumbered соседа вместо address: его IPv6 link-local адрес ищется
+	// на интерфейсе при старте и после него, см. [Speaker.DiscoverUnnumberedNeighbors].
+	Interface   string       `yaml:"interface"`
+	ASN         uint32       `yaml:"asn"`
+	MaxPrefixes *MaxPrefixes `yaml:"max_prefixes"`
+	// Weight это вес next-hop, полученного от соседа, в multipath маршруте (от 1 до 256).
+	Weight uint32 `yaml:"weight"`
+	// FIBMetric это priority маршрута по-умолчанию в ядре, если он получен только от этого соседа,
+	// например, худший metric для резервного uplink. По-умолчанию update_fib_metric или fib_metrics.
+	FIBMetric *uint32 `yaml:"fib_metric"`
+	// LocalAddress это адрес, с которого устанавливается TCP сессия; если не задан, адрес выбирает ядро.
+	LocalAddress string `yaml:"local_address"`
+	// BindInterface привязывает сокет сессии к интерфейсу (SO_BINDTODEVICE).
+	BindInterface string `yaml:"bind_interface"`
+	// Onlink ставит next-hop, полученные от соседа, в ядро с флагом onlink через bind_interface
+	// или интерфейс маршрута до адреса соседа, если next-hop не входит в подсеть интерфейса.
+	Onlink bool `yaml:"onlink"`
+	// LocalAS задает ASN, который speaker представляет соседу вместо asn.
+	LocalAS *LocalAS `yaml:"local_as"`
+	// AllowASIn это сколько раз собственный ASN может встретиться в AS_PATH маршрута от соседа
+	// (allowas-in), например, когда на всех площадках используется один приватный ASN.
+	AllowASIn uint32 `yaml:"allowas_in"`
+	// ASOverride заменяет ASN соседа в AS_PATH анонсов ему на собственный ASN (as-override),
+	// чтобы сосед не отбрасывал их как петлю.
+	ASOverride bool `yaml:"as_override"`
+	// RemovePrivateAS удаляет (all) или заменяет на собственный ASN (replace) приватные ASN
+	// в AS_PATH анонсов соседу, например, если uplink это внешний провайдер.
+	RemovePrivateAS RemovePrivateAS `yaml:"remove_private_as"`
+	// ExtendedNexthop включает IPv4 unicast в сессии с соседом IPv6: gobgp согласует capability
+	// extended next-hop (RFC 5549/8950), и маршруты IPv4 с next-hop IPv6 ставятся в ядро через RTA_VIA.
+	// Для link-local next-hop нужен bind_interface соседа.
+	ExtendedNexthop bool `yaml:"extended_nexthop"`
+	// TrackInterface это uplink интерфейс соседа: пока он выключен или без carrier,
+	// сессия с соседом выключена, см. [Speaker.TrackInterfaces].
+	TrackInterface string `yaml:"track_interface"`
+	// NextHopSelf заставляет отправлять соседу анонсы с адресом speaker в качестве next-hop,
+	// даже если для префикса задан next_hop. Работает только с policy_mode: strict.
+	NextHopSelf bool `yaml:"next_hop_self"`
+
+	// dnsName это имя хоста или SRV запись, из которой получен адрес соседа.
+	dnsName string
+	// remotePort это порт соседа из SRV записи.
+	remotePort uint16
+	// lldpInterface это интерфейс, на котором сосед найден по LLDP.
+	lldpInterface string
+}
+
+// MaxPrefixes ограничивает количество префиксов, принимаемых от соседа:
+//   - при превышении WarningThresholdPct процентов от Limit gobgp пишет предупреждение
+//   - при превышении Limit gobgp разрывает сессию и держит соседа в состоянии PFX_CT
+//   - если RestartSeconds больше нуля, сессия будет включена заново через указанное время
+type MaxPrefixes struct {
+	Limit               uint32 `yaml:"limit"`
+	WarningThresholdPct uint32 `yaml:"warning_threshold_pct"`
+	RestartSeconds      uint32 `yaml:"restart_seconds"`
+}
+
+// PolicyMode определяет, какие политики импорта и экспорта настраивает speaker:
+//   - strict: принимается только default route от uplinks, анонсируется только anycast ip
+//   - permissive: политики не настраиваются, gobgp принимает и анонсирует все (для лабораторных стендов)
+//   - custom: политики описываются в policies или в gobgp_config
+type PolicyMode string
+
+const (
+	PolicyModeStrict     PolicyMode = "strict"
+	PolicyModePermissive PolicyMode = "permissive"
+	PolicyModeCustom     PolicyMode = "custom"
+)
+
+func (m *PolicyMode) UnmarshalYAML(node *yaml.Node) error {
+	switch mode := PolicyMode(node.Value); mode {
+	case PolicyModeStrict, PolicyModePermissive, PolicyModeCustom:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown policy_mode: %s", node.Value)
+	}
 }
 
 type LogLevel string
@@ -79,3 +440,24 @@ func (l *LogLevel) LrLevel() logrus.Level {
 		return logrus.InfoLevel
 	}
 }
+
+// RouteMTU это значение опции fib_mtu: фиксированный MTU или "auto".
+type RouteMTU struct {
+	Auto  bool
+	Value uint32
+}
+
+const routeMTUAuto = "auto"
+
+func (m *RouteMTU) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value == routeMTUAuto {
+		m.Auto = true
+		return nil
+	}
+	v, err := strconv.ParseUint(node.Value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("fib_mtu must be a number or %q: %w", routeMTUAuto, err)
+	}
+	m.Value = uint32(v)
+	return nil
+}
@@ -0,0 +1,184 @@
+package speaker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// frrSections это команды верхнего уровня frr.conf, с которых начинается следующая секция
+// или которые не могут стоять внутри router bgp.
+var frrSections = map[string]bool{
+	"router": true, "interface": true, "line": true, "vrf": true, "route-map": true,
+	"ip": true, "ipv6": true, "access-list": true, "bfd": true,
+	"segment-routing": true, "mpls": true, "nexthop-group": true, "key": true,
+	"frr": true, "hostname": true, "log": true, "service": true, "end": true,
+}
+
+// frrPeer это сосед или peer-group из секции router bgp FRR.
+type frrPeer struct {
+	name         string
+	group        bool
+	unnumbered   bool
+	remoteAS     string
+	peerGroup    string
+	localAddress string
+}
+
+// ConvertFRRConfig преобразует секцию router bgp конфигурации FRR (frr.conf или вывод
+// show running-config) в [ConfigTemplate] для переезда с FRR:
+//   - router bgp задает asn
+//   - neighbor remote-as, peer-group, update-source и interface (unnumbered) задают neighbors
+//   - первая сеть IPv4 /32 из network становится anycast_ip
+//
+// Остальные строки секции, секции router bgp в VRF и другие сети не переносятся,
+// о них возвращаются предупреждения.
+func ConvertFRRConfig(r io.Reader) (ConfigTemplate, []string, error) {
+	config := ConfigTemplate{}
+	warnings := []string{}
+	warn := func(lineNum int, format string, a ...any) {
+		warnings = append(warnings, fmt.Sprintf("line %d: ", lineNum)+fmt.Sprintf(format, a...))
+	}
+	peers := map[string]*frrPeer{}
+	order := []string{}
+	peer := func(name string) *frrPeer {
+		if p, ok := peers[name]; ok {
+			return p
+		}
+		p := &frrPeer{name: name}
+		peers[name] = p
+		order = append(order, name)
+		return p
+	}
+	inRouter, found := false, false
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "!") {
+			continue
+		}
+		// Секция заканчивается на exit или на следующей секции: отступы в frr.conf необязательны.
+		if fields[0] == "exit" || frrSections[fields[0]] {
+			inRouter = false
+		}
+		if fields[0] == "router" {
+			if len(fields) < 3 || fields[1] != "bgp" {
+				continue
+			}
+			if len(fields) > 3 {
+				warn(lineNum, "router bgp %s is skipped, only default vrf is converted", strings.Join(fields[2:], " "))
+				continue
+			}
+			if found {
+				return config, warnings, fmt.Errorf("line %d: router bgp is defined twice", lineNum)
+			}
+			asn, err := strconv.ParseUint(fields[2], 10, 32)
+			if err != nil {
+				return config, warnings, fmt.Errorf("line %d: invalid asn %q", lineNum, fields[2])
+			}
+			config.ASN = uint32(asn)
+			inRouter, found = true, true
+			continue
+		}
+		if !inRouter {
+			continue
+		}
+		switch {
+		case fields[0] == "neighbor" && len(fields) >= 3:
+			p := peer(fields[1])
+			args := fields[2:]
+			if args[0] == "interface" {
+				p.unnumbered = true
+				args = args[1:]
+			}
+			switch {
+			case len(args) == 0:
+			case args[0] == "remote-as" && len(args) == 2:
+				p.remoteAS = args[1]
+			case args[0] == "peer-group" && len(args) == 1:
+				p.group = true
+			case args[0] == "peer-group" && len(args) == 2:
+				p.peerGroup = args[1]
+			case args[0] == "update-source" && len(args) == 2 && net.ParseIP(args[1]) != nil:
+				p.localAddress = args[1]
+			case args[0] == "activate":
+			default:
+				warn(lineNum, "ignored: %s", strings.TrimSpace(line))
+			}
+		case fields[0] == "network" && len(fields) >= 2:
+			prefix, err := netip.ParsePrefix(fields[1])
+			switch {
+			case len(fields) > 2:
+				warn(lineNum, "ignored: %s, options of network are not supported", strings.TrimSpace(line))
+			case err != nil:
+				return config, warnings, fmt.Errorf("line %d: invalid network %q: %w", lineNum, fields[1], err)
+			case !prefix.Addr().Is4() || prefix.Bits() != 32:
+				warn(lineNum, "network %s is skipped, only ipv4 /32 anycast ip is supported", prefix)
+			case config.AnycastIP == "":
+				config.AnycastIP = prefix.Addr().String()
+			default:
+				warn(lineNum, "network %s is skipped, anycast_ip is already %s", prefix, config.AnycastIP)
+			}
+		case fields[0] == "address-family" || fields[0] == "exit-address-family":
+		default:
+			warn(lineNum, "ignored: %s", strings.TrimSpace(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return config, warnings, err
+	}
+	if !found {
+		return config, warnings, errors.New("router bgp is not found")
+	}
+	errs := []error{}
+	for _, name := range order {
+		p := peers[name]
+		if p.group {
+			continue
+		}
+		if group, ok := peers[p.peerGroup]; ok && group.group {
+			if p.remoteAS == "" {
+				p.remoteAS = group.remoteAS
+			}
+			if p.localAddress == "" {
+				p.localAddress = group.localAddress
+			}
+		} else if p.peerGroup != "" {
+			errs = append(errs, fmt.Errorf("neighbor %s: peer-group %s is not defined", name, p.peerGroup))
+			continue
+		}
+		n := NeighborTemplate{Address: name, LocalAddress: p.localAddress}
+		if p.unnumbered {
+			n.Address, n.Interface = "", name
+		}
+		switch p.remoteAS {
+		case "internal":
+			n.ASN = config.ASN
+		case "":
+			errs = append(errs, fmt.Errorf("neighbor %s: remote-as is not set", name))
+			continue
+		case "external":
+			errs = append(errs, fmt.Errorf("neighbor %s: remote-as external is not supported, set asn of neighbor", name))
+			continue
+		default:
+			asn, err := strconv.ParseUint(p.remoteAS, 10, 32)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("neighbor %s: invalid remote-as %q", name, p.remoteAS))
+				continue
+			}
+			n.ASN = uint32(asn)
+		}
+		config.Neighbors = append(config.Neighbors, n)
+	}
+	if config.AnycastIP == "" {
+		errs = append(errs, errors.New("no ipv4 /32 network to use as anycast_ip"))
+	}
+	warnings = append(warnings, "health_check_url is not set, anycast ip is advertised without health check")
+	return config, warnings, errors.Join(errs...)
+}
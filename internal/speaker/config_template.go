@@ -0,0 +1,102 @@
+package speaker
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"slices"
+
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigTemplate это минимальная конфигурация, которую пишут команды config init и config convert:
+// в отличие от [Config] в YAML попадают только заданные поля.
+type ConfigTemplate struct {
+	AnycastIP      string             `yaml:"anycast_ip"`
+	ASN            uint32             `yaml:"asn"`
+	Neighbors      []NeighborTemplate `yaml:"neighbors"`
+	HealthCheckURL string             `yaml:"health_check_url,omitempty"`
+}
+
+// NeighborTemplate это сосед в [ConfigTemplate].
+type NeighborTemplate struct {
+	Address      string `yaml:"address,omitempty"`
+	Interface    string `yaml:"interface,omitempty"`
+	ASN          uint32 `yaml:"asn"`
+	LocalAddress string `yaml:"local_address,omitempty"`
+}
+
+// Marshal возвращает конфигурацию в YAML, предварительно проверив ее так же, как [LoadConfig].
+func (t ConfigTemplate) Marshal() ([]byte, error) {
+	buf := bytes.NewBufferString("---\n")
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(t); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	if _, err := ParseConfig(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("generated config is invalid:\n%w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ValidateHealthCheckURL проверяет health_check_url так же, как [LoadConfig].
+func ValidateHealthCheckURL(rawURL string) error {
+	return validateHealthCheckURL(rawURL)
+}
+
+// ConfigCandidates это значения, которые config init предлагает по умолчанию.
+type ConfigCandidates struct {
+	// AnycastIPs это IPv4 адреса на loopback интерфейсах, кроме 127.0.0.0/8.
+	AnycastIPs []string
+	// Neighbors это gateway маршрутов по-умолчанию IPv4 в таблице main.
+	Neighbors []string
+}
+
+// DetectConfigCandidates ищет кандидатов в anycast ip и соседи по адресам интерфейсов
+// и таблице маршрутов ядра.
+func DetectConfigCandidates() (ConfigCandidates, error) {
+	candidates := ConfigCandidates{AnycastIPs: []string{}, Neighbors: []string{}}
+	links, err := net.Interfaces()
+	if err != nil {
+		return candidates, err
+	}
+	for _, link := range links {
+		if link.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		addrs, err := link.Addrs()
+		if err != nil {
+			return candidates, err
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLoopback() {
+				candidates.AnycastIPs = append(candidates.AnycastIPs, ipNet.IP.String())
+			}
+		}
+	}
+	routes, err := nl.ListRoutes(nl.RouteFilter{Table: rtTableMain, Family: unix.AF_INET})
+	if err != nil {
+		return candidates, err
+	}
+	for _, route := range routes {
+		if route.Dst != "default" {
+			continue
+		}
+		gateways := []string{route.Gateway}
+		for _, nh := range route.Multipath {
+			gateways = append(gateways, nh.Gateway)
+		}
+		for _, gw := range gateways {
+			if gw != "" && !slices.Contains(candidates.Neighbors, gw) {
+				candidates.Neighbors = append(candidates.Neighbors, gw)
+			}
+		}
+	}
+	return candidates, nil
+}
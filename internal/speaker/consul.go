@@ -0,0 +1,99 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConsulAddress = "http://127.0.0.1:8500"
+	consulTokenHeader    = "X-Consul-Token"
+)
+
+// HealthSource выбирает источник статуса здоровья: http (health_check_url), consul, grpc, icmp или file.
+type HealthSource string
+
+const (
+	HealthSourceHTTP   HealthSource = "http"
+	HealthSourceConsul HealthSource = "consul"
+	HealthSourceGRPC   HealthSource = "grpc"
+	HealthSourceICMP   HealthSource = "icmp"
+	HealthSourceFile   HealthSource = "file"
+)
+
+func (hs *HealthSource) UnmarshalYAML(node *yaml.Node) error {
+	switch source := HealthSource(node.Value); source {
+	case HealthSourceHTTP, HealthSourceConsul, HealthSourceGRPC, HealthSourceICMP, HealthSourceFile:
+		*hs = source
+		return nil
+	default:
+		return fmt.Errorf("unknown health_source %q, expected one of: %s, %s, %s, %s, %s",
+			node.Value, HealthSourceHTTP, HealthSourceConsul, HealthSourceGRPC, HealthSourceICMP, HealthSourceFile)
+	}
+}
+
+// Consul задает сервис локального агента Consul, статус которого определяет анонс anycast ip.
+type Consul struct {
+	// Address это адрес HTTP API агента, по-умолчанию http://127.0.0.1:8500.
+	Address string `yaml:"address"`
+	Service string `yaml:"service"`
+	Token   string `yaml:"token"`
+	// AllowWarning считает сервис здоровым, если его checks в статусе warning.
+	AllowWarning bool `yaml:"allow_warning"`
+}
+
+// NewConsulHealthCheck создает HealthCheck, который вместо health_check_url опрашивает
+// агрегированный статус checks сервиса на локальном агенте Consul.
+//
+// Агент отвечает кодом 200 для passing, 429 для warning и 503 для critical.
+func NewConsulHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error, consul Consul) (*HealthCheck, error) {
+	if consul.Service == "" {
+		return nil, fmt.Errorf("HealthCheck: consul service is not set")
+	}
+	address := consul.Address
+	if address == "" {
+		address = defaultConsulAddress
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("HealthCheck: parse consul address error: %w", err)
+	}
+	u = u.JoinPath("/v1/agent/health/service/name", consul.Service)
+	hc := newHealthCheck(cbHealthy, cbUnhealthy)
+	hc.check = func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		if consul.Token != "" {
+			req.Header.Set(consulTokenHeader, consul.Token)
+		}
+		resp, err := hc.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("HealthCheck: consul request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		if err := hc.readBody(resp); err != nil {
+			return err
+		}
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests && consul.AllowWarning:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return fmt.Errorf("HealthCheck: consul service %s is in warning state", consul.Service)
+		case resp.StatusCode == http.StatusServiceUnavailable:
+			return fmt.Errorf("HealthCheck: consul service %s is in critical state", consul.Service)
+		case resp.StatusCode == http.StatusNotFound:
+			return fmt.Errorf("HealthCheck: consul service %s is not registered on local agent", consul.Service)
+		default:
+			return fmt.Errorf("HealthCheck: unexpected consul status code: %d", resp.StatusCode)
+		}
+	}
+	return hc, nil
+}
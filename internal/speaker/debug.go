@@ -0,0 +1,132 @@
+package speaker
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const debugPathPath = "/debug/path"
+
+// AnnouncedPathDump описывает анонс anycast ip в том виде, в каком он передан в gobgp и отправлен соседям.
+type AnnouncedPathDump struct {
+	// Request это path из последнего вызова AddPath в кодировке anypb (protojson).
+	Request json.RawMessage `json:"request"`
+	// Global это path из глобальной RIB gobgp.
+	Global []WirePath `json:"global"`
+	// AdjOut это path из Adj-RIB-Out каждого соседа, т.е. после применения политик экспорта.
+	AdjOut map[string]NeighborWirePaths `json:"adj_out"`
+}
+
+type NeighborWirePaths struct {
+	Paths []WirePath `json:"paths,omitempty"`
+	Error string     `json:"error,omitempty"`
+}
+
+// WirePath это path с атрибутами в wire-формате BGP.
+type WirePath struct {
+	NlriHex    string          `json:"nlri_hex"`
+	Attributes []WireAttribute `json:"attributes"`
+}
+
+type WireAttribute struct {
+	Type    string `json:"type"`
+	Decoded string `json:"decoded"`
+	Hex     string `json:"hex"`
+}
+
+// Метод dumpAnnouncedPath собирает AnnouncedPathDump для anycast ip.
+func (sp *Speaker) dumpAnnouncedPath(ctx context.Context) (*AnnouncedPathDump, error) {
+	dump := &AnnouncedPathDump{
+		Request: json.RawMessage("null"),
+		AdjOut:  map[string]NeighborWirePaths{},
+	}
+	if path := sp.announcedPath.Load(); path != nil {
+		b, err := protojson.Marshal(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal announced path: %w", err)
+		}
+		dump.Request = b
+	}
+	global, err := sp.listWirePaths(ctx, api.TableType_GLOBAL, "")
+	if err != nil {
+		return nil, err
+	}
+	dump.Global = global
+	for _, n := range sp.neighbors() {
+		paths, err := sp.listWirePaths(ctx, api.TableType_ADJ_OUT, n.Address)
+		if err != nil {
+			dump.AdjOut[n.Address] = NeighborWirePaths{Error: err.Error()}
+			continue
+		}
+		dump.AdjOut[n.Address] = NeighborWirePaths{Paths: paths}
+	}
+	return dump, nil
+}
+
+func (sp *Speaker) listWirePaths(ctx context.Context, tableType api.TableType, name string) ([]WirePath, error) {
+	req := &api.ListPathRequest{
+		TableType: tableType,
+		Name:      name,
+		Family: &api.Family{
+			Afi:  api.Family_AFI_IP,
+			Safi: api.Family_SAFI_UNICAST,
+		},
+		Prefixes:              []*api.TableLookupPrefix{{Prefix: fmt.Sprintf("%s/32", sp.config.AnycastIP)}},
+		EnableNlriBinary:      true,
+		EnableAttributeBinary: true,
+	}
+	paths := []WirePath{}
+	var decodeErr error
+	err := sp.s.ListPath(ctx, req, func(d *api.Destination) {
+		for _, p := range d.Paths {
+			wp, err := newWirePath(p)
+			if err != nil {
+				decodeErr = err
+				return
+			}
+			paths = append(paths, wp)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bgp list path error: %w", err)
+	}
+	return paths, decodeErr
+}
+
+func newWirePath(p *api.Path) (WirePath, error) {
+	wp := WirePath{
+		NlriHex:    hex.EncodeToString(p.NlriBinary),
+		Attributes: make([]WireAttribute, 0, len(p.PattrsBinary)),
+	}
+	for _, b := range p.PattrsBinary {
+		attr, err := bgp.GetPathAttribute(b)
+		if err != nil {
+			return wp, fmt.Errorf("unknown path attribute %x: %w", b, err)
+		}
+		if err := attr.DecodeFromBytes(b); err != nil {
+			return wp, fmt.Errorf("malformed path attribute %x: %w", b, err)
+		}
+		wp.Attributes = append(wp.Attributes, WireAttribute{
+			Type:    attr.GetType().String(),
+			Decoded: attr.String(),
+			Hex:     hex.EncodeToString(b),
+		})
+	}
+	return wp, nil
+}
+
+func (sp *Speaker) handleDebugPath(w http.ResponseWriter, r *http.Request) {
+	dump, err := sp.dumpAnnouncedPath(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, dump)
+}
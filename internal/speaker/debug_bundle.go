@@ -0,0 +1,217 @@
+package speaker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	debugBundlePath = "/debug/bundle"
+	redacted        = "<redacted>"
+)
+
+// bundleFile это файл debug bundle: name это имя в архиве, collect возвращает содержимое.
+type bundleFile struct {
+	name    string
+	collect func(ctx context.Context) ([]byte, error)
+}
+
+// Метод writeDebugBundle пишет в w архив tar.gz с состоянием speaker для приложения к инциденту:
+// конфигурацию без секретов, RIB, политики, состояние соседей, маршруты ядра, последние записи
+// лога, историю проверок здоровья и решений об анонсе. Ошибки отдельных частей не прерывают сбор, а пишутся в errors.txt.
+func (sp *Speaker) writeDebugBundle(ctx context.Context, w io.Writer) error {
+	now := time.Now()
+	dir := "bgp-speaker-bundle-" + now.UTC().Format("20060102-150405")
+	files := []bundleFile{
+		{name: "config.yaml", collect: func(context.Context) ([]byte, error) {
+			return yaml.Marshal(sp.redactedConfig())
+		}},
+		{name: "status.json", collect: func(context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.adminStatus(), nil)
+		}},
+		{name: "rib-ipv4.json", collect: func(ctx context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.listRIB(ctx, RIBQuery{Family: familyIPv4}))
+		}},
+		{name: "rib-ipv6.json", collect: func(ctx context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.listRIB(ctx, RIBQuery{Family: familyIPv6}))
+		}},
+		{name: "policies.json", collect: func(ctx context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.dumpPolicies(ctx))
+		}},
+		{name: "peers.json", collect: func(ctx context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.dumpPeers(ctx))
+		}},
+		{name: "kernel-routes.json", collect: func(context.Context) ([]byte, error) {
+			return marshalBundleJSON(nl.ListRoutes(nl.RouteFilter{}))
+		}},
+		{name: "health-history.json", collect: func(context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.healthHistory(), nil)
+		}},
+		{name: "audit.json", collect: func(context.Context) ([]byte, error) {
+			return marshalBundleJSON(sp.auditEntries(), nil)
+		}},
+		{name: "logs.txt", collect: func(context.Context) ([]byte, error) {
+			lines := sp.logger.RecentLines()
+			if lines == nil {
+				return nil, fmt.Errorf("recent logs are not collected by external logger")
+			}
+			return []byte(strings.Join(lines, "")), nil
+		}},
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var collectErrs bytes.Buffer
+	for _, f := range files {
+		b, err := f.collect(ctx)
+		if err != nil {
+			fmt.Fprintf(&collectErrs, "%s: %s\n", f.name, err.Error())
+			continue
+		}
+		if err := writeTarFile(tw, dir+"/"+f.name, b, now); err != nil {
+			return err
+		}
+	}
+	if collectErrs.Len() > 0 {
+		if err := writeTarFile(tw, dir+"/errors.txt", collectErrs.Bytes(), now); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Метод redactedConfig возвращает конфигурацию с замененными секретами: токенами consul,
+// значениями заголовков проверок здоровья и паролями в URL проверок.
+func (sp *Speaker) redactedConfig() Config {
+	config := sp.config
+	config.HealthCheckURL = redactURL(config.HealthCheckURL)
+	config.Consul = redactConsul(config.Consul)
+	config.HealthCheck = redactHealthCheckConfig(config.HealthCheck)
+	if config.HealthChecks != nil {
+		checks := make(map[string]HealthCheckSpec, len(config.HealthChecks))
+		for name, spec := range config.HealthChecks {
+			spec.URL = redactURL(spec.URL)
+			spec.Consul = redactConsul(spec.Consul)
+			spec.HTTP = redactHealthCheckConfig(spec.HTTP)
+			checks[name] = spec
+		}
+		config.HealthChecks = checks
+	}
+	if config.Verification != nil {
+		verification := *config.Verification
+		verification.URLs = make([]string, len(config.Verification.URLs))
+		for i, u := range config.Verification.URLs {
+			verification.URLs[i] = redactURL(u)
+		}
+		config.Verification = &verification
+	}
+	return config
+}
+
+func redactConsul(consul *Consul) *Consul {
+	if consul == nil || consul.Token == "" {
+		return consul
+	}
+	redactedConsul := *consul
+	redactedConsul.Token = redacted
+	return &redactedConsul
+}
+
+// Функция redactHealthCheckConfig заменяет значения заголовков, например, Authorization.
+func redactHealthCheckConfig(conf *HealthCheckConfig) *HealthCheckConfig {
+	if conf == nil || len(conf.Headers) == 0 {
+		return conf
+	}
+	redactedConf := *conf
+	redactedConf.Headers = make(map[string]string, len(conf.Headers))
+	for name := range conf.Headers {
+		redactedConf.Headers[name] = redacted
+	}
+	return &redactedConf
+}
+
+// Функция redactURL заменяет userinfo URL, где бывает не только пароль, но и токен вместо
+// имени пользователя, а если URL не разбирается, заменяет его целиком.
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	if u.User == nil {
+		return rawURL
+	}
+	u.User = url.User("redacted")
+	return u.String()
+}
+
+// Метод dumpPeers возвращает соседей gobgp с состоянием сессий в кодировке protojson.
+func (sp *Speaker) dumpPeers(ctx context.Context) ([]json.RawMessage, error) {
+	peers := []json.RawMessage{}
+	var marshalErr error
+	err := sp.s.ListPeer(ctx, &api.ListPeerRequest{EnableAdvertised: true}, func(p *api.Peer) {
+		b, err := protojson.Marshal(p)
+		if err != nil {
+			marshalErr = err
+			return
+		}
+		peers = append(peers, b)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bgp list peer error: %w", err)
+	}
+	if marshalErr != nil {
+		return nil, fmt.Errorf("failed to marshal peers: %w", marshalErr)
+	}
+	return peers, nil
+}
+
+func marshalBundleJSON(v any, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(b)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
+
+func (sp *Speaker) handleDebugBundle(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := sp.writeDebugBundle(r.Context(), &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(contentTypeHeader, "application/gzip")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,182 @@
+package speaker
+
+import (
+	"context"
+	"net"
+	"slices"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const defaultNeighborResolveIntervalSeconds = 60
+
+// Метод isDNS возвращает true, если адрес соседа задан именем хоста или SRV записью.
+func (n Neighbor) isDNS() bool {
+	return n.SRV != "" || (n.Address != "" && net.ParseIP(n.Address) == nil)
+}
+
+// Метод neighbors возвращает копию списка соседей.
+func (sp *Speaker) neighbors() []Neighbor {
+	sp.neighborsMu.RLock()
+	defer sp.neighborsMu.RUnlock()
+	return slices.Clone(sp.config.Neighbors)
+}
+
+// Метод resolveDNSNeighbors заменяет в конфигурации соседей с именем хоста или SRV записью
+// на соседей с их адресами. Если имя не разрешается при старте, это пишется в лог,
+// и соседи добавятся при следующем разрешении в [Speaker.ResolveNeighbors].
+func (sp *Speaker) resolveDNSNeighbors(ctx context.Context) {
+	neighbors := []Neighbor{}
+	for _, n := range sp.config.Neighbors {
+		if n.isDNS() {
+			n.dnsName = n.Address
+			if n.SRV != "" {
+				n.dnsName = n.SRV
+			}
+			sp.dnsNeighbors = append(sp.dnsNeighbors, n)
+		} else {
+			neighbors = append(neighbors, n)
+		}
+	}
+	sp.config.Neighbors = neighbors
+	for _, tmpl := range sp.dnsNeighbors {
+		resolved, err := resolveNeighbor(ctx, tmpl)
+		if err != nil {
+			sp.logger.Warn("failed to resolve neighbor", log.Fields{"name": tmpl.dnsName, "error": err.Error()})
+			continue
+		}
+		for _, n := range resolved {
+			if !slices.ContainsFunc(sp.config.Neighbors, func(c Neighbor) bool { return sameNeighbor(c.Address, n.Address) }) {
+				sp.logger.Info("resolved neighbor", log.Fields{"name": n.dnsName, "neighbor": n.Address})
+				sp.config.Neighbors = append(sp.config.Neighbors, n)
+			}
+		}
+	}
+}
+
+// Функция resolveNeighbor возвращает соседей с адресами имени хоста или target SRV записи tmpl.
+func resolveNeighbor(ctx context.Context, tmpl Neighbor) ([]Neighbor, error) {
+	targets := []*net.SRV{{Target: tmpl.Address}}
+	if tmpl.SRV != "" {
+		var err error
+		if _, targets, err = net.DefaultResolver.LookupSRV(ctx, "", "", tmpl.SRV); err != nil {
+			return nil, err
+		}
+	}
+	neighbors := []Neighbor{}
+	for _, target := range targets {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, target.Target)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			n := tmpl
+			n.Address = addr.IP.String()
+			n.remotePort = target.Port
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors, nil
+}
+
+// ResolveNeighbors раз в neighbor_resolve_interval_seconds заново разрешает имена хостов
+// и SRV записи соседей и добавляет или удаляет соседей, если набор адресов изменился.
+// Если имя не разрешилось, соседи из него остаются как есть.
+func (sp *Speaker) ResolveNeighbors(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second * time.Duration(sp.config.NeighborResolveIntervalSeconds))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			for _, tmpl := range sp.dnsNeighbors {
+				resolved, err := resolveNeighbor(ctx, tmpl)
+				if err != nil {
+					sp.logger.Warn("failed to resolve neighbor", log.Fields{"name": tmpl.dnsName, "error": err.Error()})
+					continue
+				}
+				sp.syncDNSNeighbors(ctx, tmpl.dnsName, resolved)
+			}
+		}
+	}
+}
+
+// Метод syncDNSNeighbors приводит соседей, полученных из name, к resolved.
+func (sp *Speaker) syncDNSNeighbors(ctx context.Context, name string, resolved []Neighbor) {
+	current := sp.neighbors()
+	for _, n := range current {
+		if n.dnsName != name || slices.ContainsFunc(resolved, func(r Neighbor) bool { return sameNeighbor(r.Address, n.Address) }) {
+			continue
+		}
+		sp.logger.Info("removing neighbor that is no longer resolved", log.Fields{"name": name, "neighbor": n.Address})
+		sp.removeRuntimeNeighbor(ctx, n)
+	}
+	for _, n := range resolved {
+		if slices.ContainsFunc(current, func(c Neighbor) bool { return sameNeighbor(c.Address, n.Address) }) {
+			continue
+		}
+		sp.logger.Info("adding resolved neighbor", log.Fields{"name": name, "neighbor": n.Address})
+		sp.addRuntimeNeighbor(ctx, n)
+	}
+}
+
+// Метод addRuntimeNeighbor добавляет соседа, найденного после старта, в gobgp, defined-set
+// соседей политик и конфигурацию. Ошибка пишется в лог, и возвращается false.
+func (sp *Speaker) addRuntimeNeighbor(ctx context.Context, n Neighbor) bool {
+	sp.updateNeighborSets(ctx, n, true)
+	if err := sp.addNeighbor(ctx, n); err != nil {
+		sp.logger.Error("error adding neighbor", log.Fields{"neighbor": n.Address, "error": err.Error()})
+		sp.updateNeighborSets(ctx, n, false)
+		return false
+	}
+	// Сосед, выключенный через admin API, остается выключенным после удаления и повторного добавления.
+	if sp.peerDisabled(n.Address) {
+		if err := sp.s.DisablePeer(ctx, &api.DisablePeerRequest{Address: n.Address, Communication: defaultDisableCommunication}); err != nil {
+			sp.logger.Error("error disabling re-added neighbor", log.Fields{"neighbor": n.Address, "error": err.Error()})
+		}
+	}
+	sp.neighborsMu.Lock()
+	sp.config.Neighbors = append(sp.config.Neighbors, n)
+	sp.neighborsMu.Unlock()
+	return true
+}
+
+// Метод removeRuntimeNeighbor удаляет соседа из gobgp, defined-set соседей политик и конфигурации.
+// Ошибка пишется в лог, и возвращается false.
+func (sp *Speaker) removeRuntimeNeighbor(ctx context.Context, n Neighbor) bool {
+	if err := sp.s.DeletePeer(ctx, &api.DeletePeerRequest{Address: n.Address}); err != nil {
+		sp.logger.Error("error deleting neighbor", log.Fields{"neighbor": n.Address, "error": err.Error()})
+		return false
+	}
+	sp.updateNeighborSets(ctx, n, false)
+	sp.neighborsMu.Lock()
+	sp.config.Neighbors = slices.DeleteFunc(sp.config.Neighbors, func(c Neighbor) bool { return c.Address == n.Address })
+	sp.neighborsMu.Unlock()
+	return true
+}
+
+// Метод updateNeighborSets добавляет соседа в defined-set соседей политик или удаляет из них.
+func (sp *Speaker) updateNeighborSets(ctx context.Context, n Neighbor, add bool) {
+	if !sp.config.strictPolicySets() {
+		return
+	}
+	sets := []string{uplinks}
+	if n.NextHopSelf {
+		sets = append(sets, nextHopSelf)
+	}
+	for _, name := range sets {
+		set := &api.DefinedSet{DefinedType: api.DefinedType_NEIGHBOR, Name: name, List: []string{neighborPrefix(n.Address)}}
+		var err error
+		if add {
+			err = sp.s.AddDefinedSet(ctx, &api.AddDefinedSetRequest{DefinedSet: set})
+		} else {
+			err = sp.s.DeleteDefinedSet(ctx, &api.DeleteDefinedSetRequest{DefinedSet: set})
+		}
+		if err != nil {
+			sp.logger.Error("error updating neighbor defined-set", log.Fields{"defined_set": name, "neighbor": n.Address, "error": err.Error()})
+		}
+	}
+}
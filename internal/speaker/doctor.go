@@ -0,0 +1,223 @@
+package speaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const doctorDialTimeout = 3 * time.Second
+
+var errDoctorFailed = errors.New("some checks failed")
+
+// doctorStatus это итог одной проверки [Speaker.Doctor].
+type doctorStatus string
+
+const (
+	doctorPass doctorStatus = "PASS"
+	doctorFail doctorStatus = "FAIL"
+	doctorSkip doctorStatus = "SKIP"
+)
+
+// doctorCheck это одна строка вывода [Speaker.Doctor].
+type doctorCheck struct {
+	name   string
+	status doctorStatus
+	detail string
+}
+
+// Doctor проверяет перед запуском, что на хосте есть все, что нужно speaker с этой
+// конфигурацией: CAP_NET_ADMIN, доступ к rtnetlink, доступность TCP порта каждого соседа,
+// отсутствие в ядре чужих маршрутов с metric speaker и свободный порт gRPC API gobgp.
+// Результат каждой проверки печатается в w, если хотя бы одна не прошла, возвращается ошибка.
+// В отличие от [Speaker.Run] ничего не меняется: из netlink только читается.
+func (sp *Speaker) Doctor(ctx context.Context, w io.Writer) error {
+	checks := []doctorCheck{sp.doctorPrivileges(), sp.doctorRtnetlink()}
+	checks = append(checks, sp.doctorNeighbors(ctx)...)
+	checks = append(checks, sp.doctorFIBConflicts(), sp.doctorGRPCAddress())
+	failed := false
+	for _, c := range checks {
+		fmt.Fprintf(w, "%-4s  %-32s %s\n", c.status, c.name, c.detail)
+		failed = failed || c.status == doctorFail
+	}
+	if failed {
+		return errDoctorFailed
+	}
+	return nil
+}
+
+// Метод doctorPrivileges проверяет CAP_NET_ADMIN, без которого ядро не даст изменить
+// маршруты и адреса интерфейсов.
+func (sp *Speaker) doctorPrivileges() doctorCheck {
+	c := doctorCheck{name: "privileges"}
+	if _, ok := sp.netlinkFIBMetric(); !ok && sp.config.AnycastInterface == nil {
+		c.status, c.detail = doctorSkip, "not required: speaker does not change fib or interfaces"
+		return c
+	}
+	ok, err := nl.HasCapability(unix.CAP_NET_ADMIN)
+	switch {
+	case err != nil:
+		c.status, c.detail = doctorFail, err.Error()
+	case !ok:
+		c.status, c.detail = doctorFail, "CAP_NET_ADMIN is missing, run as root or grant the capability"
+	default:
+		c.status, c.detail = doctorPass, "CAP_NET_ADMIN"
+	}
+	return c
+}
+
+func (sp *Speaker) doctorRtnetlink() doctorCheck {
+	c := doctorCheck{name: "rtnetlink"}
+	conn, err := rtnetlink.Dial(nil)
+	if err != nil {
+		c.status, c.detail = doctorFail, err.Error()
+		return c
+	}
+	defer conn.Close()
+	links, err := conn.Link.List()
+	if err != nil {
+		c.status, c.detail = doctorFail, fmt.Sprintf("failed to list links: %s", err)
+		return c
+	}
+	c.status, c.detail = doctorPass, fmt.Sprintf("%d links", len(links))
+	return c
+}
+
+// Метод doctorNeighbors проверяет, что с каждым соседом можно установить TCP соединение
+// на порт BGP с local_address и bind_interface соседа. Имена и SRV записи соседей разрешаются,
+// а адреса unnumbered соседей ищутся так же, как при старте.
+func (sp *Speaker) doctorNeighbors(ctx context.Context) []doctorCheck {
+	checks := []doctorCheck{}
+	for _, tmpl := range sp.neighbors() {
+		neighbors := []Neighbor{tmpl}
+		name := "neighbor " + tmpl.Address
+		switch {
+		case tmpl.Interface != "":
+			name = "neighbor on " + tmpl.Interface
+			ip, err := nl.DiscoverLinkLocalNeighbor(ctx, tmpl.Interface, unnumberedDiscoveryTimeout)
+			if err != nil {
+				checks = append(checks, doctorCheck{name: name, status: doctorFail, detail: err.Error()})
+				continue
+			}
+			neighbors[0].Address = fmt.Sprintf("%s%%%s", ip, tmpl.Interface)
+			neighbors[0].BindInterface = tmpl.Interface
+		case tmpl.isDNS():
+			if tmpl.SRV != "" {
+				name = "neighbor " + tmpl.SRV
+			}
+			resolved, err := resolveNeighbor(ctx, tmpl)
+			if err != nil {
+				checks = append(checks, doctorCheck{name: name, status: doctorFail, detail: err.Error()})
+				continue
+			}
+			neighbors = resolved
+		}
+		for _, n := range neighbors {
+			checks = append(checks, doctorDialNeighbor(ctx, name, n))
+		}
+	}
+	if sp.config.LLDPDiscovery != nil {
+		checks = append(checks, doctorCheck{name: "lldp neighbors", status: doctorSkip, detail: "discovered by running speaker"})
+	}
+	return checks
+}
+
+func doctorDialNeighbor(ctx context.Context, name string, n Neighbor) doctorCheck {
+	port := uint16(bgpPort)
+	if n.remotePort != 0 {
+		port = n.remotePort
+	}
+	address := net.JoinHostPort(n.Address, strconv.Itoa(int(port)))
+	c := doctorCheck{name: name}
+	dialer := net.Dialer{Timeout: doctorDialTimeout}
+	if n.LocalAddress != "" {
+		dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(n.LocalAddress)}
+	}
+	if n.BindInterface != "" {
+		dialer.Control = func(network, address string, rc syscall.RawConn) error {
+			var err error
+			if controlErr := rc.Control(func(fd uintptr) {
+				err = unix.BindToDevice(int(fd), n.BindInterface)
+			}); controlErr != nil {
+				return controlErr
+			}
+			return err
+		}
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		c.status, c.detail = doctorFail, fmt.Sprintf("tcp %s is unreachable: %s", address, err)
+		return c
+	}
+	conn.Close()
+	c.status, c.detail = doctorPass, fmt.Sprintf("tcp %s is reachable", address)
+	return c
+}
+
+// Метод doctorFIBConflicts ищет в ядре маршруты с protocol bgp и metric speaker, как при старте,
+// см. [FIBConflictMode]. Такие маршруты остаются после аварийной остановки или принадлежат
+// другому экземпляру с той же metric.
+func (sp *Speaker) doctorFIBConflicts() doctorCheck {
+	c := doctorCheck{name: "fib conflicts"}
+	metric, ok := sp.netlinkFIBMetric()
+	if !ok {
+		c.status, c.detail = doctorSkip, "speaker does not program fib via netlink"
+		return c
+	}
+	if err := sp.resolveVRF(); err != nil {
+		c.status, c.detail = doctorFail, err.Error()
+		return c
+	}
+	conn, err := rtnetlink.Dial(nil)
+	if err != nil {
+		c.status, c.detail = doctorFail, err.Error()
+		return c
+	}
+	defer conn.Close()
+	metrics := sp.fibMetrics(metric)
+	dsts := []string{}
+	for _, m := range metrics {
+		conflicts, err := findFIBConflicts(conn, m, sp.table())
+		if err != nil {
+			c.status, c.detail = doctorFail, err.Error()
+			return c
+		}
+		for _, route := range conflicts {
+			dsts = append(dsts, fmt.Sprintf("%s metric %d", routeDst(route), m))
+		}
+	}
+	if len(dsts) > 0 {
+		c.status, c.detail = doctorFail, fmt.Sprintf("bgp routes exist (fib_conflict_mode %s): %v", sp.config.FIBConflictMode, dsts)
+		return c
+	}
+	c.status, c.detail = doctorPass, fmt.Sprintf("no bgp routes with metric %v in table %d", metrics, sp.table())
+	return c
+}
+
+// Метод doctorGRPCAddress проверяет, что адрес gRPC API встроенного gobgp не занят,
+// например, другим экземпляром speaker или gobgpd.
+func (sp *Speaker) doctorGRPCAddress() doctorCheck {
+	c := doctorCheck{name: "grpc address"}
+	if sp.config.RemoteGoBGP != nil {
+		c.status, c.detail = doctorSkip, "not used with remote_gobgp"
+		return c
+	}
+	l, err := net.Listen("tcp", defaults.GRPCAddress)
+	if err != nil {
+		c.status, c.detail = doctorFail, err.Error()
+		return c
+	}
+	l.Close()
+	c.status, c.detail = doctorPass, defaults.GRPCAddress+" is free"
+	return c
+}
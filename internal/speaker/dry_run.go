@@ -0,0 +1,96 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jsimonetti/rtnetlink"
+	bgpserver "github.com/sir-sukhov/bgp-speaker/internal/bgp"
+)
+
+// DryRun выполняет настройку speaker так же, как [Speaker.Run], но вместо вызовов gobgp
+// печатает в w запросы, которые были бы отправлены, а также план изменений FIB.
+// Из netlink только читается таблица маршрутов, чтобы показать конфликты.
+func (sp *Speaker) DryRun(w io.Writer) error {
+	ctx := context.Background()
+	sp.s = bgpserver.NewDryRun(w)
+	if sp.hooks != nil {
+		if err := sp.applyHooks(ctx); err != nil {
+			return err
+		}
+	}
+	if err := sp.setup(ctx); err != nil {
+		return err
+	}
+	if sp.healthCheckEnabled() {
+		fmt.Fprintln(w, "# anycast ip is advertised after health check succeeds:")
+		path, err := sp.anycastPath(pathAttrs{communities: sp.hookCommunities})
+		if err != nil {
+			return err
+		}
+		if err := sp.announce(ctx, path); err != nil {
+			return err
+		}
+	}
+	return sp.printFIBPlan(w)
+}
+
+func (sp *Speaker) printFIBPlan(w io.Writer) error {
+	if !sp.config.fibManaged() {
+		fmt.Fprintln(w, "# fib: not managed, manage_fib is false")
+		return nil
+	}
+	if sp.config.FIBBackend == FIBBackendZebra {
+		fmt.Fprintln(w, "# fib: managed by zebra")
+		return nil
+	}
+	metric, ok := sp.netlinkFIBMetric()
+	if !ok {
+		fmt.Fprintln(w, "# fib: not managed, no fib metric configured")
+		return nil
+	}
+	mtu := "none"
+	if sp.config.FIBMTU != nil {
+		mtu = fmt.Sprint(sp.config.FIBMTU.Value)
+		if sp.config.FIBMTU.Auto {
+			mtu = "auto"
+		}
+	}
+	src := "auto"
+	if sp.config.FIBSrc != "" {
+		src = sp.config.FIBSrc
+	}
+	table := "main"
+	if sp.config.VRF != nil {
+		table = "of vrf " + sp.config.VRF.Name
+	}
+	fmt.Fprintf(w, "# fib: replace default route from bgp rib: table %s, protocol bgp (%d), metric %d, mtu %s, src %s\n", table, protoBgp, metric, mtu, src)
+	if sp.config.FIBHoldSeconds != nil {
+		fmt.Fprintf(w, "# fib: delete default route %d seconds after it disappears from rib\n", *sp.config.FIBHoldSeconds)
+	}
+	fmt.Fprintf(w, "# fib: delete default route with metric %d on exit\n", metric)
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return fmt.Errorf("failed to read fib: %w", err)
+	}
+	defer c.Close()
+	if err := sp.resolveVRF(); err != nil {
+		return err
+	}
+	conflicts, err := findFIBConflicts(c, metric, sp.table())
+	if err != nil {
+		return err
+	}
+	for _, route := range conflicts {
+		switch {
+		case sp.config.FIBConflictMode == FIBConflictModeStrict:
+			fmt.Fprintf(w, "# fib: refuse to start, conflicting route %s\n", routeDst(route))
+		case route.DstLength == 0:
+			fmt.Fprintf(w, "# fib: adopt existing default route\n")
+		default:
+			fmt.Fprintf(w, "# fib: delete conflicting route %s\n", routeDst(route))
+		}
+	}
+	return nil
+}
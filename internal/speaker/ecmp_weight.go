@@ -0,0 +1,62 @@
+package speaker
+
+import (
+	"net/netip"
+	"slices"
+
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+const maxECMPWeight = 256
+
+// Метод nextHopWeight возвращает вес next-hop для multipath маршрута: сначала из
+// next_hop_weights по адресу next-hop, затем weight соседа, от которого получен path.
+// По-умолчанию вес 1, как у ip route ... nexthop ... weight 1.
+func (sp *Speaker) nextHopWeight(path *api.Path, gateway string) uint32 {
+	if weight, ok := sp.config.NextHopWeights[gateway]; ok && weight > 0 {
+		return weight
+	}
+	for _, n := range sp.neighbors() {
+		if n.Weight > 0 && sameNeighbor(path.NeighborIp, n.Address) {
+			return n.Weight
+		}
+	}
+	return 1
+}
+
+// Функция weightHops переводит вес в поле rtnh_hops, в котором ядро хранит вес минус один.
+func weightHops(weight uint32) uint8 {
+	return uint8(weight - 1)
+}
+
+// Метод limitECMPPaths оставляет пути не более чем max_ecmp_paths разных next-hop: next-hop
+// выбираются по возрастанию адреса, чтобы при одном и том же RIB в ядро ставились одни и те же.
+func (sp *Speaker) limitECMPPaths(paths []*api.Path) []*api.Path {
+	limit := int(sp.config.MaxECMPPaths)
+	if limit == 0 || len(paths) <= limit {
+		return paths
+	}
+	gateways := []netip.Addr{}
+	for _, path := range paths {
+		gw, err := nextHop(path)
+		if err != nil {
+			continue
+		}
+		if addr, err := netip.ParseAddr(gw); err == nil && !slices.Contains(gateways, addr) {
+			gateways = append(gateways, addr)
+		}
+	}
+	if len(gateways) <= limit {
+		return paths
+	}
+	slices.SortFunc(gateways, func(a, b netip.Addr) int { return a.Compare(b) })
+	gateways = gateways[:limit]
+	return slices.DeleteFunc(slices.Clone(paths), func(path *api.Path) bool {
+		gw, err := nextHop(path)
+		if err != nil {
+			return true
+		}
+		addr, err := netip.ParseAddr(gw)
+		return err != nil || !slices.Contains(gateways, addr)
+	})
+}
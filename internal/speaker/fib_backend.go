@@ -0,0 +1,86 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultZebraURL     = "unix:/var/run/frr/zserv.api"
+	defaultZebraVersion = 6
+)
+
+// FIBBackend определяет, кто программирует маршруты в ядро:
+//   - netlink: speaker сам синхронизирует маршрут по-умолчанию через rtnetlink (см. UpdateFIB)
+//   - zebra: gobgp передает лучшие пути в zebra из FRR, а маршруты в ядро ставит zebra
+type FIBBackend string
+
+const (
+	FIBBackendNetlink FIBBackend = "netlink"
+	FIBBackendZebra   FIBBackend = "zebra"
+)
+
+func (b *FIBBackend) UnmarshalYAML(node *yaml.Node) error {
+	switch backend := FIBBackend(node.Value); backend {
+	case FIBBackendNetlink, FIBBackendZebra:
+		*b = backend
+		return nil
+	default:
+		return fmt.Errorf("unknown fib_backend: %s", node.Value)
+	}
+}
+
+// Zebra задает подключение gobgp к zebra для fib_backend: zebra.
+type Zebra struct {
+	// URL это адрес zserv, по-умолчанию unix:/var/run/frr/zserv.api.
+	URL string `yaml:"url"`
+	// Version это версия протокола ZAPI, по-умолчанию 6 (FRR 7.5 и новее).
+	Version uint32 `yaml:"version"`
+	// SoftwareName уточняет версию FRR, например, frr7.5, если gobgp не может ее угадать по Version.
+	SoftwareName string `yaml:"software_name"`
+}
+
+// Метод fibManaged возвращает false, если задан manage_fib: false.
+func (c *Config) fibManaged() bool {
+	return c.ManageFIB == nil || *c.ManageFIB
+}
+
+// Метод netlinkFIBMetric возвращает priority маршрута по-умолчанию в ядре,
+// если его программирует сам speaker, то есть manage_fib не выключен, fib_backend это netlink,
+// metric задан и синхронизация не выключена через [Speaker.SetFIBEnabled].
+func (sp *Speaker) netlinkFIBMetric() (uint32, bool) {
+	if !sp.config.fibManaged() || sp.config.FIBBackend == FIBBackendZebra || sp.fibDisabled {
+		return 0, false
+	}
+	return sp.config.fibMetric(zeroPrefix)
+}
+
+// Метод enableZebra подключает gobgp к zebra, если выбран fib_backend: zebra.
+func (sp *Speaker) enableZebra(ctx context.Context) error {
+	if !sp.config.fibManaged() || sp.config.FIBBackend != FIBBackendZebra {
+		return nil
+	}
+	zebra := Zebra{}
+	if sp.config.Zebra != nil {
+		zebra = *sp.config.Zebra
+	}
+	if zebra.URL == "" {
+		zebra.URL = defaultZebraURL
+	}
+	if zebra.Version == 0 {
+		zebra.Version = defaultZebraVersion
+	}
+	sp.logger.Info("connecting to zebra", log.Fields{"url": zebra.URL, "version": zebra.Version})
+	if err := sp.s.EnableZebra(ctx, &api.EnableZebraRequest{
+		Url:          zebra.URL,
+		Version:      zebra.Version,
+		SoftwareName: zebra.SoftwareName,
+	}); err != nil {
+		return fmt.Errorf("error enabling zebra: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"gopkg.in/yaml.v3"
+)
+
+// FIBConflictMode определяет, что делать, если при старте в ядре уже есть маршруты
+// с protocol bgp (186) и metric speaker, например, после аварийной остановки
+// или если на хосте запущен другой экземпляр с той же metric:
+//   - adopt (по-умолчанию) забирает маршруты себе: маршрут по-умолчанию приводится
+//     в соответствие с RIB, остальные удаляются
+//   - strict отказывается стартовать, пока маршруты не будут удалены вручную
+type FIBConflictMode string
+
+const (
+	FIBConflictModeAdopt  FIBConflictMode = "adopt"
+	FIBConflictModeStrict FIBConflictMode = "strict"
+)
+
+func (m *FIBConflictMode) UnmarshalYAML(node *yaml.Node) error {
+	switch mode := FIBConflictMode(node.Value); mode {
+	case FIBConflictModeAdopt, FIBConflictModeStrict:
+		*m = mode
+		return nil
+	default:
+		return fmt.Errorf("unknown fib_conflict_mode: %s", node.Value)
+	}
+}
+
+var errFIBConflict = errors.New("kernel already has bgp routes with speaker metric")
+
+// Метод checkFIBConflicts ищет в ядре маршруты с protocol bgp и metric, которые speaker
+// считает своими, до того как speaker начнет писать в FIB.
+func (sp *Speaker) checkFIBConflicts(metric uint32) error {
+	c, err := rtnetlink.Dial(nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	conflicts, err := findFIBConflicts(c, metric, sp.table())
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	dsts := make([]string, 0, len(conflicts))
+	for _, route := range conflicts {
+		dsts = append(dsts, routeDst(route))
+	}
+	if sp.config.FIBConflictMode == FIBConflictModeStrict {
+		return fmt.Errorf("%w: metric %d, routes %v", errFIBConflict, metric, dsts)
+	}
+	sp.logger.Warn("adopting existing bgp routes with speaker metric", log.Fields{"metric": metric, "routes": dsts})
+	for _, route := range conflicts {
+		if route.DstLength == 0 {
+			continue
+		}
+		if _, err := c.Execute(route, deleteRoute, netlink.Request|netlink.Acknowledge); err != nil {
+			return fmt.Errorf("failed to delete adopted route %s: %w", routeDst(route), err)
+		}
+	}
+	return nil
+}
+
+// Функция findFIBConflicts возвращает маршруты таблицы table с protocol bgp и metric.
+func findFIBConflicts(c *rtnetlink.Conn, metric, table uint32) ([]*rtnetlink.RouteMessage, error) {
+	msgs, err := c.Execute(&rtnetlink.RouteMessage{}, getRoute, netlink.Request|netlink.Dump)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table of routes: %w", err)
+	}
+	conflicts := []*rtnetlink.RouteMessage{}
+	for i := range msgs {
+		route, ok := msgs[i].(*rtnetlink.RouteMessage)
+		if !ok {
+			return nil, fmt.Errorf("unexpected rtnetlink message: %w", errors.ErrUnsupported)
+		}
+		if route.Protocol == protoBgp && routeTable(route) == table && route.Family == familyAfInet && route.Attributes.Priority == metric {
+			conflicts = append(conflicts, route)
+		}
+	}
+	return conflicts, nil
+}
+
+func routeDst(route *rtnetlink.RouteMessage) string {
+	dst := route.Attributes.Dst
+	if dst == nil {
+		dst = net.IPv4zero
+	}
+	return (&net.IPNet{IP: dst, Mask: net.CIDRMask(int(route.DstLength), 32)}).String()
+}
@@ -0,0 +1,48 @@
+package speaker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+// Метод fibDesiredState возвращает ключ нужного состояния маршрута по-умолчанию по paths из RIB:
+// next-hop, их веса и metric. UpdateFIB каждую секунду сверяет маршрут в ядре с RIB и ставит его
+// заново, если они различаются; если при этом RIB не изменился с прошлой успешной установки,
+// значит маршрут в ядре изменил кто-то другой, и это считается дрейфом.
+func (sp *Speaker) fibDesiredState(paths []*api.Path) string {
+	hops := []string{}
+	for _, path := range paths {
+		gw, err := nextHop(path)
+		if err != nil {
+			return ""
+		}
+		hops = append(hops, fmt.Sprintf("%s*%d", gw, sp.nextHopWeight(path, gw)))
+	}
+	slices.Sort(hops)
+	return fmt.Sprintf("%s metric %d", strings.Join(hops, ","), sp.pathFIBMetric(paths))
+}
+
+// Метод reconcileDefaultRoute ставит маршрут по-умолчанию через set и считает дрейф: запись в ядро
+// при неизменном RIB увеличивает fib_drift_detected_total, а успешная запись - fib_drift_repaired_total.
+func (sp *Speaker) reconcileDefaultRoute(paths []*api.Path, set func() error) error {
+	desired := sp.fibDesiredState(paths)
+	writes := sp.fibWrites
+	err := set()
+	if desired != "" && desired == sp.fibDesired && sp.fibWrites != writes {
+		sp.fibDriftDetected.Add(1)
+		if err == nil {
+			sp.fibDriftRepaired.Add(1)
+			sp.logger.Warn("kernel default route was modified externally, repaired", log.Fields{"desired": desired})
+		} else {
+			sp.logger.Warn("kernel default route was modified externally", log.Fields{"desired": desired})
+		}
+	}
+	if err == nil {
+		sp.fibDesired = desired
+	}
+	return err
+}
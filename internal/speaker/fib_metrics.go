@@ -0,0 +1,85 @@
+package speaker
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+)
+
+const (
+	fibFamilyIPv4 = "ipv4"
+	fibFamilyIPv6 = "ipv6"
+)
+
+// Метод fibMetric возвращает priority маршрута в ядре для prefix: сначала ищется ключ
+// fib_metrics с самим префиксом, затем ключ семейства (ipv4 или ipv6), а для маршрута
+// по-умолчанию IPv4 также update_fib_metric. Если ничего не задано, префикс не синхронизируется в FIB.
+func (c *Config) fibMetric(prefix string) (uint32, bool) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return 0, false
+	}
+	if metric, ok := c.FIBMetrics[p.Masked().String()]; ok {
+		return metric, true
+	}
+	family := fibFamilyIPv4
+	if p.Addr().Is6() {
+		family = fibFamilyIPv6
+	}
+	if metric, ok := c.FIBMetrics[family]; ok {
+		return metric, true
+	}
+	if family == fibFamilyIPv4 && c.UpdateFIBMetric != nil {
+		return *c.UpdateFIBMetric, true
+	}
+	return 0, false
+}
+
+// Метод validateFIBMetrics проверяет, что ключи fib_metrics это семейство или префикс,
+// которые синхронизируются в FIB, и что для одного маршрута не заданы несколько metric,
+// например, через ipv4 и 0.0.0.0/0 или через update_fib_metric и fib_metrics одновременно.
+//
+// В FIB ставится только маршрут по-умолчанию IPv4, поэтому ключ ipv6 и другие префиксы
+// отклоняются, чтобы они не игнорировались молча.
+func (c *Config) validateFIBMetrics() error {
+	targets := map[string][]string{}
+	keys := make([]string, 0, len(c.FIBMetrics))
+	for key := range c.FIBMetrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case fibFamilyIPv4:
+			targets["0.0.0.0/0"] = append(targets["0.0.0.0/0"], "fib_metrics."+key)
+		case fibFamilyIPv6:
+			return fmt.Errorf("fib_metrics: key %s is not supported, only %s default route is synchronized to fib", key, fibFamilyIPv4)
+		default:
+			p, err := netip.ParsePrefix(key)
+			if err != nil {
+				return fmt.Errorf("fib_metrics: key %q is neither %s, %s nor prefix: %w", key, fibFamilyIPv4, fibFamilyIPv6, err)
+			}
+			if p.Masked() != p {
+				return fmt.Errorf("fib_metrics: prefix %s has host bits set, use %s", key, p.Masked())
+			}
+			if p.String() != zeroPrefix {
+				return fmt.Errorf("fib_metrics: prefix %s is not supported, only %s is synchronized to fib", key, zeroPrefix)
+			}
+			targets[p.String()] = append(targets[p.String()], "fib_metrics."+key)
+		}
+	}
+	if c.UpdateFIBMetric != nil {
+		targets[zeroPrefix] = append(targets[zeroPrefix], "update_fib_metric")
+	}
+	prefixes := make([]string, 0, len(targets))
+	for prefix := range targets {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if len(targets[prefix]) > 1 {
+			return fmt.Errorf("fib metric for %s is set more than once: %v", prefix, targets[prefix])
+		}
+	}
+	return nil
+}
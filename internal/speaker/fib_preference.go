@@ -0,0 +1,82 @@
+package speaker
+
+import (
+	"errors"
+	"slices"
+
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+// FIBPreference выбирает, какие из полученных путей маршрута по-умолчанию ставить в FIB,
+// вместо ECMP по всем путям, например, только основной uplink, пока его путь есть в RIB:
+//   - Communities это community в порядке предпочтения: в FIB попадают только пути с первой
+//     из них, которая есть хотя бы у одного пути, если ее нет ни у одного, то все пути
+//   - ShortestASPath оставляет из этих путей только пути с самым коротким AS_PATH
+type FIBPreference struct {
+	Communities    []Community `yaml:"communities"`
+	ShortestASPath bool        `yaml:"shortest_as_path"`
+}
+
+func (p *FIBPreference) validate() error {
+	if len(p.Communities) == 0 && !p.ShortestASPath {
+		return errors.New("fib_preference: communities or shortest_as_path is required")
+	}
+	return nil
+}
+
+// Метод preferPaths возвращает пути, которые выбирает fib_preference. Результат не бывает пустым,
+// если paths не пуст: предпочтение только сужает выбор, но не оставляет хост без маршрута.
+func (sp *Speaker) preferPaths(paths []*api.Path) []*api.Path {
+	pref := sp.config.FIBPreference
+	if pref == nil || len(paths) < 2 {
+		return paths
+	}
+	for _, c := range pref.Communities {
+		preferred := slices.DeleteFunc(slices.Clone(paths), func(path *api.Path) bool {
+			return !slices.Contains(pathCommunities(path), uint32(c))
+		})
+		if len(preferred) > 0 {
+			paths = preferred
+			break
+		}
+	}
+	if pref.ShortestASPath {
+		shortest := slices.MinFunc(paths, func(a, b *api.Path) int { return asPathLength(a) - asPathLength(b) })
+		paths = slices.DeleteFunc(slices.Clone(paths), func(path *api.Path) bool {
+			return asPathLength(path) > asPathLength(shortest)
+		})
+	}
+	return paths
+}
+
+func pathCommunities(path *api.Path) []uint32 {
+	attr := new(api.CommunitiesAttribute)
+	for _, a := range path.Pattrs {
+		if a.MessageIs(attr) && a.UnmarshalTo(attr) == nil {
+			return attr.Communities
+		}
+	}
+	return nil
+}
+
+// Функция asPathLength считает длину AS_PATH, как при выборе лучшего пути (RFC 4271):
+// AS_SET считается за одну AS, сегменты конфедерации не считаются.
+func asPathLength(path *api.Path) int {
+	attr := new(api.AsPathAttribute)
+	for _, a := range path.Pattrs {
+		if !a.MessageIs(attr) || a.UnmarshalTo(attr) != nil {
+			continue
+		}
+		length := 0
+		for _, segment := range attr.Segments {
+			switch segment.Type {
+			case api.AsSegment_AS_SET:
+				length++
+			case api.AsSegment_AS_SEQUENCE:
+				length += len(segment.Numbers)
+			}
+		}
+		return length
+	}
+	return 0
+}
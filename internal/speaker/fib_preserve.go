@@ -0,0 +1,60 @@
+package speaker
+
+import (
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const defaultPreserveFIBGraceSeconds = 60
+
+// PreserveFIBOnExit оставляет маршрут по-умолчанию speaker в ядре при остановке вместо удаления,
+// чтобы при обновлении бинарника хост не терял связность, пока новый процесс устанавливает сессии.
+// Новый процесс забирает маршрут себе (fib_conflict_mode: adopt) и заменяет его при первом
+// обновлении FIB, а если за GraceSeconds после старта default route так и не появился в RIB,
+// удаляет его, даже если fib_hold_seconds не задан. По-умолчанию GraceSeconds 60.
+type PreserveFIBOnExit struct {
+	GraceSeconds uint32 `yaml:"grace_seconds"`
+}
+
+func (p *PreserveFIBOnExit) applyDefaults() {
+	if p.GraceSeconds == 0 {
+		p.GraceSeconds = defaultPreserveFIBGraceSeconds
+	}
+}
+
+// Метод startPreservedRouteGrace вызывается при старте UpdateFIB: если в ядре остался маршрут
+// по-умолчанию предыдущего процесса, он держится до preservedRouteDeadline. После handoff
+// маршрут держится restart_time_seconds, пока соседи заново присылают свои маршруты.
+func (sp *Speaker) startPreservedRouteGrace() error {
+	var grace uint32
+	switch {
+	case sp.config.PreserveFIBOnExit != nil:
+		grace = sp.config.PreserveFIBOnExit.GraceSeconds
+	case sp.tookOver != nil:
+		grace = sp.config.Handoff.RestartTimeSeconds
+	default:
+		return nil
+	}
+	route, err := sp.getLinuxBGPDefaultRoute()
+	if err != nil || route == nil {
+		return err
+	}
+	sp.preservedRouteDeadline = time.Now().Add(time.Second * time.Duration(grace))
+	sp.logger.Info("found preserved kernel default route", log.Fields{"grace_seconds": grace})
+	return nil
+}
+
+// Метод holdPreservedRoute держит маршрут предыдущего процесса, пока не истек grace_seconds,
+// и удаляет его после этого. Возвращает false, если такого маршрута нет.
+func (sp *Speaker) holdPreservedRoute() (bool, error) {
+	if sp.preservedRouteDeadline.IsZero() {
+		return false, nil
+	}
+	if time.Now().Before(sp.preservedRouteDeadline) {
+		return true, nil
+	}
+	sp.preservedRouteDeadline = time.Time{}
+	sp.logger.Warn("grace period of preserved default route expired, removing kernel default route", nil)
+	return true, sp.cleanupDefaultRoute()
+}
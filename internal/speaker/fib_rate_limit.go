@@ -0,0 +1,142 @@
+package speaker
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/mdlayher/netlink"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	// fibRetries это сколько раз повторяется запись в ядро, на которую ядро ответило EBUSY или ENOBUFS.
+	fibRetries      = 5
+	fibRetryBackoff = 10 * time.Millisecond
+	// fibBatchSize это сколько сообщений executeFIBBatch отправляет одним sendmsg, чтобы подтверждения
+	// ядра поместились в буфер сокета.
+	fibBatchSize = 64
+)
+
+// Метод executeFIB выполняет запись маршрута или next-hop в ядро через op не чаще fib_ops_per_second
+// в секунду и повторяет ее с экспоненциальным backoff, если ядро ответило EBUSY или ENOBUFS.
+// Ожидание прерывается отменой контекста UpdateFIB.
+func (sp *Speaker) executeFIB(op func() error) error {
+	backoff := fibRetryBackoff
+	for attempt := 0; ; attempt++ {
+		sp.waitFIBRateLimit(1)
+		err := op()
+		if err == nil || attempt == fibRetries || !fibRetryable(err) {
+			return err
+		}
+		sp.logs.Warn(sp.logger, "kernel is busy, retrying fib operation", log.Fields{"error": err.Error(), "backoff": backoff.String()})
+		if !sp.sleepFIB(backoff) {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// Метод executeFIBBatch отправляет msgs в ядро через conn пачками по fibBatchSize сообщений
+// в одном sendmsg и возвращает ошибку каждого сообщения, на которое ядро ответило ошибкой.
+// Сообщения с ответом EBUSY или ENOBUFS повторяются так же, как в executeFIB.
+func (sp *Speaker) executeFIBBatch(conn *netlink.Conn, msgs []netlink.Message) []error {
+	errs := make([]error, len(msgs))
+	pending := make([]int, len(msgs))
+	for i := range msgs {
+		msgs[i].Header.Flags |= netlink.Request | netlink.Acknowledge
+		pending[i] = i
+	}
+	backoff := fibRetryBackoff
+	for attempt := 0; len(pending) > 0; attempt++ {
+		retry := []int{}
+		for start := 0; start < len(pending); start += fibBatchSize {
+			batch := pending[start:min(start+fibBatchSize, len(pending))]
+			sp.waitFIBRateLimit(len(batch))
+			for _, i := range batch {
+				errs[i] = nil
+			}
+			if err := sendFIBBatch(conn, msgs, batch, errs); err != nil {
+				for _, i := range batch {
+					errs[i] = err
+				}
+				return errs
+			}
+			for _, i := range batch {
+				if errs[i] != nil && fibRetryable(errs[i]) {
+					retry = append(retry, i)
+				}
+			}
+		}
+		if len(retry) == 0 || attempt == fibRetries {
+			break
+		}
+		sp.logs.Warn(sp.logger, "kernel is busy, retrying fib operations", log.Fields{"error": errs[retry[0]].Error(), "count": len(retry), "backoff": backoff.String()})
+		if !sp.sleepFIB(backoff) {
+			break
+		}
+		backoff *= 2
+		pending = retry
+	}
+	return errs
+}
+
+// Функция sendFIBBatch отправляет сообщения msgs с индексами batch и читает по одному подтверждению
+// на каждое: ядро обрабатывает сообщения по порядку и отвечает на каждое отдельным NLMSG_ERROR,
+// ошибка ответа записывается в errs. Возвращается ошибка отправки или чтения из сокета.
+func sendFIBBatch(conn *netlink.Conn, msgs []netlink.Message, batch []int, errs []error) error {
+	out := make([]netlink.Message, 0, len(batch))
+	for _, i := range batch {
+		out = append(out, msgs[i])
+	}
+	if _, err := conn.SendMessages(out); err != nil {
+		return err
+	}
+	for _, i := range batch {
+		if _, err := conn.Receive(); err != nil {
+			// Ошибка из ответа ядра это syscall.Errno без os.SyscallError, как у ошибок сокета.
+			var opErr *netlink.OpError
+			if !errors.As(err, &opErr) {
+				return err
+			}
+			if _, ok := opErr.Err.(syscall.Errno); !ok {
+				return err
+			}
+			errs[i] = err
+		}
+	}
+	return nil
+}
+
+func fibRetryable(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// Метод waitFIBRateLimit ждет, пока с прошлой записи в ядро не пройдет n/fib_ops_per_second секунды.
+func (sp *Speaker) waitFIBRateLimit(n int) {
+	if sp.config.FIBOpsPerSecond == 0 {
+		return
+	}
+	interval := time.Duration(n) * time.Second / time.Duration(sp.config.FIBOpsPerSecond)
+	if wait := time.Until(sp.fibLastOp.Add(interval)); wait > 0 {
+		sp.sleepFIB(wait)
+	}
+	sp.fibLastOp = time.Now()
+}
+
+// Метод sleepFIB ждет d и возвращает false, если раньше отменен контекст UpdateFIB.
+func (sp *Speaker) sleepFIB(d time.Duration) bool {
+	ctx := sp.fibCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
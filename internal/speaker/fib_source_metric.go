@@ -0,0 +1,48 @@
+package speaker
+
+import (
+	"slices"
+
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+// Метод pathFIBMetric возвращает priority маршрута по-умолчанию в ядре для paths: fib_metric
+// соседа, от которого получен path, или общий metric, если у соседа fib_metric не задан.
+// Для multipath маршрута берется наименьший metric, то есть маршрут получает metric резервного
+// uplink, только если все его next-hop получены от резервных соседей.
+func (sp *Speaker) pathFIBMetric(paths []*api.Path) uint32 {
+	metric := sp.linuxRouteMetric
+	for i, path := range paths {
+		m := sp.linuxRouteMetric
+		if n := sp.pathNeighbor(path); n != nil && n.FIBMetric != nil {
+			m = *n.FIBMetric
+		}
+		if i == 0 || m < metric {
+			metric = m
+		}
+	}
+	return metric
+}
+
+// Метод pathNeighbor возвращает соседа из конфигурации, от которого получен path.
+func (sp *Speaker) pathNeighbor(path *api.Path) *Neighbor {
+	neighbors := sp.neighbors()
+	for i := range neighbors {
+		if sameNeighbor(path.NeighborIp, neighbors[i].Address) {
+			return &neighbors[i]
+		}
+	}
+	return nil
+}
+
+// Метод fibMetrics возвращает все metric, с которыми speaker ставит маршрут по-умолчанию:
+// metric и fib_metric соседей, в том числе заданных именем хоста или SRV записью.
+func (sp *Speaker) fibMetrics(metric uint32) []uint32 {
+	metrics := []uint32{metric}
+	for _, n := range append(sp.neighbors(), sp.dnsNeighbors...) {
+		if n.FIBMetric != nil && !slices.Contains(metrics, *n.FIBMetric) {
+			metrics = append(metrics, *n.FIBMetric)
+		}
+	}
+	return metrics
+}
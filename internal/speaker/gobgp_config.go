@@ -0,0 +1,439 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	api "github.com/osrg/gobgp/v3/api"
+	gobgpconfig "github.com/osrg/gobgp/v3/pkg/config"
+	"github.com/osrg/gobgp/v3/pkg/config/oc"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+)
+
+// GoBGPConfig задает файл конфигурации gobgpd, из которого берутся asn, соседи, RPKI кэши
+// и политики, чтобы перейти на speaker с gobgpd, не переписывая конфигурацию:
+//   - global.config.as заполняет asn, а если asn задан, должен с ним совпадать;
+//     router-id не переносится, speaker использует anycast_ip
+//   - neighbors добавляются к neighbors, адрес или neighbor-interface, peer-as, local-as,
+//     remove-private-as, allow-own-as, replace-peer-as, transport и prefix-limit ipv4-unicast
+//     переносятся в соответствующие поля [Neighbor]
+//   - rpki-servers заполняют rpki.caches, если rpki не задан
+//   - defined-sets, policy-definitions и global.apply-policy становятся политиками
+//     policy_mode: custom, который включается по-умолчанию, если в файле есть политики
+//
+// Настройки, которые speaker не может перенести без изменения поведения (peer-groups,
+// dynamic-neighbors, vrfs, auth-password, ebgp-multihop, route-reflector, route-server
+// и другие), считаются ошибкой.
+type GoBGPConfig struct {
+	Path string `yaml:"path"`
+	// Format это toml, yaml или json, по-умолчанию определяется по расширению файла,
+	// а для файла без расширения это toml.
+	Format string `yaml:"format"`
+}
+
+// customPolicies это политики policy_mode: custom в формате gobgp API.
+type customPolicies struct {
+	definedSets []*api.DefinedSet
+	policies    []*api.Policy
+	assignments []*api.PolicyAssignment
+}
+
+// Метод importGoBGPConfig переносит в конфигурацию настройки из gobgp_config один раз.
+func (c *Config) importGoBGPConfig() error {
+	if c.GoBGPConfig == nil || c.goBGPConfigImported {
+		return nil
+	}
+	if c.GoBGPConfig.Path == "" {
+		return errors.New("gobgp_config.path: is required")
+	}
+	format, err := c.GoBGPConfig.format()
+	if err != nil {
+		return err
+	}
+	set, err := gobgpconfig.ReadConfigFile(c.GoBGPConfig.Path, format)
+	if err != nil {
+		return fmt.Errorf("gobgp_config: %w", err)
+	}
+	errs := []error{}
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("gobgp_config: "+format, args...))
+	}
+	for name, unsupported := range map[string]bool{
+		"peer-groups":       len(set.PeerGroups) > 0,
+		"dynamic-neighbors": len(set.DynamicNeighbors) > 0,
+		"vrfs":              len(set.Vrfs) > 0,
+		"bmp-servers":       len(set.BmpServers) > 0,
+		"mrt-dump":          len(set.MrtDump) > 0,
+		"zebra":             set.Zebra.Config.Enabled,
+		"collector":         set.Collector.Config.Url != "",
+	} {
+		if unsupported {
+			add("%s are not supported", name)
+		}
+	}
+	if as := set.Global.Config.As; c.ASN == 0 {
+		c.ASN = as
+	} else if as != 0 && as != c.ASN {
+		add("global.config.as %d differs from asn %d", as, c.ASN)
+	}
+	for _, n := range set.Neighbors {
+		neighbor, err := c.goBGPNeighbor(n, set.Global.Config.As)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !slices.ContainsFunc(c.Neighbors, func(existing Neighbor) bool {
+			return neighbor.Address != "" && sameNeighbor(existing.Address, neighbor.Address) ||
+				neighbor.Interface != "" && existing.Interface == neighbor.Interface
+		}) {
+			c.Neighbors = append(c.Neighbors, neighbor)
+		}
+	}
+	if len(set.RpkiServers) > 0 && c.RPKI == nil {
+		c.RPKI = &RPKI{}
+		for _, s := range set.RpkiServers {
+			c.RPKI.Caches = append(c.RPKI.Caches, net.JoinHostPort(s.Config.Address, strconv.Itoa(int(s.Config.Port))))
+		}
+	}
+	if len(set.PolicyDefinitions) > 0 {
+		switch c.PolicyMode {
+		case "":
+			c.PolicyMode = PolicyModeCustom
+		case PolicyModeCustom:
+		default:
+			add("policy-definitions require policy_mode: %s", PolicyModeCustom)
+		}
+		policies, err := goBGPPolicies(set)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		c.customPolicies = policies
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	c.goBGPConfigImported = true
+	return nil
+}
+
+// Метод format возвращает формат файла из format или расширения path.
+func (g *GoBGPConfig) format() (string, error) {
+	format := g.Format
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(g.Path), ".")
+	}
+	switch format {
+	case "":
+		return "toml", nil
+	case "toml", "yaml", "json":
+		return format, nil
+	case "yml":
+		return "yaml", nil
+	default:
+		return "", fmt.Errorf("gobgp_config.format: %q is not supported, use toml, yaml or json", format)
+	}
+}
+
+// Метод goBGPNeighbor переносит настройки соседа gobgpd в [Neighbor].
+func (c *Config) goBGPNeighbor(n oc.Neighbor, globalAS uint32) (Neighbor, error) {
+	address := n.Config.NeighborAddress
+	if address == "" {
+		address = n.Config.NeighborInterface
+	}
+	errs := []error{}
+	unsupported := func(option string) {
+		errs = append(errs, fmt.Errorf("gobgp_config: neighbor %s: %s is not supported", address, option))
+	}
+	if n.Config.AuthPassword != "" {
+		unsupported("auth-password")
+	}
+	if n.Config.PeerGroup != "" {
+		unsupported("peer-group")
+	}
+	if n.Config.Vrf != "" {
+		unsupported("vrf")
+	}
+	if n.EbgpMultihop.Config.Enabled {
+		unsupported("ebgp-multihop")
+	}
+	if n.RouteReflector.Config.RouteReflectorClient {
+		unsupported("route-reflector")
+	}
+	if n.RouteServer.Config.RouteServerClient {
+		unsupported("route-server")
+	}
+	if n.Transport.Config.PassiveMode {
+		unsupported("passive-mode")
+	}
+	neighbor := Neighbor{
+		ASN:             n.Config.PeerAs,
+		LocalAddress:    n.Transport.Config.LocalAddress,
+		BindInterface:   n.Transport.Config.BindInterface,
+		AllowASIn:       uint32(n.AsPathOptions.Config.AllowOwnAs),
+		ASOverride:      n.AsPathOptions.Config.ReplacePeerAs,
+		RemovePrivateAS: RemovePrivateAS(n.Config.RemovePrivateAs),
+		remotePort:      n.Transport.Config.RemotePort,
+	}
+	if n.Config.NeighborInterface != "" {
+		neighbor.Interface = n.Config.NeighborInterface
+	} else {
+		neighbor.Address = n.Config.NeighborAddress
+	}
+	// Значения по-умолчанию gobgpd подставляют local-as равным global.config.as.
+	if n.Config.LocalAs != 0 && n.Config.LocalAs != globalAS && n.Config.LocalAs != c.ASN {
+		neighbor.LocalAS = &LocalAS{ASN: n.Config.LocalAs}
+	}
+	if neighbor.LocalAddress == "0.0.0.0" || neighbor.LocalAddress == "::" {
+		neighbor.LocalAddress = ""
+	}
+	if neighbor.remotePort == bgp.BGP_PORT {
+		neighbor.remotePort = 0
+	}
+	for _, afiSafi := range n.AfiSafis {
+		if afiSafi.Config.AfiSafiName != oc.AFI_SAFI_TYPE_IPV4_UNICAST {
+			continue
+		}
+		if limit := afiSafi.PrefixLimit.Config; limit.MaxPrefixes > 0 {
+			neighbor.MaxPrefixes = &MaxPrefixes{
+				Limit:               limit.MaxPrefixes,
+				WarningThresholdPct: uint32(limit.ShutdownThresholdPct),
+				RestartSeconds:      uint32(limit.RestartTimer),
+			}
+		}
+		if ip := net.ParseIP(neighbor.Address); ip != nil && ip.To4() == nil {
+			neighbor.ExtendedNexthop = true
+		}
+	}
+	return neighbor, errors.Join(errs...)
+}
+
+// Функция goBGPPolicies переводит defined-sets, policy-definitions и global.apply-policy
+// gobgpd в формат gobgp API.
+func goBGPPolicies(set *oc.BgpConfigSet) (*customPolicies, error) {
+	policies := &customPolicies{}
+	errs := []error{}
+	for _, s := range set.DefinedSets.PrefixSets {
+		ds := &api.DefinedSet{DefinedType: api.DefinedType_PREFIX, Name: s.PrefixSetName}
+		for _, p := range s.PrefixList {
+			prefix, err := goBGPPrefix(p)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("gobgp_config: prefix-set %s: %w", s.PrefixSetName, err))
+				continue
+			}
+			ds.Prefixes = append(ds.Prefixes, prefix)
+		}
+		policies.definedSets = append(policies.definedSets, ds)
+	}
+	for _, s := range set.DefinedSets.NeighborSets {
+		policies.definedSets = append(policies.definedSets, &api.DefinedSet{DefinedType: api.DefinedType_NEIGHBOR, Name: s.NeighborSetName, List: s.NeighborInfoList})
+	}
+	bgpSets := set.DefinedSets.BgpDefinedSets
+	for _, s := range bgpSets.CommunitySets {
+		policies.definedSets = append(policies.definedSets, &api.DefinedSet{DefinedType: api.DefinedType_COMMUNITY, Name: s.CommunitySetName, List: s.CommunityList})
+	}
+	for _, s := range bgpSets.ExtCommunitySets {
+		policies.definedSets = append(policies.definedSets, &api.DefinedSet{DefinedType: api.DefinedType_EXT_COMMUNITY, Name: s.ExtCommunitySetName, List: s.ExtCommunityList})
+	}
+	for _, s := range bgpSets.AsPathSets {
+		policies.definedSets = append(policies.definedSets, &api.DefinedSet{DefinedType: api.DefinedType_AS_PATH, Name: s.AsPathSetName, List: s.AsPathList})
+	}
+	for _, s := range bgpSets.LargeCommunitySets {
+		policies.definedSets = append(policies.definedSets, &api.DefinedSet{DefinedType: api.DefinedType_LARGE_COMMUNITY, Name: s.LargeCommunitySetName, List: s.LargeCommunityList})
+	}
+	if len(set.DefinedSets.TagSets) > 0 {
+		errs = append(errs, errors.New("gobgp_config: tag-sets are not supported"))
+	}
+	byName := map[string]*api.Policy{}
+	for _, p := range set.PolicyDefinitions {
+		policy := &api.Policy{Name: p.Name}
+		for _, st := range p.Statements {
+			statement, err := goBGPStatement(st)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("gobgp_config: policy %s: statement %s: %w", p.Name, st.Name, err))
+				continue
+			}
+			policy.Statements = append(policy.Statements, statement)
+		}
+		policies.policies = append(policies.policies, policy)
+		byName[p.Name] = policy
+	}
+	apply := set.Global.ApplyPolicy.Config
+	for _, a := range []struct {
+		direction     api.PolicyDirection
+		names         []string
+		defaultAction oc.DefaultPolicyType
+	}{
+		{api.PolicyDirection_IMPORT, apply.ImportPolicyList, apply.DefaultImportPolicy},
+		{api.PolicyDirection_EXPORT, apply.ExportPolicyList, apply.DefaultExportPolicy},
+	} {
+		if len(a.names) == 0 && a.defaultAction == "" {
+			continue
+		}
+		assignment := &api.PolicyAssignment{Name: global, Direction: a.direction, DefaultAction: api.RouteAction_ACCEPT}
+		if a.defaultAction == oc.DEFAULT_POLICY_TYPE_REJECT_ROUTE {
+			assignment.DefaultAction = api.RouteAction_REJECT
+		}
+		for _, name := range a.names {
+			policy, ok := byName[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("gobgp_config: global.apply-policy: policy %s is not defined", name))
+				continue
+			}
+			assignment.Policies = append(assignment.Policies, policy)
+		}
+		policies.assignments = append(policies.assignments, assignment)
+	}
+	return policies, errors.Join(errs...)
+}
+
+// Функция goBGPPrefix разбирает префикс с masklength-range вида "24..32".
+func goBGPPrefix(p oc.Prefix) (*api.Prefix, error) {
+	_, ipNet, err := net.ParseCIDR(p.IpPrefix)
+	if err != nil {
+		return nil, err
+	}
+	length, _ := ipNet.Mask.Size()
+	prefix := &api.Prefix{IpPrefix: p.IpPrefix, MaskLengthMin: uint32(length), MaskLengthMax: uint32(length)}
+	if p.MasklengthRange == "" || p.MasklengthRange == "exact" {
+		return prefix, nil
+	}
+	lo, hi, ok := strings.Cut(p.MasklengthRange, "..")
+	minLength, errMin := strconv.ParseUint(lo, 10, 8)
+	maxLength, errMax := strconv.ParseUint(hi, 10, 8)
+	if !ok || errMin != nil || errMax != nil || minLength > maxLength {
+		return nil, fmt.Errorf("invalid masklength-range %q", p.MasklengthRange)
+	}
+	prefix.MaskLengthMin, prefix.MaskLengthMax = uint32(minLength), uint32(maxLength)
+	return prefix, nil
+}
+
+// Функция goBGPStatement переводит statement gobgpd, условия и действия, которые нельзя
+// перенести, считаются ошибкой.
+func goBGPStatement(st oc.Statement) (*api.Statement, error) {
+	cond, bgpCond := st.Conditions, st.Conditions.BgpConditions
+	if cond.CallPolicy != "" || cond.MatchTagSet.TagSet != "" || cond.InstallProtocolEq != "" ||
+		bgpCond.MedEq != 0 || bgpCond.OriginEq != "" || bgpCond.LocalPrefEq != 0 || bgpCond.CommunityCount.Operator != "" ||
+		bgpCond.RouteType != "" || len(bgpCond.AfiSafiInList) > 0 {
+		return nil, errors.New("only prefix, neighbor, community, ext-community, large-community, as-path, as-path-length, next-hop and rpki conditions are supported")
+	}
+	conditions := &api.Conditions{
+		PrefixSet:         goBGPMatchSet(cond.MatchPrefixSet.PrefixSet, string(cond.MatchPrefixSet.MatchSetOptions)),
+		NeighborSet:       goBGPMatchSet(cond.MatchNeighborSet.NeighborSet, string(cond.MatchNeighborSet.MatchSetOptions)),
+		AsPathSet:         goBGPMatchSet(bgpCond.MatchAsPathSet.AsPathSet, string(bgpCond.MatchAsPathSet.MatchSetOptions)),
+		CommunitySet:      goBGPMatchSet(bgpCond.MatchCommunitySet.CommunitySet, string(bgpCond.MatchCommunitySet.MatchSetOptions)),
+		ExtCommunitySet:   goBGPMatchSet(bgpCond.MatchExtCommunitySet.ExtCommunitySet, string(bgpCond.MatchExtCommunitySet.MatchSetOptions)),
+		LargeCommunitySet: goBGPMatchSet(bgpCond.MatchLargeCommunitySet.LargeCommunitySet, string(bgpCond.MatchLargeCommunitySet.MatchSetOptions)),
+		NextHopInList:     bgpCond.NextHopInList,
+	}
+	if l := bgpCond.AsPathLength; l.Operator != "" {
+		conditions.AsPathLength = &api.AsPathLength{Length: l.Value}
+		switch l.Operator {
+		case oc.ATTRIBUTE_COMPARISON_EQ, oc.ATTRIBUTE_COMPARISON_ATTRIBUTE_EQ:
+			conditions.AsPathLength.Type = api.AsPathLength_EQ
+		case oc.ATTRIBUTE_COMPARISON_GE, oc.ATTRIBUTE_COMPARISON_ATTRIBUTE_GE:
+			conditions.AsPathLength.Type = api.AsPathLength_GE
+		case oc.ATTRIBUTE_COMPARISON_LE, oc.ATTRIBUTE_COMPARISON_ATTRIBUTE_LE:
+			conditions.AsPathLength.Type = api.AsPathLength_LE
+		}
+	}
+	switch bgpCond.RpkiValidationResult {
+	case oc.RPKI_VALIDATION_RESULT_TYPE_VALID:
+		conditions.RpkiResult = int32(api.Validation_STATE_VALID)
+	case oc.RPKI_VALIDATION_RESULT_TYPE_INVALID:
+		conditions.RpkiResult = int32(api.Validation_STATE_INVALID)
+	case oc.RPKI_VALIDATION_RESULT_TYPE_NOT_FOUND:
+		conditions.RpkiResult = int32(api.Validation_STATE_NOT_FOUND)
+	}
+
+	act, bgpAct := st.Actions, st.Actions.BgpActions
+	if bgpAct.SetExtCommunity.Options != "" || bgpAct.SetLargeCommunity.Options != "" || bgpAct.SetRouteOrigin != "" ||
+		bgpAct.SetCommunity.SetCommunityMethod.CommunitySetRef != "" {
+		return nil, errors.New("only route-disposition, set-community, set-med, set-local-pref, set-next-hop and set-as-path-prepend actions are supported")
+	}
+	actions := &api.Actions{RouteAction: api.RouteAction_NONE}
+	switch act.RouteDisposition {
+	case oc.ROUTE_DISPOSITION_ACCEPT_ROUTE:
+		actions.RouteAction = api.RouteAction_ACCEPT
+	case oc.ROUTE_DISPOSITION_REJECT_ROUTE:
+		actions.RouteAction = api.RouteAction_REJECT
+	}
+	if c := bgpAct.SetCommunity; c.Options != "" {
+		actions.Community = &api.CommunityAction{Communities: c.SetCommunityMethod.CommunitiesList}
+		switch strings.ToLower(c.Options) {
+		case "add":
+			actions.Community.Type = api.CommunityAction_ADD
+		case "remove":
+			actions.Community.Type = api.CommunityAction_REMOVE
+		case "replace":
+			actions.Community.Type = api.CommunityAction_REPLACE
+		default:
+			return nil, fmt.Errorf("set-community options %q is not supported", c.Options)
+		}
+	}
+	if med := string(bgpAct.SetMed); med != "" {
+		action, err := goBGPMedAction(med)
+		if err != nil {
+			return nil, err
+		}
+		actions.Med = action
+	}
+	if bgpAct.SetLocalPref != 0 {
+		actions.LocalPref = &api.LocalPrefAction{Value: bgpAct.SetLocalPref}
+	}
+	if nh := string(bgpAct.SetNextHop); nh != "" {
+		switch {
+		case strings.EqualFold(nh, "self"):
+			actions.Nexthop = &api.NexthopAction{Self: true}
+		case strings.EqualFold(nh, "unchanged"):
+			actions.Nexthop = &api.NexthopAction{Unchanged: true}
+		case net.ParseIP(nh) != nil:
+			actions.Nexthop = &api.NexthopAction{Address: nh}
+		default:
+			return nil, fmt.Errorf("set-next-hop %q is not supported", nh)
+		}
+	}
+	if p := bgpAct.SetAsPathPrepend; p.RepeatN > 0 {
+		actions.AsPrepend = &api.AsPrependAction{Repeat: uint32(p.RepeatN)}
+		if p.As == "last-as" {
+			actions.AsPrepend.UseLeftMost = true
+		} else {
+			asn, err := strconv.ParseUint(p.As, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("set-as-path-prepend as %q is not supported", p.As)
+			}
+			actions.AsPrepend.Asn = uint32(asn)
+		}
+	}
+	return &api.Statement{Name: st.Name, Conditions: conditions, Actions: actions}, nil
+}
+
+func goBGPMatchSet(name, option string) *api.MatchSet {
+	if name == "" {
+		return nil
+	}
+	set := &api.MatchSet{Type: api.MatchSet_ANY, Name: name}
+	switch strings.ToLower(option) {
+	case "all":
+		set.Type = api.MatchSet_ALL
+	case "invert":
+		set.Type = api.MatchSet_INVERT
+	}
+	return set
+}
+
+// Функция goBGPMedAction разбирает set-med: "+10" и "-10" меняют MED, число заменяет его.
+func goBGPMedAction(med string) (*api.MedAction, error) {
+	value, err := strconv.ParseInt(med, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("set-med %q is not supported", med)
+	}
+	if strings.HasPrefix(med, "+") || strings.HasPrefix(med, "-") {
+		return &api.MedAction{Type: api.MedAction_MOD, Value: value}, nil
+	}
+	return &api.MedAction{Type: api.MedAction_REPLACE, Value: value}, nil
+}
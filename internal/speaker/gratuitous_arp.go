@@ -0,0 +1,46 @@
+package speaker
+
+import (
+	"net"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+const defaultGratuitousARPCount = 3
+
+// GratuitousARP задает рассылку gratuitous ARP для anycast ip при его активации.
+// anycast ip в speaker только IPv4, поэтому unsolicited NA для IPv6 не отправляется.
+type GratuitousARP struct {
+	// Interfaces это интерфейсы, в которые отправляется ARP, например, uplink в L2 сегмент.
+	Interfaces []string `yaml:"interfaces"`
+	// Count это сколько ARP отправить с интервалом в секунду, по-умолчанию 3.
+	Count uint32 `yaml:"count"`
+}
+
+// Метод announceGratuitousARP в фоне рассылает gratuitous ARP для anycast ip в интерфейсы
+// из gratuitous_arp, чтобы соседи на L2 сразу направили трафик на этот хост.
+func (sp *Speaker) announceGratuitousARP() {
+	conf := sp.config.GratuitousARP
+	if conf == nil || len(conf.Interfaces) == 0 {
+		return
+	}
+	count := conf.Count
+	if count == 0 {
+		count = defaultGratuitousARPCount
+	}
+	ip := net.ParseIP(sp.config.AnycastIP)
+	go func() {
+		for i := uint32(0); i < count; i++ {
+			if i > 0 {
+				time.Sleep(time.Second)
+			}
+			for _, ifName := range conf.Interfaces {
+				if err := nl.SendGratuitousARP(ifName, ip); err != nil {
+					sp.logger.Warn("failed to send gratuitous arp", log.Fields{"interface": ifName, "error": err.Error()})
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	defaultHandoffRestartTimeSeconds = 120
+	defaultHandoffTimeoutSeconds     = 30
+	// maxGracefulRestartTime это наибольшее restart time, которое помещается в capability (RFC 4724).
+	maxGracefulRestartTime = 4095
+)
+
+// Handoff включает обновление бинарника без перестроения маршрутов у соседей. Старый процесс
+// слушает unix socket Socket. Новый процесс при старте подключается к нему и получает режим
+// обслуживания и дополнительные префиксы, после чего старый процесс завершается: маршруты
+// не отзываются, сессии закрываются без NOTIFICATION, маршрут по-умолчанию остается в ядре.
+// Соседи держат маршруты speaker по graceful restart (RFC 4724), пока новый процесс
+// устанавливает сессии, а маршрут в ядре новый процесс забирает себе (fib_conflict_mode: adopt).
+//
+// Новый процесс запускает BGP только после выхода старого: speaker не принимает входящие
+// BGP соединения, поэтому SO_REUSEPORT не нужен, а адреса gRPC и admin API к этому моменту свободны.
+type Handoff struct {
+	// Socket это путь unix socket для передачи управления.
+	Socket string `yaml:"socket"`
+	// RestartTimeSeconds это restart time, которое speaker объявляет соседям, по-умолчанию 120.
+	RestartTimeSeconds uint32 `yaml:"restart_time_seconds"`
+	// TimeoutSeconds это сколько новый процесс ждет состояния и выхода старого, по-умолчанию 30.
+	TimeoutSeconds uint32 `yaml:"timeout_seconds"`
+}
+
+func (h *Handoff) applyDefaults() {
+	if h.RestartTimeSeconds == 0 {
+		h.RestartTimeSeconds = defaultHandoffRestartTimeSeconds
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = defaultHandoffTimeoutSeconds
+	}
+}
+
+func (h *Handoff) validate() error {
+	errs := []error{}
+	if h.Socket == "" {
+		errs = append(errs, errors.New("handoff.socket: is required"))
+	}
+	if h.RestartTimeSeconds > maxGracefulRestartTime {
+		errs = append(errs, fmt.Errorf("handoff.restart_time_seconds: must be at most %d", maxGracefulRestartTime))
+	}
+	return errors.Join(errs...)
+}
+
+// handoffState это состояние, которое старый процесс передает новому.
+type handoffState struct {
+	Maintenance bool `json:"maintenance"`
+	// Prefixes это дополнительные анонсируемые префиксы и их next-hop.
+	Prefixes map[string]string `json:"prefixes"`
+}
+
+// Метод takeOverHandoff вызывается в начале Start, до запуска BGP: если старый процесс слушает
+// handoff.socket, забирает у него состояние и ждет его выхода. Возвращает nil, если старого процесса нет.
+func (sp *Speaker) takeOverHandoff() (*handoffState, error) {
+	conf := sp.config.Handoff
+	if conf == nil {
+		return nil, nil
+	}
+	conn, err := net.Dial("unix", conf.Socket)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to handoff socket: %w", err)
+	}
+	defer conn.Close()
+	sp.logger.Info("taking over from previous speaker process", log.Fields{"socket": conf.Socket})
+	_ = conn.SetDeadline(time.Now().Add(time.Second * time.Duration(conf.TimeoutSeconds)))
+	state := &handoffState{}
+	if err := json.NewDecoder(conn).Decode(state); err != nil {
+		return nil, fmt.Errorf("error receiving handoff state: %w", err)
+	}
+	// Старый процесс держит соединение до выхода, поэтому EOF означает, что он завершился.
+	if _, err := io.Copy(io.Discard, conn); err != nil {
+		return nil, fmt.Errorf("previous speaker process did not exit: %w", err)
+	}
+	sp.logger.Info("previous speaker process exited", log.Fields{"maintenance": state.Maintenance, "prefixes": len(state.Prefixes)})
+	return state, nil
+}
+
+// Метод restoreHandoffState анонсирует дополнительные префиксы, полученные от старого процесса.
+func (sp *Speaker) restoreHandoffState(ctx context.Context, state *handoffState) error {
+	prefixes := maps.Keys(state.Prefixes)
+	slices.Sort(prefixes)
+	for _, ip := range prefixes {
+		if err := sp.AdvertisePrefixVia(ctx, ip, state.Prefixes[ip]); err != nil {
+			return fmt.Errorf("error restoring prefix %s: %w", ip, err)
+		}
+	}
+	return nil
+}
+
+// ServeHandoff слушает handoff.socket, пока не завершится ctx. Когда подключается новый процесс,
+// отправляет ему состояние и останавливает фоновые задачи так, что [Speaker.Stop] ничего не отзывает
+// и не удаляет. Соединение с новым процессом остается открытым до выхода процесса.
+func (sp *Speaker) ServeHandoff(ctx context.Context) error {
+	socket := sp.config.Handoff.Socket
+	// Сокет мог остаться от аварийно завершившегося процесса: в takeOverHandoff к нему никто не ответил.
+	if err := os.Remove(socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing stale handoff socket: %w", err)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("error listening handoff socket: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error accepting handoff connection: %w", err)
+		}
+		if err := sp.handOff(conn); err != nil {
+			sp.logger.Error("handoff failed", log.Fields{"error": err.Error()})
+			conn.Close()
+			continue
+		}
+		l.Close()
+		sp.logger.Warn("handed off to new speaker process, exiting", nil)
+		sp.cancel()
+		return nil
+	}
+}
+
+func (sp *Speaker) handOff(conn net.Conn) error {
+	sp.pathMu.Lock()
+	state := handoffState{Maintenance: sp.maintenance}
+	sp.pathMu.Unlock()
+	sp.prefixMu.Lock()
+	state.Prefixes = maps.Clone(sp.extraPrefixes)
+	if state.Prefixes == nil {
+		state.Prefixes = map[string]string{}
+	}
+	maps.Copy(state.Prefixes, sp.heldPrefixes)
+	sp.prefixMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second * time.Duration(sp.config.Handoff.TimeoutSeconds)))
+	if err := json.NewEncoder(conn).Encode(state); err != nil {
+		return err
+	}
+	sp.handedOff.Store(true)
+	sp.handoffConn = conn
+	return nil
+}
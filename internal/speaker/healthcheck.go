@@ -1,11 +1,17 @@
 package speaker
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/osrg/gobgp/v3/pkg/log"
@@ -15,6 +21,7 @@ const (
 	healthyThreshold = 3
 	interval         = 1
 	timeoutSeconds   = 1
+	maxBodyBytes     = 1 << 20
 )
 
 const (
@@ -34,88 +41,232 @@ func (s Status) String() string {
 // HealthCheck проверяет статус сервиса 1 раз в секунду.
 type HealthCheck struct {
 	status      Status
-	u           *url.URL
+	check       func(context.Context) error
 	okCounter   int
 	client      *http.Client
 	cbHealthy   func(context.Context) error
 	cbUnhealthy func(context.Context) error
+	mu          sync.Mutex
+	lastBody    []byte
+	// history это кольцевой буфер последних результатов, historyNext указывает на самый старый.
+	history     []HealthCheckResult
+	historyNext int
+	// cleanup освобождает ресурсы проверки, например, соединение, после завершения Run.
+	cleanup func()
+	// minUp это сколько времени проверки должны непрерывно проходить перед переходом в healthy,
+	// upSince это время первой успешной проверки в текущей серии.
+	minUp   time.Duration
+	upSince time.Time
+	// jitter и maxBackoff задают расписание проверок, см. [HealthCheck.nextDelay].
+	jitter     time.Duration
+	maxBackoff time.Duration
+	failures   int
+	// logs подавляет повторы ошибок callback, которые повторяются на каждой проверке.
+	logs logSampler
 }
 
 // NewHealthCheck создает новый HealthCheck, который после запуска HealthCheck.Run:
 //   - выполняет cbHealthy call back, eсли статус меняется на healthy
 //   - выполняет cbUnhealthy call back, eсли статус меняется на unhealthy
 //   - ничего не делает, если статус не меняется
-func NewHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error, rawURL string) (*HealthCheck, error) {
+//
+// Запросы к rawURL выполняются с параметрами opts. Для сервиса, который слушает только
+// unix socket, rawURL задается как unix:///var/run/app.sock:/healthz.
+func NewHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error, rawURL string, opts HTTPCheckOptions) (*HealthCheck, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("HealthCheck: parse url error: %w", err)
 	}
+	hc := newHealthCheck(cbHealthy, cbUnhealthy)
+	if u.Scheme == unixScheme {
+		socket, target, err := parseUnixSocketURL(u)
+		if err != nil {
+			return nil, fmt.Errorf("HealthCheck: %w", err)
+		}
+		hc.client.Transport = unixSocketTransport(socket)
+		u = target
+	} else if opts.TLS != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = opts.TLS
+		hc.client.Transport = transport
+	}
+	if u.String() != "" {
+		hc.check = func(ctx context.Context) error {
+			return hc.request(ctx, u, opts)
+		}
+	}
+	return hc, nil
+}
+
+// HTTPCheckOptions это параметры запросов HTTP проверки здоровья.
+type HTTPCheckOptions struct {
+	// Header добавляется к каждому запросу, см. [Speaker.healthCheckHeader].
+	Header http.Header
+	// TLS используется для https URL, если задан.
+	TLS *tls.Config
+	// Method по-умолчанию GET.
+	Method string
+	// Body отправляется в каждом запросе, если задан.
+	Body []byte
+	// ExpectStatus это коды ответа, которые считаются успешными, по-умолчанию только 200.
+	ExpectStatus []StatusRange
+	// ExpectBody, если задан, должен находить совпадение в теле ответа.
+	ExpectBody *regexp.Regexp
+}
+
+func newHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error) *HealthCheck {
 	return &HealthCheck{
 		status: Unhealthy,
-		u:      u,
 		client: &http.Client{
 			Timeout: time.Second * timeoutSeconds,
 		},
 		cbHealthy:   cbHealthy,
 		cbUnhealthy: cbUnhealthy,
-	}, nil
+	}
 }
 
 func (hc *HealthCheck) Run(ctx context.Context, logger Logger) error {
-	if hc.u.String() == "" {
+	if hc.cleanup != nil {
+		defer hc.cleanup()
+	}
+	if hc.check == nil {
 		logger.Warn("HealthCheck URL is empty", nil)
 		<-ctx.Done()
 		return nil
 	}
-	ticker := time.NewTicker(time.Second * interval)
-	defer ticker.Stop()
+	timer := time.NewTimer(hc.nextDelay())
+	defer timer.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Info(fmt.Sprintf("HealthCheck: exiting: %s", ctx.Err().Error()), nil)
 			return nil
-		case <-ticker.C:
-			logger.Debug("HealthCheck", log.Fields{"status": hc.status, "okCount": hc.okCounter})
-			err := hc.Do(ctx)
-			if err != nil && hc.status == Healthy {
-				if err := hc.cbUnhealthy(ctx); err != nil {
-					logger.Error("HealthCheck callback error, status not changed", log.Fields{"error": err.Error()})
-					continue
-				}
-				hc.status = Unhealthy
-				hc.okCounter = 0
-				logger.Warn("HealthCheck failed, status changed", log.Fields{"status": hc.status, "okCount": hc.okCounter})
-				continue
-			}
-			if err == nil && hc.status == Unhealthy {
-				if hc.okCounter >= healthyThreshold {
-					if err := hc.cbHealthy(ctx); err != nil {
-						logger.Error("HealthCheck callback error, status not changed", log.Fields{"error": err.Error()})
-						continue
-					}
-					hc.status = Healthy
-					logger.Info("HealthCheck succeeded, status changed", log.Fields{"status": hc.status, "okCount": hc.okCounter})
-					continue
-				}
-				hc.okCounter++
+		case <-timer.C:
+			hc.tick(ctx, logger)
+			timer.Reset(hc.nextDelay())
+		}
+	}
+}
+
+const healthCallbackError = "HealthCheck callback error, status not changed"
+
+// Метод tick выполняет одну проверку и при смене статуса вызывает callback.
+func (hc *HealthCheck) tick(ctx context.Context, logger Logger) {
+	logger.Debug("HealthCheck", log.Fields{"status": hc.status, "okCount": hc.okCounter})
+	start := time.Now()
+	err := hc.Do(ctx)
+	result := HealthCheckResult{Time: start, Latency: time.Since(start)}
+	defer func() {
+		result.Status = hc.status.String()
+		hc.record(result)
+	}()
+	if err != nil {
+		result.Error = err.Error()
+		hc.upSince = time.Time{}
+		hc.failures++
+	} else {
+		if hc.upSince.IsZero() {
+			hc.upSince = start
+		}
+		hc.failures = 0
+	}
+	if err != nil && hc.status == Healthy {
+		if err := hc.cbUnhealthy(withLatencyTrace(ctx, "health_check")); err != nil {
+			hc.logs.Error(&logger, healthCallbackError, log.Fields{"error": err.Error()})
+			return
+		}
+		hc.logs.Resolved(&logger, healthCallbackError)
+		hc.status = Unhealthy
+		hc.okCounter = 0
+		logger.Warn("HealthCheck failed, status changed", log.Fields{"status": hc.status, "okCount": hc.okCounter})
+		return
+	}
+	if err == nil && hc.status == Unhealthy {
+		if hc.okCounter >= healthyThreshold && time.Since(hc.upSince) >= hc.minUp {
+			if err := hc.cbHealthy(withLatencyTrace(ctx, "health_check")); err != nil {
+				hc.logs.Error(&logger, healthCallbackError, log.Fields{"error": err.Error()})
+				return
 			}
+			hc.logs.Resolved(&logger, healthCallbackError)
+			hc.status = Healthy
+			logger.Info("HealthCheck succeeded, status changed", log.Fields{"status": hc.status, "okCount": hc.okCounter})
+			return
+		}
+		hc.okCounter++
+	}
+}
+
+// Метод nextDelay возвращает время до следующей проверки: интервал, который удваивается
+// после каждой неудачной проверки подряд до maxBackoff, плюс случайный jitter,
+// чтобы множество speaker не опрашивали сервис в одну и ту же секунду.
+func (hc *HealthCheck) nextDelay() time.Duration {
+	delay := time.Second * interval
+	if hc.maxBackoff > delay && hc.failures > 1 {
+		for i := 1; i < hc.failures && delay < hc.maxBackoff; i++ {
+			delay *= 2
 		}
+		delay = min(delay, hc.maxBackoff)
 	}
+	if hc.jitter > 0 {
+		delay += rand.N(hc.jitter)
+	}
+	return delay
 }
 
 func (hc *HealthCheck) Do(ctx context.Context) error {
-	req := http.Request{Method: http.MethodGet, URL: hc.u}
-	resp, err := hc.client.Do(req.WithContext(ctx))
+	return hc.check(ctx)
+}
+
+func (hc *HealthCheck) request(ctx context.Context, u *url.URL, opts HTTPCheckOptions) error {
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(opts.Body))
 	if err != nil {
-		return fmt.Errorf("HealthCheck: http get failed: %w", err)
+		return fmt.Errorf("HealthCheck: failed to create request: %w", err)
 	}
-	defer resp.Body.Close()
-	_, err = io.Copy(io.Discard, resp.Body)
+	req.Header = opts.Header.Clone()
+	// Заголовок Host net/http берет из поля Request.Host, а не из Header.
+	if host := req.Header.Get(headerHost); host != "" {
+		req.Host = host
+		req.Header.Del(headerHost)
+	}
+	resp, err := hc.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("HealthCheck: read response failed: %w", err)
+		return fmt.Errorf("HealthCheck: http %s failed: %w", strings.ToLower(method), err)
+	}
+	defer resp.Body.Close()
+	if err := hc.readBody(resp); err != nil {
+		return err
 	}
-	if resp.StatusCode != http.StatusOK {
+	if !statusExpected(opts.ExpectStatus, resp.StatusCode) {
 		return fmt.Errorf("HealthCheck: unexpected status code: %d", resp.StatusCode)
 	}
+	if opts.ExpectBody != nil && !opts.ExpectBody.Match(hc.LastBody()) {
+		return fmt.Errorf("HealthCheck: response body does not match %q", opts.ExpectBody.String())
+	}
 	return nil
 }
+
+// Метод readBody читает тело ответа и запоминает его для [HealthCheck.LastBody].
+func (hc *HealthCheck) readBody(resp *http.Response) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	if err != nil {
+		return fmt.Errorf("HealthCheck: read response failed: %w", err)
+	}
+	hc.mu.Lock()
+	hc.lastBody = body
+	hc.mu.Unlock()
+	return nil
+}
+
+// LastBody возвращает тело последнего ответа сервиса (не более 1 MiB).
+func (hc *HealthCheck) LastBody() []byte {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.lastBody
+}
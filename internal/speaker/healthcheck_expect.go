@@ -0,0 +1,66 @@
+package speaker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StatusRange это диапазон кодов ответа для expect_status: один код, например, 204,
+// или диапазон "200-299".
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+func (r *StatusRange) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseStatusRange(node.Value)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func ParseStatusRange(s string) (StatusRange, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hi = lo
+	}
+	from, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return StatusRange{}, fmt.Errorf("invalid status %q: %w", s, err)
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil {
+		return StatusRange{}, fmt.Errorf("invalid status %q: %w", s, err)
+	}
+	if from < 100 || to > 599 || from > to {
+		return StatusRange{}, fmt.Errorf("invalid status %q: must be between 100 and 599", s)
+	}
+	return StatusRange{Min: from, Max: to}, nil
+}
+
+func (r StatusRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// Функция statusExpected проверяет, что code входит в один из диапазонов expected,
+// если диапазоны не заданы, ожидается только 200.
+func statusExpected(expected []StatusRange, code int) bool {
+	if len(expected) == 0 {
+		return code == http.StatusOK
+	}
+	for _, r := range expected {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
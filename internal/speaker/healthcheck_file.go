@@ -0,0 +1,37 @@
+package speaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// FileHealthCheck задает файл-флаг для health_source: file: пока файл существует,
+// сервис считается нездоровым и anycast ip отзывается. Так инструменты деплоя могут
+// вывести узел из балансировки командой touch и вернуть командой rm.
+type FileHealthCheck struct {
+	// Path это путь к файлу, например, /var/run/bgp-speaker/maintenance.
+	Path string `yaml:"path"`
+}
+
+// NewFileHealthCheck создает HealthCheck, который раз в секунду проверяет наличие файла.
+func NewFileHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error, check FileHealthCheck) (*HealthCheck, error) {
+	if check.Path == "" {
+		return nil, fmt.Errorf("HealthCheck: file path is not set")
+	}
+	hc := newHealthCheck(cbHealthy, cbUnhealthy)
+	hc.check = func(context.Context) error {
+		_, err := os.Stat(check.Path)
+		switch {
+		case err == nil:
+			return fmt.Errorf("HealthCheck: maintenance file %s exists", check.Path)
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		default:
+			return fmt.Errorf("HealthCheck: failed to check maintenance file: %w", err)
+		}
+	}
+	return hc, nil
+}
@@ -0,0 +1,63 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// GRPCHealthCheck задает сервис, который проверяется по протоколу [grpc.health.v1],
+// для health_source: grpc.
+//
+// [grpc.health.v1]: https://github.com/grpc/grpc/blob/master/doc/health-checking.md
+type GRPCHealthCheck struct {
+	// Address это адрес сервиса в формате host:port.
+	Address string `yaml:"address"`
+	// Service это имя сервиса в запросе Health/Check, пустое имя означает статус сервера целиком.
+	Service string `yaml:"service"`
+	// TLS включает TLS, без него соединение не шифруется.
+	TLS *HealthCheckTLS `yaml:"tls"`
+}
+
+// NewGRPCHealthCheck создает HealthCheck, который вызывает Health/Check и считает
+// сервис здоровым только при статусе SERVING.
+func NewGRPCHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error, check GRPCHealthCheck) (*HealthCheck, error) {
+	if check.Address == "" {
+		return nil, fmt.Errorf("HealthCheck: grpc address is not set")
+	}
+	creds := insecure.NewCredentials()
+	if check.TLS != nil {
+		tlsConfig, err := check.TLS.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("HealthCheck: grpc tls: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.Dial(check.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("HealthCheck: grpc dial failed: %w", err)
+	}
+	client := healthpb.NewHealthClient(conn)
+	hc := newHealthCheck(cbHealthy, cbUnhealthy)
+	hc.cleanup = func() {
+		_ = conn.Close()
+	}
+	hc.check = func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Second*timeoutSeconds)
+		defer cancel()
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: check.Service})
+		if err != nil {
+			return fmt.Errorf("HealthCheck: grpc check failed: %w", err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("HealthCheck: grpc service %q is %s", check.Service, resp.Status)
+		}
+		return nil
+	}
+	return hc, nil
+}
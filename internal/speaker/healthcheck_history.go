@@ -0,0 +1,75 @@
+package speaker
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/exp/maps"
+)
+
+const (
+	// healthCheckHistorySize это сколько последних результатов хранит HealthCheck.
+	healthCheckHistorySize = 64
+	healthHistoryPath      = "/health/history"
+)
+
+// HealthCheckResult это результат одной проверки здоровья.
+type HealthCheckResult struct {
+	Time    time.Time     `json:"time"`
+	Latency time.Duration `json:"latency_ns"`
+	// Error пустой, если проверка прошла.
+	Error string `json:"error,omitempty"`
+	// Status это статус HealthCheck после проверки.
+	Status string `json:"status"`
+}
+
+// HealthHistoryResponse это ответ GET /health/history.
+type HealthHistoryResponse struct {
+	// AnycastIP это история проверки здоровья anycast ip, от старых к новым.
+	AnycastIP []HealthCheckResult `json:"anycast_ip"`
+	// HealthChecks это история проверок из health_checks по имени.
+	HealthChecks map[string][]HealthCheckResult `json:"health_checks,omitempty"`
+}
+
+// Метод record добавляет результат проверки в кольцевой буфер истории.
+func (hc *HealthCheck) record(result HealthCheckResult) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if len(hc.history) < healthCheckHistorySize {
+		hc.history = append(hc.history, result)
+		return
+	}
+	hc.history[hc.historyNext] = result
+	hc.historyNext = (hc.historyNext + 1) % healthCheckHistorySize
+}
+
+// History возвращает результаты последних проверок (не более 64), от старых к новым.
+func (hc *HealthCheck) History() []HealthCheckResult {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return append(slices.Clone(hc.history[hc.historyNext:]), hc.history[:hc.historyNext]...)
+}
+
+// Метод healthHistory возвращает историю проверки anycast ip и проверок из health_checks.
+func (sp *Speaker) healthHistory() HealthHistoryResponse {
+	sp.pathMu.Lock()
+	healthCheck := sp.healthCheck
+	prefixHealthChecks := maps.Clone(sp.prefixHealthChecks)
+	sp.pathMu.Unlock()
+	resp := HealthHistoryResponse{AnycastIP: []HealthCheckResult{}}
+	if healthCheck != nil {
+		resp.AnycastIP = healthCheck.History()
+	}
+	if len(prefixHealthChecks) > 0 {
+		resp.HealthChecks = map[string][]HealthCheckResult{}
+		for name, hc := range prefixHealthChecks {
+			resp.HealthChecks[name] = hc.History()
+		}
+	}
+	return resp
+}
+
+func (sp *Speaker) handleHealthHistory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sp.healthHistory())
+}
@@ -0,0 +1,129 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	defaultICMPAttempts = 3
+	icmpProtocolIPv4    = 1
+	icmpMaxMessageBytes = 1500
+)
+
+// ICMPHealthCheck задает хост, доступность которого по ping определяет анонс anycast ip,
+// для health_source: icmp. Например, если speaker работает на шлюзе, а сервис на другом хосте.
+type ICMPHealthCheck struct {
+	// Address это ipv4 адрес хоста.
+	Address string `yaml:"address"`
+	// Privileged отправляет ping через raw socket, для этого нужен CAP_NET_RAW.
+	// По-умолчанию используется unprivileged ping через UDP socket, который требует,
+	// чтобы группа процесса входила в net.ipv4.ping_group_range.
+	Privileged bool `yaml:"privileged"`
+	// Attempts это сколько echo request отправить за проверку, проверка успешна,
+	// если пришел хотя бы один ответ. По-умолчанию 3.
+	Attempts uint32 `yaml:"attempts"`
+}
+
+// NewICMPHealthCheck создает HealthCheck, который раз в секунду отправляет ICMP echo request.
+func NewICMPHealthCheck(cbHealthy, cbUnhealthy func(context.Context) error, check ICMPHealthCheck) (*HealthCheck, error) {
+	ip := net.ParseIP(check.Address)
+	if ip == nil || ip.To4() == nil {
+		return nil, fmt.Errorf("HealthCheck: icmp address %q is not an ipv4 address", check.Address)
+	}
+	attempts := check.Attempts
+	if attempts == 0 {
+		attempts = defaultICMPAttempts
+	}
+	p := &pinger{ip: ip, privileged: check.Privileged, id: os.Getpid() & 0xffff}
+	hc := newHealthCheck(cbHealthy, cbUnhealthy)
+	hc.check = func(ctx context.Context) error {
+		// Все попытки должны уложиться в интервал проверок.
+		timeout := time.Second * timeoutSeconds / time.Duration(attempts)
+		var err error
+		for i := uint32(0); i < attempts; i++ {
+			if err = p.ping(ctx, timeout); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("HealthCheck: icmp %s: %w", check.Address, err)
+	}
+	return hc, nil
+}
+
+type pinger struct {
+	ip         net.IP
+	privileged bool
+	id         int
+	seq        atomic.Uint32
+}
+
+// Метод ping отправляет один echo request и ждет ответа не дольше timeout.
+func (p *pinger) ping(ctx context.Context, timeout time.Duration) error {
+	network, dst := "udp4", net.Addr(&net.UDPAddr{IP: p.ip})
+	if p.privileged {
+		network, dst = "ip4:icmp", &net.IPAddr{IP: p.ip}
+	}
+	conn, err := icmp.ListenPacket(network, "0.0.0.0")
+	if err != nil && !p.privileged {
+		return fmt.Errorf("failed to open icmp socket, check net.ipv4.ping_group_range or use privileged: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open icmp socket: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	seq := int(p.seq.Add(1) & 0xffff)
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: p.id, Seq: seq, Data: []byte("bgp-speaker")},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return fmt.Errorf("failed to send echo request: %w", err)
+	}
+	reply := make([]byte, icmpMaxMessageBytes)
+	for {
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			return fmt.Errorf("no echo reply: %w", err)
+		}
+		m, err := icmp.ParseMessage(icmpProtocolIPv4, reply[:n])
+		if err != nil || m.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := m.Body.(*icmp.Echo)
+		// В unprivileged режиме ядро подменяет ID и само отбрасывает чужие ответы.
+		if !ok || echo.Seq != seq || (p.privileged && echo.ID != p.id) || !samePeer(peer, p.ip) {
+			continue
+		}
+		return nil
+	}
+}
+
+func samePeer(addr net.Addr, ip net.IP) bool {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.Equal(ip)
+	case *net.IPAddr:
+		return a.IP.Equal(ip)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,103 @@
+package speaker
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultHealthCheckUserAgent = "bgp-speaker-healthcheck/1.0"
+	headerUserAgent             = "User-Agent"
+	headerHost                  = "Host"
+	headerInstance              = "X-Bgp-Speaker-Instance"
+	headerSite                  = "X-Bgp-Speaker-Site"
+	headerPrefix                = "X-Bgp-Speaker-Prefix"
+)
+
+// HealthCheckConfig задает, как speaker представляется в запросах проверки здоровья,
+// чтобы в логах приложения их можно было отличить от настоящего трафика.
+type HealthCheckConfig struct {
+	// UserAgent по-умолчанию bgp-speaker-healthcheck/1.0.
+	UserAgent string `yaml:"user_agent"`
+	// Instance по-умолчанию это hostname.
+	Instance string `yaml:"instance"`
+	Site     string `yaml:"site"`
+	// Headers это дополнительные заголовки, они заменяют одноименные заголовки speaker.
+	// Заголовок Host заменяет имя хоста из health_check_url.
+	Headers map[string]string `yaml:"headers"`
+	// TLS задает CA, клиентский сертификат и проверку сертификата для https health_check_url.
+	TLS *HealthCheckTLS `yaml:"tls"`
+	// Method это метод запроса, например, HEAD или POST, по-умолчанию GET.
+	Method string `yaml:"method"`
+	// Body это тело каждого запроса, например, JSON для POST.
+	Body string `yaml:"body"`
+	// ExpectStatus это коды или диапазоны кодов ответа, которые считаются успешными,
+	// например, [200, "300-399"], по-умолчанию только 200.
+	ExpectStatus []StatusRange `yaml:"expect_status"`
+	// ExpectBodyRegex это регулярное выражение, которое должно находиться в теле ответа,
+	// например, '"status":\s*"ok"', чтобы ответ 200 со статусом degraded считался неудачным.
+	ExpectBodyRegex string `yaml:"expect_body_regex"`
+}
+
+// Метод httpCheckOptions возвращает параметры запросов HTTP проверки здоровья prefix с настройками conf.
+func (sp *Speaker) httpCheckOptions(conf *HealthCheckConfig, prefix string) (HTTPCheckOptions, error) {
+	opts := HTTPCheckOptions{Header: sp.healthCheckHeader(conf, prefix)}
+	if conf != nil {
+		opts.Method = strings.ToUpper(conf.Method)
+		if conf.Body != "" {
+			opts.Body = []byte(conf.Body)
+		}
+		opts.ExpectStatus = conf.ExpectStatus
+		if conf.ExpectBodyRegex != "" {
+			re, err := regexp.Compile(conf.ExpectBodyRegex)
+			if err != nil {
+				return HTTPCheckOptions{}, fmt.Errorf("expect_body_regex: %w", err)
+			}
+			opts.ExpectBody = re
+		}
+	}
+	if conf != nil && conf.TLS != nil {
+		tlsConfig, err := conf.TLS.tlsConfig()
+		if err != nil {
+			return HTTPCheckOptions{}, fmt.Errorf("tls: %w", err)
+		}
+		opts.TLS = tlsConfig
+	}
+	return opts, nil
+}
+
+// Метод healthCheckHeader возвращает заголовки запроса проверки здоровья:
+// User-Agent, а также X-Bgp-Speaker-Instance, X-Bgp-Speaker-Site и X-Bgp-Speaker-Prefix,
+// по которым можно найти speaker, выполнивший запрос. Если prefix пустой, заголовок
+// X-Bgp-Speaker-Prefix не добавляется.
+func (sp *Speaker) healthCheckHeader(c *HealthCheckConfig, prefix string) http.Header {
+	conf := HealthCheckConfig{}
+	if c != nil {
+		conf = *c
+	}
+	header := http.Header{}
+	header.Set(headerUserAgent, defaultHealthCheckUserAgent)
+	if conf.UserAgent != "" {
+		header.Set(headerUserAgent, conf.UserAgent)
+	}
+	instance := conf.Instance
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	if instance != "" {
+		header.Set(headerInstance, instance)
+	}
+	if conf.Site != "" {
+		header.Set(headerSite, conf.Site)
+	}
+	if prefix != "" {
+		header.Set(headerPrefix, prefix+"/32")
+	}
+	for name, value := range conf.Headers {
+		header.Set(name, value)
+	}
+	return header
+}
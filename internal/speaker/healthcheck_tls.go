@@ -0,0 +1,50 @@
+package speaker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// HealthCheckTLS задает параметры TLS для https health_check_url, например, если сервис
+// принимает только mTLS.
+type HealthCheckTLS struct {
+	// CAFile это PEM файл с сертификатами CA, которыми проверяется сертификат сервиса,
+	// по-умолчанию используются системные.
+	CAFile string `yaml:"ca_file"`
+	// CertFile и KeyFile это клиентский сертификат и ключ в формате PEM, задаются вместе.
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ServerName заменяет имя из URL при проверке сертификата сервиса.
+	ServerName string `yaml:"server_name"`
+	// InsecureSkipVerify выключает проверку сертификата сервиса, только для отладки.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// Метод tlsConfig читает сертификаты и возвращает [tls.Config] для клиента проверки здоровья.
+func (t *HealthCheckTLS) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s contains no pem certificates", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
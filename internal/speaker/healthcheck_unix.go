@@ -0,0 +1,44 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	unixScheme = "unix"
+	// unixRequestHost подставляется в URL запроса через unix socket, если не задан заголовок Host.
+	unixRequestHost = "localhost"
+)
+
+// Функция parseUnixSocketURL разбирает health_check_url вида unix:///var/run/app.sock:/healthz
+// на путь к сокету и URL запроса http://localhost/healthz.
+func parseUnixSocketURL(u *url.URL) (string, *url.URL, error) {
+	socket, path, ok := strings.Cut(u.Path, ":")
+	if !ok {
+		path = "/"
+	}
+	if socket == "" || !strings.HasPrefix(socket, "/") {
+		return "", nil, fmt.Errorf("%q must be in unix:///path/to.sock:/path format", u.String())
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	target := &url.URL{Scheme: "http", Host: unixRequestHost, Path: path, RawQuery: u.RawQuery}
+	return socket, target, nil
+}
+
+// Функция unixSocketTransport возвращает transport, который соединяется с socket
+// вместо адреса из URL запроса.
+func unixSocketTransport(socket string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := net.Dialer{}
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, unixScheme, socket)
+	}
+	return transport
+}
@@ -0,0 +1,154 @@
+package speaker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	hooksIntervalSeconds = 1
+	hooksError           = "hooks evaluation failed"
+)
+
+// HookData это данные, доступные выражениям hooks.
+type HookData struct {
+	// Now это текущее время.
+	Now time.Time
+	// Health это тело последнего ответа health_check_url, разобранное как JSON-объект.
+	Health map[string]any
+	// Healthy это текущий статус проверки здоровья.
+	Healthy bool
+	// Maintenance показывает, включен ли режим обслуживания.
+	Maintenance bool
+}
+
+type hooks struct {
+	announceIf  *template.Template
+	communities *template.Template
+}
+
+func compileHooks(cfg *Hooks) (*hooks, error) {
+	h := &hooks{}
+	var err error
+	if cfg.AnnounceIf != "" {
+		if h.announceIf, err = template.New("announce_if").Option("missingkey=zero").Parse(cfg.AnnounceIf); err != nil {
+			return nil, fmt.Errorf("hooks.announce_if: %w", err)
+		}
+	}
+	if cfg.Communities != "" {
+		if h.communities, err = template.New("communities").Option("missingkey=zero").Parse(cfg.Communities); err != nil {
+			return nil, fmt.Errorf("hooks.communities: %w", err)
+		}
+	}
+	return h, nil
+}
+
+func (h *hooks) eval(data HookData) (bool, []uint32, error) {
+	announce := true
+	if h.announceIf != nil {
+		out, err := execute(h.announceIf, data)
+		if err != nil {
+			return false, nil, err
+		}
+		if announce, err = strconv.ParseBool(out); err != nil {
+			return false, nil, fmt.Errorf("hooks.announce_if must evaluate to true or false: %w", err)
+		}
+	}
+	var communities []uint32
+	if h.communities != nil {
+		out, err := execute(h.communities, data)
+		if err != nil {
+			return false, nil, err
+		}
+		for _, field := range strings.Fields(out) {
+			c, err := ParseCommunity(field)
+			if err != nil {
+				return false, nil, fmt.Errorf("hooks.communities: %w", err)
+			}
+			communities = append(communities, uint32(c))
+		}
+	}
+	return announce, communities, nil
+}
+
+func execute(t *template.Template, data HookData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("hooks.%s: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// RunHooks раз в секунду вычисляет hooks и, если результат изменился, анонсирует
+// или отзывает anycast ip с учетом статуса проверки здоровья и режима обслуживания.
+func (sp *Speaker) RunHooks(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second * hooksIntervalSeconds)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := sp.applyHooks(ctx); err != nil {
+				sp.logs.Error(sp.logger, hooksError, log.Fields{"error": err.Error()})
+			} else {
+				sp.logs.Resolved(sp.logger, hooksError)
+			}
+		}
+	}
+}
+
+func (sp *Speaker) applyHooks(ctx context.Context) error {
+	ctx = withAuditCause(ctx, auditCauseHooks, "")
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	data := HookData{
+		Now:         time.Now(),
+		Health:      map[string]any{},
+		Healthy:     sp.healthy,
+		Maintenance: sp.maintenance,
+	}
+	if sp.healthCheck != nil {
+		_ = json.Unmarshal(sp.healthCheck.LastBody(), &data.Health)
+	}
+	announce, communities, err := sp.hooks.eval(data)
+	if err != nil {
+		return err
+	}
+	announceChanged := announce != sp.hookAnnounce
+	communitiesChanged := !slices.Equal(communities, sp.hookCommunities)
+	if !announceChanged && !communitiesChanged {
+		return nil
+	}
+	sp.logger.Info("hooks result changed", log.Fields{"announce": announce, "communities": formatCommunities(communities)})
+	sp.hookAnnounce = announce
+	sp.hookCommunities = communities
+	switch {
+	case !announce && sp.advertised.Load():
+		return sp.deletePath(ctx)
+	case announce && announceChanged && sp.healthy && !sp.maintenance && sp.leader:
+		return sp.addPath(ctx)
+	case announce && sp.advertised.Load() && sp.degraded.Load():
+		return sp.degradePath(ctx)
+	case announce && sp.advertised.Load():
+		return sp.addPath(ctx)
+	}
+	return nil
+}
+
+func formatCommunities(communities []uint32) []string {
+	formatted := make([]string, 0, len(communities))
+	for _, c := range communities {
+		formatted = append(formatted, Community(c).String())
+	}
+	return formatted
+}
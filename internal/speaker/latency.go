@@ -0,0 +1,228 @@
+package speaker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	bgpserver "github.com/sir-sukhov/bgp-speaker/internal/bgp"
+)
+
+const (
+	defaultLatencyBudgetMs = 1000
+	adjOutPollInterval     = 10 * time.Millisecond
+	// Сколько ждать появления изменения в глобальной RIB и adj-rib-out, прежде чем считать трассировку неполной.
+	adjOutWaitTimeout = 10 * time.Second
+
+	stageCallbackQueue = "callback_queue"
+	stageGobgpCall     = "gobgp_call"
+	stagePolicy        = "policy"
+	stageAdjRibOut     = "adj_rib_out"
+)
+
+// LatencyStage это длительность одного этапа анонса или отзыва anycast ip.
+type LatencyStage struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// LatencyTrace это последняя трассировка пути от смены статуса до обновления adj-rib-out:
+//   - callback_queue: ожидание pathMu, то есть завершения предыдущего анонса или отзыва
+//   - gobgp_call: вызов AddPath или DeletePath
+//   - policy: применение глобальной политики импорта, выбор лучшего пути и появление изменения в глобальной RIB
+//   - adj_rib_out: применение политики экспорта и появление изменения в adj-rib-out всех established соседей
+type LatencyTrace struct {
+	Reason   string         `json:"reason"`
+	Withdraw bool           `json:"withdraw"`
+	Start    time.Time      `json:"start"`
+	Total    time.Duration  `json:"total"`
+	Stages   []LatencyStage `json:"stages"`
+	Complete bool           `json:"complete"`
+	Exceeded bool           `json:"exceeded"`
+}
+
+type latencyTrace struct {
+	mu     sync.Mutex
+	trace  LatencyTrace
+	last   time.Time
+	closed bool
+	// now возвращает текущее время, в тестах подменяется.
+	now func() time.Time
+}
+
+type latencyTraceKey struct{}
+
+// Функция withLatencyTrace начинает трассировку анонса, вызванного reason, если ctx еще не трассируется.
+func withLatencyTrace(ctx context.Context, reason string) context.Context {
+	if latencyTraceFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, latencyTraceKey{}, newLatencyTrace(reason, time.Now))
+}
+
+func newLatencyTrace(reason string, now func() time.Time) *latencyTrace {
+	start := now()
+	return &latencyTrace{
+		trace: LatencyTrace{Reason: reason, Start: start},
+		last:  start,
+		now:   now,
+	}
+}
+
+func latencyTraceFrom(ctx context.Context) *latencyTrace {
+	t, _ := ctx.Value(latencyTraceKey{}).(*latencyTrace)
+	return t
+}
+
+// Метод mark завершает этап name; для nil ничего не делает.
+func (t *latencyTrace) mark(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.addStage(name)
+}
+
+// Метод addStage добавляет этап name, длившийся с конца предыдущего этапа. Вызывается под mu.
+func (t *latencyTrace) addStage(name string) {
+	now := t.now()
+	t.trace.Stages = append(t.trace.Stages, LatencyStage{Name: name, Duration: now.Sub(t.last)})
+	t.last = now
+}
+
+// Метод finishLatencyTrace в фоне дожидается, пока изменение prefix появится в глобальной RIB
+// и в adj-rib-out, и сообщает, если весь путь занял больше latency_budget_ms.
+func (sp *Speaker) finishLatencyTrace(ctx context.Context, prefix string, withdraw bool) {
+	t := latencyTraceFrom(ctx)
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	t.trace.Withdraw = withdraw
+	t.mu.Unlock()
+	// Stop обнуляет sp.s после остановки BGP, поэтому сервер читается до запуска горутины,
+	// а Stop дожидается latencyTraces, отменив runCtx.
+	s := sp.s
+	runCtx := sp.runCtx
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+	sp.latencyTraces.Add(1)
+	go func() {
+		defer sp.latencyTraces.Done()
+		ctx, cancel := context.WithTimeout(runCtx, adjOutWaitTimeout)
+		defer cancel()
+		complete := sp.waitConverged(ctx, func(ctx context.Context) bool { return globalRIBConverged(ctx, s, prefix, withdraw) })
+		t.mu.Lock()
+		t.addStage(stagePolicy)
+		t.mu.Unlock()
+		complete = complete && sp.waitConverged(ctx, func(ctx context.Context) bool { return adjOutConverged(ctx, s, prefix, withdraw) })
+		if runCtx.Err() != nil {
+			// Speaker останавливается, незавершенная трассировка не означает превышение бюджета.
+			return
+		}
+		t.mu.Lock()
+		t.addStage(stageAdjRibOut)
+		t.trace.Total = t.last.Sub(t.trace.Start)
+		t.trace.Complete = complete
+		t.trace.Exceeded = !complete || t.trace.Total > sp.latencyBudget()
+		trace := t.trace
+		t.mu.Unlock()
+		sp.lastLatency.Store(&trace)
+		fields := log.Fields{"reason": trace.Reason, "withdraw": trace.Withdraw, "total": trace.Total.String(), "complete": trace.Complete}
+		for _, s := range trace.Stages {
+			fields[s.Name] = s.Duration.String()
+		}
+		if trace.Exceeded {
+			sp.latencyExceeded.Add(1)
+			fields["budget"] = sp.latencyBudget().String()
+			sp.logger.Warn("announce latency budget exceeded", fields)
+			return
+		}
+		sp.logger.Debug("announce latency", fields)
+	}()
+}
+
+// Метод waitConverged опрашивает converged, пока тот не вернет true; возвращает false,
+// если не дождался до завершения ctx.
+func (sp *Speaker) waitConverged(ctx context.Context, converged func(context.Context) bool) bool {
+	ticker := time.NewTicker(adjOutPollInterval)
+	defer ticker.Stop()
+	for {
+		if converged(ctx) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+func globalRIBConverged(ctx context.Context, s bgpserver.Server, prefix string, withdraw bool) bool {
+	found := false
+	err := s.ListPath(ctx, &api.ListPathRequest{
+		TableType: api.TableType_GLOBAL,
+		Family:    &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
+		Prefixes:  []*api.TableLookupPrefix{{Prefix: prefix, Type: api.TableLookupPrefix_EXACT}},
+	}, func(d *api.Destination) {
+		found = found || d.Prefix == prefix
+	})
+	return err == nil && found != withdraw
+}
+
+func adjOutConverged(ctx context.Context, s bgpserver.Server, prefix string, withdraw bool) bool {
+	neighbors := []string{}
+	err := s.ListPeer(ctx, &api.ListPeerRequest{}, func(p *api.Peer) {
+		if p.GetState().GetSessionState() == api.PeerState_ESTABLISHED {
+			neighbors = append(neighbors, p.GetConf().GetNeighborAddress())
+		}
+	})
+	if err != nil {
+		return false
+	}
+	for _, neighbor := range neighbors {
+		found := false
+		err := s.ListPath(ctx, &api.ListPathRequest{
+			TableType: api.TableType_ADJ_OUT,
+			Name:      neighbor,
+			Family:    &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
+			Prefixes:  []*api.TableLookupPrefix{{Prefix: prefix, Type: api.TableLookupPrefix_EXACT}},
+		}, func(d *api.Destination) {
+			found = found || d.Prefix == prefix
+		})
+		if err != nil || found == withdraw {
+			return false
+		}
+	}
+	return true
+}
+
+func (sp *Speaker) latencyBudget() time.Duration {
+	budget := uint32(defaultLatencyBudgetMs)
+	if sp.config.LatencyBudgetMs != 0 {
+		budget = sp.config.LatencyBudgetMs
+	}
+	return time.Millisecond * time.Duration(budget)
+}
+
+// latencyStats это счетчики для /metrics, обновляются из finishLatencyTrace,
+// latencyTraces это незавершенные трассировки, их дожидается Stop.
+type latencyStats struct {
+	lastLatency     atomic.Pointer[LatencyTrace]
+	latencyExceeded atomic.Uint64
+	latencyTraces   sync.WaitGroup
+}
@@ -0,0 +1,128 @@
+package speaker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/sir-sukhov/bgp-speaker/internal/bgp"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeClock сдвигает время на step при каждом вызове now.
+type fakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+	step    time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.current
+	c.current = c.current.Add(c.step)
+	return now
+}
+
+func TestLatencyTrace(t *testing.T) {
+	tests := []struct {
+		name         string
+		withdraw     bool
+		budgetMs     uint32
+		wantExceeded bool
+	}{
+		{name: "announce within budget", budgetMs: 100},
+		{name: "announce exceeds budget", budgetMs: 30, wantExceeded: true},
+		{name: "withdraw within budget", withdraw: true, budgetMs: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := bgp.NewFake()
+			sp := &Speaker{
+				logger: NewLogger(logrus.PanicLevel),
+				config: Config{AnycastIP: "10.0.0.1", ASN: 65001, LatencyBudgetMs: tt.budgetMs},
+				s:      fake,
+			}
+			clock := &fakeClock{current: time.Unix(0, 0), step: 10 * time.Millisecond}
+			trace := newLatencyTrace("test", clock.now)
+			ctx := context.WithValue(context.Background(), latencyTraceKey{}, trace)
+
+			latencyTraceFrom(ctx).mark(stageCallbackQueue)
+			path, err := sp.anycastPath(pathAttrs{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.withdraw {
+				if _, err := fake.AddPath(ctx, &api.AddPathRequest{Path: path}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			latencyTraceFrom(ctx).mark(stageGobgpCall)
+			sp.finishLatencyTrace(ctx, sp.config.AnycastIP+"/32", tt.withdraw)
+			// После завершения трассировки этапы больше не добавляются.
+			latencyTraceFrom(ctx).mark(stageCallbackQueue)
+
+			sp.latencyTraces.Wait()
+			got := sp.lastLatency.Load()
+			if got == nil {
+				t.Fatal("latency trace is not finished")
+			}
+			wantStages := []string{stageCallbackQueue, stageGobgpCall, stagePolicy, stageAdjRibOut}
+			if len(got.Stages) != len(wantStages) {
+				t.Fatalf("got stages %v, want %v", got.Stages, wantStages)
+			}
+			for i, stage := range got.Stages {
+				if stage.Name != wantStages[i] || stage.Duration != clock.step {
+					t.Errorf("stage %d is %s %s, want %s %s", i, stage.Name, stage.Duration, wantStages[i], clock.step)
+				}
+			}
+			if got.Total != 4*clock.step {
+				t.Errorf("total is %s, want %s", got.Total, 4*clock.step)
+			}
+			if !got.Complete || got.Withdraw != tt.withdraw || got.Exceeded != tt.wantExceeded {
+				t.Errorf("complete %t, withdraw %t, exceeded %t, want true, %t, %t", got.Complete, got.Withdraw, got.Exceeded, tt.withdraw, tt.wantExceeded)
+			}
+			wantCount := uint64(0)
+			if tt.wantExceeded {
+				wantCount = 1
+			}
+			if count := sp.latencyExceeded.Load(); count != wantCount {
+				t.Errorf("latency exceeded counter is %d, want %d", count, wantCount)
+			}
+		})
+	}
+}
+
+func TestLatencyTraceStop(t *testing.T) {
+	fake := bgp.NewFake()
+	ctx, cancel := context.WithCancel(context.Background())
+	sp := &Speaker{
+		logger: NewLogger(logrus.PanicLevel),
+		config: Config{AnycastIP: "10.0.0.1", ASN: 65001},
+		s:      fake,
+		runCtx: ctx,
+	}
+	// Путь не добавлен, поэтому трассировка ждет его появления в RIB до отмены runCtx.
+	traceCtx := withLatencyTrace(context.Background(), "test")
+	sp.finishLatencyTrace(traceCtx, sp.config.AnycastIP+"/32", false)
+	sp.s = nil
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		sp.latencyTraces.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("latency trace is not stopped by cancel of run context")
+	}
+	if got := sp.lastLatency.Load(); got != nil {
+		t.Errorf("trace interrupted by stop is recorded: %+v", got)
+	}
+	if count := sp.latencyExceeded.Load(); count != 0 {
+		t.Errorf("latency exceeded counter is %d, want 0", count)
+	}
+}
@@ -0,0 +1,36 @@
+package speaker
+
+import (
+	"context"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+// SetLeader вызывается при смене лидерства, если настроен leader_election.
+//
+// Standby реплика держит сессии BGP, но не анонсирует anycast ip; при получении
+// лидерства anycast ip анонсируется, если сервис healthy.
+func (sp *Speaker) SetLeader(ctx context.Context, leader bool) error {
+	ctx = withAuditCause(ctx, auditCauseLeader, "")
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	if sp.leader == leader {
+		return nil
+	}
+	sp.logger.Warn("leader state changed", log.Fields{"leader": leader})
+	if !leader && sp.advertised.Load() {
+		if err := sp.deletePath(ctx); err != nil {
+			return err
+		}
+	}
+	if leader && sp.healthy && sp.hookAnnounce && !sp.maintenance {
+		if err := sp.addPath(ctx); err != nil {
+			return err
+		}
+	}
+	sp.leader = leader
+	if err := sp.holdPrefixes(ctx, !leader || sp.maintenance); err != nil {
+		return err
+	}
+	return sp.reconcilePrefixes(ctx)
+}
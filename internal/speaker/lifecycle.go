@@ -0,0 +1,366 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/server"
+	"github.com/sir-sukhov/bgp-speaker/internal/defaults"
+	"github.com/sir-sukhov/bgp-speaker/internal/kubernetes"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// New создает Speaker из уже загруженной конфигурации: подставляет значения по-умолчанию
+// и проверяет ее так же, как [LoadConfig]. Если logger равен nil, используется логгер по-умолчанию
+// с выводом из log_output, иначе log_output не применяется.
+func New(config Config, logger *Logger) (*Speaker, error) {
+	if err := config.importGoBGPConfig(); err != nil {
+		return nil, err
+	}
+	if err := config.applyDefaults(); err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = NewLogger(logrus.InfoLevel)
+		if err := logger.SetOutput(config.LogOutput, config.Syslog); err != nil {
+			return nil, err
+		}
+	}
+	sp := &Speaker{
+		logger: logger,
+		config: config,
+	}
+	if err := sp.init(); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// SetHealthCheck заменяет проверку здоровья из конфигурации на check: anycast ip
+// анонсируется, пока check не возвращает ошибку.
+func (sp *Speaker) SetHealthCheck(check func(context.Context) error) {
+	sp.customCheck = check
+}
+
+// SetFIBEnabled включает или выключает синхронизацию маршрута по-умолчанию в ядро через netlink.
+func (sp *Speaker) SetFIBEnabled(enabled bool) {
+	sp.fibDisabled = !enabled
+}
+
+// Start запускает BGP, настраивает политики и соседей и запускает в фоне проверку здоровья,
+// admin API и остальные задачи. Задачи работают, пока не завершится ctx или не будет вызван
+// [Speaker.Stop]; дождаться их можно через [Speaker.Wait].
+func (sp *Speaker) Start(ctx context.Context) error {
+	state, err := sp.takeOverHandoff()
+	if err != nil {
+		return err
+	}
+	sp.tookOver = state
+	if state != nil {
+		sp.pathMu.Lock()
+		sp.maintenance = state.Maintenance
+		sp.prefixMu.Lock()
+		sp.prefixesHeld = sp.maintenance || !sp.leader
+		sp.prefixMu.Unlock()
+		sp.pathMu.Unlock()
+	}
+	ctx, sp.cancel = context.WithCancel(ctx)
+	sp.runCtx = ctx
+	if sp.s == nil && sp.config.RemoteGoBGP != nil {
+		remote, err := sp.dialRemoteGoBGP()
+		if err != nil {
+			return err
+		}
+		sp.remoteBgp = remote
+		sp.s = remote
+	} else if sp.s == nil {
+		bgpServer := server.NewBgpServer(server.GrpcListenAddress(defaults.GRPCAddress), server.LoggerOption(sp.logger))
+		go bgpServer.Serve()
+		sp.bgpServer = bgpServer
+		sp.s = bgpServer
+	}
+
+	sp.resolveUnnumbered(ctx)
+	if sp.config.LLDPDiscovery != nil {
+		sp.discoverLLDPNeighbors(ctx)
+	}
+	sp.resolveDNSNeighbors(ctx)
+	if metric, ok := sp.netlinkFIBMetric(); ok {
+		if err := sp.resolveVRF(); err != nil {
+			return sp.abortStart(err)
+		}
+		for _, m := range sp.fibMetrics(metric) {
+			if err := sp.checkFIBConflicts(m); err != nil {
+				return sp.abortStart(err)
+			}
+		}
+	}
+	if err := sp.setupAnycastInterface(); err != nil {
+		return sp.abortStart(err)
+	}
+	startupCtx := withAuditCause(ctx, auditCauseStartup, "")
+	if sp.hooks != nil {
+		if err := sp.applyHooks(startupCtx); err != nil {
+			return sp.abortStart(err)
+		}
+	}
+	sp.restarting = state != nil
+	err = sp.setup(startupCtx)
+	sp.restarting = false
+	if err != nil {
+		return sp.abortStart(err)
+	}
+	if state != nil {
+		if err := sp.restoreHandoffState(withAuditCause(ctx, auditCauseStartup, "handoff"), state); err != nil {
+			return sp.abortStart(err)
+		}
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sp.eg = eg
+
+	if sp.config.advertiseEnabled() {
+		if err := sp.runHealthChecks(ctx, eg); err != nil {
+			return sp.abortStart(fmt.Errorf("error creating health check: %w", err))
+		}
+	} else {
+		sp.logger.Info("advertise is disabled, only programming received routes into fib", nil)
+	}
+	if sp.hooks != nil {
+		eg.Go(func() error {
+			return sp.RunHooks(ctx)
+		})
+	}
+
+	eg.Go(func() error {
+		return sp.handleMaintenanceSignals(ctx)
+	})
+	eg.Go(func() error {
+		return sp.ServeAdmin(ctx)
+	})
+	if sp.config.Handoff != nil {
+		eg.Go(func() error {
+			return sp.ServeHandoff(ctx)
+		})
+	}
+	if sp.config.ProbeAddress != "" {
+		eg.Go(func() error {
+			return sp.ServeProbes(ctx)
+		})
+	}
+
+	if sp.config.Verification != nil && len(sp.config.Verification.URLs) > 0 {
+		eg.Go(func() error {
+			return sp.RunVerification(ctx)
+		})
+	}
+
+	if sp.config.LeaderElection != nil {
+		elector, err := kubernetes.NewLeaderElector(*sp.config.LeaderElection, sp.SetLeader, sp.logger)
+		if err != nil {
+			return sp.abortStart(fmt.Errorf("error creating leader elector: %w", err))
+		}
+		eg.Go(func() error {
+			return elector.Run(ctx)
+		})
+	}
+
+	if sp.config.Kubernetes != nil {
+		controller, err := kubernetes.NewController(*sp.config.Kubernetes, sp, sp.logger)
+		if err != nil {
+			return sp.abortStart(fmt.Errorf("error creating kubernetes controller: %w", err))
+		}
+		eg.Go(func() error {
+			return controller.Run(withAuditCause(ctx, auditCauseKubernetes, ""))
+		})
+	}
+
+	if sp.config.PeerFlapDampening != nil {
+		eg.Go(func() error {
+			return sp.DampenPeerFlaps(ctx)
+		})
+	}
+	if sp.config.PeerEvents != nil {
+		eg.Go(func() error {
+			return sp.WatchPeerEvents(ctx)
+		})
+	}
+	if sp.config.LLDPDiscovery != nil {
+		eg.Go(func() error {
+			return sp.DiscoverLLDPNeighbors(ctx)
+		})
+	}
+	if sp.config.LLDPDiscovery != nil && sp.config.LLDPDiscovery.ASNRange != nil {
+		eg.Go(func() error {
+			return sp.EnforceLLDPASNRange(ctx)
+		})
+	}
+	if len(sp.unnumberedNeighbors) > 0 {
+		eg.Go(func() error {
+			return sp.DiscoverUnnumberedNeighbors(ctx)
+		})
+	}
+	if len(sp.dnsNeighbors) > 0 {
+		eg.Go(func() error {
+			return sp.ResolveNeighbors(ctx)
+		})
+	}
+	if len(sp.trackedInterfaces()) > 0 {
+		eg.Go(func() error {
+			return sp.TrackInterfaces(ctx)
+		})
+	}
+
+	if sp.prefixLimitRestartEnabled() {
+		eg.Go(func() error {
+			return sp.RestartPrefixLimitedPeers(ctx)
+		})
+	}
+
+	if metric, ok := sp.netlinkFIBMetric(); ok {
+		sp.linuxRouteMetric = metric
+		eg.Go(func() error {
+			return sp.UpdateFIB(ctx)
+		})
+	}
+
+	return nil
+}
+
+// Метод abortStart останавливает то, что [Speaker.Start] успел запустить до ошибки err:
+// фоновые задачи, анонс anycast ip и BGP сервер, и возвращает err. Сервер, переданный через
+// SetBgpServer, не останавливается, но на нем вызывается StopBgp. После abortStart
+// [Speaker.Stop] ничего не делает.
+func (sp *Speaker) abortStart(err error) error {
+	sp.stopOnce.Do(func() {
+		sp.cancel()
+		_ = sp.Wait()
+		sp.eg = nil
+		sp.latencyTraces.Wait()
+		if sp.advertised.Load() {
+			if err := sp.deletePath(withAuditCause(context.Background(), auditCauseShutdown, "")); err != nil {
+				sp.logger.Error(fmt.Sprintf("failed to withdraw anycast ip: %s", err.Error()), nil)
+			}
+		}
+		if sp.bgpServer == nil && sp.remoteBgp == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := sp.stopBgp(ctx); err != nil {
+				sp.logger.Error(fmt.Sprintf("failed to stop bgp server: %s", err.Error()), nil)
+			}
+		}
+		sp.stopOwnBgpServer()
+	})
+	return err
+}
+
+// Метод runHealthChecks запускает в eg проверку здоровья anycast ip и проверки из health_checks.
+func (sp *Speaker) runHealthChecks(ctx context.Context, eg *errgroup.Group) error {
+	healthCheck, err := sp.newHealthCheck()
+	if err != nil {
+		return err
+	}
+	sp.pathMu.Lock()
+	sp.healthCheck = healthCheck
+	sp.pathMu.Unlock()
+	eg.Go(func() error {
+		return healthCheck.Run(ctx, *sp.logger)
+	})
+	return sp.runPrefixHealthChecks(ctx, eg)
+}
+
+// Wait ждет завершения фоновых задач, запущенных [Speaker.Start], и возвращает первую ошибку.
+func (sp *Speaker) Wait() error {
+	if sp.eg == nil {
+		return nil
+	}
+	return sp.eg.Wait()
+}
+
+// Drain уводит трафик со speaker, не разрывая сессии: включается режим обслуживания, в котором
+// anycast ip анонсируется с community GRACEFUL_SHUTDOWN, если задан graceful_shutdown_seconds,
+// затем отзывается, и проверка здоровья не анонсирует его снова.
+func (sp *Speaker) Drain(ctx context.Context) error {
+	return sp.SetMaintenance(ctx, true)
+}
+
+// Stop останавливает фоновые задачи (маршрут по-умолчанию удаляется из ядра, если не задан
+// preserve_fib_on_exit) и запись message_capture, отзывает anycast ip и снимает его
+// с anycast_interface, выключает соседей с shutdown_message и останавливает BGP.
+//
+// Если управление передано новому процессу через handoff, Stop только останавливает фоновые
+// задачи: сессии BGP закрываются без NOTIFICATION вместе с процессом, который должен завершиться.
+//
+// Ошибки остановки пишутся в лог, возвращается ошибка остановки BGP. Повторные вызовы Stop,
+// а также Stop после неудачного Start ничего не делают.
+func (sp *Speaker) Stop(ctx context.Context) error {
+	sp.stopOnce.Do(func() {
+		sp.stopErr = sp.stop(ctx)
+	})
+	return sp.stopErr
+}
+
+func (sp *Speaker) stop(ctx context.Context) error {
+	if sp.cancel != nil {
+		sp.cancel()
+	}
+	_ = sp.Wait()
+	sp.latencyTraces.Wait()
+	if sp.s == nil {
+		// Start не вызывался.
+		return nil
+	}
+	sp.pathMu.Lock()
+	sp.stopMaintenanceWithdraw()
+	sp.pathMu.Unlock()
+	sp.StopCapture()
+	if sp.handedOff.Load() {
+		sp.logger.Info("leaving bgp sessions and fib to new speaker process", nil)
+		return nil
+	}
+	if err := sp.gracefulShutdown(ctx); err != nil {
+		sp.logger.Error(fmt.Sprintf("graceful shutdown failed: %s", err.Error()), nil)
+	}
+	sp.removeAnycastAddress()
+	sp.auditShutdown(ctx)
+	sp.logger.Info("shutting down bgp", nil)
+	// Доставка NOTIFICATION соседям не должна съедать время на остановку BGP.
+	shutdownCtx, cancelShutdown := context.WithTimeout(ctx, time.Second)
+	defer cancelShutdown()
+	if err := sp.shutdownPeers(shutdownCtx, sp.config.ShutdownMessage); err != nil {
+		sp.logger.Error(fmt.Sprintf("failed to shutdown peers: %s", err.Error()), nil)
+	}
+	stopCtx, cancelStop := context.WithTimeout(ctx, time.Second)
+	defer cancelStop()
+	err := sp.stopBgp(stopCtx)
+	if err != nil {
+		sp.logger.Error(fmt.Sprintf("failed to stop bgp server: %s", err.Error()), nil)
+	}
+	sp.stopOwnBgpServer()
+	return err
+}
+
+// Метод stopOwnBgpServer останавливает BGP сервер, если его создал [Speaker.Start],
+// или удаляет созданное speaker в удаленном gobgpd и закрывает соединение с ним.
+func (sp *Speaker) stopOwnBgpServer() {
+	if sp.bgpServer != nil {
+		sp.bgpServer.Stop()
+		sp.bgpServer = nil
+		sp.s = nil
+	}
+	if sp.remoteBgp != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := sp.remoteBgp.StopBgp(ctx, nil); err != nil {
+			sp.logger.Error(fmt.Sprintf("failed to clean up remote gobgpd: %s", err.Error()), nil)
+		}
+		_ = sp.remoteBgp.Close()
+		sp.remoteBgp = nil
+		sp.s = nil
+	}
+}
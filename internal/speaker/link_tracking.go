@@ -0,0 +1,53 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+	"golang.org/x/exp/maps"
+)
+
+// Метод trackedInterfaces возвращает адреса соседей по интерфейсам из track_interface.
+func (sp *Speaker) trackedInterfaces() map[string][]string {
+	tracked := map[string][]string{}
+	for _, n := range sp.neighbors() {
+		if n.TrackInterface != "" {
+			tracked[n.TrackInterface] = append(tracked[n.TrackInterface], n.Address)
+		}
+	}
+	return tracked
+}
+
+// TrackInterfaces следит за интерфейсами из track_interface соседей и выключает сессии
+// с соседями, когда их интерфейс падает, не дожидаясь hold timer: anycast ip перестает
+// анонсироваться через этот uplink, а next-hop соседа пропадает из RIB и на следующем
+// обновлении FIB из маршрута по-умолчанию. Когда интерфейс поднимается, сессии включаются снова.
+func (sp *Speaker) TrackInterfaces(ctx context.Context) error {
+	tracked := sp.trackedInterfaces()
+	names := maps.Keys(tracked)
+	slices.Sort(names)
+	return nl.WatchLinkState(ctx, names, func(name string, up bool) {
+		for _, address := range tracked[name] {
+			if err := sp.setLinkState(ctx, name, address, up); err != nil {
+				sp.logger.Error("failed to change neighbor state on link state change", log.Fields{"interface": name, "neighbor": address, "error": err.Error()})
+			}
+		}
+	})
+}
+
+func (sp *Speaker) setLinkState(ctx context.Context, name, address string, up bool) error {
+	if up && sp.peerDisabled(address) {
+		sp.logger.Info("tracked interface is up, neighbor is disabled via admin api", log.Fields{"interface": name, "neighbor": address})
+		return nil
+	}
+	if up {
+		sp.logger.Info("tracked interface is up, enabling neighbor", log.Fields{"interface": name, "neighbor": address})
+		return sp.s.EnablePeer(ctx, &api.EnablePeerRequest{Address: address})
+	}
+	sp.logger.Warn("tracked interface is down, shutting down neighbor", log.Fields{"interface": name, "neighbor": address})
+	return sp.s.ShutdownPeer(ctx, &api.ShutdownPeerRequest{Address: address, Communication: fmt.Sprintf("interface %s is down", name)})
+}
@@ -0,0 +1,38 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Метод advertiseEnabled возвращает false, если задан advertise: false, то есть режим
+// слушателя: speaker поднимает сессии и программирует в ядро полученные маршруты,
+// но ничего не анонсирует. anycast_ip в этом режиме используется только как router-id.
+func (c *Config) advertiseEnabled() bool {
+	return c.Advertise == nil || *c.Advertise
+}
+
+// Метод validateListener проверяет, что в режиме слушателя не заданы настройки анонса.
+func (c *Config) validateListener() error {
+	if c.advertiseEnabled() {
+		return nil
+	}
+	errs := []error{}
+	for _, field := range []struct {
+		name string
+		set  bool
+	}{
+		{"prefixes", len(c.Prefixes) > 0},
+		{"kubernetes", c.Kubernetes != nil},
+		{"verification", c.Verification != nil},
+		{"soft_fail", c.SoftFail != nil},
+	} {
+		if field.set {
+			errs = append(errs, fmt.Errorf("%s: requires advertise: true", field.name))
+		}
+	}
+	if !c.fibManaged() {
+		errs = append(errs, errors.New("advertise: false requires manage_fib: true, otherwise speaker has nothing to do"))
+	}
+	return errors.Join(errs...)
+}
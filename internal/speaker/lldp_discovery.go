@@ -0,0 +1,226 @@
+package speaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+const (
+	// defaultLLDPTimeoutSeconds это два стандартных интервала отправки LLDP (msgTxInterval).
+	defaultLLDPTimeoutSeconds = 60
+	// lldpRetryInterval это пауза перед повторным приемом LLDP после ошибки.
+	lldpRetryInterval = 10 * time.Second
+)
+
+// LLDPDiscovery задает поиск соседей по LLDP: на каждом uplink интерфейсе ждется LLDPDU от ToR,
+// и его management адрес добавляется в neighbors при старте или позже, когда LLDPDU придет.
+type LLDPDiscovery struct {
+	Interfaces []string `yaml:"interfaces"`
+	// ASN это ASN найденных соседей. Вместо него можно задать ASNRange.
+	ASN uint32 `yaml:"asn"`
+	// ASNRange принимает у найденных соседей любой ASN из диапазона: сессия с соседом
+	// из другого ASN выключается после установки.
+	ASNRange *ASNRange `yaml:"asn_range"`
+	// TimeoutSeconds это сколько при старте ждать LLDPDU на интерфейсах перед запуском BGP,
+	// по-умолчанию 60. Соседи, не найденные за это время, добавляются в фоне.
+	TimeoutSeconds uint32 `yaml:"timeout_seconds"`
+}
+
+// ASNRange это диапазон ASN включительно.
+type ASNRange struct {
+	Min uint32 `yaml:"min"`
+	Max uint32 `yaml:"max"`
+}
+
+func (r ASNRange) contains(asn uint32) bool {
+	return asn >= r.Min && asn <= r.Max
+}
+
+func (d *LLDPDiscovery) applyDefaults() {
+	if d.TimeoutSeconds == 0 {
+		d.TimeoutSeconds = defaultLLDPTimeoutSeconds
+	}
+}
+
+func (d *LLDPDiscovery) validate() error {
+	errs := []error{}
+	if len(d.Interfaces) == 0 {
+		errs = append(errs, errors.New("lldp_discovery.interfaces: at least one interface is required"))
+	}
+	if (d.ASN == 0) == (d.ASNRange == nil) {
+		errs = append(errs, errors.New("lldp_discovery: exactly one of asn and asn_range is required"))
+	}
+	if r := d.ASNRange; r != nil && (r.Min == 0 || r.Min > r.Max) {
+		errs = append(errs, errors.New("lldp_discovery.asn_range: min must be greater than 0 and not greater than max"))
+	}
+	return errors.Join(errs...)
+}
+
+// Метод discoverLLDPNeighbors до timeout_seconds ждет LLDPDU на каждом интерфейсе из lldp_discovery
+// и добавляет найденных соседей в конфигурацию перед стартом BGP. Интерфейс, на котором LLDPDU
+// не пришел, не мешает старту: на нем сосед ищется дальше в [Speaker.DiscoverLLDPNeighbors].
+func (sp *Speaker) discoverLLDPNeighbors(ctx context.Context) {
+	conf := sp.config.LLDPDiscovery
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(conf.TimeoutSeconds))
+	defer cancel()
+	discovered := make([]*nl.LLDPNeighbor, len(conf.Interfaces))
+	var wg sync.WaitGroup
+	for i, ifName := range conf.Interfaces {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			neighbor, err := nl.ReceiveLLDP(ctx, ifName)
+			if err != nil {
+				sp.logger.Warn("lldp neighbor is not discovered at startup, discovery continues in background", log.Fields{"interface": ifName, "error": err.Error()})
+				return
+			}
+			discovered[i] = &neighbor
+		}()
+	}
+	wg.Wait()
+	for i, ifName := range conf.Interfaces {
+		if discovered[i] == nil {
+			continue
+		}
+		address := lldpNeighborAddress(discovered[i].ManagementAddresses, ifName)
+		if slices.ContainsFunc(sp.config.Neighbors, func(n Neighbor) bool { return sameNeighbor(n.Address, address) }) {
+			sp.logger.Info("lldp neighbor is already configured", log.Fields{"interface": ifName, "neighbor": address})
+			continue
+		}
+		sp.logDiscoveredLLDPNeighbor(ifName, address, *discovered[i])
+		sp.config.Neighbors = append(sp.config.Neighbors, sp.lldpNeighbor(ifName, address))
+	}
+}
+
+// DiscoverLLDPNeighbors слушает LLDP на интерфейсах из lldp_discovery после старта: сосед добавляется,
+// как только на интерфейсе без соседа приходит LLDPDU, а если management адрес соседа изменился,
+// прежний сосед заменяется новым. Ошибки приема пишутся в лог и повторяются через lldpRetryInterval.
+func (sp *Speaker) DiscoverLLDPNeighbors(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, ifName := range sp.config.LLDPDiscovery.Interfaces {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				received, err := nl.ReceiveLLDP(ctx, ifName)
+				if err == nil {
+					sp.syncLLDPNeighbor(ctx, ifName, received)
+					continue
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				sp.logger.Warn("error receiving lldp", log.Fields{"interface": ifName, "error": err.Error()})
+				select {
+				case <-ctx.Done():
+				case <-time.After(lldpRetryInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// Метод syncLLDPNeighbor приводит соседа, найденного по LLDP на интерфейсе ifName, к received.
+func (sp *Speaker) syncLLDPNeighbor(ctx context.Context, ifName string, received nl.LLDPNeighbor) {
+	address := lldpNeighborAddress(received.ManagementAddresses, ifName)
+	current := sp.neighbors()
+	if slices.ContainsFunc(current, func(n Neighbor) bool { return sameNeighbor(n.Address, address) }) {
+		return
+	}
+	for _, n := range current {
+		if n.lldpInterface != ifName {
+			continue
+		}
+		sp.logger.Info("lldp neighbor address changed, removing previous neighbor", log.Fields{"interface": ifName, "neighbor": n.Address})
+		if !sp.removeRuntimeNeighbor(ctx, n) {
+			return
+		}
+	}
+	sp.logDiscoveredLLDPNeighbor(ifName, address, received)
+	sp.addRuntimeNeighbor(ctx, sp.lldpNeighbor(ifName, address))
+}
+
+// Метод lldpNeighbor возвращает соседа с адресом address, найденного по LLDP на интерфейсе ifName:
+// сессия привязывается к этому интерфейсу.
+func (sp *Speaker) lldpNeighbor(ifName, address string) Neighbor {
+	ip := net.ParseIP(address)
+	return Neighbor{
+		Address:         address,
+		ASN:             sp.config.LLDPDiscovery.ASN,
+		BindInterface:   ifName,
+		ExtendedNexthop: ip == nil || ip.To4() == nil,
+		lldpInterface:   ifName,
+	}
+}
+
+func (sp *Speaker) logDiscoveredLLDPNeighbor(ifName, address string, received nl.LLDPNeighbor) {
+	sp.logger.Info("discovered lldp neighbor", log.Fields{
+		"interface": ifName,
+		"neighbor":  address,
+		"system":    received.SystemName,
+		"chassis":   received.ChassisID,
+		"port":      received.PortID,
+	})
+}
+
+// Функция lldpNeighborAddress выбирает адрес соседа из management адресов, link-local адрес
+// дополняется зоной интерфейса.
+func lldpNeighborAddress(addresses []net.IP, ifName string) string {
+	ip := addresses[0]
+	if i := slices.IndexFunc(addresses, func(ip net.IP) bool { return ip.To4() != nil }); i >= 0 {
+		ip = addresses[i]
+	}
+	if ip.IsLinkLocalUnicast() && ip.To4() == nil {
+		return fmt.Sprintf("%s%%%s", ip, ifName)
+	}
+	return ip.String()
+}
+
+// EnforceLLDPASNRange выключает сессии с соседями, найденными по LLDP с asn_range,
+// если сосед прислал в OPEN ASN не из диапазона.
+func (sp *Speaker) EnforceLLDPASNRange(ctx context.Context) error {
+	asnRange := *sp.config.LLDPDiscovery.ASNRange
+	peers := make(chan *api.Peer, 64)
+	err := sp.s.WatchEvent(ctx, &api.WatchEventRequest{Peer: &api.WatchEventRequest_Peer{}}, func(resp *api.WatchEventResponse) {
+		if p := resp.GetPeer().GetPeer(); p != nil && resp.GetPeer().GetType() == api.WatchEventResponse_PeerEvent_STATE {
+			select {
+			case peers <- p:
+			case <-ctx.Done():
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error watching peer events: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case p := <-peers:
+			address := p.GetConf().GetNeighborAddress()
+			asn := p.GetState().GetPeerAsn()
+			if p.GetState().GetSessionState() != api.PeerState_ESTABLISHED || asnRange.contains(asn) {
+				continue
+			}
+			// ASN 0 в конфигурации бывает только у соседей, найденных по LLDP с asn_range.
+			if !slices.ContainsFunc(sp.neighbors(), func(n Neighbor) bool { return n.ASN == 0 && sameNeighbor(n.Address, address) }) {
+				continue
+			}
+			sp.logger.Warn("lldp neighbor asn is out of asn_range, shutting it down", log.Fields{"neighbor": address, "asn": asn, "min": asnRange.Min, "max": asnRange.Max})
+			if err := sp.s.ShutdownPeer(ctx, &api.ShutdownPeerRequest{Address: address, Communication: "asn is out of range"}); err != nil {
+				sp.logger.Error("error shutting down peer", log.Fields{"neighbor": address, "error": err.Error()})
+			}
+		}
+	}
+}
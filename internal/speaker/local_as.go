@@ -0,0 +1,40 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LocalAS задает ASN, который speaker представляет соседу вместо asn, например, на время
+// перенумерации ASN, чтобы старые соседи продолжали видеть прежний ASN.
+//
+// gobgp поддерживает только поведение no-prepend replace-as: в AS_PATH анонсов соседу
+// добавляется только ASN из local_as, без asn, а к AS_PATH маршрутов от соседа local_as
+// не добавляется. Поэтому NoPrepend и ReplaceAS по-умолчанию true и не могут быть false.
+type LocalAS struct {
+	ASN       uint32 `yaml:"asn"`
+	NoPrepend *bool  `yaml:"no_prepend"`
+	ReplaceAS *bool  `yaml:"replace_as"`
+}
+
+func (l *LocalAS) validate(field string) error {
+	errs := []error{}
+	if l.ASN == 0 {
+		errs = append(errs, fmt.Errorf("%s.local_as.asn: is required and must be greater than 0", field))
+	}
+	if l.NoPrepend != nil && !*l.NoPrepend {
+		errs = append(errs, fmt.Errorf("%s.local_as.no_prepend: false is not supported by gobgp", field))
+	}
+	if l.ReplaceAS != nil && !*l.ReplaceAS {
+		errs = append(errs, fmt.Errorf("%s.local_as.replace_as: false is not supported by gobgp", field))
+	}
+	return errors.Join(errs...)
+}
+
+// Метод localASN возвращает ASN, который speaker представляет соседу n.
+func (c *Config) localASN(n Neighbor) uint32 {
+	if n.LocalAS != nil {
+		return n.LocalAS.ASN
+	}
+	return c.ASN
+}
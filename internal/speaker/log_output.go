@@ -0,0 +1,225 @@
+package speaker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"gopkg.in/yaml.v3"
+)
+
+// LogOutput выбирает, куда пишется лог:
+//   - stderr (по-умолчанию)
+//   - syslog пишет в локальный или удаленный syslog, см. [SyslogOutput]
+//   - journald пишет в systemd-journald по native протоколу, поля записи становятся полями журнала
+//
+// Уровни logrus переводятся в severity syslog: panic и fatal в crit, error в err,
+// warn в warning, info в info, debug и trace в debug.
+type LogOutput string
+
+const (
+	LogOutputStderr   LogOutput = "stderr"
+	LogOutputSyslog   LogOutput = "syslog"
+	LogOutputJournald LogOutput = "journald"
+)
+
+func (o *LogOutput) UnmarshalYAML(node *yaml.Node) error {
+	switch output := LogOutput(node.Value); output {
+	case LogOutputStderr, LogOutputSyslog, LogOutputJournald:
+		*o = output
+		return nil
+	default:
+		return fmt.Errorf("unknown log_output: %s", node.Value)
+	}
+}
+
+const (
+	defaultSyslogFacility = "daemon"
+	defaultSyslogTag      = "bgp-speaker"
+	journaldSocket        = "/run/systemd/journal/socket"
+)
+
+// syslogFacilities это имена facility, как в syslog.conf.
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":   syslog.LOG_KERN,
+	"user":   syslog.LOG_USER,
+	"daemon": syslog.LOG_DAEMON,
+	"auth":   syslog.LOG_AUTH,
+	"syslog": syslog.LOG_SYSLOG,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// SyslogOutput задает syslog для log_output: syslog.
+type SyslogOutput struct {
+	// Address это адрес удаленного syslog, например, udp://10.0.0.1:514 или tcp://10.0.0.1:514,
+	// по-умолчанию используется локальный syslog.
+	Address string `yaml:"address"`
+	// Facility по-умолчанию daemon.
+	Facility string `yaml:"facility"`
+	// Tag по-умолчанию bgp-speaker, для journald это SYSLOG_IDENTIFIER.
+	Tag string `yaml:"tag"`
+}
+
+func (s *SyslogOutput) applyDefaults() {
+	if s.Facility == "" {
+		s.Facility = defaultSyslogFacility
+	}
+	if s.Tag == "" {
+		s.Tag = defaultSyslogTag
+	}
+}
+
+func (s *SyslogOutput) validate() error {
+	errs := []error{}
+	if _, ok := syslogFacilities[s.Facility]; !ok {
+		errs = append(errs, fmt.Errorf("syslog.facility: unknown facility %q", s.Facility))
+	}
+	if s.Address != "" {
+		if _, _, err := s.network(); err != nil {
+			errs = append(errs, fmt.Errorf("syslog.address: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Метод network возвращает сеть и адрес для syslog.Dial, пустые для локального syslog.
+func (s *SyslogOutput) network() (string, string, error) {
+	if s.Address == "" {
+		return "", "", nil
+	}
+	u, err := url.Parse(s.Address)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return "", "", fmt.Errorf("unsupported scheme %q, expected udp or tcp", u.Scheme)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return "", "", err
+	}
+	return u.Scheme, u.Host, nil
+}
+
+// SetOutput направляет лог в output. Для syslog и journald запись в stderr выключается,
+// а время записи не пишется, его добавляет получатель.
+func (l *Logger) SetOutput(output LogOutput, conf *SyslogOutput) error {
+	var hook logrus.Hook
+	switch output {
+	case "", LogOutputStderr:
+		return nil
+	case LogOutputSyslog:
+		network, raddr, err := conf.network()
+		if err != nil {
+			return err
+		}
+		h, err := lsyslog.NewSyslogHook(network, raddr, syslogFacilities[conf.Facility]|syslog.LOG_INFO, conf.Tag)
+		if err != nil {
+			return fmt.Errorf("error connecting to syslog: %w", err)
+		}
+		hook = h
+	case LogOutputJournald:
+		conn, err := net.Dial("unixgram", journaldSocket)
+		if err != nil {
+			return fmt.Errorf("error connecting to journald: %w", err)
+		}
+		hook = &journaldHook{conn: conn, identifier: conf.Tag}
+	default:
+		return fmt.Errorf("unknown log_output: %s", output)
+	}
+	l.logger.SetFormatter(&logrus.TextFormatter{DisableColors: true, DisableTimestamp: true})
+	l.logger.SetOutput(io.Discard)
+	l.logger.AddHook(hook)
+	return nil
+}
+
+// journaldHook пишет записи в systemd-journald по native протоколу
+// (https://systemd.io/JOURNAL_NATIVE_PROTOCOL/).
+type journaldHook struct {
+	conn       net.Conn
+	identifier string
+}
+
+func (h *journaldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *journaldHook) Fire(entry *logrus.Entry) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", entry.Message)
+	writeJournalField(&buf, "PRIORITY", fmt.Sprint(int(syslogSeverity(entry.Level))))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", h.identifier)
+	for k, v := range entry.Data {
+		if name := journalFieldName(k); name != "" {
+			writeJournalField(&buf, name, fmt.Sprint(v))
+		}
+	}
+	_, err := h.conn.Write(buf.Bytes())
+	return err
+}
+
+// Функция syslogSeverity переводит уровень logrus в severity syslog так же, как hook syslog logrus.
+func syslogSeverity(level logrus.Level) syslog.Priority {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return syslog.LOG_CRIT
+	case logrus.ErrorLevel:
+		return syslog.LOG_ERR
+	case logrus.WarnLevel:
+		return syslog.LOG_WARNING
+	case logrus.InfoLevel:
+		return syslog.LOG_INFO
+	default:
+		return syslog.LOG_DEBUG
+	}
+}
+
+// Функция journalFieldName приводит имя поля logrus к имени поля журнала: заглавные латинские
+// буквы, цифры и подчеркивание, не в начале. Возвращает пустую строку, если имя не подходит.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return ""
+	}
+	return name
+}
+
+// Функция writeJournalField пишет поле в формате native протокола: значения с переводом строки
+// передаются с длиной в little endian.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
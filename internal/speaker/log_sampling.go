@@ -0,0 +1,80 @@
+package speaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"golang.org/x/exp/maps"
+)
+
+// logSamplingInterval это как часто повторяется одна и та же запись о постоянной ошибке.
+const logSamplingInterval = time.Minute
+
+// logSampler подавляет повторы записей циклов, которые раз в секунду пишут одну и ту же ошибку,
+// например, когда сломан netlink. Первая запись пишется сразу, повторы с тем же сообщением
+// и той же ошибкой не чаще раза в logSamplingInterval с числом подавленных записей в поле suppressed.
+// Нулевое значение готово к использованию.
+type logSampler struct {
+	mu      sync.Mutex
+	entries map[string]*sampledEntry
+}
+
+// sampledEntry это последняя записанная запись с сообщением msg.
+type sampledEntry struct {
+	err        string
+	logged     time.Time
+	suppressed int
+}
+
+// Метод Error пишет msg с уровнем error, если это не повтор, см. [logSampler].
+func (s *logSampler) Error(logger *Logger, msg string, fields log.Fields) {
+	if fields, ok := s.sample(msg, fields); ok {
+		logger.Error(msg, fields)
+	}
+}
+
+// Метод Warn пишет msg с уровнем warn, если это не повтор, см. [logSampler].
+func (s *logSampler) Warn(logger *Logger, msg string, fields log.Fields) {
+	if fields, ok := s.sample(msg, fields); ok {
+		logger.Warn(msg, fields)
+	}
+}
+
+// Метод Resolved вызывается, когда ошибка из записи msg пропала: следующая такая запись
+// будет записана сразу, а если повторы подавлялись, пишется их итоговое число.
+func (s *logSampler) Resolved(logger *Logger, msg string) {
+	s.mu.Lock()
+	entry, ok := s.entries[msg]
+	delete(s.entries, msg)
+	s.mu.Unlock()
+	if ok && entry.suppressed > 0 {
+		logger.Info(msg+": resolved", log.Fields{"suppressed": entry.suppressed})
+	}
+}
+
+// Метод sample решает, писать ли запись, и добавляет к fields число подавленных повторов.
+func (s *logSampler) sample(msg string, fields log.Fields) (log.Fields, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = map[string]*sampledEntry{}
+	}
+	err := fmt.Sprint(fields["error"])
+	now := time.Now()
+	entry, ok := s.entries[msg]
+	if ok && entry.err == err && now.Sub(entry.logged) < logSamplingInterval {
+		entry.suppressed++
+		return nil, false
+	}
+	if ok && entry.suppressed > 0 {
+		fields = maps.Clone(fields)
+		if fields == nil {
+			fields = log.Fields{}
+		}
+		fields["suppressed"] = entry.suppressed
+	}
+	s.entries[msg] = &sampledEntry{err: err, logged: now}
+	return fields, true
+}
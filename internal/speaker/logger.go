@@ -1,13 +1,21 @@
 package speaker
 
 import (
+	"slices"
+	"sync"
+
 	"github.com/osrg/gobgp/v3/pkg/log"
 	"github.com/sirupsen/logrus"
 )
 
+// recentLogLines это сколько последних записей лога хранится для debug bundle.
+const recentLogLines = 1000
+
 // implement github.com/osrg/gobgp/v3/pkg/log/Logger interface
 type Logger struct {
 	logger *logrus.Logger
+	// recent это последние записи лога, nil для логгера из [WrapLogger].
+	recent *recentLogs
 }
 
 func NewLogger(l logrus.Level) *Logger {
@@ -17,8 +25,18 @@ func NewLogger(l logrus.Level) *Logger {
 		FullTimestamp: true,
 	})
 	logger.SetLevel(l)
+	recent := &recentLogs{format: &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}}
+	logger.AddHook(recent)
 	return &Logger{
 		logger: logger,
+		recent: recent,
+	}
+}
+
+// WrapLogger использует уже настроенный logrus логгер, например, логгер приложения, в которое встроен speaker.
+func WrapLogger(l *logrus.Logger) *Logger {
+	return &Logger{
+		logger: l,
 	}
 }
 
@@ -53,3 +71,45 @@ func (l *Logger) SetLevel(level log.LogLevel) {
 func (l *Logger) GetLevel() log.LogLevel {
 	return log.LogLevel(l.logger.GetLevel())
 }
+
+// RecentLines возвращает последние записи лога от старых к новым.
+func (l *Logger) RecentLines() []string {
+	if l.recent == nil {
+		return nil
+	}
+	return l.recent.lines()
+}
+
+// recentLogs это logrus hook, который хранит последние recentLogLines записей в кольцевом буфере.
+type recentLogs struct {
+	mu     sync.Mutex
+	buf    []string
+	next   int
+	format *logrus.TextFormatter
+}
+
+func (r *recentLogs) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (r *recentLogs) Fire(entry *logrus.Entry) error {
+	b, err := r.format.Format(entry)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.buf) < recentLogLines {
+		r.buf = append(r.buf, string(b))
+		return nil
+	}
+	r.buf[r.next] = string(b)
+	r.next = (r.next + 1) % recentLogLines
+	return nil
+}
+
+func (r *recentLogs) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(slices.Clone(r.buf[r.next:]), r.buf[:r.next]...)
+}
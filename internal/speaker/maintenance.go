@@ -0,0 +1,221 @@
+package speaker
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+)
+
+const shutdownPollInterval = 50 * time.Millisecond
+
+// gracefulShutdownCommunity это well-known community GRACEFUL_SHUTDOWN (65535:0) из [RFC 8326].
+//
+// [RFC 8326]: https://www.rfc-editor.org/rfc/rfc8326
+const gracefulShutdownCommunity = 0xffff0000
+
+// Метод gracefulShutdown переводит speaker в режим обслуживания перед остановкой:
+//   - anycast ip анонсируется заново с community GRACEFUL_SHUTDOWN, чтобы соседи понизили его приоритет
+//   - через graceful_shutdown_seconds секунд маршрут отзывается, или сразу, если раньше завершится ctx
+//
+// Если graceful_shutdown_seconds не задан или маршрут не анонсирован, метод ничего не делает.
+func (sp *Speaker) gracefulShutdown(ctx context.Context) error {
+	if sp.config.GracefulShutdownSeconds == 0 || !sp.advertised.Load() {
+		return nil
+	}
+	ctx = withAuditCause(ctx, auditCauseShutdown, "")
+	if err := sp.announceGracefulShutdown(ctx); err != nil {
+		return err
+	}
+	timer := time.NewTimer(time.Second * time.Duration(sp.config.GracefulShutdownSeconds))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		sp.logger.Warn("stop deadline reached, withdrawing anycast ip before graceful shutdown period ends", nil)
+	case <-timer.C:
+	}
+	// Отзыв должен дойти до gobgp и после завершения ctx.
+	withdrawCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	return sp.deletePath(withdrawCtx)
+}
+
+// Метод announceGracefulShutdown анонсирует anycast ip заново с community GRACEFUL_SHUTDOWN,
+// чтобы соседи понизили его приоритет до отзыва через graceful_shutdown_seconds.
+func (sp *Speaker) announceGracefulShutdown(ctx context.Context) error {
+	path, err := sp.anycastPath(pathAttrs{communities: []uint32{gracefulShutdownCommunity}})
+	if err != nil {
+		return err
+	}
+	period := time.Second * time.Duration(sp.config.GracefulShutdownSeconds)
+	sp.logger.Warn("announcing graceful shutdown", log.Fields{"anycast_ip": sp.config.AnycastIP, "period": period.String()})
+	if err := sp.announce(ctx, path); err != nil {
+		return err
+	}
+	sp.audit(ctx, auditActionGracefulShutdown, sp.config.AnycastIP, "")
+	return nil
+}
+
+// Метод shutdownPeers административно выключает всех соседей, передавая им message
+// в NOTIFICATION Cease/Administrative Shutdown ([RFC 9003]), и ждет, пока сессии закроются.
+//
+// gobgp обрезает сообщение до [bgp.BGP_ERROR_ADMINISTRATIVE_COMMUNICATION_MAX] байт.
+//
+// [RFC 9003]: https://www.rfc-editor.org/rfc/rfc9003
+func (sp *Speaker) shutdownPeers(ctx context.Context, message string) error {
+	if len(message) > bgp.BGP_ERROR_ADMINISTRATIVE_COMMUNICATION_MAX {
+		sp.logger.Warn("shutdown message is too long and will be truncated", log.Fields{"max_length": bgp.BGP_ERROR_ADMINISTRATIVE_COMMUNICATION_MAX})
+	}
+	for _, n := range sp.neighbors() {
+		if err := sp.s.ShutdownPeer(ctx, &api.ShutdownPeerRequest{Address: n.Address, Communication: message}); err != nil {
+			return err
+		}
+	}
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		established := 0
+		err := sp.s.ListPeer(ctx, &api.ListPeerRequest{}, func(p *api.Peer) {
+			if p.State != nil && p.State.SessionState == api.PeerState_ESTABLISHED {
+				established++
+			}
+		})
+		if err != nil || established == 0 {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Метод onHealthy вызывается HealthCheck при переходе в статус healthy.
+// В режиме обслуживания маршрут не анонсируется, запоминается только статус.
+func (sp *Speaker) onHealthy(ctx context.Context) error {
+	ctx = withAuditCause(ctx, auditCauseHealthCheck, "")
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	latencyTraceFrom(ctx).mark(stageCallbackQueue)
+	if sp.maintenance || !sp.hookAnnounce || !sp.leader {
+		sp.logger.Info("maintenance mode is on, hooks forbid announce or not a leader, not advertising anycast ip", nil)
+		sp.healthy = true
+		return nil
+	}
+	if err := sp.addPath(ctx); err != nil {
+		return err
+	}
+	sp.healthy = true
+	return nil
+}
+
+// Метод onUnhealthy вызывается HealthCheck при переходе в статус unhealthy:
+// маршрут отзывается или, если задан soft_fail, анонсируется как резервный.
+func (sp *Speaker) onUnhealthy(ctx context.Context) error {
+	ctx = withAuditCause(ctx, auditCauseHealthCheck, "")
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	latencyTraceFrom(ctx).mark(stageCallbackQueue)
+	if sp.maintenance || !sp.hookAnnounce || !sp.leader {
+		sp.healthy = false
+		return nil
+	}
+	withdraw := sp.deletePath
+	if sp.config.SoftFail != nil {
+		withdraw = sp.degradePath
+	}
+	if err := withdraw(ctx); err != nil {
+		return err
+	}
+	sp.healthy = false
+	return nil
+}
+
+// SetMaintenance включает или выключает режим обслуживания.
+//
+// При включении anycast ip отзывается независимо от статуса проверки здоровья: если задан
+// graceful_shutdown_seconds, он сначала анонсируется с community GRACEFUL_SHUTDOWN и отзывается
+// в фоне через graceful_shutdown_seconds. При выключении anycast ip анонсируется снова,
+// если сервис healthy, или как резервный, если сервис unhealthy и задан soft_fail.
+func (sp *Speaker) SetMaintenance(ctx context.Context, enabled bool) error {
+	ctx = withAuditCause(withLatencyTrace(ctx, "maintenance"), auditCauseMaintenance, "")
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	latencyTraceFrom(ctx).mark(stageCallbackQueue)
+	if sp.maintenance == enabled {
+		return nil
+	}
+	sp.logger.Warn("maintenance mode changed", log.Fields{"maintenance": enabled})
+	if enabled && sp.advertised.Load() && sp.config.GracefulShutdownSeconds > 0 {
+		if err := sp.announceGracefulShutdown(ctx); err != nil {
+			return err
+		}
+		withdrawCtx := context.WithoutCancel(ctx)
+		sp.maintenanceWithdraw = time.AfterFunc(time.Second*time.Duration(sp.config.GracefulShutdownSeconds), func() {
+			sp.pathMu.Lock()
+			defer sp.pathMu.Unlock()
+			if !sp.maintenance || !sp.advertised.Load() {
+				return
+			}
+			if err := sp.deletePath(withdrawCtx); err != nil {
+				sp.logger.Error("failed to withdraw anycast ip after graceful shutdown", log.Fields{"error": err.Error()})
+			}
+		})
+	} else if enabled && sp.advertised.Load() {
+		if err := sp.deletePath(ctx); err != nil {
+			return err
+		}
+	}
+	if !enabled {
+		sp.stopMaintenanceWithdraw()
+	}
+	if !enabled && sp.hookAnnounce && sp.leader {
+		switch {
+		case sp.healthy:
+			if err := sp.addPath(ctx); err != nil {
+				return err
+			}
+		case sp.config.SoftFail != nil:
+			if err := sp.degradePath(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	sp.maintenance = enabled
+	if err := sp.holdPrefixes(ctx, enabled || !sp.leader); err != nil {
+		return err
+	}
+	return sp.reconcilePrefixes(ctx)
+}
+
+// Метод stopMaintenanceWithdraw отменяет отложенный отзыв anycast ip, запланированный
+// [Speaker.SetMaintenance]. Вызывается под pathMu.
+func (sp *Speaker) stopMaintenanceWithdraw() {
+	if sp.maintenanceWithdraw != nil {
+		sp.maintenanceWithdraw.Stop()
+		sp.maintenanceWithdraw = nil
+	}
+}
+
+// Метод handleMaintenanceSignals включает режим обслуживания по SIGUSR1 и выключает по SIGUSR2.
+func (sp *Speaker) handleMaintenanceSignals(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case sig := <-signals:
+			if err := sp.SetMaintenance(withAuditCause(ctx, auditCauseMaintenance, sig.String()), sig == syscall.SIGUSR1); err != nil {
+				sp.logger.Error("failed to change maintenance mode", log.Fields{"signal": sig.String(), "error": err.Error()})
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+const (
+	capturePath                      = "/debug/capture"
+	bgpPort                          = 179
+	defaultCaptureDurationSeconds    = 60
+	defaultCaptureMaxDurationSeconds = 600
+	captureFileMode                  = 0o600
+)
+
+// MessageCapture разрешает запись сообщений BGP с соседями в файл pcap, которая включается
+// и выключается через admin API (bgp-speaker debug capture). Записываются пакеты TCP сессий
+// со всех интерфейсов, поэтому с remote_gobgp запись работает, только если gobgpd на том же хосте.
+type MessageCapture struct {
+	// Directory это каталог для файлов pcap.
+	Directory string `yaml:"directory"`
+	// MaxDurationSeconds ограничивает длительность записи, по-умолчанию 600.
+	MaxDurationSeconds uint32 `yaml:"max_duration_seconds"`
+}
+
+func (c *MessageCapture) applyDefaults() {
+	if c.MaxDurationSeconds == 0 {
+		c.MaxDurationSeconds = defaultCaptureMaxDurationSeconds
+	}
+}
+
+func (c *MessageCapture) validate() error {
+	if c.Directory == "" {
+		return errors.New("message_capture.directory: is required")
+	}
+	return nil
+}
+
+// CaptureRequest это тело POST /debug/capture.
+type CaptureRequest struct {
+	// Enabled запускает запись или останавливает текущую.
+	Enabled bool `json:"enabled"`
+	// Neighbors это адреса соседей, если не заданы, записываются все соседи.
+	Neighbors []string `json:"neighbors,omitempty"`
+	// DurationSeconds это длительность записи, по-умолчанию 60.
+	DurationSeconds uint32 `json:"duration_seconds,omitempty"`
+}
+
+// CaptureStatus это ответ /debug/capture о текущей или последней записи.
+type CaptureStatus struct {
+	Running   bool       `json:"running"`
+	File      string     `json:"file,omitempty"`
+	Neighbors []string   `json:"neighbors,omitempty"`
+	Started   *time.Time `json:"started,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	// Packets это число записанных пакетов, известно после окончания записи.
+	Packets int    `json:"packets"`
+	Error   string `json:"error,omitempty"`
+}
+
+// messageCapture это текущая или последняя запись.
+type messageCapture struct {
+	status CaptureStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartCapture запускает запись сообщений BGP с соседями req.Neighbors в новый файл
+// в message_capture.directory на req.DurationSeconds секунд, см. [MessageCapture].
+func (sp *Speaker) StartCapture(req CaptureRequest) (CaptureStatus, error) {
+	conf := sp.config.MessageCapture
+	if conf == nil {
+		return CaptureStatus{}, errors.New("message_capture is not configured")
+	}
+	duration := req.DurationSeconds
+	if duration == 0 {
+		duration = defaultCaptureDurationSeconds
+	}
+	if duration > conf.MaxDurationSeconds {
+		return CaptureStatus{}, fmt.Errorf("duration must be at most %d seconds", conf.MaxDurationSeconds)
+	}
+	neighbors := []string{}
+	hosts := []netip.Addr{}
+	ports := []uint16{bgpPort}
+	for _, n := range sp.neighbors() {
+		if len(req.Neighbors) > 0 && !slices.Contains(req.Neighbors, n.Address) {
+			continue
+		}
+		addr, err := netip.ParseAddr(n.Address)
+		if err != nil {
+			return CaptureStatus{}, fmt.Errorf("invalid neighbor address %s: %w", n.Address, err)
+		}
+		neighbors = append(neighbors, n.Address)
+		hosts = append(hosts, addr)
+		if n.remotePort != 0 && !slices.Contains(ports, n.remotePort) {
+			ports = append(ports, n.remotePort)
+		}
+	}
+	for _, address := range req.Neighbors {
+		if !slices.Contains(neighbors, address) {
+			return CaptureStatus{}, fmt.Errorf("neighbor %s is not configured", address)
+		}
+	}
+	if len(neighbors) == 0 {
+		return CaptureStatus{}, errors.New("no neighbors to capture")
+	}
+
+	sp.captureMu.Lock()
+	defer sp.captureMu.Unlock()
+	if sp.capture != nil && sp.capture.status.Running {
+		return CaptureStatus{}, fmt.Errorf("capture to %s is already running", sp.capture.status.File)
+	}
+	capture, err := nl.NewTCPCapture(hosts, ports)
+	if err != nil {
+		return CaptureStatus{}, err
+	}
+	now := time.Now()
+	file := filepath.Join(conf.Directory, "bgp-"+now.UTC().Format("20060102-150405")+".pcap")
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, captureFileMode)
+	if err != nil {
+		capture.Close()
+		return CaptureStatus{}, err
+	}
+	until := now.Add(time.Second * time.Duration(duration))
+	ctx, cancel := context.WithDeadline(context.Background(), until)
+	c := &messageCapture{
+		status: CaptureStatus{
+			Running:   true,
+			File:      file,
+			Neighbors: neighbors,
+			Started:   &now,
+			Until:     &until,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	sp.capture = c
+	sp.logger.Info("starting bgp message capture", log.Fields{"file": file, "neighbors": neighbors, "until": until})
+	go func() {
+		defer close(c.done)
+		defer cancel()
+		packets, err := capture.WritePcap(ctx, f)
+		capture.Close()
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		sp.captureMu.Lock()
+		c.status.Running = false
+		c.status.Packets = packets
+		if err != nil {
+			c.status.Error = err.Error()
+		}
+		sp.captureMu.Unlock()
+		if err != nil {
+			sp.logger.Error("bgp message capture failed", log.Fields{"file": file, "packets": packets, "error": err.Error()})
+			return
+		}
+		sp.logger.Info("bgp message capture finished", log.Fields{"file": file, "packets": packets})
+	}()
+	return c.status, nil
+}
+
+// StopCapture останавливает текущую запись и возвращает ее итог.
+func (sp *Speaker) StopCapture() CaptureStatus {
+	sp.captureMu.Lock()
+	c := sp.capture
+	sp.captureMu.Unlock()
+	if c == nil {
+		return CaptureStatus{}
+	}
+	c.cancel()
+	<-c.done
+	return sp.captureStatus()
+}
+
+func (sp *Speaker) captureStatus() CaptureStatus {
+	sp.captureMu.Lock()
+	defer sp.captureMu.Unlock()
+	if sp.capture == nil {
+		return CaptureStatus{}
+	}
+	return sp.capture.status
+}
+
+func (sp *Speaker) handleCaptureStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sp.captureStatus())
+}
+
+func (sp *Speaker) handleCapture(w http.ResponseWriter, r *http.Request) {
+	var req CaptureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if !req.Enabled {
+		writeJSON(w, http.StatusOK, sp.StopCapture())
+		return
+	}
+	status, err := sp.StartCapture(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
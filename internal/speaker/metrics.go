@@ -0,0 +1,214 @@
+package speaker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/sir-sukhov/bgp-speaker/internal/version"
+)
+
+const (
+	metricsPath        = "/metrics"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+	metricsNamespace   = "bgp_speaker_"
+	metricTypeGauge    = "gauge"
+	metricTypeCounter  = "counter"
+	labelNeighbor      = "neighbor"
+	labelASN           = "asn"
+	labelFamily        = "family"
+	labelState         = "state"
+	labelDirection     = "direction"
+	labelReason        = "reason"
+	labelCode          = "code"
+	labelSubcode       = "subcode"
+)
+
+var sessionStates = []api.PeerState_SessionState{
+	api.PeerState_IDLE,
+	api.PeerState_CONNECT,
+	api.PeerState_ACTIVE,
+	api.PeerState_OPENSENT,
+	api.PeerState_OPENCONFIRM,
+	api.PeerState_ESTABLISHED,
+}
+
+// metric это одна метрика в [text exposition format] Prometheus со всеми ее значениями.
+//
+// [text exposition format]: https://prometheus.io/docs/instrumenting/exposition_formats/
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+func (m *metric) add(value float64, labels ...[2]string) {
+	m.samples = append(m.samples, sample{labels: labels, value: value})
+}
+
+func (m *metric) write(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s%s %s\n", metricsNamespace, m.name, m.help)
+	fmt.Fprintf(buf, "# TYPE %s%s %s\n", metricsNamespace, m.name, m.typ)
+	for _, s := range m.samples {
+		buf.WriteString(metricsNamespace + m.name)
+		if len(s.labels) > 0 {
+			buf.WriteByte('{')
+			for i, l := range s.labels {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, "%s=\"%s\"", l[0], escapeLabelValue(l[1]))
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		buf.WriteByte('\n')
+	}
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func label(name, value string) [2]string {
+	return [2]string{name, value}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Метод collectMetrics собирает состояние анонса и метрики каждого соседа из gobgp.
+//
+// gobgp не отдает причину последнего разрыва сессии через API, поэтому вместо last error
+// экспортируются счетчики NOTIFICATION и время последнего разрыва, а с peer_events
+// разрывы по причинам, см. [PeerEvents].
+func (sp *Speaker) collectMetrics(ctx context.Context) ([]*metric, error) {
+	status := sp.adminStatus()
+	build := version.Get()
+	buildInfo := &metric{name: "build_info", help: "Version and build metadata of speaker, always 1.", typ: metricTypeGauge}
+	buildInfo.add(1,
+		label("version", build.Version),
+		label("commit", build.Commit),
+		label("go_version", build.GoVersion),
+		label("gobgp_version", build.GoBGPVersion),
+	)
+	advertised := &metric{name: "anycast_advertised", help: "Whether anycast ip is advertised.", typ: metricTypeGauge}
+	advertised.add(boolValue(status.Advertised))
+	healthy := &metric{name: "healthy", help: "Whether health check reports healthy.", typ: metricTypeGauge}
+	healthy.add(boolValue(status.Healthy))
+	maintenance := &metric{name: "maintenance", help: "Whether maintenance mode is on.", typ: metricTypeGauge}
+	maintenance.add(boolValue(status.Maintenance))
+	degraded := &metric{name: "degraded", help: "Whether anycast ip is advertised in soft_fail mode.", typ: metricTypeGauge}
+	degraded.add(boolValue(status.Degraded))
+	fibProgrammed := &metric{name: "fib_programmed", help: "Whether default route is programmed into kernel.", typ: metricTypeGauge}
+	fibProgrammed.add(boolValue(sp.fibProgrammed.Load()))
+	driftDetected := &metric{name: "fib_drift_detected_total", help: "Number of times kernel default route was found modified externally.", typ: metricTypeCounter}
+	driftDetected.add(float64(sp.fibDriftDetected.Load()))
+	driftRepaired := &metric{name: "fib_drift_repaired_total", help: "Number of times externally modified kernel default route was repaired.", typ: metricTypeCounter}
+	driftRepaired.add(float64(sp.fibDriftRepaired.Load()))
+	flapDampened := &metric{name: "peer_flap_dampened_total", help: "Number of times a neighbor exceeded peer_flap_dampening.max_flaps.", typ: metricTypeCounter}
+	flapDampened.add(float64(sp.peerFlapDampened.Load()))
+	latency := &metric{name: "announce_latency_seconds", help: "Duration of stages of last anycast ip announce or withdraw.", typ: metricTypeGauge}
+	if trace := sp.lastLatency.Load(); trace != nil {
+		for _, s := range trace.Stages {
+			latency.add(s.Duration.Seconds(), label("stage", s.Name))
+		}
+		latency.add(trace.Total.Seconds(), label("stage", "total"))
+	}
+	latencyExceeded := &metric{name: "announce_latency_budget_exceeded_total", help: "Number of announces and withdraws which exceeded latency_budget_ms.", typ: metricTypeCounter}
+	latencyExceeded.add(float64(sp.latencyExceeded.Load()))
+
+	state := &metric{name: "peer_state", help: "BGP FSM state of neighbor, 1 for current state.", typ: metricTypeGauge}
+	up := &metric{name: "peer_up", help: "Whether BGP session with neighbor is established.", typ: metricTypeGauge}
+	adminDown := &metric{name: "peer_admin_down", help: "Whether neighbor is administratively down, e.g. after max_prefixes.", typ: metricTypeGauge}
+	uptime := &metric{name: "peer_uptime_seconds", help: "Seconds since BGP session with neighbor was established.", typ: metricTypeGauge}
+	lastDown := &metric{name: "peer_last_down_timestamp_seconds", help: "Unix time of last BGP session down.", typ: metricTypeGauge}
+	flaps := &metric{name: "peer_flaps_total", help: "Number of times BGP session with neighbor went down.", typ: metricTypeCounter}
+	notifications := &metric{name: "peer_notifications_total", help: "Number of NOTIFICATION messages by direction.", typ: metricTypeCounter}
+	received := &metric{name: "peer_prefixes_received", help: "Number of prefixes received from neighbor.", typ: metricTypeGauge}
+	accepted := &metric{name: "peer_prefixes_accepted", help: "Number of prefixes received from neighbor and accepted by import policy.", typ: metricTypeGauge}
+	sent := &metric{name: "peer_prefixes_advertised", help: "Number of prefixes advertised to neighbor.", typ: metricTypeGauge}
+
+	peers := []*api.Peer{}
+	err := sp.s.ListPeer(ctx, &api.ListPeerRequest{EnableAdvertised: true}, func(p *api.Peer) {
+		peers = append(peers, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].GetConf().GetNeighborAddress() < peers[j].GetConf().GetNeighborAddress()
+	})
+	now := time.Now()
+	for _, p := range peers {
+		neighbor := label(labelNeighbor, p.GetConf().GetNeighborAddress())
+		asn := label(labelASN, strconv.FormatUint(uint64(p.GetConf().GetPeerAsn()), 10))
+		s := p.GetState()
+		for _, sessionState := range sessionStates {
+			state.add(boolValue(s.GetSessionState() == sessionState), neighbor, asn, label(labelState, strings.ToLower(sessionState.String())))
+		}
+		established := s.GetSessionState() == api.PeerState_ESTABLISHED
+		up.add(boolValue(established), neighbor, asn)
+		adminDown.add(boolValue(s.GetAdminState() != api.PeerState_UP), neighbor, asn)
+		if t := p.GetTimers().GetState().GetUptime(); established && t != nil {
+			uptime.add(now.Sub(t.AsTime()).Seconds(), neighbor, asn)
+		} else {
+			uptime.add(0, neighbor, asn)
+		}
+		if t := p.GetTimers().GetState().GetDowntime(); t != nil && t.GetSeconds() > 0 {
+			lastDown.add(float64(t.GetSeconds()), neighbor, asn)
+		}
+		flaps.add(float64(s.GetFlops()), neighbor, asn)
+		notifications.add(float64(s.GetMessages().GetReceived().GetNotification()), neighbor, asn, label(labelDirection, "received"))
+		notifications.add(float64(s.GetMessages().GetSent().GetNotification()), neighbor, asn, label(labelDirection, "sent"))
+		for _, afiSafi := range p.GetAfiSafis() {
+			afiSafiState := afiSafi.GetState()
+			if afiSafiState == nil {
+				continue
+			}
+			family := label(labelFamily, familyName(afiSafiState.GetFamily()))
+			received.add(float64(afiSafiState.GetReceived()), neighbor, asn, family)
+			accepted.add(float64(afiSafiState.GetAccepted()), neighbor, asn, family)
+			sent.add(float64(afiSafiState.GetAdvertised()), neighbor, asn, family)
+		}
+	}
+	return []*metric{
+		buildInfo, advertised, healthy, maintenance, degraded, fibProgrammed, driftDetected, driftRepaired, flapDampened, latency, latencyExceeded,
+		state, up, adminDown, uptime, lastDown, flaps, notifications, sp.peerDownMetric(), received, accepted, sent,
+	}, nil
+}
+
+func familyName(f *api.Family) string {
+	return strings.ToLower(strings.TrimPrefix(f.GetAfi().String(), "AFI_") + "_" + strings.TrimPrefix(f.GetSafi().String(), "SAFI_"))
+}
+
+func (sp *Speaker) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := sp.collectMetrics(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.write(&buf)
+	}
+	w.Header().Set(contentTypeHeader, metricsContentType)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,279 @@
+package speaker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"syscall"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	netlinkRoute  = 0
+	newNexthop    = 0x68
+	deleteNexthop = 0x69
+	nhaID         = 1
+	nhaGroup      = 2
+	nhaOIF        = 5
+	nhaGateway    = 6
+	rtaNhID       = 30
+	// nexthopGroupID это id группы next-hop маршрута по-умолчанию, id next-hop группы идут следом.
+	nexthopGroupID = protoBgp << 16
+)
+
+var errNexthopGroupsUnsupported = errors.New("kernel does not support nexthop objects")
+
+// nexthopObject это next-hop объект в ядре, входящий в группу маршрута по-умолчанию.
+type nexthopObject struct {
+	id  uint32
+	hop rtnetlink.RTNextHop
+}
+
+// nexthopRoute это параметры маршрута по-умолчанию, который ссылается на группу.
+type nexthopRoute struct {
+	metric uint32
+	mtu    uint32
+	src    string
+}
+
+// Метод nexthopGroupsEnabled возвращает true, если задан fib_nexthop_groups и ядро
+// не отказалось создавать nexthop объекты.
+func (sp *Speaker) nexthopGroupsEnabled() bool {
+	return sp.config.FIBNexthopGroups && !sp.nexthopGroupsUnsupported
+}
+
+// Метод setNexthopGroupRoute ставит multipath маршрут по-умолчанию через группу nexthop объектов
+// (RTM_NEWNEXTHOP, ядро 5.3 и новее) вместо RTA_MULTIPATH: маршрут ссылается на группу по id,
+// а смена next-hop или весов заменяет группу целиком одним сообщением, не трогая маршрут.
+//
+// Если ядро не поддерживает nexthop объекты, возвращается errNexthopGroupsUnsupported,
+// и speaker дальше ставит маршруты через RTA_MULTIPATH.
+func (sp *Speaker) setNexthopGroupRoute(nextHops []rtnetlink.NextHop, metric, mtu uint32, oldDefaultRoute *rtnetlink.RouteMessage) error {
+	if _, err := sp.rawConn(); err != nil {
+		return err
+	}
+	changed := len(nextHops) != len(sp.nexthopObjects)
+	objects := map[string]nexthopObject{}
+	for _, nh := range nextHops {
+		gw := nh.Gateway.String()
+		obj, ok := sp.nexthopObjects[gw]
+		if !ok {
+			obj.id = sp.nextNexthopID(objects)
+		}
+		if !ok || obj.hop != nh.Hop {
+			changed = true
+			obj.hop = nh.Hop
+			if err := sp.setNexthop(obj.id, nh); err != nil {
+				if errors.Is(err, syscall.EOPNOTSUPP) {
+					sp.nexthopGroupsUnsupported = true
+					sp.logger.Warn("kernel does not support nexthop objects, falling back to multipath routes", nil)
+					return errNexthopGroupsUnsupported
+				}
+				return fmt.Errorf("failed to set nexthop %s: %w", gw, err)
+			}
+		}
+		objects[gw] = obj
+	}
+	if changed {
+		sp.logger.Info("setting linux nexthop group of default route", log.Fields{"dst": maps.Keys(objects), "id": nexthopGroupID})
+		if err := sp.setNexthopGroup(objects); err != nil {
+			return fmt.Errorf("failed to set nexthop group: %w", err)
+		}
+	}
+	stale := sp.nexthopObjects
+	sp.nexthopObjects = objects
+	for gw, obj := range stale {
+		if _, ok := objects[gw]; !ok || objects[gw].id != obj.id {
+			if err := sp.deleteNexthop(obj.id); err != nil {
+				sp.logger.Warn("failed to delete stale nexthop", log.Fields{"id": obj.id, "error": err.Error()})
+			}
+		}
+	}
+	route := nexthopRoute{metric: metric, mtu: mtu, src: sp.routeSrc().String()}
+	if oldDefaultRoute != nil && oldDefaultRoute.Attributes.Priority == metric && sp.nexthopRoute == route {
+		return nil
+	}
+	sp.fibWrites++
+	sp.logger.Info("setting linux default route via nexthop group", log.Fields{"id": nexthopGroupID, "mtu": mtu, "metric": metric})
+	if err := sp.replaceNexthopGroupRoute(metric, mtu); err != nil {
+		return err
+	}
+	sp.nexthopRoute = route
+	if oldDefaultRoute != nil && oldDefaultRoute.Attributes.Priority != metric {
+		return sp.deleteDefaultRoute(oldDefaultRoute.Attributes.Priority)
+	}
+	return nil
+}
+
+// Метод rawConn возвращает netlink соединение для сообщений, которые не умеет отправлять rtnetlink.
+func (sp *Speaker) rawConn() (*netlink.Conn, error) {
+	if sp.nhConn == nil {
+		c, err := netlink.Dial(netlinkRoute, nil)
+		if err != nil {
+			return nil, err
+		}
+		sp.nhConn = c
+	}
+	return sp.nhConn, nil
+}
+
+// Метод nextNexthopID возвращает свободный id next-hop объекта.
+func (sp *Speaker) nextNexthopID(objects map[string]nexthopObject) uint32 {
+	used := []uint32{}
+	for _, obj := range sp.nexthopObjects {
+		used = append(used, obj.id)
+	}
+	for _, obj := range objects {
+		used = append(used, obj.id)
+	}
+	id := uint32(nexthopGroupID + 1)
+	for slices.Contains(used, id) {
+		id++
+	}
+	return id
+}
+
+// Метод setNexthop создает или заменяет next-hop объект id. Ядро требует интерфейс
+// для next-hop объекта, поэтому без onlink он ищется так же, как "ip route get".
+func (sp *Speaker) setNexthop(id uint32, nh rtnetlink.NextHop) error {
+	index := nh.Hop.IfIndex
+	if index == 0 {
+		route, err := sp.lookupRoute(nh.Gateway)
+		if err != nil {
+			return err
+		}
+		index = route.Attributes.OutIface
+	}
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nhaID, id)
+	ae.Bytes(nhaGateway, nh.Gateway.To4())
+	ae.Uint32(nhaOIF, index)
+	return sp.executeNexthop(newNexthop, replaceFlags, uint32(nh.Hop.Flags), ae)
+}
+
+// Метод setNexthopGroup создает или атомарно заменяет группу nexthopGroupID из objects,
+// вес хранится так же, как rtnh_hops, то есть вес минус один.
+func (sp *Speaker) setNexthopGroup(objects map[string]nexthopObject) error {
+	gateways := maps.Keys(objects)
+	slices.Sort(gateways)
+	group := []byte{}
+	for _, gw := range gateways {
+		// struct nexthop_grp: id, weight, resvd1, resvd2.
+		entry := make([]byte, 8)
+		binary.NativeEndian.PutUint32(entry[0:4], objects[gw].id)
+		entry[4] = objects[gw].hop.Hops
+		group = append(group, entry...)
+	}
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nhaID, nexthopGroupID)
+	ae.Bytes(nhaGroup, group)
+	return sp.executeNexthop(newNexthop, replaceFlags, 0, ae)
+}
+
+func (sp *Speaker) deleteNexthop(id uint32) error {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(nhaID, id)
+	return sp.executeNexthop(deleteNexthop, netlink.Request|netlink.Acknowledge, 0, ae)
+}
+
+// Метод executeNexthop отправляет сообщение nhmsg с атрибутами ae.
+func (sp *Speaker) executeNexthop(msgType netlink.HeaderType, flags netlink.HeaderFlags, nhFlags uint32, ae *netlink.AttributeEncoder) error {
+	msg, err := nexthopMessage(msgType, flags, nhFlags, ae)
+	if err != nil {
+		return err
+	}
+	return sp.executeFIB(func() error {
+		_, err := sp.nhConn.Execute(msg)
+		return err
+	})
+}
+
+// Функция nexthopMessage собирает сообщение nhmsg с атрибутами ae.
+func nexthopMessage(msgType netlink.HeaderType, flags netlink.HeaderFlags, nhFlags uint32, ae *netlink.AttributeEncoder) (netlink.Message, error) {
+	attrs, err := ae.Encode()
+	if err != nil {
+		return netlink.Message{}, err
+	}
+	// struct nhmsg: family, scope, protocol, resvd, flags.
+	data := make([]byte, 8, 8+len(attrs))
+	data[0] = familyAfInet
+	data[2] = protoBgp
+	binary.NativeEndian.PutUint32(data[4:8], nhFlags)
+	return netlink.Message{
+		Header: netlink.Header{Type: msgType, Flags: flags},
+		Data:   append(data, attrs...),
+	}, nil
+}
+
+// Метод replaceNexthopGroupRoute ставит маршрут по-умолчанию с RTA_NH_ID группы.
+// rtnetlink не знает атрибут RTA_NH_ID, поэтому он дописывается к сообщению вручную.
+func (sp *Speaker) replaceNexthopGroupRoute(metric, mtu uint32) error {
+	routeMessage := &rtnetlink.RouteMessage{
+		Family:   familyAfInet,
+		Protocol: protoBgp,
+		Type:     typeUnicast,
+		Attributes: rtnetlink.RouteAttributes{
+			Src:      sp.routeSrc(),
+			Priority: metric,
+			Metrics:  routeMetrics(mtu),
+		},
+	}
+	sp.setRouteTable(routeMessage)
+	data, err := routeMessage.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(rtaNhID, nexthopGroupID)
+	nhID, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+	return sp.executeFIB(func() error {
+		_, err := sp.nhConn.Execute(netlink.Message{
+			Header: netlink.Header{Type: newRoute, Flags: replaceFlags},
+			Data:   append(data, nhID...),
+		})
+		return err
+	})
+}
+
+// Метод cleanupNexthopGroup удаляет группу и next-hop объекты после того, как маршрут
+// по-умолчанию перестал на них ссылаться: удаление группы удаляет и маршруты через нее.
+func (sp *Speaker) cleanupNexthopGroup() error {
+	if sp.nhConn == nil || len(sp.nexthopObjects) == 0 {
+		return nil
+	}
+	ids := []uint32{nexthopGroupID}
+	for _, obj := range sp.nexthopObjects {
+		ids = append(ids, obj.id)
+	}
+	batch := make([]netlink.Message, 0, len(ids))
+	for _, id := range ids {
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint32(nhaID, id)
+		msg, err := nexthopMessage(deleteNexthop, 0, 0, ae)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, msg)
+	}
+	errs := []error{}
+	for i, err := range sp.executeFIBBatch(sp.nhConn, batch) {
+		switch {
+		case err == nil:
+		case ids[i] == nexthopGroupID:
+			errs = append(errs, fmt.Errorf("failed to delete nexthop group: %w", err))
+		default:
+			errs = append(errs, fmt.Errorf("failed to delete nexthop %d: %w", ids[i], err))
+		}
+	}
+	sp.nexthopObjects = nil
+	sp.nexthopRoute = nexthopRoute{}
+	return errors.Join(errs...)
+}
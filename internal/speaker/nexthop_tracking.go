@@ -0,0 +1,130 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+// maxNextHopRecursion ограничивает глубину рекурсивной проверки next-hop через маршруты с gateway.
+const maxNextHopRecursion = 8
+
+// Метод trackNextHops при включенном next_hop_tracking убирает из paths пути, next-hop которых
+// ядро не может разрешить: нет маршрута до next-hop, кроме маршрута по-умолчанию, или выключен
+// интерфейс, через который он доступен. Так next-hop перестает использоваться сразу, не дожидаясь
+// падения BGP сессии по hold timer, например, при проблемах на L2. Вызывается только из UpdateFIB.
+func (sp *Speaker) trackNextHops(paths []*api.Path) []*api.Path {
+	if !sp.config.NextHopTracking {
+		return paths
+	}
+	if sp.unresolvedNextHops == nil {
+		sp.unresolvedNextHops = map[string]bool{}
+	}
+	resolved := make([]*api.Path, 0, len(paths))
+	current := map[string]bool{}
+	for _, path := range paths {
+		gw, err := nextHop(path)
+		if err != nil {
+			resolved = append(resolved, path)
+			continue
+		}
+		current[gw] = true
+		if err := sp.resolveNextHop(path, gw); err != nil {
+			if !sp.unresolvedNextHops[gw] {
+				sp.logger.Warn("next-hop is unresolved, removing it from fib", log.Fields{"next_hop": gw, "error": err.Error()})
+				sp.unresolvedNextHops[gw] = true
+			}
+			continue
+		}
+		if sp.unresolvedNextHops[gw] {
+			sp.logger.Info("next-hop is resolved again", log.Fields{"next_hop": gw})
+			delete(sp.unresolvedNextHops, gw)
+		}
+		resolved = append(resolved, path)
+	}
+	for gw := range sp.unresolvedNextHops {
+		if !current[gw] {
+			delete(sp.unresolvedNextHops, gw)
+		}
+	}
+	return resolved
+}
+
+// Метод resolveNextHop проверяет next-hop gw пути path. Для соседей с bind_interface или onlink
+// next-hop не обязан входить в подсеть хоста, поэтому проверяется только интерфейс.
+func (sp *Speaker) resolveNextHop(path *api.Path, gw string) error {
+	if n := sp.pathNeighbor(path); n != nil && (n.BindInterface != "" || n.Onlink) {
+		hop, err := sp.onlinkHop(path)
+		if err != nil {
+			return err
+		}
+		if hop.IfIndex == 0 {
+			index, ok := sp.links.Index(n.BindInterface)
+			if !ok {
+				return fmt.Errorf("interface %s not found", n.BindInterface)
+			}
+			hop.IfIndex = index
+		}
+		return sp.linkUp(hop.IfIndex)
+	}
+	ip := net.ParseIP(gw)
+	if ip == nil {
+		return fmt.Errorf("gateway %q is not ip address: %w", gw, errors.ErrUnsupported)
+	}
+	return sp.resolveGateway(ip, 0)
+}
+
+// Метод resolveGateway рекурсивно проверяет, что до gw есть маршрут в ядре, кроме маршрута
+// по-умолчанию, и что интерфейс хотя бы одного его next-hop поднят.
+func (sp *Speaker) resolveGateway(gw net.IP, depth int) error {
+	if depth >= maxNextHopRecursion {
+		return fmt.Errorf("recursion depth %d exceeded resolving %s", maxNextHopRecursion, gw)
+	}
+	route, err := sp.lookupFIBMatch(gw)
+	if err != nil {
+		return err
+	}
+	if route.DstLength == 0 {
+		return fmt.Errorf("%s is reachable only via default route", gw)
+	}
+	if route.Type != typeUnicast {
+		return fmt.Errorf("route to %s is not unicast", gw)
+	}
+	if len(route.Attributes.Multipath) == 0 {
+		return sp.resolveHop(gw, route.Attributes.Gateway, route.Attributes.OutIface, depth)
+	}
+	errs := []error{}
+	for _, nh := range route.Attributes.Multipath {
+		err := sp.resolveHop(gw, nh.Gateway, nh.Hop.IfIndex, depth)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (sp *Speaker) resolveHop(gw, via net.IP, ifIndex uint32, depth int) error {
+	if via != nil && !via.IsUnspecified() && !via.Equal(gw) && !via.IsLinkLocalUnicast() {
+		if err := sp.resolveGateway(via, depth+1); err != nil {
+			return fmt.Errorf("%s via %s: %w", gw, via, err)
+		}
+	}
+	return sp.linkUp(ifIndex)
+}
+
+func (sp *Speaker) linkUp(index uint32) error {
+	link, ok := sp.links.Link(index)
+	if !ok {
+		return fmt.Errorf("interface %d not found", index)
+	}
+	if !nl.LinkUp(link) {
+		name, _ := sp.links.Name(index)
+		return fmt.Errorf("interface %s is down", name)
+	}
+	return nil
+}
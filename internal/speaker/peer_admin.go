@@ -0,0 +1,140 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	disablePeerPath = "/peers/disable"
+	enablePeerPath  = "/peers/enable"
+	resetPeerPath   = "/peers/reset"
+	// defaultDisableCommunication передается соседу в NOTIFICATION, если сообщение не задано.
+	defaultDisableCommunication = "administratively disabled"
+)
+
+// PeerAdminRequest это тело POST /peers/disable, POST /peers/enable и POST /peers/reset.
+type PeerAdminRequest struct {
+	Neighbor string `json:"neighbor"`
+	// Communication передается соседу в NOTIFICATION при выключении и сбросе (RFC 9003).
+	Communication string `json:"communication,omitempty"`
+}
+
+// PeerAdminResponse это ответ POST /peers/disable, POST /peers/enable и POST /peers/reset.
+type PeerAdminResponse struct {
+	Neighbor string `json:"neighbor"`
+	Disabled bool   `json:"disabled"`
+}
+
+// DisablePeer административно выключает сессию с соседом, не удаляя его из конфигурации,
+// например, на время работ на одном uplink. Пока сосед выключен, его не включают обратно
+// track_interface, peer_flap_dampening и max_prefixes.restart_seconds. Состояние не сохраняется
+// при перезапуске speaker: после него сосед снова включен.
+func (sp *Speaker) DisablePeer(ctx context.Context, req PeerAdminRequest) (PeerAdminResponse, error) {
+	address, err := sp.configuredNeighbor(req.Neighbor)
+	if err != nil {
+		return PeerAdminResponse{}, err
+	}
+	communication := req.Communication
+	if communication == "" {
+		communication = defaultDisableCommunication
+	}
+	sp.disabledMu.Lock()
+	defer sp.disabledMu.Unlock()
+	sp.logger.Warn("disabling neighbor via admin api", log.Fields{"neighbor": address, "communication": communication})
+	if err := sp.s.DisablePeer(ctx, &api.DisablePeerRequest{Address: address, Communication: communication}); err != nil {
+		return PeerAdminResponse{}, fmt.Errorf("disable of %s failed: %w", address, err)
+	}
+	if sp.disabledPeers == nil {
+		sp.disabledPeers = map[string]bool{}
+	}
+	sp.disabledPeers[address] = true
+	return PeerAdminResponse{Neighbor: address, Disabled: true}, nil
+}
+
+// EnablePeer включает сессию с соседом, выключенным через [Speaker.DisablePeer] или gobgp.
+func (sp *Speaker) EnablePeer(ctx context.Context, req PeerAdminRequest) (PeerAdminResponse, error) {
+	address, err := sp.configuredNeighbor(req.Neighbor)
+	if err != nil {
+		return PeerAdminResponse{}, err
+	}
+	sp.disabledMu.Lock()
+	defer sp.disabledMu.Unlock()
+	sp.logger.Info("enabling neighbor via admin api", log.Fields{"neighbor": address})
+	if err := sp.s.EnablePeer(ctx, &api.EnablePeerRequest{Address: address}); err != nil {
+		return PeerAdminResponse{}, fmt.Errorf("enable of %s failed: %w", address, err)
+	}
+	delete(sp.disabledPeers, address)
+	return PeerAdminResponse{Neighbor: address, Disabled: false}, nil
+}
+
+// ResetPeer разрывает сессию с соседом, отправляя ему NOTIFICATION Cease/Administrative Reset,
+// после чего gobgp устанавливает ее заново. Для обновления маршрутов без разрыва сессии
+// есть [Speaker.SoftRefresh].
+func (sp *Speaker) ResetPeer(ctx context.Context, req PeerAdminRequest) (PeerAdminResponse, error) {
+	address, err := sp.configuredNeighbor(req.Neighbor)
+	if err != nil {
+		return PeerAdminResponse{}, err
+	}
+	if sp.peerDisabled(address) {
+		return PeerAdminResponse{}, fmt.Errorf("neighbor %s is disabled, enable it instead of reset", address)
+	}
+	sp.logger.Warn("resetting neighbor via admin api", log.Fields{"neighbor": address, "communication": req.Communication})
+	if err := sp.s.ResetPeer(ctx, &api.ResetPeerRequest{Address: address, Communication: req.Communication}); err != nil {
+		return PeerAdminResponse{}, fmt.Errorf("reset of %s failed: %w", address, err)
+	}
+	return PeerAdminResponse{Neighbor: address, Disabled: false}, nil
+}
+
+// Метод peerDisabled возвращает true, если сосед выключен через [Speaker.DisablePeer].
+func (sp *Speaker) peerDisabled(address string) bool {
+	sp.disabledMu.Lock()
+	defer sp.disabledMu.Unlock()
+	return sp.disabledPeers[address]
+}
+
+// Метод configuredNeighbor возвращает адрес соседа из конфигурации, link-local адрес
+// можно передать без зоны.
+func (sp *Speaker) configuredNeighbor(address string) (string, error) {
+	if address == "" {
+		return "", errors.New("neighbor is required")
+	}
+	for _, n := range sp.neighbors() {
+		if n.Address == address || sameNeighbor(n.Address, address) {
+			return n.Address, nil
+		}
+	}
+	return "", fmt.Errorf("neighbor %s is not configured", address)
+}
+
+func (sp *Speaker) handleDisablePeer(w http.ResponseWriter, r *http.Request) {
+	sp.handlePeerAdmin(w, r, sp.DisablePeer)
+}
+
+func (sp *Speaker) handleEnablePeer(w http.ResponseWriter, r *http.Request) {
+	sp.handlePeerAdmin(w, r, sp.EnablePeer)
+}
+
+func (sp *Speaker) handleResetPeer(w http.ResponseWriter, r *http.Request) {
+	sp.handlePeerAdmin(w, r, sp.ResetPeer)
+}
+
+func (sp *Speaker) handlePeerAdmin(w http.ResponseWriter, r *http.Request, fn func(context.Context, PeerAdminRequest) (PeerAdminResponse, error)) {
+	var req PeerAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	resp, err := fn(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,114 @@
+package speaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const peerDampeningCheckInterval = time.Second
+
+// PeerFlapDampening задает подавление соседей, сессия с которыми часто разрывается.
+type PeerFlapDampening struct {
+	// MaxFlaps это сколько разрывов сессии за WindowSeconds секунд допустимо.
+	MaxFlaps      uint32 `yaml:"max_flaps"`
+	WindowSeconds uint32 `yaml:"window_seconds"`
+	// HoldDownSeconds это на сколько секунд сосед выключается после превышения MaxFlaps.
+	// Если не задан, превышение только пишется в лог и учитывается в метрике.
+	HoldDownSeconds uint32 `yaml:"hold_down_seconds"`
+}
+
+func (d *PeerFlapDampening) validate() error {
+	errs := []error{}
+	if d.MaxFlaps == 0 {
+		errs = append(errs, errors.New("peer_flap_dampening.max_flaps: must be greater than 0"))
+	}
+	if d.WindowSeconds == 0 {
+		errs = append(errs, errors.New("peer_flap_dampening.window_seconds: must be greater than 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// DampenPeerFlaps следит за сессиями соседей через WatchEvent и считает разрывы установленных
+// сессий. Если сосед разорвал сессию больше max_flaps раз за window_seconds секунд, это пишется
+// в лог и в метрику peer_flap_dampened_total, а с hold_down_seconds сосед выключается на это время
+// вместо бесконечных попыток установить сессию. Разрывы выключенных соседей не учитываются.
+func (sp *Speaker) DampenPeerFlaps(ctx context.Context) error {
+	conf := sp.config.PeerFlapDampening
+	window := time.Second * time.Duration(conf.WindowSeconds)
+	holdDown := time.Second * time.Duration(conf.HoldDownSeconds)
+	peers := make(chan *api.Peer, 64)
+	err := sp.s.WatchEvent(ctx, &api.WatchEventRequest{Peer: &api.WatchEventRequest_Peer{}}, func(resp *api.WatchEventResponse) {
+		if p := resp.GetPeer().GetPeer(); p != nil && resp.GetPeer().GetType() == api.WatchEventResponse_PeerEvent_STATE {
+			select {
+			case peers <- p:
+			case <-ctx.Done():
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error watching peer events: %w", err)
+	}
+	states := map[string]api.PeerState_SessionState{}
+	flaps := map[string][]time.Time{}
+	heldDown := map[string]time.Time{}
+	ticker := time.NewTicker(peerDampeningCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case p := <-peers:
+			address := p.GetConf().GetNeighborAddress()
+			state := p.GetState().GetSessionState()
+			wasEstablished := states[address] == api.PeerState_ESTABLISHED
+			states[address] = state
+			if !wasEstablished || state == api.PeerState_ESTABLISHED || p.GetState().GetAdminState() != api.PeerState_UP {
+				continue
+			}
+			now := time.Now()
+			recent := []time.Time{now}
+			for _, t := range flaps[address] {
+				if now.Sub(t) < window {
+					recent = append(recent, t)
+				}
+			}
+			flaps[address] = recent
+			if uint32(len(recent)) <= conf.MaxFlaps {
+				continue
+			}
+			sp.peerFlapDampened.Add(1)
+			flaps[address] = nil
+			if holdDown == 0 {
+				sp.logger.Warn("peer is flapping", log.Fields{"neighbor": address, "flaps": len(recent), "window": window.String()})
+				continue
+			}
+			sp.logger.Warn("peer is flapping, holding it down", log.Fields{"neighbor": address, "flaps": len(recent), "window": window.String(), "hold_down": holdDown.String()})
+			if err := sp.s.ShutdownPeer(ctx, &api.ShutdownPeerRequest{Address: address, Communication: "session flap dampening"}); err != nil {
+				sp.logger.Error("error shutting down flapping peer", log.Fields{"neighbor": address, "error": err.Error()})
+				continue
+			}
+			heldDown[address] = now
+		case <-ticker.C:
+			for address, since := range heldDown {
+				if time.Since(since) < holdDown {
+					continue
+				}
+				if sp.peerDisabled(address) {
+					delete(heldDown, address)
+					continue
+				}
+				sp.logger.Info("enabling peer after flap dampening hold down", log.Fields{"neighbor": address})
+				if err := sp.s.EnablePeer(ctx, &api.EnablePeerRequest{Address: address}); err != nil {
+					sp.logger.Error("error enabling peer", log.Fields{"neighbor": address, "error": err.Error()})
+					continue
+				}
+				delete(heldDown, address)
+			}
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package speaker
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/bmp"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	defaultPeerEventsAddress = "127.0.0.1:0"
+	// bmpMaxMessageSize ограничивает размер сообщения BMP: route monitoring с extended message
+	// может быть до 64 КБ, остальные сообщения заметно меньше.
+	bmpMaxMessageSize = 1 << 20
+	// peerEventsRetryInterval это пауза перед повторным запуском BMP станции после ошибки.
+	peerEventsRetryInterval = 10 * time.Second
+)
+
+// PeerEvents включает журнал сессий с соседями: установление сессии пишется с уровнем info,
+// разрыв с уровнем warn с причиной, направлением NOTIFICATION, его кодом и subcode, а разрывы
+// считаются в метрике peer_down_total. По ним можно понять, почему сессия разорвалась в 03:12.
+//
+// События WatchEvent gobgp не содержат причину разрыва, поэтому speaker поднимает BMP станцию
+// (RFC 7854), к которой подключается gobgp, и берет причину из Peer Down Notification.
+// У BMP в gobgp нет режима без route monitoring, поэтому gobgp также отправляет станции
+// все маршруты, полученные от соседей до политик (adj-rib-in pre-policy): полную таблицу
+// при каждом подключении и затем каждое UPDATE. Speaker их только пропускает, но с полной
+// таблицей от соседей это заметная нагрузка на CPU gobgp и loopback.
+type PeerEvents struct {
+	// Address это адрес BMP станции, по-умолчанию 127.0.0.1 и свободный порт.
+	// С remote_gobgp на другом хосте это должен быть адрес, доступный с него.
+	Address string `yaml:"address"`
+}
+
+func (e *PeerEvents) applyDefaults() {
+	if e.Address == "" {
+		e.Address = defaultPeerEventsAddress
+	}
+}
+
+func (e *PeerEvents) validate() error {
+	host, port, err := net.SplitHostPort(e.Address)
+	if err != nil {
+		return fmt.Errorf("peer_events.address: %w", err)
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		return errors.New("peer_events.address: host must be an ip address reachable by gobgp")
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("peer_events.address: invalid port %q", port)
+	}
+	return nil
+}
+
+// peerDownKey это метки peer_down_total.
+type peerDownKey struct {
+	neighbor string
+	reason   string
+	code     uint8
+	subcode  uint8
+}
+
+// peerDownReasons это значения reason по кодам причины Peer Down Notification.
+var peerDownReasons = map[uint8]string{
+	bmp.BMP_PEER_DOWN_REASON_LOCAL_BGP_NOTIFICATION:  "local_notification",
+	bmp.BMP_PEER_DOWN_REASON_LOCAL_NO_NOTIFICATION:   "local_no_notification",
+	bmp.BMP_PEER_DOWN_REASON_REMOTE_BGP_NOTIFICATION: "remote_notification",
+	bmp.BMP_PEER_DOWN_REASON_REMOTE_NO_NOTIFICATION:  "remote_no_notification",
+	bmp.BMP_PEER_DOWN_REASON_PEER_DE_CONFIGURED:      "deconfigured",
+}
+
+// WatchPeerEvents слушает peer_events.address, пока не завершится ctx, и пишет в лог события
+// сессий, которые отправляет gobgp, см. [PeerEvents]. Сообщения route monitoring пропускаются.
+// Ошибки BMP станции не останавливают speaker: они пишутся в лог, и станция поднимается заново
+// через peerEventsRetryInterval.
+func (sp *Speaker) WatchPeerEvents(ctx context.Context) error {
+	for {
+		if err := sp.watchPeerEvents(ctx); err != nil {
+			sp.logger.Error("peer events failed, retrying", log.Fields{"error": err.Error(), "retry": peerEventsRetryInterval.String()})
+		}
+		timer := time.NewTimer(peerEventsRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// Метод watchPeerEvents поднимает BMP станцию, добавляет ее в gobgp и читает из нее события,
+// пока не завершится ctx или не произойдет ошибка.
+func (sp *Speaker) watchPeerEvents(ctx context.Context) error {
+	l, err := net.Listen("tcp", sp.config.PeerEvents.Address)
+	if err != nil {
+		return fmt.Errorf("error listening peer events address: %w", err)
+	}
+	defer l.Close()
+	stop := context.AfterFunc(ctx, func() {
+		l.Close()
+	})
+	defer stop()
+	addr := l.Addr().(*net.TCPAddr)
+	req := &api.AddBmpRequest{
+		Address: addr.IP.String(),
+		Port:    uint32(addr.Port),
+		Policy:  api.AddBmpRequest_PRE,
+		SysName: defaultSyslogTag,
+	}
+	if err := sp.s.AddBmp(ctx, req); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("bgp add bmp error: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := sp.s.DeleteBmp(ctx, &api.DeleteBmpRequest{Address: req.Address, Port: req.Port}); err != nil {
+			sp.logger.Error("error deleting bmp station", log.Fields{"error": err.Error()})
+		}
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error accepting bmp connection: %w", err)
+		}
+		sp.readPeerEvents(ctx, conn)
+	}
+}
+
+// Метод readPeerEvents читает сообщения BMP из conn, пока gobgp не закроет соединение
+// или не завершится ctx. gobgp переподключается сам, поэтому ошибки только пишутся в лог.
+func (sp *Speaker) readPeerEvents(ctx context.Context, conn net.Conn) {
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), bmpMaxMessageSize)
+	scanner.Split(bmp.SplitBMP)
+	for scanner.Scan() {
+		b := scanner.Bytes()
+		switch b[5] {
+		case bmp.BMP_MSG_PEER_UP_NOTIFICATION:
+			msg, err := bmp.ParseBMPMessage(b)
+			if err != nil {
+				sp.logger.Error("error parsing bmp peer up", log.Fields{"error": err.Error()})
+				continue
+			}
+			sp.logPeerUp(&msg.PeerHeader, msg.Body.(*bmp.BMPPeerUpNotification))
+		case bmp.BMP_MSG_PEER_DOWN_NOTIFICATION:
+			header, down, err := parsePeerDown(b)
+			if err != nil {
+				sp.logger.Error("error parsing bmp peer down", log.Fields{"error": err.Error()})
+				continue
+			}
+			sp.logPeerDown(header, down)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		sp.logger.Warn("bmp connection from gobgp failed", log.Fields{"error": err.Error()})
+	}
+}
+
+// Функция parsePeerDown разбирает Peer Down Notification. [bmp.ParseBMPMessage] не подходит:
+// при истечении hold timer gobgp отправляет причину local_notification без NOTIFICATION.
+func parsePeerDown(b []byte) (*bmp.BMPPeerHeader, *bmp.BMPPeerDownNotification, error) {
+	if len(b) < bmp.BMP_HEADER_SIZE+bmp.BMP_PEER_HEADER_SIZE+1 {
+		return nil, nil, fmt.Errorf("message is too short: %d bytes", len(b))
+	}
+	header := &bmp.BMPPeerHeader{}
+	if err := header.DecodeFromBytes(b[bmp.BMP_HEADER_SIZE:]); err != nil {
+		return nil, nil, err
+	}
+	body := b[bmp.BMP_HEADER_SIZE+bmp.BMP_PEER_HEADER_SIZE:]
+	down := &bmp.BMPPeerDownNotification{Reason: body[0]}
+	if len(body) > 1 && (down.Reason == bmp.BMP_PEER_DOWN_REASON_LOCAL_BGP_NOTIFICATION || down.Reason == bmp.BMP_PEER_DOWN_REASON_REMOTE_BGP_NOTIFICATION) {
+		msg, err := bgp.ParseBGPMessage(body[1:])
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing notification: %w", err)
+		}
+		down.BGPNotification = msg
+	}
+	return header, down, nil
+}
+
+func (sp *Speaker) logPeerUp(header *bmp.BMPPeerHeader, up *bmp.BMPPeerUpNotification) {
+	sp.logger.Info("peer up", log.Fields{
+		"neighbor":      header.PeerAddress.String(),
+		"asn":           header.PeerAS,
+		"local_address": up.LocalAddress.String(),
+		"local_port":    up.LocalPort,
+		"remote_port":   up.RemotePort,
+	})
+}
+
+func (sp *Speaker) logPeerDown(header *bmp.BMPPeerHeader, down *bmp.BMPPeerDownNotification) {
+	reason, ok := peerDownReasons[down.Reason]
+	if !ok {
+		reason = "unknown"
+	}
+	key := peerDownKey{neighbor: header.PeerAddress.String(), reason: reason}
+	fields := log.Fields{"neighbor": key.neighbor, "asn": header.PeerAS, "reason": reason}
+	if n, ok := notificationBody(down.BGPNotification); ok {
+		key.code, key.subcode = n.ErrorCode, n.ErrorSubcode
+		fields["direction"] = "sent"
+		if down.Reason == bmp.BMP_PEER_DOWN_REASON_REMOTE_BGP_NOTIFICATION {
+			fields["direction"] = "received"
+		}
+		fields["code"] = n.ErrorCode
+		fields["subcode"] = n.ErrorSubcode
+		fields["notification"] = bgp.NewNotificationErrorCode(n.ErrorCode, n.ErrorSubcode).String()
+		if communication := shutdownCommunication(n); communication != "" {
+			fields["communication"] = communication
+		}
+	}
+	sp.peerDownMu.Lock()
+	if sp.peerDowns == nil {
+		sp.peerDowns = map[peerDownKey]uint64{}
+	}
+	sp.peerDowns[key]++
+	sp.peerDownMu.Unlock()
+	sp.logger.Warn("peer down", fields)
+}
+
+func notificationBody(msg *bgp.BGPMessage) (*bgp.BGPNotification, bool) {
+	if msg == nil {
+		return nil, false
+	}
+	n, ok := msg.Body.(*bgp.BGPNotification)
+	return n, ok
+}
+
+// Функция shutdownCommunication возвращает сообщение из NOTIFICATION Cease с subcode
+// administrative shutdown или administrative reset (RFC 9003).
+func shutdownCommunication(n *bgp.BGPNotification) string {
+	if n.ErrorCode != bgp.BGP_ERROR_CEASE || n.ErrorSubcode != bgp.BGP_ERROR_SUB_ADMINISTRATIVE_SHUTDOWN && n.ErrorSubcode != bgp.BGP_ERROR_SUB_ADMINISTRATIVE_RESET {
+		return ""
+	}
+	if len(n.Data) == 0 || int(n.Data[0]) > len(n.Data)-1 {
+		return ""
+	}
+	return string(n.Data[1 : 1+n.Data[0]])
+}
+
+// Метод peerDownMetric возвращает peer_down_total по разрывам, записанным [Speaker.WatchPeerEvents].
+func (sp *Speaker) peerDownMetric() *metric {
+	m := &metric{name: "peer_down_total", help: "Number of BGP session downs by reason and NOTIFICATION code, requires peer_events.", typ: metricTypeCounter}
+	sp.peerDownMu.Lock()
+	defer sp.peerDownMu.Unlock()
+	keys := maps.Keys(sp.peerDowns)
+	slices.SortFunc(keys, func(a, b peerDownKey) int {
+		return cmp.Or(cmp.Compare(a.neighbor, b.neighbor), cmp.Compare(a.reason, b.reason), cmp.Compare(a.code, b.code), cmp.Compare(a.subcode, b.subcode))
+	})
+	for _, k := range keys {
+		m.add(float64(sp.peerDowns[k]),
+			label(labelNeighbor, k.neighbor),
+			label(labelReason, k.reason),
+			label(labelCode, strconv.Itoa(int(k.code))),
+			label(labelSubcode, strconv.Itoa(int(k.subcode))),
+		)
+	}
+	return m
+}
@@ -0,0 +1,67 @@
+package speaker
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+const peersPath = "/peers"
+
+// PeerStatus это состояние сессии с соседом в ответе GET /peers.
+type PeerStatus struct {
+	Neighbor string `json:"neighbor"`
+	ASN      uint32 `json:"asn"`
+	// State это состояние сессии gobgp в нижнем регистре, например, established или active.
+	State     string `json:"state"`
+	AdminDown bool   `json:"admin_down"`
+	// UptimeSeconds это сколько секунд установлена сессия, 0 если не установлена.
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Received      uint64 `json:"received"`
+	Accepted      uint64 `json:"accepted"`
+	Advertised    uint64 `json:"advertised"`
+}
+
+// Метод peerStatuses возвращает состояние сессий со всеми соседями gobgp, отсортированное по адресу.
+func (sp *Speaker) peerStatuses(ctx context.Context) ([]PeerStatus, error) {
+	peers := []PeerStatus{}
+	now := time.Now()
+	err := sp.s.ListPeer(ctx, &api.ListPeerRequest{EnableAdvertised: true}, func(p *api.Peer) {
+		s := p.GetState()
+		status := PeerStatus{
+			Neighbor:  p.GetConf().GetNeighborAddress(),
+			ASN:       p.GetConf().GetPeerAsn(),
+			State:     strings.ToLower(s.GetSessionState().String()),
+			AdminDown: s.GetAdminState() != api.PeerState_UP,
+		}
+		if t := p.GetTimers().GetState().GetUptime(); s.GetSessionState() == api.PeerState_ESTABLISHED && t != nil {
+			status.UptimeSeconds = int64(now.Sub(t.AsTime()).Seconds())
+		}
+		for _, afiSafi := range p.GetAfiSafis() {
+			status.Received += afiSafi.GetState().GetReceived()
+			status.Accepted += afiSafi.GetState().GetAccepted()
+			status.Advertised += afiSafi.GetState().GetAdvertised()
+		}
+		peers = append(peers, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Neighbor < peers[j].Neighbor
+	})
+	return peers, nil
+}
+
+func (sp *Speaker) handlePeers(w http.ResponseWriter, r *http.Request) {
+	peers, err := sp.peerStatuses(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, peers)
+}
@@ -0,0 +1,400 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/config/oc"
+	"golang.org/x/exp/maps"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Policies описывает политики policy_mode: custom. Шаблоном служат политики strict:
+//   - defined sets default-route, anycast-ip, uplinks (и next-hop-self) и политики
+//     only-default-route, only-anycast-ip, only-local-anycast-ip (и reject-rpki-invalid)
+//     создаются всегда, на них можно ссылаться по имени
+//   - направление, для которого не задан import или export, назначается как в strict
+//
+// Политики можно собирать из общих statements: statement с use добавляется в политику
+// копией statement из statements или statement политик strict, например, allow-anycast-ip.
+// Одна и та же политика может быть назначена и import, и export.
+type Policies struct {
+	// PrefixSets это наборы префиксов по имени, к префиксу можно добавить диапазон длины маски,
+	// например, "10.0.0.0/8 24..32".
+	PrefixSets map[string][]string `yaml:"prefix_sets"`
+	// NeighborSets это наборы адресов или подсетей соседей по имени.
+	NeighborSets map[string][]string `yaml:"neighbor_sets"`
+	// Statements это общие statements, которые политики подключают через use.
+	Statements  []PolicyStatement  `yaml:"statements"`
+	Definitions []PolicyDefinition `yaml:"definitions"`
+	Import      *PolicyAssignment  `yaml:"import"`
+	Export      *PolicyAssignment  `yaml:"export"`
+}
+
+type PolicyDefinition struct {
+	Name       string            `yaml:"name"`
+	Statements []PolicyStatement `yaml:"statements"`
+}
+
+type PolicyStatement struct {
+	// Use подключает общий statement по имени, остальные поля при этом не задаются.
+	// В gobgp такой statement называется "<политика>/<use>", так как имена statement в gobgp
+	// общие для всех политик.
+	Use   string      `yaml:"use"`
+	Name  string      `yaml:"name"`
+	Match PolicyMatch `yaml:"match"`
+	// Action это accept или reject, без action после set-действий проверяется следующий statement.
+	Action PolicyAction  `yaml:"action"`
+	Set    PolicyActions `yaml:"set"`
+}
+
+// PolicyMatch это условия statement, все заданные условия должны выполняться.
+// Имя набора с "!" в начале инвертирует условие, например, "!uplinks".
+type PolicyMatch struct {
+	PrefixSet   string `yaml:"prefix_set"`
+	NeighborSet string `yaml:"neighbor_set"`
+	// Local выбирает только маршруты, анонсируемые самим speaker.
+	Local bool `yaml:"local"`
+}
+
+type PolicyActions struct {
+	// Communities добавляются к маршруту.
+	Communities []Community `yaml:"communities"`
+	LocalPref   *uint32     `yaml:"local_pref"`
+	MED         *uint32     `yaml:"med"`
+	// NextHop это ip адрес или "self".
+	NextHop string `yaml:"next_hop"`
+	// Prepend задает, сколько раз добавить свой ASN в AS_PATH.
+	Prepend uint8 `yaml:"prepend"`
+}
+
+// PolicyAssignment назначает политики направлению, маршрут, не принятый и не отклоненный
+// ни одной из них, обрабатывается default_action (по-умолчанию как в default_action конфигурации).
+type PolicyAssignment struct {
+	Policies      []string     `yaml:"policies"`
+	DefaultAction PolicyAction `yaml:"default_action"`
+}
+
+// DefaultAction это действия по-умолчанию назначений политик import и export.
+type DefaultAction struct {
+	Import PolicyAction `yaml:"import"`
+	Export PolicyAction `yaml:"export"`
+}
+
+type PolicyAction string
+
+const (
+	PolicyActionAccept PolicyAction = "accept"
+	PolicyActionReject PolicyAction = "reject"
+)
+
+// Метод inline возвращает true, если у statement задано что-то кроме use.
+func (st PolicyStatement) inline() bool {
+	return st.Name != "" || st.Match != (PolicyMatch{}) || st.Action != "" || len(st.Set.Communities) > 0 ||
+		st.Set.LocalPref != nil || st.Set.MED != nil || st.Set.NextHop != "" || st.Set.Prepend > 0
+}
+
+func (a *PolicyAssignment) policies() []string {
+	if a == nil {
+		return nil
+	}
+	return a.Policies
+}
+
+func (a *PolicyAction) UnmarshalYAML(node *yaml.Node) error {
+	switch action := PolicyAction(node.Value); action {
+	case PolicyActionAccept, PolicyActionReject:
+		*a = action
+		return nil
+	default:
+		return fmt.Errorf("unknown policy action %q, expected %s or %s", node.Value, PolicyActionAccept, PolicyActionReject)
+	}
+}
+
+func (a PolicyAction) routeAction() api.RouteAction {
+	switch a {
+	case PolicyActionAccept:
+		return api.RouteAction_ACCEPT
+	case PolicyActionReject:
+		return api.RouteAction_REJECT
+	default:
+		return api.RouteAction_NONE
+	}
+}
+
+// Метод strictPolicySets возвращает true, если создаются defined sets политик strict,
+// которые нужно обновлять при изменении соседей.
+func (c *Config) strictPolicySets() bool {
+	return c.PolicyMode == PolicyModeStrict || c.PolicyMode == PolicyModeCustom && c.Policies != nil
+}
+
+// Метод defaultAction возвращает действие по-умолчанию для направления, reject, если оно не задано.
+func (c *Config) defaultAction(direction api.PolicyDirection) api.RouteAction {
+	action := PolicyActionReject
+	if c.DefaultAction != nil && direction == api.PolicyDirection_IMPORT && c.DefaultAction.Import != "" {
+		action = c.DefaultAction.Import
+	}
+	if c.DefaultAction != nil && direction == api.PolicyDirection_EXPORT && c.DefaultAction.Export != "" {
+		action = c.DefaultAction.Export
+	}
+	return action.routeAction()
+}
+
+// Метод validatePolicies проверяет имена и ссылки в policies.
+func (c *Config) validatePolicies() error {
+	errs := []error{}
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	p := c.Policies
+	if c.PolicyMode != PolicyModeCustom {
+		add("policies: requires policy_mode: %s", PolicyModeCustom)
+	}
+	if c.customPolicies != nil {
+		add("policies: cannot be combined with policy-definitions in gobgp_config")
+	}
+	prefixSets := map[string]bool{defaultRoute: true, anycastIP: true}
+	neighborSets := map[string]bool{uplinks: true, nextHopSelf: true}
+	prefixSetNames, neighborSetNames := maps.Keys(p.PrefixSets), maps.Keys(p.NeighborSets)
+	slices.Sort(prefixSetNames)
+	slices.Sort(neighborSetNames)
+	for _, name := range prefixSetNames {
+		if prefixSets[name] || neighborSets[name] {
+			add("policies.prefix_sets.%s: name is reserved", name)
+		}
+		for _, prefix := range p.PrefixSets[name] {
+			if _, err := parsePolicyPrefix(prefix); err != nil {
+				add("policies.prefix_sets.%s: %q: %s", name, prefix, err.Error())
+			}
+		}
+	}
+	for _, name := range neighborSetNames {
+		if prefixSets[name] || neighborSets[name] {
+			add("policies.neighbor_sets.%s: name is reserved", name)
+		}
+		if _, ok := p.PrefixSets[name]; ok {
+			add("policies.neighbor_sets.%s: name is already used by prefix_sets", name)
+		}
+		for _, n := range p.NeighborSets[name] {
+			if _, _, err := net.ParseCIDR(n); err != nil && net.ParseIP(n) == nil {
+				add("policies.neighbor_sets.%s: %q is not a valid ip address or subnet", name, n)
+			}
+		}
+	}
+	for _, name := range prefixSetNames {
+		prefixSets[name] = true
+	}
+	for _, name := range neighborSetNames {
+		neighborSets[name] = true
+	}
+	policies := map[string]bool{defaultRoutePolicy: true, onlyAnycastIP: true, onlyLocalAnycastIP: true}
+	if c.RPKI != nil {
+		policies[rejectRPKIInvalidPolicy] = true
+	}
+	// имена statement в gobgp общие для всех политик
+	statements := map[string]bool{
+		"allow-default-route": true, "allow-anycast-ip": true, "allow-anycast-ip-next-hop-self": true,
+		"allow-anycast-ip-igp": true, "reject-rpki-invalid": true,
+	}
+	// statements, которые можно подключить через use
+	shared := map[string]bool{"allow-default-route": true, "allow-anycast-ip": true, "allow-anycast-ip-igp": true}
+	if slices.ContainsFunc(c.Neighbors, func(n Neighbor) bool { return n.NextHopSelf }) {
+		shared["allow-anycast-ip-next-hop-self"] = true
+	}
+	if c.RPKI != nil {
+		shared["reject-rpki-invalid"] = true
+	}
+	checkStatement := func(field string, st PolicyStatement) {
+		switch {
+		case st.Name == "":
+			add("%s.name: is required", field)
+		case strings.Contains(st.Name, "/"):
+			add("%s.name: %q must not contain /", field, st.Name)
+		case statements[st.Name]:
+			add("%s.name: statement %q is already defined", field, st.Name)
+		}
+		statements[st.Name] = true
+		if name := strings.TrimPrefix(st.Match.PrefixSet, "!"); name != "" && !prefixSets[name] {
+			add("%s.match.prefix_set: unknown prefix set %q", field, name)
+		}
+		if name := strings.TrimPrefix(st.Match.NeighborSet, "!"); name != "" && !neighborSets[name] {
+			add("%s.match.neighbor_set: unknown neighbor set %q", field, name)
+		}
+		if nh := st.Set.NextHop; nh != "" && nh != "self" && net.ParseIP(nh) == nil {
+			add("%s.set.next_hop: %q is not a valid ip address or self", field, nh)
+		}
+	}
+	for i, st := range p.Statements {
+		field := fmt.Sprintf("policies.statements[%d]", i)
+		if st.Use != "" {
+			add("%s.use: is not allowed in shared statements", field)
+		}
+		checkStatement(field, st)
+		shared[st.Name] = true
+	}
+	for i, d := range p.Definitions {
+		field := fmt.Sprintf("policies.definitions[%d]", i)
+		switch {
+		case d.Name == "":
+			add("%s.name: is required", field)
+		case d.Name == defaultRoutePolicy || d.Name == onlyAnycastIP || d.Name == onlyLocalAnycastIP || d.Name == rejectRPKIInvalidPolicy:
+			add("%s.name: %q is reserved", field, d.Name)
+		case policies[d.Name]:
+			add("%s.name: duplicate policy %q", field, d.Name)
+		}
+		policies[d.Name] = true
+		if len(d.Statements) == 0 {
+			add("%s.statements: at least one statement is required", field)
+		}
+		used := map[string]bool{}
+		for j, st := range d.Statements {
+			field := fmt.Sprintf("%s.statements[%d]", field, j)
+			switch {
+			case st.Use == "":
+				checkStatement(field, st)
+			case st.inline():
+				add("%s.use: must be the only field of statement", field)
+			case !shared[st.Use]:
+				add("%s.use: unknown statement %q", field, st.Use)
+			case used[st.Use]:
+				add("%s.use: statement %q is already used in policy", field, st.Use)
+			}
+			used[st.Use] = true
+		}
+	}
+	for _, name := range p.Import.policies() {
+		if !policies[name] {
+			add("policies.import.policies: unknown policy %q", name)
+		}
+	}
+	for _, name := range p.Export.policies() {
+		if !policies[name] {
+			add("policies.export.policies: unknown policy %q", name)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Функция parsePolicyPrefix разбирает префикс prefix_sets с необязательным диапазоном длины маски.
+func parsePolicyPrefix(s string) (*api.Prefix, error) {
+	prefix, masklengthRange, _ := strings.Cut(strings.TrimSpace(s), " ")
+	return goBGPPrefix(oc.Prefix{IpPrefix: prefix, MasklengthRange: strings.TrimSpace(masklengthRange)})
+}
+
+// Метод compile переводит policies в формат gobgp API поверх template, политик strict.
+// Конфигурация уже проверена, поэтому ошибки разбора не возвращаются.
+func (p *Policies) compile(template *customPolicies, asn uint32) *customPolicies {
+	compiled := &customPolicies{
+		definedSets: slices.Clone(template.definedSets),
+		policies:    slices.Clone(template.policies),
+	}
+	prefixSetNames, neighborSetNames := maps.Keys(p.PrefixSets), maps.Keys(p.NeighborSets)
+	slices.Sort(prefixSetNames)
+	slices.Sort(neighborSetNames)
+	for _, name := range prefixSetNames {
+		set := &api.DefinedSet{DefinedType: api.DefinedType_PREFIX, Name: name}
+		for _, s := range p.PrefixSets[name] {
+			prefix, _ := parsePolicyPrefix(s)
+			set.Prefixes = append(set.Prefixes, prefix)
+		}
+		compiled.definedSets = append(compiled.definedSets, set)
+	}
+	for _, name := range neighborSetNames {
+		list := []string{}
+		for _, n := range p.NeighborSets[name] {
+			if strings.Contains(n, "/") {
+				list = append(list, n)
+			} else {
+				list = append(list, neighborPrefix(n))
+			}
+		}
+		compiled.definedSets = append(compiled.definedSets, &api.DefinedSet{DefinedType: api.DefinedType_NEIGHBOR, Name: name, List: list})
+	}
+	shared := map[string]*api.Statement{}
+	for _, policy := range template.policies {
+		for _, st := range policy.Statements {
+			shared[st.Name] = st
+		}
+	}
+	for _, st := range p.Statements {
+		shared[st.Name] = st.compile(asn)
+	}
+	for _, d := range p.Definitions {
+		policy := &api.Policy{Name: d.Name}
+		for _, st := range d.Statements {
+			if st.Use == "" {
+				policy.Statements = append(policy.Statements, st.compile(asn))
+				continue
+			}
+			statement := proto.Clone(shared[st.Use]).(*api.Statement)
+			statement.Name = d.Name + "/" + st.Use
+			policy.Statements = append(policy.Statements, statement)
+		}
+		compiled.policies = append(compiled.policies, policy)
+	}
+	for _, a := range template.assignments {
+		custom := p.Import
+		if a.Direction == api.PolicyDirection_EXPORT {
+			custom = p.Export
+		}
+		if custom == nil {
+			compiled.assignments = append(compiled.assignments, a)
+			continue
+		}
+		assignment := &api.PolicyAssignment{Name: a.Name, Direction: a.Direction, DefaultAction: a.DefaultAction}
+		if custom.DefaultAction != "" {
+			assignment.DefaultAction = custom.DefaultAction.routeAction()
+		}
+		for _, name := range custom.Policies {
+			assignment.Policies = append(assignment.Policies, &api.Policy{Name: name})
+		}
+		compiled.assignments = append(compiled.assignments, assignment)
+	}
+	return compiled
+}
+
+func (st PolicyStatement) compile(asn uint32) *api.Statement {
+	conditions := &api.Conditions{
+		PrefixSet:   policyMatchSet(st.Match.PrefixSet),
+		NeighborSet: policyMatchSet(st.Match.NeighborSet),
+	}
+	if st.Match.Local {
+		conditions.RouteType = api.Conditions_ROUTE_TYPE_LOCAL
+	}
+	actions := &api.Actions{RouteAction: st.Action.routeAction()}
+	if len(st.Set.Communities) > 0 {
+		communities := []string{}
+		for _, c := range st.Set.Communities {
+			communities = append(communities, c.String())
+		}
+		actions.Community = &api.CommunityAction{Type: api.CommunityAction_ADD, Communities: communities}
+	}
+	if st.Set.LocalPref != nil {
+		actions.LocalPref = &api.LocalPrefAction{Value: *st.Set.LocalPref}
+	}
+	if st.Set.MED != nil {
+		actions.Med = &api.MedAction{Type: api.MedAction_REPLACE, Value: int64(*st.Set.MED)}
+	}
+	if st.Set.NextHop == "self" {
+		actions.Nexthop = &api.NexthopAction{Self: true}
+	} else if st.Set.NextHop != "" {
+		actions.Nexthop = &api.NexthopAction{Address: st.Set.NextHop}
+	}
+	if st.Set.Prepend > 0 {
+		actions.AsPrepend = &api.AsPrependAction{Asn: asn, Repeat: uint32(st.Set.Prepend)}
+	}
+	return &api.Statement{Name: st.Name, Conditions: conditions, Actions: actions}
+}
+
+// Функция policyMatchSet переводит имя набора в условие, "!" в начале имени инвертирует его.
+func policyMatchSet(name string) *api.MatchSet {
+	if inverted, ok := strings.CutPrefix(name, "!"); ok {
+		return goBGPMatchSet(inverted, "invert")
+	}
+	return goBGPMatchSet(name, "")
+}
@@ -0,0 +1,83 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const policiesPath = "/policies"
+
+// PolicyDump это действующие в gobgp defined sets, политики и их назначения в кодировке
+// protojson, т.е. то, во что скомпилированы policy_mode, policies и default_action.
+type PolicyDump struct {
+	PolicyMode  PolicyMode        `json:"policy_mode"`
+	DefinedSets []json.RawMessage `json:"defined_sets"`
+	Policies    []json.RawMessage `json:"policies"`
+	Assignments []json.RawMessage `json:"assignments"`
+}
+
+// Метод dumpPolicies собирает PolicyDump из gobgp.
+func (sp *Speaker) dumpPolicies(ctx context.Context) (*PolicyDump, error) {
+	dump := &PolicyDump{
+		PolicyMode:  sp.config.PolicyMode,
+		DefinedSets: []json.RawMessage{},
+		Policies:    []json.RawMessage{},
+		Assignments: []json.RawMessage{},
+	}
+	var marshalErr error
+	appendMessage := func(list *[]json.RawMessage, m proto.Message) {
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			marshalErr = err
+			return
+		}
+		*list = append(*list, b)
+	}
+	definedTypes := []api.DefinedType{
+		api.DefinedType_PREFIX,
+		api.DefinedType_NEIGHBOR,
+		api.DefinedType_AS_PATH,
+		api.DefinedType_COMMUNITY,
+		api.DefinedType_EXT_COMMUNITY,
+		api.DefinedType_LARGE_COMMUNITY,
+	}
+	for _, t := range definedTypes {
+		err := sp.s.ListDefinedSet(ctx, &api.ListDefinedSetRequest{DefinedType: t}, func(s *api.DefinedSet) {
+			appendMessage(&dump.DefinedSets, s)
+		})
+		// gobgp не знает тип, пока не создан хотя бы один defined set этого типа
+		if err != nil && !strings.Contains(err.Error(), "invalid defined-set type") {
+			return nil, fmt.Errorf("bgp list defined-set error: %w", err)
+		}
+	}
+	if err := sp.s.ListPolicy(ctx, &api.ListPolicyRequest{}, func(p *api.Policy) {
+		appendMessage(&dump.Policies, p)
+	}); err != nil {
+		return nil, fmt.Errorf("bgp list policy error: %w", err)
+	}
+	if err := sp.s.ListPolicyAssignment(ctx, &api.ListPolicyAssignmentRequest{Name: global}, func(a *api.PolicyAssignment) {
+		appendMessage(&dump.Assignments, a)
+	}); err != nil {
+		return nil, fmt.Errorf("bgp list policy assignment error: %w", err)
+	}
+	if marshalErr != nil {
+		return nil, fmt.Errorf("failed to marshal policies: %w", marshalErr)
+	}
+	return dump, nil
+}
+
+func (sp *Speaker) handlePolicies(w http.ResponseWriter, r *http.Request) {
+	dump, err := sp.dumpPolicies(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, dump)
+}
@@ -0,0 +1,205 @@
+package speaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"golang.org/x/exp/maps"
+	"golang.org/x/sync/errgroup"
+)
+
+// HealthCheckSpec это именованная проверка здоровья из health_checks. Поля повторяют
+// глобальные health_source, health_check_url, health_check, consul и *_health_check.
+type HealthCheckSpec struct {
+	// Source по-умолчанию http.
+	Source HealthSource `yaml:"source"`
+	// URL это адрес HTTP проверки для source: http, как health_check_url.
+	URL    string             `yaml:"url"`
+	HTTP   *HealthCheckConfig `yaml:"http"`
+	Consul *Consul            `yaml:"consul"`
+	GRPC   *GRPCHealthCheck   `yaml:"grpc"`
+	ICMP   *ICMPHealthCheck   `yaml:"icmp"`
+	File   *FileHealthCheck   `yaml:"file"`
+}
+
+// AnycastPrefix это дополнительный префикс /32, анонс которого зависит от собственных
+// проверок здоровья, а не от проверки anycast ip: при неудаче любой из HealthChecks
+// отзывается только этот префикс.
+type AnycastPrefix struct {
+	Prefix string `yaml:"prefix"`
+	// NextHop это адрес хоста, на котором живет префикс, по-умолчанию адрес speaker.
+	NextHop string `yaml:"next_hop"`
+	// HealthChecks это имена проверок из health_checks, префикс анонсируется,
+	// только пока проходят все.
+	HealthChecks []string `yaml:"health_checks"`
+}
+
+// Метод healthCheckSpec возвращает глобальную проверку здоровья anycast ip в виде HealthCheckSpec.
+func (c *Config) healthCheckSpec() HealthCheckSpec {
+	return HealthCheckSpec{
+		Source: c.HealthSource,
+		URL:    c.HealthCheckURL,
+		HTTP:   c.HealthCheck,
+		Consul: c.Consul,
+		GRPC:   c.GRPCHealthCheck,
+		ICMP:   c.ICMPHealthCheck,
+		File:   c.FileHealthCheck,
+	}
+}
+
+// Метод managedPrefix возвращает true, если анонсом ip управляют проверки из prefixes.
+func (c *Config) managedPrefix(ip string) bool {
+	return slices.ContainsFunc(c.Prefixes, func(p AnycastPrefix) bool {
+		prefix, err := parseHostPrefix(p.Prefix)
+		return err == nil && prefix == ip
+	})
+}
+
+// Метод validatePrefixes проверяет health_checks и prefixes.
+func (c *Config) validatePrefixes() error {
+	errs := []error{}
+	add := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+	names := maps.Keys(c.HealthChecks)
+	slices.Sort(names)
+	for _, name := range names {
+		spec := c.HealthChecks[name]
+		field := "health_checks." + name
+		switch spec.Source {
+		case "", HealthSourceHTTP:
+			if spec.URL == "" {
+				add("%s.url: is required for source: http", field)
+			} else if err := validateHealthCheckURL(spec.URL); err != nil {
+				add("%s.url: %w", field, err)
+			}
+		case HealthSourceConsul:
+			if spec.Consul == nil || spec.Consul.Service == "" {
+				add("%s.consul.service: is required for source: consul", field)
+			}
+		case HealthSourceGRPC:
+			if spec.GRPC == nil || spec.GRPC.Address == "" {
+				add("%s.grpc.address: is required for source: grpc", field)
+			} else if _, _, err := net.SplitHostPort(spec.GRPC.Address); err != nil {
+				add("%s.grpc.address: %w", field, err)
+			}
+		case HealthSourceICMP:
+			if spec.ICMP == nil {
+				add("%s.icmp.address: is required for source: icmp", field)
+			} else if ip := net.ParseIP(spec.ICMP.Address); ip == nil || ip.To4() == nil {
+				add("%s.icmp.address: %q is not a valid ipv4 address", field, spec.ICMP.Address)
+			}
+		case HealthSourceFile:
+			if spec.File == nil || spec.File.Path == "" {
+				add("%s.file.path: is required for source: file", field)
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for i, p := range c.Prefixes {
+		field := fmt.Sprintf("prefixes[%d]", i)
+		ip, err := parseHostPrefix(p.Prefix)
+		if err != nil {
+			add("%s.prefix: %w", field, err)
+		} else if ip == c.AnycastIP {
+			add("%s.prefix: anycast ip is managed by the global health check", field)
+		} else if seen[ip] {
+			add("%s.prefix: %s is listed twice", field, p.Prefix)
+		}
+		seen[ip] = true
+		if parsed := net.ParseIP(p.NextHop); p.NextHop != "" && (parsed == nil || parsed.To4() == nil) {
+			add("%s.next_hop: %q is not a valid ipv4 address", field, p.NextHop)
+		}
+		if len(p.HealthChecks) == 0 {
+			add("%s.health_checks: at least one health check is required", field)
+		}
+		for _, name := range p.HealthChecks {
+			if _, ok := c.HealthChecks[name]; !ok {
+				add("%s.health_checks: %q is not defined in health_checks", field, name)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Метод runPrefixHealthChecks запускает в eg проверки из health_checks.
+func (sp *Speaker) runPrefixHealthChecks(ctx context.Context, eg *errgroup.Group) error {
+	names := maps.Keys(sp.config.HealthChecks)
+	slices.Sort(names)
+	healthChecks := map[string]*HealthCheck{}
+	for _, name := range names {
+		hc, err := sp.newSourceHealthCheck(sp.config.HealthChecks[name], "",
+			func(ctx context.Context) error {
+				return sp.setPrefixCheck(ctx, name, true)
+			},
+			func(ctx context.Context) error {
+				return sp.setPrefixCheck(ctx, name, false)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("health_checks.%s: %w", name, err)
+		}
+		sp.scheduleHealthCheck(hc)
+		healthChecks[name] = hc
+	}
+	sp.pathMu.Lock()
+	sp.prefixHealthChecks = healthChecks
+	sp.pathMu.Unlock()
+	for _, hc := range healthChecks {
+		eg.Go(func() error {
+			return hc.Run(ctx, *sp.logger)
+		})
+	}
+	return nil
+}
+
+// Метод setPrefixCheck запоминает статус проверки name и анонсирует или отзывает
+// префиксы, которые от нее зависят.
+func (sp *Speaker) setPrefixCheck(ctx context.Context, name string, healthy bool) error {
+	ctx = withAuditCause(ctx, auditCauseHealthCheck, name)
+	sp.pathMu.Lock()
+	defer sp.pathMu.Unlock()
+	if sp.prefixChecks == nil {
+		sp.prefixChecks = map[string]bool{}
+	}
+	old := sp.prefixChecks[name]
+	sp.logger.Warn("prefix health check status changed", log.Fields{"health_check": name, "healthy": healthy})
+	sp.prefixChecks[name] = healthy
+	if err := sp.reconcilePrefixes(ctx); err != nil {
+		sp.prefixChecks[name] = old
+		return err
+	}
+	return nil
+}
+
+// Метод reconcilePrefixes анонсирует префиксы из prefixes, у которых проходят все проверки,
+// и отзывает остальные. Как и anycast ip, в режиме обслуживания и на standby реплике
+// префиксы не анонсируются. Вызывается под pathMu.
+func (sp *Speaker) reconcilePrefixes(ctx context.Context) error {
+	errs := []error{}
+	for _, p := range sp.config.Prefixes {
+		ip, err := parseHostPrefix(p.Prefix)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		up := !sp.maintenance && sp.leader
+		for _, name := range p.HealthChecks {
+			up = up && sp.prefixChecks[name]
+		}
+		if up {
+			err = sp.AdvertisePrefixVia(ctx, ip, p.NextHop)
+		} else {
+			err = sp.WithdrawPrefix(ctx, ip)
+		}
+		if err != nil {
+			sp.logger.Error("failed to update prefix", log.Fields{"prefix": ip, "error": err.Error()})
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,82 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const prefixLimitCheckIntervalSeconds = 1
+
+// RestartPrefixLimitedPeers включает обратно соседей, которых gobgp выключил из-за превышения max_prefixes.
+//
+// После превышения лимита gobgp переводит соседа в административное состояние PFX_CT и
+// больше не пытается установить сессию. Метод раз в секунду проверяет состояние соседей
+// и вызывает EnablePeer, если сосед находится в PFX_CT дольше, чем restart_seconds.
+func (sp *Speaker) RestartPrefixLimitedPeers(ctx context.Context) error {
+	restartAfter := map[string]time.Duration{}
+	for _, n := range sp.neighbors() {
+		if n.MaxPrefixes != nil && n.MaxPrefixes.RestartSeconds > 0 {
+			restartAfter[n.Address] = time.Second * time.Duration(n.MaxPrefixes.RestartSeconds)
+		}
+	}
+	limitedSince := map[string]time.Time{}
+	ticker := time.NewTicker(time.Second * prefixLimitCheckIntervalSeconds)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			sp.logger.Info(fmt.Sprintf("stop watching prefix limits: %s", ctx.Err().Error()), nil)
+			return nil
+		case <-ticker.C:
+			limited := map[string]struct{}{}
+			err := sp.s.ListPeer(ctx, &api.ListPeerRequest{}, func(p *api.Peer) {
+				if p.State != nil && p.State.AdminState == api.PeerState_PFX_CT {
+					limited[p.Conf.NeighborAddress] = struct{}{}
+				}
+			})
+			if err != nil {
+				sp.logger.Error("error listing peers", log.Fields{"error": err.Error()})
+				continue
+			}
+			for address := range limitedSince {
+				if _, ok := limited[address]; !ok {
+					delete(limitedSince, address)
+				}
+			}
+			for address := range limited {
+				timeout, ok := restartAfter[address]
+				if !ok {
+					continue
+				}
+				since, ok := limitedSince[address]
+				if !ok {
+					sp.logger.Warn("peer shut down by prefix limit, restart scheduled", log.Fields{"neighbor": address, "restart_in": timeout.String()})
+					limitedSince[address] = time.Now()
+					continue
+				}
+				if time.Since(since) < timeout || sp.peerDisabled(address) {
+					continue
+				}
+				sp.logger.Info("restarting peer after prefix limit", log.Fields{"neighbor": address})
+				if err := sp.s.EnablePeer(ctx, &api.EnablePeerRequest{Address: address}); err != nil {
+					sp.logger.Error("error enabling peer", log.Fields{"neighbor": address, "error": err.Error()})
+					continue
+				}
+				delete(limitedSince, address)
+			}
+		}
+	}
+}
+
+func (sp *Speaker) prefixLimitRestartEnabled() bool {
+	for _, n := range sp.neighbors() {
+		if n.MaxPrefixes != nil && n.MaxPrefixes.RestartSeconds > 0 {
+			return true
+		}
+	}
+	return false
+}
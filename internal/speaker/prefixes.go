@@ -0,0 +1,276 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"golang.org/x/exp/maps"
+)
+
+const routesPath = "/routes"
+
+// RouteRequest это тело запросов POST и DELETE /routes.
+type RouteRequest struct {
+	Prefix string `json:"prefix"`
+	// NextHop это адрес хоста, на котором живет префикс, только для POST.
+	NextHop string `json:"next_hop,omitempty"`
+}
+
+// RoutesResponse это список дополнительных анонсируемых префиксов.
+type RoutesResponse struct {
+	Prefixes []string `json:"prefixes"`
+	// NextHops содержит next-hop префиксов, анонсированных от имени другого хоста.
+	NextHops map[string]string `json:"next_hops,omitempty"`
+	// Held это префиксы, которые анонсируются, когда реплика станет leader и выйдет из режима обслуживания.
+	Held []string `json:"held,omitempty"`
+}
+
+// AdvertisePrefix анонсирует дополнительный префикс ip/32 помимо anycast ip.
+//
+// Префикс добавляется в defined-set "anycast-ip", поэтому его пропускают те же политики
+// импорта и экспорта, что и anycast ip. Повторный анонс уже анонсированного префикса ничего не делает.
+func (sp *Speaker) AdvertisePrefix(ctx context.Context, ip string) error {
+	return sp.AdvertisePrefixVia(ctx, ip, "")
+}
+
+// AdvertisePrefixVia анонсирует дополнительный префикс ip/32 с next-hop nextHop,
+// то есть от имени другого хоста. Пустой nextHop означает адрес speaker, как в [Speaker.AdvertisePrefix].
+//
+// Повторный анонс префикса с другим next-hop заменяет анонс. Как и anycast ip, на standby реплике
+// и в режиме обслуживания префикс не анонсируется, а запоминается до выхода из этих состояний.
+func (sp *Speaker) AdvertisePrefixVia(ctx context.Context, ip, nextHop string) error {
+	if !sp.config.advertiseEnabled() {
+		return fmt.Errorf("advertise is disabled in config")
+	}
+	if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+		return fmt.Errorf("prefix %q is not an ipv4 address", ip)
+	}
+	if parsed := net.ParseIP(nextHop); nextHop != "" && (parsed == nil || parsed.To4() == nil) {
+		return fmt.Errorf("next hop %q is not an ipv4 address", nextHop)
+	}
+	sp.prefixMu.Lock()
+	defer sp.prefixMu.Unlock()
+	if sp.prefixesHeld {
+		if _, ok := sp.extraPrefixes[ip]; !ok {
+			sp.logger.Info("holding prefix until replica is leader and not in maintenance", log.Fields{"prefix": ip, "next_hop": nextHop})
+			if sp.heldPrefixes == nil {
+				sp.heldPrefixes = map[string]string{}
+			}
+			sp.heldPrefixes[ip] = nextHop
+			return nil
+		}
+	}
+	return sp.advertisePrefix(ctx, ip, nextHop)
+}
+
+// Метод advertisePrefix анонсирует префикс ip, вызывается под prefixMu.
+func (sp *Speaker) advertisePrefix(ctx context.Context, ip, nextHop string) error {
+	old, ok := sp.extraPrefixes[ip]
+	if ok && old == nextHop {
+		return nil
+	}
+	if !ok {
+		if err := sp.addDefinedSet(ctx, sp.hostPrefixSet(ip)); err != nil {
+			return err
+		}
+	}
+	path, err := sp.hostPath(ip, pathAttrs{nextHop: nextHop})
+	if err != nil {
+		return err
+	}
+	sp.logger.Info("advertising prefix", log.Fields{"prefix": ip, "next_hop": nextHop})
+	if _, err := sp.s.AddPath(ctx, &api.AddPathRequest{Path: path}); err != nil {
+		return err
+	}
+	if sp.extraPrefixes == nil {
+		sp.extraPrefixes = map[string]string{}
+	}
+	sp.extraPrefixes[ip] = nextHop
+	sp.audit(ctx, auditActionAdvertise, ip, nextHop)
+	return nil
+}
+
+// WithdrawPrefix отзывает префикс, анонсированный AdvertisePrefix.
+func (sp *Speaker) WithdrawPrefix(ctx context.Context, ip string) error {
+	sp.prefixMu.Lock()
+	defer sp.prefixMu.Unlock()
+	delete(sp.heldPrefixes, ip)
+	return sp.withdrawPrefix(ctx, ip)
+}
+
+// Метод withdrawPrefix отзывает префикс ip, вызывается под prefixMu.
+func (sp *Speaker) withdrawPrefix(ctx context.Context, ip string) error {
+	nextHop, ok := sp.extraPrefixes[ip]
+	if !ok {
+		return nil
+	}
+	path, err := sp.hostPath(ip, pathAttrs{nextHop: nextHop})
+	if err != nil {
+		return err
+	}
+	sp.logger.Warn("withdrawing prefix", log.Fields{"prefix": ip})
+	if err := sp.s.DeletePath(ctx, &api.DeletePathRequest{Path: path}); err != nil {
+		return err
+	}
+	if ip != sp.config.AnycastIP {
+		if err := sp.s.DeleteDefinedSet(ctx, &api.DeleteDefinedSetRequest{DefinedSet: sp.hostPrefixSet(ip)}); err != nil {
+			return fmt.Errorf("error deleting prefix from defined-set \"%s\": %w", anycastIP, err)
+		}
+	}
+	delete(sp.extraPrefixes, ip)
+	sp.audit(ctx, auditActionWithdraw, ip, nextHop)
+	return nil
+}
+
+// Метод holdPrefixes вызывается под pathMu при смене leader или maintenance. Если held равен true,
+// то есть реплика стала standby или вошла в режим обслуживания, префиксы из admin API и kubernetes
+// отзываются и запоминаются, иначе запомненные префиксы анонсируются снова.
+// Префиксы из prefixes анонсирует и отзывает [Speaker.reconcilePrefixes].
+func (sp *Speaker) holdPrefixes(ctx context.Context, held bool) error {
+	sp.prefixMu.Lock()
+	defer sp.prefixMu.Unlock()
+	if sp.prefixesHeld == held {
+		return nil
+	}
+	sp.prefixesHeld = held
+	errs := []error{}
+	if held {
+		prefixes := maps.Keys(sp.extraPrefixes)
+		slices.Sort(prefixes)
+		for _, ip := range prefixes {
+			if sp.config.managedPrefix(ip) {
+				continue
+			}
+			nextHop := sp.extraPrefixes[ip]
+			if err := sp.withdrawPrefix(ctx, ip); err != nil {
+				errs = append(errs, fmt.Errorf("error withdrawing prefix %s: %w", ip, err))
+				continue
+			}
+			if sp.heldPrefixes == nil {
+				sp.heldPrefixes = map[string]string{}
+			}
+			sp.heldPrefixes[ip] = nextHop
+		}
+		return errors.Join(errs...)
+	}
+	prefixes := maps.Keys(sp.heldPrefixes)
+	slices.Sort(prefixes)
+	for _, ip := range prefixes {
+		if err := sp.advertisePrefix(ctx, ip, sp.heldPrefixes[ip]); err != nil {
+			errs = append(errs, fmt.Errorf("error advertising prefix %s: %w", ip, err))
+			continue
+		}
+		delete(sp.heldPrefixes, ip)
+	}
+	return errors.Join(errs...)
+}
+
+// HeldPrefixes возвращает отсортированный список префиксов, которые не анонсируются,
+// пока реплика standby или в режиме обслуживания.
+func (sp *Speaker) HeldPrefixes() []string {
+	sp.prefixMu.Lock()
+	defer sp.prefixMu.Unlock()
+	prefixes := maps.Keys(sp.heldPrefixes)
+	slices.Sort(prefixes)
+	return prefixes
+}
+
+// AdvertisedPrefixes возвращает отсортированный список дополнительных анонсируемых префиксов.
+func (sp *Speaker) AdvertisedPrefixes() []string {
+	sp.prefixMu.Lock()
+	defer sp.prefixMu.Unlock()
+	prefixes := maps.Keys(sp.extraPrefixes)
+	slices.Sort(prefixes)
+	return prefixes
+}
+
+// Метод prefixNextHops возвращает заданные next-hop дополнительных префиксов.
+func (sp *Speaker) prefixNextHops() map[string]string {
+	sp.prefixMu.Lock()
+	defer sp.prefixMu.Unlock()
+	nextHops := map[string]string{}
+	for ip, nextHop := range sp.extraPrefixes {
+		if nextHop != "" {
+			nextHops[ip] = nextHop
+		}
+	}
+	return nextHops
+}
+
+func (sp *Speaker) hostPrefixSet(ip string) *api.DefinedSet {
+	return &api.DefinedSet{
+		DefinedType: api.DefinedType_PREFIX,
+		Name:        anycastIP,
+		Prefixes: []*api.Prefix{
+			{
+				IpPrefix:      fmt.Sprintf("%s/32", ip),
+				MaskLengthMin: 32,
+				MaskLengthMax: 32,
+			},
+		},
+	}
+}
+
+// Функция parseHostPrefix принимает адрес или префикс /32 и возвращает адрес.
+func parseHostPrefix(prefix string) (string, error) {
+	if !strings.Contains(prefix, "/") {
+		prefix += "/32"
+	}
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix: %w", err)
+	}
+	if !p.Addr().Is4() || p.Bits() != 32 {
+		return "", fmt.Errorf("only ipv4 /32 prefixes are supported: %s", prefix)
+	}
+	return p.Addr().String(), nil
+}
+
+func (sp *Speaker) handleListRoutes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, RoutesResponse{Prefixes: sp.AdvertisedPrefixes(), NextHops: sp.prefixNextHops(), Held: sp.HeldPrefixes()})
+}
+
+func (sp *Speaker) handleAdvertiseRoute(w http.ResponseWriter, r *http.Request) {
+	sp.handleRoute(w, r, sp.AdvertisePrefixVia)
+}
+
+func (sp *Speaker) handleWithdrawRoute(w http.ResponseWriter, r *http.Request) {
+	sp.handleRoute(w, r, func(ctx context.Context, ip, _ string) error {
+		return sp.WithdrawPrefix(ctx, ip)
+	})
+}
+
+func (sp *Speaker) handleRoute(w http.ResponseWriter, r *http.Request, apply func(context.Context, string, string) error) {
+	var req RouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	ip, err := parseHostPrefix(req.Prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ip == sp.config.AnycastIP {
+		http.Error(w, "anycast ip is managed by health check, use maintenance instead", http.StatusBadRequest)
+		return
+	}
+	if sp.config.managedPrefix(ip) {
+		http.Error(w, "prefix is managed by its health_checks, use maintenance instead", http.StatusBadRequest)
+		return
+	}
+	if err := apply(withAuditCause(r.Context(), auditCauseAdminAPI, ""), ip, req.NextHop); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sp.handleListRoutes(w, r)
+}
@@ -0,0 +1,78 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// Readiness это ответ /readyz, каждое поле соответствует одной проверке.
+type Readiness struct {
+	Ready          bool   `json:"ready"`
+	BGPEstablished bool   `json:"bgp_established"`
+	Advertised     bool   `json:"advertised"`
+	FIBProgrammed  *bool  `json:"fib_programmed,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ServeProbes отдает /healthz и /readyz на probe_address.
+func (sp *Speaker) ServeProbes(ctx context.Context) error {
+	mux := http.NewServeMux()
+	sp.registerProbes(mux)
+	sp.logger.Info("starting probes", log.Fields{"address": sp.config.ProbeAddress})
+	if err := serveHTTP(ctx, sp.config.ProbeAddress, mux); err != nil {
+		return fmt.Errorf("probes: %w", err)
+	}
+	return nil
+}
+
+func (sp *Speaker) registerProbes(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+healthzPath, sp.handleHealthz)
+	mux.HandleFunc("GET "+readyzPath, sp.handleReadyz)
+}
+
+// Метод handleHealthz отвечает 200, пока процесс жив и обслуживает запросы.
+func (sp *Speaker) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// Метод handleReadyz отвечает 200, если хотя бы одна BGP-сессия установлена, anycast ip анонсирован
+// (кроме режима advertise: false) и, если speaker управляет FIB, маршрут по-умолчанию установлен в ядро. Иначе отвечает 503.
+func (sp *Speaker) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := sp.readiness(r.Context())
+	code := http.StatusOK
+	if !readiness.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, readiness)
+}
+
+func (sp *Speaker) readiness(ctx context.Context) Readiness {
+	readiness := Readiness{
+		Advertised: sp.advertised.Load(),
+	}
+	err := sp.s.ListPeer(ctx, &api.ListPeerRequest{}, func(p *api.Peer) {
+		if p.State != nil && p.State.SessionState == api.PeerState_ESTABLISHED {
+			readiness.BGPEstablished = true
+		}
+	})
+	if err != nil {
+		readiness.Error = err.Error()
+	}
+	readiness.Ready = err == nil && readiness.BGPEstablished && (readiness.Advertised || !sp.config.advertiseEnabled())
+	if _, ok := sp.netlinkFIBMetric(); ok {
+		programmed := sp.fibProgrammed.Load()
+		readiness.FIBProgrammed = &programmed
+		readiness.Ready = readiness.Ready && programmed
+	}
+	return readiness
+}
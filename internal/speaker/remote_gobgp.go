@@ -0,0 +1,43 @@
+package speaker
+
+import (
+	"fmt"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	bgpserver "github.com/sir-sukhov/bgp-speaker/internal/bgp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// RemoteGoBGP задает уже запущенный gobgpd, которым speaker управляет через gRPC API
+// вместо встроенного BGP сервера: соседи, политики и анонсы создаются в нем, а при остановке
+// speaker удаляет их, не останавливая gobgpd, см. [bgpserver.Remote].
+//
+// По-умолчанию speaker не ставит маршруты в ядро в этом режиме, так как маршрутизатор
+// не на этом хосте; включить можно через manage_fib: true.
+type RemoteGoBGP struct {
+	// Address это адрес gRPC API gobgpd в формате host:port.
+	Address string `yaml:"address"`
+	// TLS включает TLS, без него соединение не шифруется.
+	TLS *HealthCheckTLS `yaml:"tls"`
+}
+
+// Метод dialRemoteGoBGP подключается к gobgpd из remote_gobgp.
+func (sp *Speaker) dialRemoteGoBGP() (*bgpserver.Remote, error) {
+	remote := sp.config.RemoteGoBGP
+	creds := insecure.NewCredentials()
+	if remote.TLS != nil {
+		tlsConfig, err := remote.TLS.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("remote gobgp tls: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.Dial(remote.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("remote gobgp dial failed: %w", err)
+	}
+	sp.logger.Info("managing remote gobgpd", log.Fields{"address": remote.Address})
+	return bgpserver.NewRemote(conn), nil
+}
@@ -0,0 +1,26 @@
+package speaker
+
+import (
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+// RemovePrivateAS задает, что делать с приватными ASN в AS_PATH анонсов соседу:
+//   - all: удалить все приватные ASN
+//   - replace: заменить приватные ASN на собственный ASN
+type RemovePrivateAS string
+
+const (
+	RemovePrivateASAll     RemovePrivateAS = "all"
+	RemovePrivateASReplace RemovePrivateAS = "replace"
+)
+
+func (r RemovePrivateAS) api() api.RemovePrivate {
+	switch r {
+	case RemovePrivateASAll:
+		return api.RemovePrivate_REMOVE_ALL
+	case RemovePrivateASReplace:
+		return api.RemovePrivate_REPLACE
+	default:
+		return api.RemovePrivate_REMOVE_NONE
+	}
+}
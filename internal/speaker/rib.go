@@ -0,0 +1,35 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+// Метод findDestinations обходит RIB gobgp и возвращает destination, для которых match вернул true.
+//
+// gobgp вызывает callback для каждого destination по очереди и проверяет ctx между вызовами,
+// поэтому обход прерывается, как только найдено limit destination (если limit больше нуля)
+// или отменен ctx. В последнем случае возвращается ошибка ctx.
+func (sp *Speaker) findDestinations(ctx context.Context, req *api.ListPathRequest, match func(*api.Destination) bool, limit int) ([]*api.Destination, error) {
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := []*api.Destination{}
+	err := sp.s.ListPath(listCtx, req, func(d *api.Destination) {
+		if listCtx.Err() != nil || !match(d) {
+			return
+		}
+		found = append(found, d)
+		if limit > 0 && len(found) >= limit {
+			cancel()
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bgp list path error: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("bgp list path interrupted: %w", err)
+	}
+	return found, nil
+}
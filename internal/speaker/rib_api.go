@@ -0,0 +1,146 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+)
+
+const (
+	ribPath      = "/rib"
+	familyIPv4   = "ipv4"
+	familyIPv6   = "ipv6"
+	paramFamily  = "family"
+	paramPrefix  = "prefix"
+	paramLonger  = "longer"
+	boolParamYes = "true"
+)
+
+// RIBDestination это префикс глобальной RIB со всеми путями к нему.
+type RIBDestination struct {
+	Prefix string    `json:"prefix"`
+	Paths  []RIBPath `json:"paths"`
+}
+
+// RIBPath это путь из глобальной RIB с основными атрибутами.
+type RIBPath struct {
+	Best        bool     `json:"best"`
+	NextHop     string   `json:"next_hop"`
+	ASPath      []uint32 `json:"as_path"`
+	Communities []string `json:"communities,omitempty"`
+	Neighbor    string   `json:"neighbor,omitempty"`
+	AgeSeconds  int64    `json:"age_seconds"`
+}
+
+// RIBQuery задает фильтр для дампа RIB: семейство (ipv4 или ipv6) и, опционально,
+// префикс, которому должны совпадать destination или, если Longer, которые он покрывает.
+type RIBQuery struct {
+	Family string
+	Prefix string
+	Longer bool
+}
+
+func (q RIBQuery) values() url.Values {
+	v := url.Values{}
+	if q.Family != "" {
+		v.Set(paramFamily, q.Family)
+	}
+	if q.Prefix != "" {
+		v.Set(paramPrefix, q.Prefix)
+	}
+	if q.Longer {
+		v.Set(paramLonger, boolParamYes)
+	}
+	return v
+}
+
+func (sp *Speaker) listRIB(ctx context.Context, q RIBQuery) ([]RIBDestination, error) {
+	req := &api.ListPathRequest{TableType: api.TableType_GLOBAL}
+	switch q.Family {
+	case "", familyIPv4:
+		req.Family = &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST}
+	case familyIPv6:
+		req.Family = &api.Family{Afi: api.Family_AFI_IP6, Safi: api.Family_SAFI_UNICAST}
+	default:
+		return nil, fmt.Errorf("unknown family %q, expected %s or %s", q.Family, familyIPv4, familyIPv6)
+	}
+	if q.Prefix != "" {
+		lookup := api.TableLookupPrefix_EXACT
+		if q.Longer {
+			lookup = api.TableLookupPrefix_LONGER
+		}
+		req.Prefixes = []*api.TableLookupPrefix{{Prefix: q.Prefix, Type: lookup}}
+	}
+	destinations, err := sp.findDestinations(ctx, req, func(*api.Destination) bool { return true }, 0)
+	if err != nil {
+		return nil, err
+	}
+	rib := make([]RIBDestination, 0, len(destinations))
+	for _, d := range destinations {
+		dst := RIBDestination{Prefix: d.Prefix, Paths: make([]RIBPath, 0, len(d.Paths))}
+		for _, p := range d.Paths {
+			path, err := newRIBPath(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", d.Prefix, err)
+			}
+			dst.Paths = append(dst.Paths, path)
+		}
+		rib = append(rib, dst)
+	}
+	return rib, nil
+}
+
+func newRIBPath(p *api.Path) (RIBPath, error) {
+	path := RIBPath{
+		Best:   p.Best,
+		ASPath: []uint32{},
+	}
+	// Для локальных путей gobgp отдает neighbor_ip "<nil>".
+	if p.NeighborIp != "" && p.NeighborIp != "<nil>" {
+		path.Neighbor = p.NeighborIp
+	}
+	if p.Age != nil {
+		path.AgeSeconds = int64(time.Since(p.Age.AsTime()).Seconds())
+	}
+	for _, attr := range p.Pattrs {
+		m, err := attr.UnmarshalNew()
+		if err != nil {
+			return path, err
+		}
+		switch a := m.(type) {
+		case *api.NextHopAttribute:
+			path.NextHop = a.NextHop
+		case *api.MpReachNLRIAttribute:
+			if len(a.NextHops) > 0 {
+				path.NextHop = a.NextHops[0]
+			}
+		case *api.AsPathAttribute:
+			for _, segment := range a.Segments {
+				path.ASPath = append(path.ASPath, segment.Numbers...)
+			}
+		case *api.CommunitiesAttribute:
+			path.Communities = formatCommunities(a.Communities)
+		}
+	}
+	return path, nil
+}
+
+func (sp *Speaker) handleRIB(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	longer, _ := strconv.ParseBool(query.Get(paramLonger))
+	rib, err := sp.listRIB(r.Context(), RIBQuery{
+		Family: query.Get(paramFamily),
+		Prefix: query.Get(paramPrefix),
+		Longer: longer,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, rib)
+}
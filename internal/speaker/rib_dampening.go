@@ -0,0 +1,115 @@
+package speaker
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	defaultDampeningHalfLifeSeconds = 900
+	defaultDampeningPenalty         = 1000
+	defaultDampeningSuppress        = 2000
+	defaultDampeningReuse           = 750
+)
+
+// RouteFlapDampening задает подавление нестабильных next-hop маршрута по-умолчанию по мотивам [RFC 2439]:
+// каждое исчезновение next-hop из RIB добавляет ему Penalty, штраф экспоненциально убывает с периодом
+// полураспада HalfLifeSeconds, next-hop со штрафом не меньше Suppress не ставится в FIB, пока штраф
+// не опустится до Reuse. Нулевые поля заменяются значениями по-умолчанию из RFC 2439.
+//
+// [RFC 2439]: https://www.rfc-editor.org/rfc/rfc2439
+type RouteFlapDampening struct {
+	HalfLifeSeconds uint32 `yaml:"half_life_seconds"`
+	Penalty         uint32 `yaml:"penalty"`
+	Suppress        uint32 `yaml:"suppress"`
+	Reuse           uint32 `yaml:"reuse"`
+}
+
+func (d *RouteFlapDampening) applyDefaults() {
+	if d.HalfLifeSeconds == 0 {
+		d.HalfLifeSeconds = defaultDampeningHalfLifeSeconds
+	}
+	if d.Penalty == 0 {
+		d.Penalty = defaultDampeningPenalty
+	}
+	if d.Suppress == 0 {
+		d.Suppress = defaultDampeningSuppress
+	}
+	if d.Reuse == 0 {
+		d.Reuse = defaultDampeningReuse
+	}
+}
+
+func (d *RouteFlapDampening) validate() error {
+	if d.Reuse >= d.Suppress {
+		return errors.New("route_flap_dampening.reuse: must be less than suppress")
+	}
+	return nil
+}
+
+// nextHopDampening это штраф next-hop на момент updated.
+type nextHopDampening struct {
+	penalty    float64
+	updated    time.Time
+	suppressed bool
+}
+
+// Метод dampenPaths начисляет штраф next-hop, которые пропали из RIB с прошлого обновления FIB,
+// и возвращает paths без подавленных next-hop. Если подавлены все next-hop, возвращаются все paths,
+// чтобы не оставить хост без маршрута по-умолчанию. Вызывается только из UpdateFIB.
+func (sp *Speaker) dampenPaths(paths []*api.Path) []*api.Path {
+	conf := sp.config.RouteFlapDampening
+	if conf == nil {
+		return paths
+	}
+	if sp.nextHopDampening == nil {
+		sp.nextHopDampening = map[string]*nextHopDampening{}
+	}
+	now := time.Now()
+	halfLife := time.Second * time.Duration(conf.HalfLifeSeconds)
+	current := map[string]bool{}
+	for _, path := range paths {
+		if gw, err := nextHop(path); err == nil {
+			current[gw] = true
+		}
+	}
+	for gw, d := range sp.nextHopDampening {
+		d.penalty *= math.Pow(0.5, float64(now.Sub(d.updated))/float64(halfLife))
+		d.updated = now
+		if !current[gw] && sp.fibNextHops[gw] {
+			d.penalty += float64(conf.Penalty)
+		}
+		switch {
+		case !d.suppressed && d.penalty >= float64(conf.Suppress):
+			d.suppressed = true
+			sp.logger.Warn("next-hop of default route is flapping, suppressing it", log.Fields{"next_hop": gw, "penalty": int(d.penalty)})
+		case d.suppressed && d.penalty <= float64(conf.Reuse):
+			d.suppressed = false
+			sp.logger.Info("next-hop of default route is stable, reusing it", log.Fields{"next_hop": gw})
+		}
+		if !d.suppressed && d.penalty < 1 {
+			delete(sp.nextHopDampening, gw)
+		}
+	}
+	for gw := range sp.fibNextHops {
+		if !current[gw] && sp.nextHopDampening[gw] == nil {
+			sp.nextHopDampening[gw] = &nextHopDampening{penalty: float64(conf.Penalty), updated: now}
+		}
+	}
+	sp.fibNextHops = current
+	dampened := []*api.Path{}
+	for _, path := range paths {
+		gw, _ := nextHop(path)
+		if d := sp.nextHopDampening[gw]; d == nil || !d.suppressed {
+			dampened = append(dampened, path)
+		}
+	}
+	if len(dampened) == 0 {
+		return paths
+	}
+	return dampened
+}
@@ -0,0 +1,36 @@
+package speaker
+
+import (
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+// Метод holdDefaultRoute вызывается, когда в RIB нет ни одного default route.
+//
+// Маршрут в ядре удаляется только через fib_hold_seconds секунд после пропажи последнего
+// default route, чтобы кратковременный отзыв от всех соседей (например, одновременная
+// переконвергенция обоих ToR) не оставлял хост без связности. Если fib_hold_seconds не задан,
+// маршрут в ядре сохраняется до остановки speaker. Маршрут, оставленный предыдущим процессом
+// с preserve_fib_on_exit, удаляется по истечении grace_seconds, см. [Speaker.holdPreservedRoute].
+func (sp *Speaker) holdDefaultRoute() error {
+	if preserved, err := sp.holdPreservedRoute(); preserved {
+		return err
+	}
+	if sp.config.FIBHoldSeconds == nil {
+		return nil
+	}
+	if sp.defaultRouteLostAt.IsZero() {
+		sp.defaultRouteLostAt = time.Now()
+		sp.logger.Warn("default route disappeared from rib, holding kernel route", log.Fields{"hold_seconds": *sp.config.FIBHoldSeconds})
+	}
+	if time.Since(sp.defaultRouteLostAt) < time.Second*time.Duration(*sp.config.FIBHoldSeconds) {
+		return nil
+	}
+	oldDefaultRoute, err := sp.getLinuxBGPDefaultRoute()
+	if err != nil || oldDefaultRoute == nil {
+		return err
+	}
+	sp.logger.Warn("hold timer expired, removing kernel default route", nil)
+	return sp.cleanupDefaultRoute()
+}
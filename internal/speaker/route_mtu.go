@@ -0,0 +1,65 @@
+package speaker
+
+import (
+	"fmt"
+
+	"github.com/jsimonetti/rtnetlink"
+)
+
+// Метод routeMTU вычисляет MTU для маршрута по-умолчанию в соответствии с опцией fib_mtu.
+//
+// В Linux метрики задаются на весь маршрут, а не на отдельный next-hop, поэтому
+// для multipath маршрута в режиме "auto" берется минимальный MTU среди интерфейсов
+// всех next-hop, чтобы не получить PMTU blackhole на uplink с меньшим MTU.
+// Ноль означает, что MTU на маршруте не выставляется.
+func (sp *Speaker) routeMTU(nextHops []rtnetlink.NextHop) (uint32, error) {
+	if sp.config.FIBMTU == nil {
+		return 0, nil
+	}
+	if !sp.config.FIBMTU.Auto {
+		return sp.config.FIBMTU.Value, nil
+	}
+	var mtu uint32
+	for _, nh := range nextHops {
+		ifMTU, err := sp.nextHopInterfaceMTU(nh)
+		if err != nil {
+			return 0, fmt.Errorf("failed to determine mtu for gateway %s: %w", nh.Gateway, err)
+		}
+		if mtu == 0 || ifMTU < mtu {
+			mtu = ifMTU
+		}
+	}
+	return mtu, nil
+}
+
+// Метод nextHopInterfaceMTU возвращает MTU интерфейса next-hop: заданного для onlink next-hop
+// или найденного так же, как "ip route get".
+func (sp *Speaker) nextHopInterfaceMTU(nh rtnetlink.NextHop) (uint32, error) {
+	index := nh.Hop.IfIndex
+	if index == 0 {
+		route, err := sp.lookupRoute(nh.Gateway)
+		if err != nil {
+			return 0, err
+		}
+		index = route.Attributes.OutIface
+	}
+	mtu, err := sp.links.MTU(index)
+	if err != nil {
+		return 0, fmt.Errorf("link lookup failed: %w", err)
+	}
+	return mtu, nil
+}
+
+func routeMetrics(mtu uint32) *rtnetlink.RouteMetrics {
+	if mtu == 0 {
+		return nil
+	}
+	return &rtnetlink.RouteMetrics{MTU: mtu}
+}
+
+func linuxRouteMTU(route *rtnetlink.RouteMessage) uint32 {
+	if route.Attributes.Metrics == nil {
+		return 0
+	}
+	return route.Attributes.Metrics.MTU
+}
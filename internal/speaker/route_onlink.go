@@ -0,0 +1,87 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	api "github.com/osrg/gobgp/v3/api"
+	"golang.org/x/sys/unix"
+)
+
+// rtnhFOnlink это флаг RTNH_F_ONLINK: ядро не проверяет, что next-hop находится
+// в подключенной подсети, а отправляет пакеты на него через указанный интерфейс.
+const rtnhFOnlink = 0x4
+
+// Метод onlinkHop возвращает флаги и интерфейс next-hop, полученного в path. Если у соседа,
+// от которого получен path, задан onlink, next-hop ставится с флагом RTNH_F_ONLINK через
+// bind_interface соседа или через интерфейс маршрута до адреса соседа, как требуют unnumbered
+// фабрики, где next-hop не входит ни в одну подсеть хоста. Иначе интерфейс выбирает ядро.
+func (sp *Speaker) onlinkHop(path *api.Path) (rtnetlink.RTNextHop, error) {
+	n := sp.pathNeighbor(path)
+	if n == nil || !n.Onlink {
+		return rtnetlink.RTNextHop{}, nil
+	}
+	if n.BindInterface != "" {
+		index, ok := sp.links.Index(n.BindInterface)
+		if !ok {
+			return rtnetlink.RTNextHop{}, fmt.Errorf("onlink: interface %s of neighbor %s not found", n.BindInterface, n.Address)
+		}
+		return rtnetlink.RTNextHop{Flags: rtnhFOnlink, IfIndex: index}, nil
+	}
+	route, err := sp.lookupRoute(net.ParseIP(n.Address))
+	if err != nil {
+		return rtnetlink.RTNextHop{}, fmt.Errorf("onlink: failed to resolve interface of neighbor %s: %w", n.Address, err)
+	}
+	return rtnetlink.RTNextHop{Flags: rtnhFOnlink, IfIndex: route.Attributes.OutIface}, nil
+}
+
+// Метод lookupRoute возвращает маршрут, которым ядро отправит пакет на dst (аналог "ip route get"),
+// с vrf поиск выполняется в таблице VRF.
+func (sp *Speaker) lookupRoute(dst net.IP) (*rtnetlink.RouteMessage, error) {
+	return sp.getRoute(dst, 0)
+}
+
+// Метод lookupFIBMatch, в отличие от lookupRoute, возвращает саму запись FIB, которая выбрана
+// для dst, с ее префиксом и протоколом (аналог "ip route get fibmatch").
+func (sp *Speaker) lookupFIBMatch(dst net.IP) (*rtnetlink.RouteMessage, error) {
+	return sp.getRoute(dst, unix.RTM_F_FIB_MATCH)
+}
+
+func (sp *Speaker) getRoute(dst net.IP, flags uint32) (*rtnetlink.RouteMessage, error) {
+	family, length := uint8(familyAfInet), uint8(32)
+	if dst.To4() == nil {
+		family, length = unix.AF_INET6, 128
+	}
+	msgs, err := sp.conn.Execute(&rtnetlink.RouteMessage{
+		Family:    family,
+		DstLength: length,
+		Flags:     flags,
+		Attributes: rtnetlink.RouteAttributes{
+			Dst:      dst,
+			OutIface: sp.vrfIndex,
+		},
+	}, getRoute, netlink.Request)
+	if err != nil {
+		return nil, fmt.Errorf("route lookup failed: %w", err)
+	}
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("unexpected number of routes to %s: %w", dst, errors.ErrUnsupported)
+	}
+	route, ok := msgs[0].(*rtnetlink.RouteMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected rtnetlink message: %w", errors.ErrUnsupported)
+	}
+	return route, nil
+}
+
+// Функция onlinkHopEqual сравнивает флаг RTNH_F_ONLINK и интерфейс next-hop в ядре с нужными.
+// Без onlink интерфейс выбирает ядро, поэтому он не сравнивается.
+func onlinkHopEqual(old, hop rtnetlink.RTNextHop) bool {
+	if hop.Flags&rtnhFOnlink == 0 {
+		return old.Flags&rtnhFOnlink == 0
+	}
+	return old.Flags&rtnhFOnlink != 0 && old.IfIndex == hop.IfIndex
+}
@@ -0,0 +1,22 @@
+package speaker
+
+import (
+	"net"
+
+	"github.com/jsimonetti/rtnetlink"
+)
+
+// Метод routeSrc возвращает RTA_PREFSRC для маршрута по-умолчанию из опции fib_src,
+// чтобы исходящий трафик уходил с anycast или loopback адреса, а не с адреса uplink.
+// Адрес должен быть назначен на интерфейс хоста, иначе ядро не примет маршрут.
+// nil означает, что адрес источника выбирает ядро.
+func (sp *Speaker) routeSrc() net.IP {
+	if sp.config.FIBSrc == "" {
+		return nil
+	}
+	return net.ParseIP(sp.config.FIBSrc).To4()
+}
+
+func linuxRouteSrc(route *rtnetlink.RouteMessage) net.IP {
+	return route.Attributes.Src
+}
@@ -0,0 +1,147 @@
+package speaker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	"golang.org/x/sys/unix"
+)
+
+// rtaVia это RTA_VIA: next-hop другого семейства, чем маршрут (ядро 5.2 и новее).
+const rtaVia = 18
+
+// Метод viaHop задает интерфейс next-hop gateway, полученного по [RFC 8950] (extended next-hop):
+// link-local адрес IPv6 имеет смысл только вместе с интерфейсом, поэтому берется bind_interface
+// соседа, от которого получен path, если интерфейс не задан через onlink.
+//
+// [RFC 8950]: https://www.rfc-editor.org/rfc/rfc8950
+func (sp *Speaker) viaHop(n *Neighbor, gateway net.IP, hop rtnetlink.RTNextHop) (rtnetlink.RTNextHop, error) {
+	if !gateway.IsLinkLocalUnicast() || hop.IfIndex != 0 {
+		return hop, nil
+	}
+	if n == nil || sp.neighborBindInterface(*n) == "" {
+		return hop, fmt.Errorf("link-local next-hop %s requires bind_interface of neighbor", gateway)
+	}
+	index, ok := sp.links.Index(sp.neighborBindInterface(*n))
+	if !ok {
+		return hop, fmt.Errorf("interface %s of neighbor %s not found", sp.neighborBindInterface(*n), n.Address)
+	}
+	hop.IfIndex = index
+	return hop, nil
+}
+
+// Метод setViaRoute ставит маршрут по-умолчанию IPv4 через next-hop IPv6 (RFC 5549/8950).
+// Такой next-hop передается в RTA_VIA, а не в RTA_GATEWAY, и rtnetlink не умеет ни кодировать,
+// ни разбирать RTA_VIA, поэтому сообщение дописывается вручную, а маршрут в ядре сравнивается
+// не с next-hop из ядра, а с тем, что speaker поставил в прошлый раз. nexthop группы для таких
+// маршрутов не используются.
+func (sp *Speaker) setViaRoute(nextHops []rtnetlink.NextHop, metric, mtu uint32, oldDefaultRoute *rtnetlink.RouteMessage) error {
+	key := sp.viaRouteKey(nextHops, metric, mtu)
+	if oldDefaultRoute != nil && oldDefaultRoute.Attributes.Priority == metric && sp.viaRoute == key {
+		return nil
+	}
+	routeMessage := &rtnetlink.RouteMessage{
+		Family:   familyAfInet,
+		Protocol: protoBgp,
+		Type:     typeUnicast,
+		Attributes: rtnetlink.RouteAttributes{
+			Src:      sp.routeSrc(),
+			Priority: metric,
+			Metrics:  routeMetrics(mtu),
+		},
+	}
+	if len(nextHops) == 1 {
+		routeMessage.Flags = uint32(nextHops[0].Hop.Flags)
+		routeMessage.Attributes.OutIface = nextHops[0].Hop.IfIndex
+	}
+	sp.setRouteTable(routeMessage)
+	data, err := routeMessage.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	ae := netlink.NewAttributeEncoder()
+	if len(nextHops) == 1 {
+		ae.Bytes(rtaVia, encodeVia(nextHops[0].Gateway))
+	} else {
+		multipath, err := encodeViaMultipath(nextHops)
+		if err != nil {
+			return err
+		}
+		ae.Bytes(unix.RTA_MULTIPATH, multipath)
+	}
+	attrs, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+	conn, err := sp.rawConn()
+	if err != nil {
+		return err
+	}
+	sp.logger.Info("setting linux default route via ipv6 next-hop", log.Fields{"route": key})
+	sp.fibWrites++
+	if err := sp.executeFIB(func() error {
+		_, err := conn.Execute(netlink.Message{
+			Header: netlink.Header{Type: newRoute, Flags: replaceFlags},
+			Data:   append(data, attrs...),
+		})
+		return err
+	}); err != nil {
+		return err
+	}
+	sp.viaRoute = key
+	if oldDefaultRoute != nil && oldDefaultRoute.Attributes.Priority != metric {
+		if err := sp.deleteDefaultRoute(oldDefaultRoute.Attributes.Priority); err != nil {
+			return err
+		}
+	}
+	return sp.cleanupNexthopGroup()
+}
+
+func (sp *Speaker) viaRouteKey(nextHops []rtnetlink.NextHop, metric, mtu uint32) string {
+	hops := []string{}
+	for _, nh := range nextHops {
+		hops = append(hops, fmt.Sprintf("%s dev %d flags %d weight %d", nh.Gateway, nh.Hop.IfIndex, nh.Hop.Flags, int(nh.Hop.Hops)+1))
+	}
+	slices.Sort(hops)
+	return fmt.Sprintf("%s metric %d mtu %d src %s table %d", strings.Join(hops, ", "), metric, mtu, sp.routeSrc(), sp.table())
+}
+
+// Функция encodeVia кодирует struct rtvia: семейство и адрес next-hop.
+func encodeVia(gateway net.IP) []byte {
+	via := make([]byte, 2, 2+net.IPv6len)
+	binary.NativeEndian.PutUint16(via, unix.AF_INET6)
+	return append(via, gateway.To16()...)
+}
+
+// Функция encodeViaMultipath кодирует RTA_MULTIPATH: struct rtnexthop с вложенным RTA_VIA на каждый
+// next-hop IPv6. Next-hop IPv4 в том же маршруте передаются как обычно в RTA_GATEWAY.
+func encodeViaMultipath(nextHops []rtnetlink.NextHop) ([]byte, error) {
+	var b []byte
+	for _, nh := range nextHops {
+		ae := netlink.NewAttributeEncoder()
+		if ip4 := nh.Gateway.To4(); ip4 != nil {
+			ae.Bytes(unix.RTA_GATEWAY, ip4)
+		} else {
+			ae.Bytes(rtaVia, encodeVia(nh.Gateway))
+		}
+		attrs, err := ae.Encode()
+		if err != nil {
+			return nil, err
+		}
+		// struct rtnexthop: len, flags, hops, ifindex.
+		hop := make([]byte, 8)
+		binary.NativeEndian.PutUint16(hop[0:2], uint16(len(hop)+len(attrs)))
+		hop[2] = nh.Hop.Flags
+		hop[3] = nh.Hop.Hops
+		binary.NativeEndian.PutUint32(hop[4:8], nh.Hop.IfIndex)
+		b = append(b, hop...)
+		b = append(b, attrs...)
+	}
+	return b, nil
+}
@@ -0,0 +1,87 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	rejectRPKIInvalidPolicy = "reject-rpki-invalid"
+	defaultRPKIPort         = "323"
+	// rpkiResultInvalid это значение RPKI_VALIDATION_RESULT_TYPE_INVALID в условии политики gobgp.
+	rpkiResultInvalid = 3
+)
+
+// RPKI задает RTR кэши ([RFC 8210]), по ROA из которых gobgp проверяет полученные префиксы.
+// Префиксы со статусом invalid отбрасываются политикой импорта и не попадают в RIB и FIB,
+// префиксы со статусом not-found принимаются как раньше.
+//
+// [RFC 8210]: https://www.rfc-editor.org/rfc/rfc8210
+type RPKI struct {
+	// Caches это адреса RTR кэшей в формате host:port, порт по-умолчанию 323.
+	Caches []string `yaml:"caches"`
+	// LifetimeSeconds это сколько секунд хранить ROA после потери связи с кэшем,
+	// по-умолчанию используется значение gobgp.
+	LifetimeSeconds int64 `yaml:"lifetime_seconds"`
+}
+
+// Функция splitRPKICache разбирает адрес RTR кэша, подставляя порт по-умолчанию.
+func splitRPKICache(cache string) (string, uint32, error) {
+	host, port, err := net.SplitHostPort(cache)
+	if err != nil {
+		host, port, err = net.SplitHostPort(net.JoinHostPort(cache, defaultRPKIPort))
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q: %w", port, err)
+	}
+	return host, uint32(p), nil
+}
+
+// Метод addRPKICaches подключает gobgp к RTR кэшам из конфигурации.
+func (sp *Speaker) addRPKICaches(ctx context.Context) error {
+	if sp.config.RPKI == nil {
+		return nil
+	}
+	for _, cache := range sp.config.RPKI.Caches {
+		host, port, err := splitRPKICache(cache)
+		if err != nil {
+			return fmt.Errorf("rpki cache %s: %w", cache, err)
+		}
+		sp.logger.Info("adding rpki cache", log.Fields{"address": host, "port": port})
+		if err := sp.s.AddRpki(ctx, &api.AddRpkiRequest{
+			Address:  host,
+			Port:     port,
+			Lifetime: sp.config.RPKI.LifetimeSeconds,
+		}); err != nil {
+			return fmt.Errorf("error adding rpki cache %s: %w", cache, err)
+		}
+	}
+	return nil
+}
+
+// Метод createRejectRPKIInvalidPolicy создает политику импорта, отбрасывающую префиксы с невалидными ROA.
+func (sp *Speaker) createRejectRPKIInvalidPolicy() *api.Policy {
+	return &api.Policy{
+		Name: rejectRPKIInvalidPolicy,
+		Statements: []*api.Statement{
+			{
+				Name: "reject-rpki-invalid",
+				Conditions: &api.Conditions{
+					RpkiResult: rpkiResultInvalid,
+				},
+				Actions: &api.Actions{
+					RouteAction: api.RouteAction_REJECT,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package speaker
+
+import (
+	"context"
+	"slices"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+// Метод degradePath вызывается вместо deletePath, если задан soft_fail: anycast ip остается
+// в анонсе, но с дополнительным prepend и communities, чтобы площадка стала резервной.
+func (sp *Speaker) degradePath(ctx context.Context) error {
+	communities := slices.Clone(sp.hookCommunities)
+	for _, c := range sp.config.SoftFail.Communities {
+		communities = append(communities, uint32(c))
+	}
+	path, err := sp.anycastPath(pathAttrs{
+		communities: communities,
+		prepend:     sp.config.SoftFail.Prepend,
+	})
+	if err != nil {
+		return err
+	}
+	sp.logger.Warn("degradePath", log.Fields{
+		"anycast_ip":  sp.config.AnycastIP,
+		"prepend":     sp.config.SoftFail.Prepend,
+		"communities": sp.config.SoftFail.Communities,
+	})
+	if err := sp.announce(ctx, path); err != nil {
+		return err
+	}
+	sp.degraded.Store(true)
+	sp.audit(ctx, auditActionDegrade, sp.config.AnycastIP, "")
+	return nil
+}
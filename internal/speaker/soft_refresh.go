@@ -0,0 +1,85 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	softRefreshPath = "/peers/soft-refresh"
+	directionIn     = "in"
+	directionOut    = "out"
+	directionBoth   = "both"
+)
+
+// SoftRefreshRequest это тело POST /peers/soft-refresh.
+type SoftRefreshRequest struct {
+	// Neighbor это адрес соседа, если не задан, обновляются все соседи.
+	Neighbor string `json:"neighbor,omitempty"`
+	// Direction это in, out или both, по-умолчанию in.
+	Direction string `json:"direction,omitempty"`
+}
+
+// SoftRefreshResponse это ответ POST /peers/soft-refresh.
+type SoftRefreshResponse struct {
+	Neighbors []string `json:"neighbors"`
+	Direction string   `json:"direction"`
+}
+
+// SoftRefresh применяет политики к маршрутам соседей заново, не разрывая сессии.
+//
+// Для направления in gobgp хранит принятые от соседа маршруты до применения политик
+// (adj-rib-in, soft reconfiguration inbound), поэтому они прогоняются через import политику
+// без запроса к соседу. Для направления out соседу заново отправляется adj-rib-out.
+func (sp *Speaker) SoftRefresh(ctx context.Context, req SoftRefreshRequest) (SoftRefreshResponse, error) {
+	direction := req.Direction
+	if direction == "" {
+		direction = directionIn
+	}
+	var apiDirection api.ResetPeerRequest_SoftResetDirection
+	switch direction {
+	case directionIn:
+		apiDirection = api.ResetPeerRequest_IN
+	case directionOut:
+		apiDirection = api.ResetPeerRequest_OUT
+	case directionBoth:
+		apiDirection = api.ResetPeerRequest_BOTH
+	default:
+		return SoftRefreshResponse{}, fmt.Errorf("unknown direction %q, expected %s, %s or %s", direction, directionIn, directionOut, directionBoth)
+	}
+	neighbors := []string{}
+	for _, n := range sp.neighbors() {
+		if req.Neighbor == "" || req.Neighbor == n.Address {
+			neighbors = append(neighbors, n.Address)
+		}
+	}
+	if len(neighbors) == 0 {
+		return SoftRefreshResponse{}, fmt.Errorf("neighbor %s is not configured", req.Neighbor)
+	}
+	for _, address := range neighbors {
+		sp.logger.Info("soft refreshing neighbor", log.Fields{"neighbor": address, "direction": direction})
+		if err := sp.s.ResetPeer(ctx, &api.ResetPeerRequest{Address: address, Soft: true, Direction: apiDirection}); err != nil {
+			return SoftRefreshResponse{}, fmt.Errorf("soft refresh of %s failed: %w", address, err)
+		}
+	}
+	return SoftRefreshResponse{Neighbors: neighbors, Direction: direction}, nil
+}
+
+func (sp *Speaker) handleSoftRefresh(w http.ResponseWriter, r *http.Request) {
+	var req SoftRefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	resp, err := sp.SoftRefresh(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
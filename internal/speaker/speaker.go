@@ -2,20 +2,26 @@ package speaker
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"net"
 	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
 	api "github.com/osrg/gobgp/v3/api"
 	"github.com/osrg/gobgp/v3/pkg/log"
 	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
 	"github.com/osrg/gobgp/v3/pkg/server"
+	bgpserver "github.com/sir-sukhov/bgp-speaker/internal/bgp"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/types/known/anypb"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -23,9 +29,12 @@ const (
 	uplinks            = "uplinks"
 	defaultRoutePolicy = "only-default-route"
 	onlyAnycastIP      = "only-anycast-ip"
+	onlyLocalAnycastIP = "only-local-anycast-ip"
 	anycastIP          = "anycast-ip"
 	global             = "global"
 	zeroPrefix         = "0.0.0.0/0"
+	defaultNextHop     = "0.0.0.0"
+	nextHopSelf        = "next-hop-self"
 )
 
 type Speaker struct {
@@ -33,9 +42,115 @@ type Speaker struct {
 	logLevel         LogLevel
 	logger           *Logger
 	config           Config
-	s                *server.BgpServer
+	s                bgpserver.Server
 	linuxRouteMetric uint32
 	conn             *rtnetlink.Conn
+	links            *nl.LinkCache
+	// fibTable и vrfIndex это таблица и интерфейс VRF из опции vrf, см. [Speaker.resolveVRF].
+	fibTable uint32
+	vrfIndex uint32
+	// anycastIfIndex это index интерфейса из anycast_interface.
+	anycastIfIndex uint32
+	// nhConn, nexthopObjects и nexthopRoute используются только в UpdateFIB для fib_nexthop_groups.
+	nhConn                   *netlink.Conn
+	nexthopObjects           map[string]nexthopObject
+	nexthopRoute             nexthopRoute
+	nexthopGroupsUnsupported bool
+	// viaRoute это маршрут по-умолчанию через next-hop IPv6, который speaker поставил в последний раз.
+	viaRoute string
+	// fibDesired это ключ состояния RIB при последней успешной установке маршрута по-умолчанию,
+	// fibWrites это счетчик записей маршрута в ядро, используются только в UpdateFIB, см. [Speaker.reconcileDefaultRoute].
+	fibDesired string
+	fibWrites  uint64
+	// fibNextHops это next-hop маршрута по-умолчанию в RIB на прошлом обновлении FIB,
+	// nextHopDampening это их штрафы, используются только в UpdateFIB, см. [Speaker.dampenPaths].
+	fibNextHops      map[string]bool
+	nextHopDampening map[string]*nextHopDampening
+	// unresolvedNextHops это next-hop, которые не прошли проверку next_hop_tracking,
+	// используется только в UpdateFIB, см. [Speaker.trackNextHops].
+	unresolvedNextHops map[string]bool
+	// fibLastOp это время последней записи в ядро для fib_ops_per_second,
+	// fibCtx это контекст UpdateFIB, отмена которого прерывает ожидание в executeFIB.
+	fibLastOp        time.Time
+	fibCtx           context.Context
+	fibDriftDetected atomic.Uint64
+	fibDriftRepaired atomic.Uint64
+	// preservedRouteDeadline это время, до которого держится маршрут, оставленный предыдущим процессом
+	// с preserve_fib_on_exit, используется только в UpdateFIB.
+	preservedRouteDeadline time.Time
+	// tookOver это состояние, полученное от старого процесса через handoff, задается в Start.
+	tookOver *handoffState
+	// defaultRouteLostAt это время пропажи default route из RIB, используется только в UpdateFIB.
+	defaultRouteLostAt time.Time
+	advertised         atomic.Bool
+	degraded           atomic.Bool
+	announcedPath      atomic.Pointer[api.Path]
+	fibProgrammed      atomic.Bool
+	announcedAt        atomic.Int64
+	verified           atomic.Pointer[bool]
+	// pathMu защищает healthy и maintenance и упорядочивает анонсы и отзывы anycast ip.
+	pathMu      sync.Mutex
+	healthy     bool
+	maintenance bool
+	// maintenanceWithdraw это отложенный отзыв anycast ip после GRACEFUL_SHUTDOWN в режиме обслуживания.
+	maintenanceWithdraw *time.Timer
+	hookAnnounce        bool
+	leader              bool
+	hookCommunities     []uint32
+	hooks               *hooks
+	healthCheck         *HealthCheck
+	// prefixChecks это статус проверок из health_checks по имени.
+	prefixChecks       map[string]bool
+	prefixHealthChecks map[string]*HealthCheck
+	// prefixMu защищает extraPrefixes, дополнительные анонсируемые префиксы /32 и их next-hop,
+	// и heldPrefixes, префиксы, не анонсируемые, пока prefixesHeld, см. [Speaker.holdPrefixes].
+	prefixMu      sync.Mutex
+	extraPrefixes map[string]string
+	heldPrefixes  map[string]string
+	prefixesHeld  bool
+	// neighborsMu защищает config.Neighbors, соседи из DNS добавляются и удаляются во время работы.
+	neighborsMu sync.RWMutex
+	// dnsNeighbors это соседи из конфигурации, заданные именем хоста или SRV записью.
+	dnsNeighbors []Neighbor
+	// unnumberedNeighbors это соседи из конфигурации, заданные через interface.
+	unnumberedNeighbors []Neighbor
+	// peerFlapDampened это сколько раз сосед превысил peer_flap_dampening.max_flaps.
+	peerFlapDampened atomic.Uint64
+	// peerDownMu защищает peerDowns, число разрывов сессий для peer_down_total.
+	peerDownMu sync.Mutex
+	peerDowns  map[peerDownKey]uint64
+	// disabledMu защищает disabledPeers, соседей, выключенных через admin API, см. [Speaker.DisablePeer].
+	disabledMu    sync.Mutex
+	disabledPeers map[string]bool
+	// auditMu защищает кольцевой буфер последних решений об анонсе, см. [Speaker.audit].
+	auditMu      sync.Mutex
+	auditHistory []AuditEntry
+	auditNext    int
+	// captureMu защищает capture, текущую или последнюю запись message_capture.
+	captureMu sync.Mutex
+	capture   *messageCapture
+	// logs подавляет повторы ошибок в циклах FIB и hooks, см. [logSampler].
+	logs logSampler
+	latencyStats
+	// Поля ниже задаются в Start и используются в Wait и Stop.
+	eg        *errgroup.Group
+	cancel    context.CancelFunc
+	bgpServer *server.BgpServer
+	remoteBgp *bgpserver.Remote
+	// runCtx отменяется в Stop, от него отсчитываются фоновые ожидания вне eg.
+	runCtx context.Context
+	// stopOnce и stopErr делают Stop идемпотентным, см. [Speaker.Stop].
+	stopOnce sync.Once
+	stopErr  error
+	// restarting задается на время setup после handoff, чтобы соседи добавлялись с флагом
+	// Restart State graceful restart. handedOff и handoffConn задаются в старом процессе,
+	// см. [Speaker.ServeHandoff].
+	restarting  bool
+	handedOff   atomic.Bool
+	handoffConn net.Conn
+	// customCheck и fibDisabled задаются через пакет pkg/speaker.
+	customCheck func(context.Context) error
+	fibDisabled bool
 }
 
 func NewAppCfg(configPath string, logLevel LogLevel) (*Speaker, error) {
@@ -47,61 +162,60 @@ func NewAppCfg(configPath string, logLevel LogLevel) (*Speaker, error) {
 	if err := sp.loadConfig(); err != nil {
 		return nil, err
 	}
+	if err := sp.logger.SetOutput(sp.config.LogOutput, sp.config.Syslog); err != nil {
+		return nil, err
+	}
+	if err := sp.init(); err != nil {
+		return nil, err
+	}
 	return sp, nil
 }
 
+// Метод init задает начальное состояние по загруженной конфигурации.
+func (sp *Speaker) init() error {
+	sp.hookAnnounce = true
+	// С leader_election реплика считается standby до первого захвата lease.
+	sp.leader = sp.config.LeaderElection == nil
+	sp.prefixesHeld = !sp.leader
+	if sp.config.Hooks != nil {
+		hooks, err := compileHooks(sp.config.Hooks)
+		if err != nil {
+			return err
+		}
+		sp.hooks = hooks
+	}
+	return nil
+}
+
+// SetBgpServer подменяет BGP сервер, который иначе создается в [Speaker.Run],
+// например, на [bgpserver.Fake].
+func (sp *Speaker) SetBgpServer(s bgpserver.Server) {
+	sp.s = s
+}
+
 func (sp *Speaker) loadConfig() error {
-	configBytes, err := os.ReadFile(sp.confitPath)
+	config, err := LoadConfig(sp.confitPath)
 	if err != nil {
 		return err
 	}
-	return yaml.Unmarshal(configBytes, &sp.config)
+	sp.config = config
+	return nil
 }
 
+// Run запускает speaker и ждет SIGTERM или SIGINT, после чего останавливает его, см. [Speaker.Stop].
 func (sp *Speaker) Run() error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
-	sp.s = server.NewBgpServer(server.GrpcListenAddress("localhost:6061"), server.LoggerOption(sp.logger))
-	go sp.s.Serve()
-	defer sp.s.Stop()
-
-	if err := sp.setup(ctx); err != nil {
+	if err := sp.Start(ctx); err != nil {
 		return err
 	}
-
-	eg, ctx := errgroup.WithContext(ctx)
-
-	healthCheck, err := NewHealthCheck(
-		sp.addPath,
-		sp.deletePath,
-		sp.config.HealthCheckURL,
-	)
-	if err != nil {
-		return fmt.Errorf("error creating health check")
-	}
-	eg.Go(func() error {
-		return healthCheck.Run(ctx, *sp.logger)
-	})
-
-	if sp.config.UpdateFIBMetric != nil {
-		sp.linuxRouteMetric = *sp.config.UpdateFIBMetric
-		eg.Go(func() error {
-			return sp.UpdateFIB(ctx)
-		})
-	}
-
-	err = eg.Wait()
+	err := sp.Wait()
 	if err != nil {
 		sp.logger.Error(fmt.Sprintf("some routines completed with error: %s", err.Error()), nil)
 	}
-	sp.logger.Info("shutting down bgp", nil)
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	if err := sp.stopBgp(timeoutCtx); err != nil {
-		sp.logger.Error(fmt.Sprintf("failed to stop bgp server: %s", err.Error()), nil)
-	}
-
+	stop()
+	_ = sp.Stop(context.Background())
 	return err
 }
 
@@ -109,20 +223,89 @@ func (sp *Speaker) setup(ctx context.Context) error {
 	if err := sp.startBgp(ctx); err != nil {
 		return fmt.Errorf("error starting bgp: %w", err)
 	}
-	if err := sp.setupPolicies(ctx); err != nil {
+	if err := sp.addRPKICaches(ctx); err != nil {
+		return err
+	}
+	if err := sp.enableZebra(ctx); err != nil {
+		return err
+	}
+	if err := sp.setupPolicyMode(ctx); err != nil {
 		return fmt.Errorf("error creating policies: %w", err)
 	}
 	if err := sp.addNeighbors(ctx); err != nil {
 		return fmt.Errorf("error adding neighbors: %w", err)
 	}
-	if sp.config.HealthCheckURL == "" {
-		if err := sp.addPath(ctx); err != nil {
+	if sp.config.advertiseEnabled() && !sp.healthCheckEnabled() {
+		if err := sp.onHealthy(ctx); err != nil {
 			return fmt.Errorf("error advertising anycast route: %w", err)
 		}
 	}
 	return nil
 }
 
+func (sp *Speaker) newHealthCheck() (*HealthCheck, error) {
+	hc := newHealthCheck(sp.onHealthy, sp.onUnhealthy)
+	hc.check = sp.customCheck
+	if sp.customCheck == nil {
+		var err error
+		hc, err = sp.newSourceHealthCheck(sp.config.healthCheckSpec(), sp.config.AnycastIP, sp.onHealthy, sp.onUnhealthy)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sp.scheduleHealthCheck(hc)
+	return hc, nil
+}
+
+// Метод scheduleHealthCheck задает проверке hc min_up_seconds и health_check_schedule.
+func (sp *Speaker) scheduleHealthCheck(hc *HealthCheck) {
+	hc.minUp = time.Second * time.Duration(sp.config.MinUpSeconds)
+	if schedule := sp.config.HealthCheckSchedule; schedule != nil {
+		hc.jitter = time.Millisecond * time.Duration(schedule.JitterMs)
+		hc.maxBackoff = time.Second * time.Duration(schedule.MaxBackoffSeconds)
+	}
+}
+
+// Метод newSourceHealthCheck создает HealthCheck для источника spec.Source,
+// prefix передается в заголовке X-Bgp-Speaker-Prefix HTTP проверки.
+func (sp *Speaker) newSourceHealthCheck(spec HealthCheckSpec, prefix string, cbHealthy, cbUnhealthy func(context.Context) error) (*HealthCheck, error) {
+	if spec.Source == HealthSourceConsul {
+		if spec.Consul == nil {
+			return nil, fmt.Errorf("health_source is consul, but consul is not configured")
+		}
+		return NewConsulHealthCheck(cbHealthy, cbUnhealthy, *spec.Consul)
+	}
+	if spec.Source == HealthSourceGRPC {
+		if spec.GRPC == nil {
+			return nil, fmt.Errorf("health_source is grpc, but grpc_health_check is not configured")
+		}
+		return NewGRPCHealthCheck(cbHealthy, cbUnhealthy, *spec.GRPC)
+	}
+	if spec.Source == HealthSourceICMP {
+		if spec.ICMP == nil {
+			return nil, fmt.Errorf("health_source is icmp, but icmp_health_check is not configured")
+		}
+		return NewICMPHealthCheck(cbHealthy, cbUnhealthy, *spec.ICMP)
+	}
+	if spec.Source == HealthSourceFile {
+		if spec.File == nil {
+			return nil, fmt.Errorf("health_source is file, but file_health_check is not configured")
+		}
+		return NewFileHealthCheck(cbHealthy, cbUnhealthy, *spec.File)
+	}
+	opts, err := sp.httpCheckOptions(spec.HTTP, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("health_check: %w", err)
+	}
+	return NewHealthCheck(cbHealthy, cbUnhealthy, spec.URL, opts)
+}
+
+// Метод healthCheckEnabled возвращает false, если проверка здоровья не настроена
+// и anycast ip анонсируется сразу после старта.
+func (sp *Speaker) healthCheckEnabled() bool {
+	return sp.customCheck != nil || sp.config.HealthSource != HealthSourceHTTP || sp.config.HealthCheckURL != ""
+}
+
 func (sp *Speaker) startBgp(ctx context.Context) error {
 	return sp.s.StartBgp(ctx, &api.StartBgpRequest{
 		Global: &api.Global{
@@ -138,23 +321,75 @@ func (sp *Speaker) stopBgp(ctx context.Context) error {
 }
 
 func (sp *Speaker) addNeighbors(ctx context.Context) error {
-	for _, neighbor := range sp.config.Neighbors {
-		peer := &api.Peer{
-			Conf: &api.PeerConf{
-				NeighborAddress: neighbor.Address,
-				PeerAsn:         neighbor.ASN,
-			},
-		}
-		if err := sp.s.AddPeer(ctx, &api.AddPeerRequest{Peer: peer}); err != nil {
+	for _, neighbor := range sp.neighbors() {
+		if err := sp.addNeighbor(ctx, neighbor); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (sp *Speaker) anycastPath() (*api.Path, error) {
+func (sp *Speaker) addNeighbor(ctx context.Context, neighbor Neighbor) error {
+	peer := &api.Peer{
+		Conf: &api.PeerConf{
+			NeighborAddress: neighbor.Address,
+			PeerAsn:         neighbor.ASN,
+			LocalAsn:        sp.config.localASN(neighbor),
+			AllowOwnAsn:     neighbor.AllowASIn,
+			ReplacePeerAsn:  neighbor.ASOverride,
+			RemovePrivate:   neighbor.RemovePrivateAS.api(),
+		},
+	}
+	if neighbor.LocalAddress != "" || sp.neighborBindInterface(neighbor) != "" || neighbor.remotePort != 0 {
+		peer.Transport = &api.Transport{
+			LocalAddress:  neighbor.LocalAddress,
+			BindInterface: sp.neighborBindInterface(neighbor),
+			RemotePort:    uint32(neighbor.remotePort),
+		}
+	}
+	if neighbor.MaxPrefixes != nil || neighbor.ExtendedNexthop || sp.config.Handoff != nil {
+		family := &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST}
+		afiSafi := &api.AfiSafi{Config: &api.AfiSafiConfig{Family: family, Enabled: true}}
+		if neighbor.MaxPrefixes != nil {
+			afiSafi.PrefixLimits = &api.PrefixLimit{
+				Family:               family,
+				MaxPrefixes:          neighbor.MaxPrefixes.Limit,
+				ShutdownThresholdPct: neighbor.MaxPrefixes.WarningThresholdPct,
+			}
+		}
+		peer.AfiSafis = []*api.AfiSafi{afiSafi}
+	}
+	if h := sp.config.Handoff; h != nil {
+		peer.GracefulRestart = &api.GracefulRestart{
+			Enabled:         true,
+			RestartTime:     h.RestartTimeSeconds,
+			DeferralTime:    h.RestartTimeSeconds,
+			LocalRestarting: sp.restarting,
+		}
+		for _, afiSafi := range peer.AfiSafis {
+			afiSafi.MpGracefulRestart = &api.MpGracefulRestart{Config: &api.MpGracefulRestartConfig{Enabled: true}}
+		}
+	}
+	return sp.s.AddPeer(ctx, &api.AddPeerRequest{Peer: peer})
+}
+
+// pathAttrs описывает дополнительные атрибуты анонса anycast ip.
+type pathAttrs struct {
+	communities []uint32
+	prepend     uint32
+	// nextHop это адрес хоста, на котором живет префикс; если не задан, next-hop выбирает gobgp.
+	nextHop string
+}
+
+func (sp *Speaker) anycastPath(extra pathAttrs) (*api.Path, error) {
+	extra.nextHop = sp.config.NextHop
+	return sp.hostPath(sp.config.AnycastIP, extra)
+}
+
+// Метод hostPath создает path для префикса ip/32.
+func (sp *Speaker) hostPath(ip string, extra pathAttrs) (*api.Path, error) {
 	nlri, err := anypb.New(&api.IPAddressPrefix{
-		Prefix:    sp.config.AnycastIP,
+		Prefix:    ip,
 		PrefixLen: 32,
 	})
 	if err != nil {
@@ -170,73 +405,205 @@ func (sp *Speaker) anycastPath() (*api.Path, error) {
 		//     "gobgp global rib add -a ipv4 10.0.0.0/24"
 		//   то выполнится строка 1658 файла cmd/gobgp/global.go, устанавливающая такой nexthop
 		//     https://github.com/osrg/gobgp/blob/dace87570846cc4b4f16e8b25516b22c43888f76/cmd/gobgp/global.go#L1658
-		NextHop: "0.0.0.0",
+		//   gobgp заменит его на адрес локального конца сессии, а заданный next-hop оставит как есть
+		NextHop: defaultNextHop,
 	})
+	if extra.nextHop != "" {
+		a2, _ = anypb.New(&api.NextHopAttribute{NextHop: extra.nextHop})
+	}
+	pattrs := []*anypb.Any{a1, a2}
+	if len(extra.communities) > 0 {
+		a3, _ := anypb.New(&api.CommunitiesAttribute{
+			Communities: extra.communities,
+		})
+		pattrs = append(pattrs, a3)
+	}
+	if extra.prepend > 0 {
+		numbers := make([]uint32, 0, extra.prepend)
+		for i := uint32(0); i < extra.prepend; i++ {
+			numbers = append(numbers, sp.config.ASN)
+		}
+		a4, _ := anypb.New(&api.AsPathAttribute{
+			Segments: []*api.AsSegment{
+				{Type: api.AsSegment_AS_SEQUENCE, Numbers: numbers},
+			},
+		})
+		pattrs = append(pattrs, a4)
+	}
 	return &api.Path{
 		Family: &api.Family{Afi: api.Family_AFI_IP, Safi: api.Family_SAFI_UNICAST},
 		Nlri:   nlri,
-		Pattrs: []*anypb.Any{a1, a2},
+		Pattrs: pattrs,
 	}, nil
 }
 
 func (sp *Speaker) addPath(ctx context.Context) error {
-	path, err := sp.anycastPath()
+	path, err := sp.anycastPath(pathAttrs{communities: sp.hookCommunities})
 	if err != nil {
 		return err
 	}
 	sp.logger.Info("addPath", log.Fields{"anycast_ip": sp.config.AnycastIP})
-	_, err = sp.s.AddPath(ctx, &api.AddPathRequest{Path: path})
-	return err
+	if err := sp.addAnycastAddress(); err != nil {
+		return err
+	}
+	activated := !sp.advertised.Load()
+	if err := sp.announce(ctx, path); err != nil {
+		return err
+	}
+	if activated {
+		sp.announceGratuitousARP()
+	}
+	sp.degraded.Store(false)
+	sp.audit(ctx, auditActionAdvertise, sp.config.AnycastIP, "")
+	return nil
+}
+
+// Метод announce анонсирует path и запоминает его для debug API.
+func (sp *Speaker) announce(ctx context.Context, path *api.Path) error {
+	if _, err := sp.s.AddPath(ctx, &api.AddPathRequest{Path: path}); err != nil {
+		return err
+	}
+	latencyTraceFrom(ctx).mark(stageGobgpCall)
+	sp.finishLatencyTrace(ctx, sp.config.AnycastIP+"/32", false)
+	sp.announcedPath.Store(path)
+	if !sp.advertised.Load() {
+		sp.announcedAt.Store(time.Now().UnixNano())
+	}
+	sp.advertised.Store(true)
+	return nil
 }
 
 func (sp *Speaker) deletePath(ctx context.Context) error {
-	bgpPath, err := sp.anycastPath()
+	bgpPath, err := sp.anycastPath(pathAttrs{})
 	if err != nil {
 		return err
 	}
 	sp.logger.Warn("deletePath", log.Fields{"anycast_ip": sp.config.AnycastIP})
-	return sp.s.DeletePath(ctx, &api.DeletePathRequest{Path: bgpPath})
+	if err := sp.s.DeletePath(ctx, &api.DeletePathRequest{Path: bgpPath}); err != nil {
+		return err
+	}
+	latencyTraceFrom(ctx).mark(stageGobgpCall)
+	sp.finishLatencyTrace(ctx, sp.config.AnycastIP+"/32", true)
+	sp.announcedPath.Store(nil)
+	sp.advertised.Store(false)
+	sp.degraded.Store(false)
+	sp.removeAnycastAddress()
+	sp.audit(ctx, auditActionWithdraw, sp.config.AnycastIP, "")
+	return nil
+}
+
+// Метод setupPolicyMode настраивает политики в соответствии с policy_mode.
+func (sp *Speaker) setupPolicyMode(ctx context.Context) error {
+	mode := sp.config.PolicyMode
+	if mode == "" {
+		mode = PolicyModeStrict
+	}
+	if d := sp.config.DefaultAction; d != nil && (d.Import == PolicyActionAccept || d.Export == PolicyActionAccept) {
+		sp.logger.Warn("routes not matched by policies are accepted by default", log.Fields{"import": d.Import, "export": d.Export})
+	}
+	switch mode {
+	case PolicyModeStrict:
+		sp.logger.Info("policy mode selected", log.Fields{"policy_mode": mode})
+		return sp.setupPolicies(ctx)
+	case PolicyModePermissive:
+		sp.logger.Warn("policy mode selected, all routes are accepted and exported", log.Fields{"policy_mode": mode})
+		return sp.setupRPKIPolicy(ctx)
+	case PolicyModeCustom:
+		sp.logger.Info("policy mode selected", log.Fields{"policy_mode": mode})
+		return sp.setupCustomPolicies(ctx)
+	default:
+		return fmt.Errorf("policy_mode %q is not implemented yet: %w", mode, errors.ErrUnsupported)
+	}
 }
 
 // Метод setupPolicies [настраивает политики], чтобы случайно не принять или не отправить ненужное.
 //
 // [настраивает политики]: https://github.com/osrg/gobgp/blob/master/docs/sources/policy.md
 func (sp *Speaker) setupPolicies(ctx context.Context) error {
-	if err := sp.addDefinedSets(ctx); err != nil {
-		return fmt.Errorf("addDefinedSets failed: %w", err)
-	}
+	return sp.applyPolicies(ctx, sp.strictPolicies())
+}
+
+// Метод strictPolicies возвращает defined sets, политики и их назначения policy_mode: strict.
+// Они же служат шаблоном для секции policies.
+func (sp *Speaker) strictPolicies() *customPolicies {
 	policyDefaultRoute := sp.createDefaultRoutePolicy()
-	if err := sp.addPolicy(ctx, policyDefaultRoute); err != nil {
-		return err
-	}
 	policyAnycastIP := sp.createAnycastIPPolicy()
-	if err := sp.addPolicy(ctx, policyAnycastIP); err != nil {
-		return err
-	}
 	policyImportAnycastIP := sp.createAnycastIPPolicyImport()
-	if err := sp.addPolicy(ctx, policyImportAnycastIP); err != nil {
-		return err
+	policies := []*api.Policy{policyDefaultRoute, policyAnycastIP, policyImportAnycastIP}
+	importPolicies := []*api.Policy{policyDefaultRoute, policyImportAnycastIP}
+	if sp.config.RPKI != nil {
+		policyRPKI := sp.createRejectRPKIInvalidPolicy()
+		policies = append(policies, policyRPKI)
+		importPolicies = append([]*api.Policy{policyRPKI}, importPolicies...)
+	}
+	return &customPolicies{
+		definedSets: sp.strictDefinedSets(),
+		policies:    policies,
+		assignments: []*api.PolicyAssignment{
+			{
+				Name:          global,
+				Direction:     api.PolicyDirection_IMPORT,
+				Policies:      importPolicies,
+				DefaultAction: sp.config.defaultAction(api.PolicyDirection_IMPORT),
+			},
+			{
+				Name:          global,
+				Direction:     api.PolicyDirection_EXPORT,
+				Policies:      []*api.Policy{policyAnycastIP},
+				DefaultAction: sp.config.defaultAction(api.PolicyDirection_EXPORT),
+			},
+		},
 	}
-	if err := sp.addPolicyAssignment(ctx, &api.PolicyAssignment{
-		Name:          global,
-		Direction:     api.PolicyDirection_IMPORT,
-		Policies:      []*api.Policy{policyDefaultRoute, policyImportAnycastIP},
-		DefaultAction: api.RouteAction_REJECT,
-	}); err != nil {
-		return err
+}
+
+// Метод setupCustomPolicies создает defined sets, политики и их назначения policy_mode: custom
+// из секции policies или из политик gobgp_config.
+func (sp *Speaker) setupCustomPolicies(ctx context.Context) error {
+	if sp.config.Policies != nil {
+		return sp.applyPolicies(ctx, sp.config.Policies.compile(sp.strictPolicies(), sp.config.ASN))
 	}
-	if err := sp.addPolicyAssignment(ctx, &api.PolicyAssignment{
-		Name:          global,
-		Direction:     api.PolicyDirection_EXPORT,
-		Policies:      []*api.Policy{policyAnycastIP},
-		DefaultAction: api.RouteAction_REJECT,
-	}); err != nil {
-		return err
+	return sp.applyPolicies(ctx, sp.config.customPolicies)
+}
+
+// Метод applyPolicies создает в BGP defined sets, затем политики и их назначения.
+func (sp *Speaker) applyPolicies(ctx context.Context, policies *customPolicies) error {
+	for _, s := range policies.definedSets {
+		if err := sp.addDefinedSet(ctx, s); err != nil {
+			return err
+		}
+	}
+	for _, p := range policies.policies {
+		if err := sp.addPolicy(ctx, p); err != nil {
+			return err
+		}
+	}
+	for _, a := range policies.assignments {
+		if err := sp.addPolicyAssignment(ctx, a); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// Метод addDefinedSets создает в конфигерации BGP несколько объектов [defined-sets]:
+// Метод setupRPKIPolicy в режиме permissive назначает только политику импорта,
+// отбрасывающую префиксы с невалидными ROA, если настроен rpki.
+func (sp *Speaker) setupRPKIPolicy(ctx context.Context) error {
+	if sp.config.RPKI == nil {
+		return nil
+	}
+	policyRPKI := sp.createRejectRPKIInvalidPolicy()
+	if err := sp.addPolicy(ctx, policyRPKI); err != nil {
+		return err
+	}
+	return sp.addPolicyAssignment(ctx, &api.PolicyAssignment{
+		Name:          global,
+		Direction:     api.PolicyDirection_IMPORT,
+		Policies:      []*api.Policy{policyRPKI},
+		DefaultAction: api.RouteAction_ACCEPT,
+	})
+}
+
+// Метод strictDefinedSets возвращает несколько объектов [defined-sets] для конфигурации BGP:
 //   - объект с именем "defaultRoute" соответствует префиксу, который анонсирует фабрика
 //   - объект с именем "anycastIP" соответствует префиксу, который анонсирует gobgp
 //   - объект с именем "uplinks" соответствует bgp-пирам
@@ -244,7 +611,7 @@ func (sp *Speaker) setupPolicies(ctx context.Context) error {
 // Имена объектов являются константами, на которые еще ссылаются политики.
 //
 // [defined-sets]: https://github.com/osrg/gobgp/blob/master/docs/sources/policy.md#1-defining-defined-sets
-func (sp *Speaker) addDefinedSets(ctx context.Context) error {
+func (sp *Speaker) strictDefinedSets() []*api.DefinedSet {
 	prefixSetDefaultRoute := &api.DefinedSet{
 		DefinedType: api.DefinedType_PREFIX,
 		Name:        defaultRoute,
@@ -256,9 +623,6 @@ func (sp *Speaker) addDefinedSets(ctx context.Context) error {
 			},
 		},
 	}
-	if err := sp.addDefinedSet(ctx, prefixSetDefaultRoute); err != nil {
-		return err
-	}
 	prefixSetAnycastIP := &api.DefinedSet{
 		DefinedType: api.DefinedType_PREFIX,
 		Name:        anycastIP,
@@ -270,22 +634,42 @@ func (sp *Speaker) addDefinedSets(ctx context.Context) error {
 			},
 		},
 	}
-	if err := sp.addDefinedSet(ctx, prefixSetAnycastIP); err != nil {
-		return err
-	}
 	neighbors := []string{}
-	for _, n := range sp.config.Neighbors {
-		neighbors = append(neighbors, fmt.Sprintf("%s/32", n.Address))
+	for _, n := range sp.neighbors() {
+		neighbors = append(neighbors, neighborPrefix(n.Address))
 	}
-	neighborSet := api.DefinedSet{
+	neighborSet := &api.DefinedSet{
 		DefinedType: api.DefinedType_NEIGHBOR,
 		Name:        uplinks,
 		List:        neighbors,
 	}
-	if err := sp.addDefinedSet(ctx, &neighborSet); err != nil {
-		return err
+	sets := []*api.DefinedSet{prefixSetDefaultRoute, prefixSetAnycastIP, neighborSet}
+	if sp.nextHopSelfEnabled() {
+		nextHopSelfSet := &api.DefinedSet{
+			DefinedType: api.DefinedType_NEIGHBOR,
+			Name:        nextHopSelf,
+			List:        sp.nextHopSelfNeighbors(),
+		}
+		sets = append(sets, nextHopSelfSet)
 	}
-	return nil
+	return sets
+}
+
+// Метод nextHopSelfNeighbors возвращает соседей с next_hop_self в формате defined-set.
+func (sp *Speaker) nextHopSelfNeighbors() []string {
+	neighbors := []string{}
+	for _, n := range sp.neighbors() {
+		if n.NextHopSelf {
+			neighbors = append(neighbors, neighborPrefix(n.Address))
+		}
+	}
+	return neighbors
+}
+
+// Метод nextHopSelfEnabled возвращает true, если next_hop_self задан хотя бы у одного соседа,
+// в том числе у еще не разрешенного имени хоста или SRV записи.
+func (sp *Speaker) nextHopSelfEnabled() bool {
+	return len(sp.nextHopSelfNeighbors()) > 0 || slices.ContainsFunc(sp.dnsNeighbors, func(n Neighbor) bool { return n.NextHopSelf })
 }
 
 // Метод createDefaultRoutePolicy создает политику, разрешающую "default route".
@@ -314,10 +698,33 @@ func (sp *Speaker) createDefaultRoutePolicy() *api.Policy {
 }
 
 // Метод createAnycastIPPolicy создает политику, разрешающую anycast ip.
+//
+// Соседям с next_hop_self префиксы отправляются с адресом локального конца сессии
+// в качестве next-hop, даже если для префикса задан next_hop.
 func (sp *Speaker) createAnycastIPPolicy() *api.Policy {
+	statements := []*api.Statement{}
+	if sp.nextHopSelfEnabled() {
+		statements = append(statements, &api.Statement{
+			Name: "allow-anycast-ip-next-hop-self",
+			Conditions: &api.Conditions{
+				PrefixSet: &api.MatchSet{
+					Type: api.MatchSet_ANY,
+					Name: anycastIP,
+				},
+				NeighborSet: &api.MatchSet{
+					Type: api.MatchSet_ANY,
+					Name: nextHopSelf,
+				},
+			},
+			Actions: &api.Actions{
+				RouteAction: api.RouteAction_ACCEPT,
+				Nexthop:     &api.NexthopAction{Self: true},
+			},
+		})
+	}
 	return &api.Policy{
 		Name: onlyAnycastIP,
-		Statements: []*api.Statement{
+		Statements: append(statements, []*api.Statement{
 			{
 				Name: "allow-anycast-ip",
 				Conditions: &api.Conditions{
@@ -334,14 +741,14 @@ func (sp *Speaker) createAnycastIPPolicy() *api.Policy {
 					RouteAction: api.RouteAction_ACCEPT,
 				},
 			},
-		},
+		}...),
 	}
 }
 
-// Метод createAnycastIPPolicy создает политику, разрешающую добавлять в rib anycast ip.
+// Метод createAnycastIPPolicyImport создает политику, разрешающую добавлять в rib anycast ip.
 func (sp *Speaker) createAnycastIPPolicyImport() *api.Policy {
 	return &api.Policy{
-		Name: onlyAnycastIP,
+		Name: onlyLocalAnycastIP,
 		Statements: []*api.Statement{
 			{
 				Name: "allow-anycast-ip-igp",
@@ -0,0 +1,193 @@
+package speaker
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/sir-sukhov/bgp-speaker/internal/bgp"
+	"github.com/sir-sukhov/bgp-speaker/internal/kubernetes"
+	"github.com/sirupsen/logrus"
+)
+
+// step это действие над speaker, которое в реальной работе вызывают HealthCheck,
+// admin API или выбор лидера.
+type step func(ctx context.Context, sp *Speaker) error
+
+var (
+	healthy   step = func(ctx context.Context, sp *Speaker) error { return sp.onHealthy(ctx) }
+	unhealthy step = func(ctx context.Context, sp *Speaker) error { return sp.onUnhealthy(ctx) }
+)
+
+func maintenance(enabled bool) step {
+	return func(ctx context.Context, sp *Speaker) error { return sp.SetMaintenance(ctx, enabled) }
+}
+
+func leader(leader bool) step {
+	return func(ctx context.Context, sp *Speaker) error { return sp.SetLeader(ctx, leader) }
+}
+
+func advertise(ip string) step {
+	return func(ctx context.Context, sp *Speaker) error { return sp.AdvertisePrefix(ctx, ip) }
+}
+
+func withdraw(ip string) step {
+	return func(ctx context.Context, sp *Speaker) error { return sp.WithdrawPrefix(ctx, ip) }
+}
+
+// newTestSpeaker возвращает speaker с запущенным bgp.Fake и политиками policy_mode: strict.
+func newTestSpeaker(t *testing.T, config Config) (*Speaker, *bgp.Fake) {
+	t.Helper()
+	config.AnycastIP = "10.0.0.1"
+	config.ASN = 65001
+	fake := bgp.NewFake()
+	sp := &Speaker{logger: NewLogger(logrus.PanicLevel), config: config, s: fake}
+	if err := sp.init(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := sp.startBgp(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.setupPolicyMode(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sp.latencyTraces.Wait)
+	return sp, fake
+}
+
+func run(t *testing.T, sp *Speaker, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		if err := s(context.Background(), sp); err != nil {
+			t.Fatalf("step %d: %s", i, err)
+		}
+	}
+}
+
+// globalPaths возвращает пути глобальной таблицы fake по префиксам.
+func globalPaths(t *testing.T, fake *bgp.Fake) map[string][]*api.Path {
+	t.Helper()
+	paths := map[string][]*api.Path{}
+	err := fake.ListPath(context.Background(), &api.ListPathRequest{TableType: api.TableType_GLOBAL}, func(d *api.Destination) {
+		paths[d.Prefix] = d.Paths
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestAnnounceGating(t *testing.T) {
+	tests := []struct {
+		name           string
+		standby        bool
+		softFail       bool
+		steps          []step
+		wantAdvertised bool
+		wantDegraded   bool
+	}{
+		{name: "healthy", steps: []step{healthy}, wantAdvertised: true},
+		{name: "unhealthy", steps: []step{healthy, unhealthy}},
+		{name: "unhealthy with soft_fail", softFail: true, steps: []step{healthy, unhealthy}, wantAdvertised: true, wantDegraded: true},
+		{name: "healthy after soft_fail", softFail: true, steps: []step{unhealthy, healthy}, wantAdvertised: true},
+		{name: "healthy in maintenance", steps: []step{maintenance(true), healthy}},
+		{name: "maintenance withdraws", steps: []step{healthy, maintenance(true)}},
+		{name: "maintenance off announces healthy", steps: []step{maintenance(true), healthy, maintenance(false)}, wantAdvertised: true},
+		{name: "maintenance off keeps unhealthy withdrawn", steps: []step{maintenance(true), unhealthy, maintenance(false)}},
+		{name: "maintenance off degrades unhealthy with soft_fail", softFail: true, steps: []step{maintenance(true), unhealthy, maintenance(false)}, wantAdvertised: true, wantDegraded: true},
+		{name: "unhealthy in maintenance with soft_fail", softFail: true, steps: []step{healthy, maintenance(true), unhealthy}},
+		{name: "healthy on standby", standby: true, steps: []step{healthy}},
+		{name: "leader announces healthy", standby: true, steps: []step{healthy, leader(true)}, wantAdvertised: true},
+		{name: "leader keeps unhealthy withdrawn", standby: true, steps: []step{unhealthy, leader(true)}},
+		{name: "standby withdraws", steps: []step{healthy, leader(false)}},
+		{name: "leader in maintenance", standby: true, steps: []step{healthy, maintenance(true), leader(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{}
+			if tt.standby {
+				config.LeaderElection = &kubernetes.LeaderElection{}
+			}
+			if tt.softFail {
+				config.SoftFail = &SoftFail{Prepend: 3, Communities: []Community{65001<<16 | 666}}
+			}
+			sp, fake := newTestSpeaker(t, config)
+			run(t, sp, tt.steps)
+
+			if got := sp.advertised.Load(); got != tt.wantAdvertised {
+				t.Errorf("advertised is %t, want %t", got, tt.wantAdvertised)
+			}
+			if got := sp.degraded.Load(); got != tt.wantDegraded {
+				t.Errorf("degraded is %t, want %t", got, tt.wantDegraded)
+			}
+			_, announced := globalPaths(t, fake)["10.0.0.1/32"]
+			if announced != tt.wantAdvertised {
+				t.Errorf("anycast ip in global table is %t, want %t", announced, tt.wantAdvertised)
+			}
+		})
+	}
+}
+
+func TestPrefixDefinedSet(t *testing.T) {
+	tests := []struct {
+		name         string
+		standby      bool
+		steps        []step
+		wantPrefixes []string
+		wantHeld     []string
+	}{
+		{name: "advertise", steps: []step{advertise("10.1.1.1")}, wantPrefixes: []string{"10.1.1.1"}},
+		{name: "advertise twice", steps: []step{advertise("10.1.1.1"), advertise("10.1.1.1")}, wantPrefixes: []string{"10.1.1.1"}},
+		{name: "advertise several", steps: []step{advertise("10.1.1.2"), advertise("10.1.1.1")}, wantPrefixes: []string{"10.1.1.1", "10.1.1.2"}},
+		{name: "withdraw", steps: []step{advertise("10.1.1.1"), advertise("10.1.1.2"), withdraw("10.1.1.1")}, wantPrefixes: []string{"10.1.1.2"}},
+		{name: "withdraw unknown", steps: []step{withdraw("10.1.1.1")}},
+		{name: "held in maintenance", steps: []step{maintenance(true), advertise("10.1.1.1")}, wantHeld: []string{"10.1.1.1"}},
+		{name: "held on standby", standby: true, steps: []step{advertise("10.1.1.1")}, wantHeld: []string{"10.1.1.1"}},
+		{name: "withdraw held", standby: true, steps: []step{advertise("10.1.1.1"), withdraw("10.1.1.1")}},
+		{name: "leader advertises held", standby: true, steps: []step{advertise("10.1.1.1"), leader(true)}, wantPrefixes: []string{"10.1.1.1"}},
+		{name: "standby holds advertised", steps: []step{advertise("10.1.1.1"), leader(false)}, wantHeld: []string{"10.1.1.1"}},
+		{name: "maintenance off advertises held", steps: []step{advertise("10.1.1.1"), maintenance(true), maintenance(false)}, wantPrefixes: []string{"10.1.1.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{}
+			if tt.standby {
+				config.LeaderElection = &kubernetes.LeaderElection{}
+			}
+			sp, fake := newTestSpeaker(t, config)
+			run(t, sp, tt.steps)
+
+			if got := sp.AdvertisedPrefixes(); !slices.Equal(got, tt.wantPrefixes) {
+				t.Errorf("advertised prefixes %v, want %v", got, tt.wantPrefixes)
+			}
+			if got := sp.HeldPrefixes(); !slices.Equal(got, tt.wantHeld) {
+				t.Errorf("held prefixes %v, want %v", got, tt.wantHeld)
+			}
+			set := fake.DefinedSet(anycastIP)
+			if set == nil {
+				t.Fatalf("defined-set %q is not found", anycastIP)
+			}
+			wantSet := []string{"10.0.0.1/32"}
+			for _, ip := range tt.wantPrefixes {
+				wantSet = append(wantSet, ip+"/32")
+			}
+			gotSet := []string{}
+			for _, p := range set.Prefixes {
+				gotSet = append(gotSet, p.IpPrefix)
+			}
+			slices.Sort(gotSet)
+			if !slices.Equal(gotSet, wantSet) {
+				t.Errorf("defined-set %q has prefixes %v, want %v", anycastIP, gotSet, wantSet)
+			}
+			paths := globalPaths(t, fake)
+			for _, ip := range []string{"10.1.1.1", "10.1.1.2"} {
+				_, announced := paths[ip+"/32"]
+				if want := slices.Contains(tt.wantPrefixes, ip); announced != want {
+					t.Errorf("prefix %s in global table is %t, want %t", ip, announced, want)
+				}
+			}
+		})
+	}
+}
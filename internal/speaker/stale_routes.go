@@ -0,0 +1,97 @@
+package speaker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+// Метод cleanupStaleRoutes вызывается при старте UpdateFIB и удаляет из таблицы speaker маршруты
+// с протоколом bgp и metric speaker, которые он сам не ставит, например, оставшиеся после
+// аварийного завершения или смены metric: маршруты не по-умолчанию и лишние маршруты по-умолчанию.
+// Один маршрут по-умолчанию остается, его заменит первое обновление FIB или удалит fib_hold_seconds,
+// чтобы перезапуск speaker не оставлял хост без связности до установления сессий.
+func (sp *Speaker) cleanupStaleRoutes() error {
+	msgs, err := sp.conn.Execute(&rtnetlink.RouteMessage{}, getRoute, netlink.Request|netlink.Dump)
+	if err != nil {
+		return fmt.Errorf("failed to get table of routes: %w", err)
+	}
+	stale := []*rtnetlink.RouteMessage{}
+	keptDefault := false
+	for i := range msgs {
+		route, ok := msgs[i].(*rtnetlink.RouteMessage)
+		if !ok {
+			return fmt.Errorf("unexpected rtnetlink message: %w", errors.ErrUnsupported)
+		}
+		if !sp.linuxRouteIsOwned(route) {
+			continue
+		}
+		if route.DstLength == 0 && !keptDefault {
+			keptDefault = true
+			continue
+		}
+		sp.logger.Warn("deleting stale route", log.Fields{"dst": fmt.Sprintf("%s/%d", route.Attributes.Dst, route.DstLength), "metric": route.Attributes.Priority})
+		stale = append(stale, route)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	conn, err := sp.rawConn()
+	if err != nil {
+		return err
+	}
+	batch := make([]netlink.Message, 0, len(stale))
+	for _, route := range stale {
+		data, err := sp.deleteRouteMessage(route).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		batch = append(batch, netlink.Message{Header: netlink.Header{Type: deleteRoute}, Data: data})
+	}
+	errs := []error{}
+	for i, err := range sp.executeFIBBatch(conn, batch) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete stale route %s/%d: %w", stale[i].Attributes.Dst, stale[i].DstLength, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Метод linuxRouteIsOwned проверяет, что маршрут похож на маршруты speaker, как в linuxRouteIsMine,
+// но с любым префиксом.
+func (sp *Speaker) linuxRouteIsOwned(route *rtnetlink.RouteMessage) bool {
+	return route.Protocol == protoBgp &&
+		routeTable(route) == sp.table() &&
+		route.Family == familyAfInet &&
+		route.Type == typeUnicast &&
+		route.Scope == scopeGlobal &&
+		slices.Contains(sp.fibMetrics(sp.linuxRouteMetric), route.Attributes.Priority)
+}
+
+func (sp *Speaker) deleteRoute(route *rtnetlink.RouteMessage) error {
+	routeMessage := sp.deleteRouteMessage(route)
+	return sp.executeFIB(func() error {
+		_, err := sp.conn.Execute(routeMessage, deleteRoute, netlink.Request|netlink.Acknowledge)
+		return err
+	})
+}
+
+// Метод deleteRouteMessage возвращает сообщение удаления маршрута route из таблицы speaker.
+func (sp *Speaker) deleteRouteMessage(route *rtnetlink.RouteMessage) *rtnetlink.RouteMessage {
+	routeMessage := &rtnetlink.RouteMessage{
+		Family:    familyAfInet,
+		DstLength: route.DstLength,
+		Protocol:  protoBgp,
+		Type:      typeUnicast,
+		Attributes: rtnetlink.RouteAttributes{
+			Dst:      route.Attributes.Dst,
+			Priority: route.Attributes.Priority,
+		},
+	}
+	sp.setRouteTable(routeMessage)
+	return routeMessage
+}
@@ -0,0 +1,129 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+const (
+	// unnumberedDiscoveryTimeout это сколько ждать появления соседа на интерфейсе за одну попытку.
+	unnumberedDiscoveryTimeout = 10 * time.Second
+	// unnumberedDiscoveryInterval это как часто заново ищутся unnumbered соседи после старта.
+	unnumberedDiscoveryInterval = 10 * time.Second
+)
+
+// Метод resolveUnnumbered находит IPv6 link-local адреса соседей, заданных через interface
+// (unnumbered BGP, как "neighbor swp1 interface" в FRR), и подставляет их в конфигурацию
+// в виде fe80::1%eth1. Сессия привязывается к интерфейсу, а IPv4 маршруты принимаются
+// с next-hop IPv6 через extended next-hop.
+//
+// Если сосед на интерфейсе не найден при старте, например, uplink выключен, это пишется в лог,
+// и сосед добавится, когда появится, в [Speaker.DiscoverUnnumberedNeighbors].
+func (sp *Speaker) resolveUnnumbered(ctx context.Context) {
+	neighbors := []Neighbor{}
+	for _, n := range sp.config.Neighbors {
+		if n.Interface != "" {
+			sp.unnumberedNeighbors = append(sp.unnumberedNeighbors, n)
+		} else {
+			neighbors = append(neighbors, n)
+		}
+	}
+	sp.config.Neighbors = neighbors
+	resolved := make([]*Neighbor, len(sp.unnumberedNeighbors))
+	var wg sync.WaitGroup
+	for i, tmpl := range sp.unnumberedNeighbors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := discoverUnnumbered(ctx, tmpl)
+			if err != nil {
+				sp.logger.Warn("unnumbered neighbor is not discovered at startup, discovery continues in background", log.Fields{"interface": tmpl.Interface, "error": err.Error()})
+				return
+			}
+			resolved[i] = &n
+		}()
+	}
+	wg.Wait()
+	for _, n := range resolved {
+		if n != nil {
+			sp.logger.Info("discovered unnumbered neighbor", log.Fields{"interface": n.Interface, "neighbor": n.Address})
+			sp.config.Neighbors = append(sp.config.Neighbors, *n)
+		}
+	}
+}
+
+// DiscoverUnnumberedNeighbors раз в unnumberedDiscoveryInterval ищет соседей на интерфейсах
+// unnumbered соседей: сосед добавляется, когда на интерфейсе появляется его link-local адрес,
+// а если адрес изменился, прежний сосед заменяется новым. Если сосед не найден, текущий остается.
+func (sp *Speaker) DiscoverUnnumberedNeighbors(ctx context.Context) error {
+	ticker := time.NewTicker(unnumberedDiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			for _, tmpl := range sp.unnumberedNeighbors {
+				sp.syncUnnumberedNeighbor(ctx, tmpl)
+			}
+		}
+	}
+}
+
+// Метод syncUnnumberedNeighbor ищет соседа на интерфейсе tmpl и приводит к нему конфигурацию.
+func (sp *Speaker) syncUnnumberedNeighbor(ctx context.Context, tmpl Neighbor) {
+	current := slices.DeleteFunc(sp.neighbors(), func(n Neighbor) bool { return n.Interface != tmpl.Interface })
+	n, err := discoverUnnumbered(ctx, tmpl)
+	if err != nil {
+		if len(current) == 0 {
+			sp.logger.Debug("unnumbered neighbor is not discovered", log.Fields{"interface": tmpl.Interface, "error": err.Error()})
+		}
+		return
+	}
+	if slices.ContainsFunc(current, func(c Neighbor) bool { return c.Address == n.Address }) {
+		return
+	}
+	for _, c := range current {
+		sp.logger.Info("unnumbered neighbor address changed, removing previous neighbor", log.Fields{"interface": tmpl.Interface, "neighbor": c.Address})
+		if !sp.removeRuntimeNeighbor(ctx, c) {
+			return
+		}
+	}
+	sp.logger.Info("discovered unnumbered neighbor", log.Fields{"interface": n.Interface, "neighbor": n.Address})
+	sp.addRuntimeNeighbor(ctx, n)
+}
+
+// Функция discoverUnnumbered возвращает соседа tmpl с найденным на его интерфейсе link-local адресом.
+func discoverUnnumbered(ctx context.Context, tmpl Neighbor) (Neighbor, error) {
+	ip, err := nl.DiscoverLinkLocalNeighbor(ctx, tmpl.Interface, unnumberedDiscoveryTimeout)
+	if err != nil {
+		return tmpl, fmt.Errorf("neighbor on interface %s: %w", tmpl.Interface, err)
+	}
+	tmpl.Address = fmt.Sprintf("%s%%%s", ip, tmpl.Interface)
+	tmpl.BindInterface = tmpl.Interface
+	tmpl.ExtendedNexthop = true
+	return tmpl, nil
+}
+
+// Функция sameNeighbor сравнивает адреса соседей без учета зоны link-local адреса.
+func sameNeighbor(a, b string) bool {
+	addrA, errA := netip.ParseAddr(a)
+	addrB, errB := netip.ParseAddr(b)
+	return errA == nil && errB == nil && addrA.WithZone("") == addrB.WithZone("")
+}
+
+// Функция neighborPrefix возвращает адрес соседа как префикс хоста для defined-set.
+func neighborPrefix(address string) string {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return address + "/32"
+	}
+	return netip.PrefixFrom(addr.WithZone(""), addr.BitLen()).String()
+}
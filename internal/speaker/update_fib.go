@@ -11,6 +11,7 @@ import (
 	"github.com/mdlayher/netlink"
 	api "github.com/osrg/gobgp/v3/api"
 	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
 	"golang.org/x/exp/maps"
 )
 
@@ -26,6 +27,7 @@ const (
 	newRoute                 = 0x18
 	deleteRoute              = 0x19
 	replaceFlags             = netlink.Request | netlink.Create | netlink.Replace | netlink.Acknowledge
+	setDefaultRouteError     = "error setting default route"
 )
 
 func (sp *Speaker) UpdateFIB(ctx context.Context) error {
@@ -35,16 +37,44 @@ func (sp *Speaker) UpdateFIB(ctx context.Context) error {
 	}
 	defer c.Close()
 	sp.conn = c
+	sp.fibCtx = ctx
+	links, err := nl.StartLinkCache(ctx)
+	if err != nil {
+		return err
+	}
+	sp.links = links
+	defer func() {
+		if sp.nhConn != nil {
+			sp.nhConn.Close()
+			sp.nhConn = nil
+		}
+	}()
+	if err := sp.cleanupStaleRoutes(); err != nil {
+		sp.logger.Error("error cleaning up stale routes", log.Fields{"error": err.Error()})
+	}
+	if err := sp.startPreservedRouteGrace(); err != nil {
+		sp.logger.Error("error looking up preserved default route", log.Fields{"error": err.Error()})
+	}
 	ticker := time.NewTicker(time.Second * UpdateFIBIntervalSeconds)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			sp.logger.Info(fmt.Sprintf("stop updating FIB: %s", ctx.Err().Error()), nil)
+			if sp.handedOff.Load() {
+				sp.logger.Info("leaving kernel default route to new speaker process", nil)
+				return nil
+			}
+			if sp.config.PreserveFIBOnExit != nil {
+				sp.logger.Info("preserving kernel default route on exit", log.Fields{"grace_seconds": sp.config.PreserveFIBOnExit.GraceSeconds})
+				return nil
+			}
 			return sp.cleanupDefaultRoute()
 		case <-ticker.C:
 			if err := sp.setDefaultRoute(ctx); err != nil {
-				sp.logger.Error("error setting default route", log.Fields{"error": err.Error()})
+				sp.logs.Error(sp.logger, setDefaultRouteError, log.Fields{"error": err.Error()})
+			} else {
+				sp.logs.Resolved(sp.logger, setDefaultRouteError)
 			}
 		}
 	}
@@ -57,50 +87,97 @@ func (sp *Speaker) setDefaultRoute(ctx context.Context) error {
 			Afi:  api.Family_AFI_IP,
 			Safi: api.Family_SAFI_UNICAST,
 		},
+		Prefixes: []*api.TableLookupPrefix{
+			{Prefix: zeroPrefix, Type: api.TableLookupPrefix_EXACT},
+		},
 	}
-	defaultRoutes := []*api.Destination{}
-	filterDefaultRoutes := func(d *api.Destination) {
-		if d.Prefix == zeroPrefix {
-			defaultRoutes = append(defaultRoutes, d)
-		}
-	}
-	if err := sp.s.ListPath(ctx, &req, filterDefaultRoutes); err != nil {
-		return fmt.Errorf("bgp list path error: %w", err)
+	isDefaultRoute := func(d *api.Destination) bool {
+		return d.Prefix == zeroPrefix
 	}
-	if len(defaultRoutes) == 0 {
-		return nil
+	// Достаточно двух destination, чтобы понять, что default route не один.
+	defaultRoutes, err := sp.findDestinations(ctx, &req, isDefaultRoute, 2)
+	if err != nil {
+		sp.fibProgrammed.Store(false)
+		return err
 	}
 	if len(defaultRoutes) > 1 {
+		sp.fibProgrammed.Store(false)
 		return fmt.Errorf("unexpeted number of default routes: %w", errors.ErrUnsupported)
 	}
-	defaultRoute := defaultRoutes[0]
-	if len(defaultRoute.Paths) == 1 {
-		return sp.setSinglePathRoute(defaultRoute.Paths[0])
+	paths := []*api.Path{}
+	if len(defaultRoutes) == 1 {
+		paths = defaultRoutes[0].Paths
+	}
+	// Если ни один next-hop не разрешается, маршрут обрабатывается так же, как пропавший из RIB.
+	paths = sp.trackNextHops(sp.dampenPaths(paths))
+	if len(paths) == 0 {
+		sp.fibProgrammed.Store(false)
+		return sp.holdDefaultRoute()
 	}
-	return sp.setMultiPathRoute(defaultRoute.Paths)
+	sp.preservedRouteDeadline = time.Time{}
+	if !sp.defaultRouteLostAt.IsZero() {
+		sp.logger.Info("default route is back in rib", log.Fields{"held": time.Since(sp.defaultRouteLostAt).String()})
+		sp.defaultRouteLostAt = time.Time{}
+	}
+	paths = sp.limitECMPPaths(sp.preferPaths(paths))
+	err = sp.reconcileDefaultRoute(paths, func() error {
+		if len(paths) == 1 {
+			return sp.setSinglePathRoute(paths[0])
+		}
+		return sp.setMultiPathRoute(paths)
+	})
+	sp.fibProgrammed.Store(err == nil)
+	return err
 }
 
 func (sp *Speaker) cleanupDefaultRoute() error {
+	sp.fibDesired = ""
+	sp.viaRoute = ""
+	oldDefaultRoute, err := sp.getLinuxBGPDefaultRoute()
+	if err != nil {
+		return fmt.Errorf("cleanupDefaultRoute: failed to lookup default route: %w", err)
+	}
+	if oldDefaultRoute != nil {
+		if err := sp.deleteDefaultRoute(oldDefaultRoute.Attributes.Priority); err != nil {
+			return fmt.Errorf("bgp default route cleanup from linux failed: %w", err)
+		}
+	}
+	return sp.cleanupNexthopGroup()
+}
+
+// Метод deleteDefaultRoute удаляет из ядра маршрут по-умолчанию speaker с priority metric.
+func (sp *Speaker) deleteDefaultRoute(metric uint32) error {
 	routeMessage := &rtnetlink.RouteMessage{
 		Family:   familyAfInet,
-		Table:    rtTableMain,
 		Protocol: protoBgp,
 		Type:     typeUnicast,
 		Attributes: rtnetlink.RouteAttributes{
-			Priority: sp.linuxRouteMetric,
+			Priority: metric,
 		},
 	}
-	oldDefaultRoute, err := sp.getLinuxBGPDefaultRoute()
-	if err != nil {
-		return fmt.Errorf("cleanupDefaultRoute: failed to lookup default route: %w", err)
+	sp.setRouteTable(routeMessage)
+	return sp.executeFIB(func() error {
+		_, err := sp.conn.Execute(routeMessage, deleteRoute, netlink.Request|netlink.Acknowledge)
+		return err
+	})
+}
+
+// Метод replaceDefaultRoute ставит маршрут routeMessage в ядро и, если metric изменился,
+// удаляет старый маршрут oldDefaultRoute: priority входит в ключ маршрута, поэтому
+// replace с другим metric добавляет второй маршрут.
+func (sp *Speaker) replaceDefaultRoute(routeMessage, oldDefaultRoute *rtnetlink.RouteMessage) error {
+	sp.fibWrites++
+	if err := sp.executeFIB(func() error {
+		_, err := sp.conn.Execute(routeMessage, newRoute, replaceFlags)
+		return err
+	}); err != nil {
+		return err
 	}
-	if oldDefaultRoute != nil {
-		_, err = sp.conn.Execute(routeMessage, deleteRoute, netlink.Request|netlink.Acknowledge)
-		if err != nil {
-			return fmt.Errorf("bgp default route cleanup from linux failed: %w", err)
-		}
+	if oldDefaultRoute == nil || oldDefaultRoute.Attributes.Priority == routeMessage.Attributes.Priority {
+		return nil
 	}
-	return nil
+	sp.logger.Info("default route metric changed, removing old route", log.Fields{"old": oldDefaultRoute.Attributes.Priority, "new": routeMessage.Attributes.Priority})
+	return sp.deleteDefaultRoute(oldDefaultRoute.Attributes.Priority)
 }
 
 func (sp *Speaker) setSinglePathRoute(path *api.Path) error {
@@ -112,46 +189,111 @@ func (sp *Speaker) setSinglePathRoute(path *api.Path) error {
 	if err != nil {
 		return fmt.Errorf("setSinglePathRoute: failed to lookup default route: %w", err)
 	}
-	if oldDefaultRoute != nil &&
-		oldDefaultRoute.Attributes.Gateway.String() == newGateway {
-		return nil
-	}
 	gateway := net.ParseIP(newGateway)
+	if gateway == nil {
+		return fmt.Errorf("gateway %q is not ip address: %w", newGateway, errors.ErrUnsupported)
+	}
+	hop, err := sp.onlinkHop(path)
+	if err != nil {
+		return fmt.Errorf("setSinglePathRoute: %w", err)
+	}
+	hop, err = sp.viaHop(sp.pathNeighbor(path), gateway, hop)
+	if err != nil {
+		return fmt.Errorf("setSinglePathRoute: %w", err)
+	}
+	mtu, err := sp.routeMTU([]rtnetlink.NextHop{{Hop: hop, Gateway: gateway}})
+	if err != nil {
+		return fmt.Errorf("setSinglePathRoute: %w", err)
+	}
+	metric := sp.pathFIBMetric([]*api.Path{path})
 	if gateway.To4() == nil {
-		return fmt.Errorf("gateway is not ipv4: %w", errors.ErrUnsupported)
+		return sp.setViaRoute([]rtnetlink.NextHop{{Hop: hop, Gateway: gateway}}, metric, mtu, oldDefaultRoute)
+	}
+	if oldDefaultRoute != nil &&
+		oldDefaultRoute.Attributes.Gateway.String() == newGateway &&
+		onlinkHopEqual(rtnetlink.RTNextHop{Flags: uint8(oldDefaultRoute.Flags), IfIndex: oldDefaultRoute.Attributes.OutIface}, hop) &&
+		oldDefaultRoute.Attributes.Priority == metric &&
+		linuxRouteMTU(oldDefaultRoute) == mtu &&
+		linuxRouteSrc(oldDefaultRoute).Equal(sp.routeSrc()) {
+		return nil
 	}
 	routeMessage := &rtnetlink.RouteMessage{
 		Family:   familyAfInet,
-		Table:    rtTableMain,
 		Protocol: protoBgp,
 		Type:     typeUnicast,
+		Flags:    uint32(hop.Flags),
 		Attributes: rtnetlink.RouteAttributes{
 			Gateway:  gateway,
-			Priority: sp.linuxRouteMetric,
+			OutIface: hop.IfIndex,
+			Src:      sp.routeSrc(),
+			Priority: metric,
+			Metrics:  routeMetrics(mtu),
 		},
 	}
-	sp.logger.Info("setting linux single path default route", log.Fields{"dst": newGateway})
-	_, err = sp.conn.Execute(routeMessage, newRoute, replaceFlags)
-	return err
+	sp.setRouteTable(routeMessage)
+	sp.logger.Info("setting linux single path default route", log.Fields{"dst": newGateway, "mtu": mtu, "metric": metric})
+	if err := sp.replaceDefaultRoute(routeMessage, oldDefaultRoute); err != nil {
+		return err
+	}
+	sp.viaRoute = ""
+	return sp.cleanupNexthopGroup()
 }
 
 func (sp *Speaker) setMultiPathRoute(paths []*api.Path) error {
-	newNextHops := map[string]struct{}{}
+	// Для каждого next-hop хранится rtnh_hops, то есть вес минус один, и для onlink next-hop флаги и интерфейс.
+	newNextHops := map[string]rtnetlink.RTNextHop{}
 	for _, path := range paths {
 		nextHop, err := nextHop(path)
 		if err != nil {
 			return fmt.Errorf("failed to retrieve gateway: %w", err)
 		}
-		newNextHops[nextHop] = struct{}{}
+		hop, err := sp.onlinkHop(path)
+		if err != nil {
+			return fmt.Errorf("setMultiPathRoute: %w", err)
+		}
+		hop, err = sp.viaHop(sp.pathNeighbor(path), net.ParseIP(nextHop), hop)
+		if err != nil {
+			return fmt.Errorf("setMultiPathRoute: %w", err)
+		}
+		hop.Hops = weightHops(sp.nextHopWeight(path, nextHop))
+		newNextHops[nextHop] = hop
 	}
 	oldDefaultRoute, err := sp.getLinuxBGPDefaultRoute()
 	if err != nil {
 		return fmt.Errorf("setMultiPathRoute: failed to lookup default route: %w", err)
 	}
-	if oldDefaultRoute != nil && oldDefaultRoute.Attributes.Multipath != nil && len(oldDefaultRoute.Attributes.Multipath) == len(newNextHops) {
+	nextHops := []rtnetlink.NextHop{}
+	via := false
+	for gw, hop := range newNextHops {
+		gateway := net.ParseIP(gw)
+		if gateway == nil {
+			return fmt.Errorf("gateway %q is not ip address: %w", gw, errors.ErrUnsupported)
+		}
+		via = via || gateway.To4() == nil
+		nextHops = append(nextHops, rtnetlink.NextHop{
+			Hop:     hop,
+			Gateway: gateway,
+		})
+	}
+	mtu, err := sp.routeMTU(nextHops)
+	if err != nil {
+		return fmt.Errorf("setMultiPathRoute: %w", err)
+	}
+	metric := sp.pathFIBMetric(paths)
+	if via {
+		return sp.setViaRoute(nextHops, metric, mtu, oldDefaultRoute)
+	}
+	if sp.nexthopGroupsEnabled() {
+		if err := sp.setNexthopGroupRoute(nextHops, metric, mtu, oldDefaultRoute); !errors.Is(err, errNexthopGroupsUnsupported) {
+			return err
+		}
+	}
+	if oldDefaultRoute != nil && oldDefaultRoute.Attributes.Multipath != nil && len(oldDefaultRoute.Attributes.Multipath) == len(newNextHops) &&
+		oldDefaultRoute.Attributes.Priority == metric && linuxRouteMTU(oldDefaultRoute) == mtu &&
+		linuxRouteSrc(oldDefaultRoute).Equal(sp.routeSrc()) {
 		routesAreEqual := true
 		for _, oldNextHop := range oldDefaultRoute.Attributes.Multipath {
-			if _, ok := newNextHops[oldNextHop.Gateway.String()]; !ok {
+			if hop, ok := newNextHops[oldNextHop.Gateway.String()]; !ok || hop.Hops != oldNextHop.Hop.Hops || !onlinkHopEqual(oldNextHop.Hop, hop) {
 				routesAreEqual = false
 			}
 		}
@@ -159,29 +301,24 @@ func (sp *Speaker) setMultiPathRoute(paths []*api.Path) error {
 			return nil
 		}
 	}
-	nextHops := []rtnetlink.NextHop{}
-	for gw := range newNextHops {
-		gateway := net.ParseIP(gw)
-		if gateway.To4() == nil {
-			return fmt.Errorf("gateway is not ipv4: %w", errors.ErrUnsupported)
-		}
-		nextHops = append(nextHops, rtnetlink.NextHop{
-			Gateway: gateway,
-		})
-	}
-	sp.logger.Info("setting linux multi path default route", log.Fields{"dst": maps.Keys(newNextHops)})
+	sp.logger.Info("setting linux multi path default route", log.Fields{"dst": maps.Keys(newNextHops), "mtu": mtu, "metric": metric})
 	routeMessage := &rtnetlink.RouteMessage{
 		Family:   familyAfInet,
-		Table:    rtTableMain,
 		Protocol: protoBgp,
 		Type:     typeUnicast,
 		Attributes: rtnetlink.RouteAttributes{
-			Priority:  sp.linuxRouteMetric,
+			Src:       sp.routeSrc(),
+			Priority:  metric,
 			Multipath: nextHops,
+			Metrics:   routeMetrics(mtu),
 		},
 	}
-	_, err = sp.conn.Execute(routeMessage, newRoute, replaceFlags)
-	return err
+	sp.setRouteTable(routeMessage)
+	if err := sp.replaceDefaultRoute(routeMessage, oldDefaultRoute); err != nil {
+		return err
+	}
+	sp.viaRoute = ""
+	return nil
 }
 
 func (sp *Speaker) getLinuxBGPDefaultRoute() (*rtnetlink.RouteMessage, error) {
@@ -202,17 +339,14 @@ func (sp *Speaker) getLinuxBGPDefaultRoute() (*rtnetlink.RouteMessage, error) {
 }
 
 func (sp *Speaker) linuxRouteIsMine(route *rtnetlink.RouteMessage) bool {
-	return route.Protocol == protoBgp &&
-		route.DstLength == 0 &&
-		route.Table == rtTableMain &&
-		route.Family == familyAfInet &&
-		route.Type == typeUnicast &&
-		route.Scope == scopeGlobal &&
-		route.Attributes.Priority == sp.linuxRouteMetric
+	return route.DstLength == 0 && sp.linuxRouteIsOwned(route)
 }
 
+// Функция nextHop возвращает next-hop path: из NEXT_HOP или, для маршрутов IPv4, полученных
+// с next-hop IPv6 (RFC 5549), из MP_REACH_NLRI, где глобальный адрес предпочтительнее link-local.
 func nextHop(path *api.Path) (string, error) {
 	nextHopAttr := new(api.NextHopAttribute)
+	mpReachAttr := new(api.MpReachNLRIAttribute)
 	for _, attr := range path.Pattrs {
 		if attr.MessageIs(nextHopAttr) {
 			if err := attr.UnmarshalTo(nextHopAttr); err != nil {
@@ -220,6 +354,30 @@ func nextHop(path *api.Path) (string, error) {
 			}
 			return nextHopAttr.NextHop, nil
 		}
+		if attr.MessageIs(mpReachAttr) {
+			if err := attr.UnmarshalTo(mpReachAttr); err != nil {
+				return "", err
+			}
+			return mpReachNextHop(mpReachAttr.NextHops)
+		}
 	}
 	return "", fmt.Errorf("faild to extract next hop from gobgp api.Path")
 }
+
+func mpReachNextHop(nextHops []string) (string, error) {
+	linkLocal := ""
+	for _, nh := range nextHops {
+		ip := net.ParseIP(nh)
+		switch {
+		case ip == nil || ip.IsUnspecified():
+		case ip.IsLinkLocalUnicast():
+			linkLocal = nh
+		default:
+			return nh, nil
+		}
+	}
+	if linkLocal == "" {
+		return "", fmt.Errorf("faild to extract next hop from gobgp api.Path")
+	}
+	return linkLocal, nil
+}
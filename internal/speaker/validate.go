@@ -0,0 +1,401 @@
+package speaker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"gopkg.in/yaml.v3"
+)
+
+// hostnameRegexp это имя хоста из меток RFC 1123. Имя только из цифр и точек не принимается,
+// чтобы опечатка в ip адресе не считалась именем хоста.
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.?$`)
+
+// maxAllowASIn это максимальный allowas_in, как в большинстве реализаций BGP.
+const maxAllowASIn = 10
+
+// ValidateConfigFile строго разбирает конфигурацию и проверяет ее, не запуская BGP
+// и не обращаясь к netlink, см. [LoadConfig].
+func ValidateConfigFile(path string) error {
+	_, err := LoadConfig(path)
+	return err
+}
+
+// LoadConfig строго разбирает конфигурацию (неизвестные ключи считаются ошибкой),
+// подставляет значения по-умолчанию и проверяет ее.
+//
+// Возвращается сразу список всех найденных ошибок, объединенный через [errors.Join].
+func LoadConfig(path string) (Config, error) {
+	configBytes, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(configBytes)
+}
+
+// ParseConfig разбирает и проверяет конфигурацию из configBytes так же, как [LoadConfig].
+func ParseConfig(configBytes []byte) (Config, error) {
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(configBytes))
+	decoder.KnownFields(true)
+	errs := []error{}
+	// При ошибках типов yaml.v3 разбирает остальные поля, поэтому проверку можно продолжить.
+	var typeErr *yaml.TypeError
+	if err := decoder.Decode(&config); errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			errs = append(errs, errors.New(e))
+		}
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, err
+	}
+	if err := config.importGoBGPConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := config.applyDefaults(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(append(errs, config.validate())...); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Метод validate проверяет синтаксис адресов, ASN и URL и непротиворечивость настроек.
+func (c *Config) validate() error {
+	errs := []error{}
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if c.AnycastIP == "" {
+		add("anycast_ip: is required")
+	} else if ip := net.ParseIP(c.AnycastIP); ip == nil || ip.To4() == nil {
+		add("anycast_ip: %q is not a valid ipv4 address", c.AnycastIP)
+	}
+	if c.NextHop != "" {
+		if ip := net.ParseIP(c.NextHop); ip == nil || ip.To4() == nil {
+			add("next_hop: %q is not a valid ipv4 address", c.NextHop)
+		}
+	}
+	if c.FIBSrc != "" {
+		if ip := net.ParseIP(c.FIBSrc); ip == nil || ip.To4() == nil {
+			add("fib_src: %q is not a valid ipv4 address", c.FIBSrc)
+		}
+	}
+	if c.VRF != nil && c.VRF.Name == "" {
+		add("vrf.name: is required")
+	}
+	if c.AnycastInterface != nil && c.AnycastInterface.Name == "" {
+		add("anycast_interface.name: is required")
+	}
+	if c.ASN == 0 {
+		add("asn: is required and must be greater than 0")
+	}
+	if len(c.Neighbors) == 0 && c.LLDPDiscovery == nil {
+		add("neighbors: at least one neighbor is required")
+	}
+	seen := map[string]int{}
+	for i, n := range c.Neighbors {
+		field := fmt.Sprintf("neighbors[%d]", i)
+		if n.Interface != "" {
+			if n.Address != "" {
+				add("%s.interface: can not be used together with address", field)
+			} else if j, ok := seen[n.Interface]; ok {
+				add("%s.interface: %s duplicates neighbors[%d]", field, n.Interface, j)
+			} else {
+				seen[n.Interface] = i
+			}
+		} else if n.SRV != "" {
+			if n.Address != "" {
+				add("%s.srv: can not be used together with address", field)
+			} else if j, ok := seen[n.SRV]; ok {
+				add("%s.srv: %s duplicates neighbors[%d]", field, n.SRV, j)
+			} else {
+				seen[n.SRV] = i
+			}
+		} else if n.Address != "" && net.ParseIP(n.Address) == nil {
+			if !hostnameRegexp.MatchString(n.Address) || strings.Trim(n.Address, "0123456789.") == "" {
+				add("%s.address: %q is not a valid ip address or hostname", field, n.Address)
+			} else if j, ok := seen[n.Address]; ok {
+				add("%s.address: %s duplicates neighbors[%d]", field, n.Address, j)
+			} else {
+				seen[n.Address] = i
+			}
+		} else if net.ParseIP(n.Address) == nil {
+			add("%s.address: %q is not a valid ip address", field, n.Address)
+		} else if j, ok := seen[net.ParseIP(n.Address).String()]; ok {
+			add("%s.address: %s duplicates neighbors[%d]", field, n.Address, j)
+		} else {
+			seen[net.ParseIP(n.Address).String()] = i
+		}
+		if n.ASN == 0 {
+			add("%s.asn: is required and must be greater than 0", field)
+		}
+		if n.LocalAddress != "" && net.ParseIP(n.LocalAddress) == nil {
+			add("%s.local_address: %q is not a valid ip address", field, n.LocalAddress)
+		}
+		if n.NextHopSelf && !c.strictPolicySets() {
+			add("%s.next_hop_self: requires policy_mode: %s or policies", field, PolicyModeStrict)
+		}
+		if _, ok := c.fibMetric(zeroPrefix); n.FIBMetric != nil && !ok {
+			add("%s.fib_metric: requires update_fib_metric or fib_metrics for %s", field, zeroPrefix)
+		}
+		if n.AllowASIn > maxAllowASIn {
+			add("%s.allowas_in: must be between 0 and %d", field, maxAllowASIn)
+		}
+		if ip := net.ParseIP(n.Address); n.ExtendedNexthop && ip != nil && ip.To4() != nil {
+			add("%s.extended_nexthop: requires ipv6 neighbor address", field)
+		}
+		if n.LocalAS != nil {
+			if err := n.LocalAS.validate(field); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		switch n.RemovePrivateAS {
+		case "", RemovePrivateASAll, RemovePrivateASReplace:
+		default:
+			add("%s.remove_private_as: %q is not supported, use %s or %s", field, n.RemovePrivateAS, RemovePrivateASAll, RemovePrivateASReplace)
+		}
+		if n.ASOverride && n.ASN == c.localASN(n) {
+			add("%s.as_override: requires ebgp neighbor", field)
+		}
+		if n.Weight > maxECMPWeight {
+			add("%s.weight: must be between 1 and %d", field, maxECMPWeight)
+		}
+		if n.MaxPrefixes != nil {
+			if n.MaxPrefixes.Limit == 0 {
+				add("%s.max_prefixes.limit: must be greater than 0", field)
+			}
+			if n.MaxPrefixes.WarningThresholdPct > 100 {
+				add("%s.max_prefixes.warning_threshold_pct: must be between 0 and 100", field)
+			}
+		}
+	}
+	gateways := maps.Keys(c.NextHopWeights)
+	slices.Sort(gateways)
+	for _, gw := range gateways {
+		if net.ParseIP(gw) == nil {
+			add("next_hop_weights: %q is not a valid ip address", gw)
+		}
+		if w := c.NextHopWeights[gw]; w == 0 || w > maxECMPWeight {
+			add("next_hop_weights.%s: must be between 1 and %d", gw, maxECMPWeight)
+		}
+	}
+	if c.HealthCheckURL != "" {
+		if err := validateHealthCheckURL(c.HealthCheckURL); err != nil {
+			add("health_check_url: %w", err)
+		}
+	}
+	if c.HealthCheck != nil {
+		switch strings.ToUpper(c.HealthCheck.Method) {
+		case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut:
+		default:
+			add("health_check.method: %q is not supported, use GET, HEAD, POST or PUT", c.HealthCheck.Method)
+		}
+		if c.HealthCheck.Body != "" && (c.HealthCheck.Method == "" || strings.EqualFold(c.HealthCheck.Method, http.MethodGet) || strings.EqualFold(c.HealthCheck.Method, http.MethodHead)) {
+			add("health_check.body: requires method POST or PUT")
+		}
+		if _, err := regexp.Compile(c.HealthCheck.ExpectBodyRegex); err != nil {
+			add("health_check.expect_body_regex: %w", err)
+		}
+		if c.HealthCheck.ExpectBodyRegex != "" && strings.EqualFold(c.HealthCheck.Method, http.MethodHead) {
+			add("health_check.expect_body_regex: responses to HEAD have no body")
+		}
+	}
+	if c.HealthCheck != nil && c.HealthCheck.TLS != nil {
+		if t := c.HealthCheck.TLS; (t.CertFile == "") != (t.KeyFile == "") {
+			add("health_check.tls: cert_file and key_file must be set together")
+		}
+	}
+	if c.HealthSource == HealthSourceConsul && (c.Consul == nil || c.Consul.Service == "") {
+		add("consul.service: is required for health_source: consul")
+	}
+	if c.HealthSource == HealthSourceGRPC && (c.GRPCHealthCheck == nil || c.GRPCHealthCheck.Address == "") {
+		add("grpc_health_check.address: is required for health_source: grpc")
+	}
+	if c.GRPCHealthCheck != nil && c.GRPCHealthCheck.Address != "" {
+		if _, _, err := net.SplitHostPort(c.GRPCHealthCheck.Address); err != nil {
+			add("grpc_health_check.address: %w", err)
+		}
+	}
+	if c.HealthSource == HealthSourceICMP && c.ICMPHealthCheck == nil {
+		add("icmp_health_check.address: is required for health_source: icmp")
+	}
+	if c.ICMPHealthCheck != nil {
+		if ip := net.ParseIP(c.ICMPHealthCheck.Address); ip == nil || ip.To4() == nil {
+			add("icmp_health_check.address: %q is not a valid ipv4 address", c.ICMPHealthCheck.Address)
+		}
+	}
+	if c.HealthSource == HealthSourceFile && (c.FileHealthCheck == nil || c.FileHealthCheck.Path == "") {
+		add("file_health_check.path: is required for health_source: file")
+	}
+	if c.Consul != nil && c.Consul.Address != "" {
+		if err := validateHTTPURL(c.Consul.Address); err != nil {
+			add("consul.address: %w", err)
+		}
+	}
+	if c.RemoteGoBGP != nil {
+		if _, _, err := net.SplitHostPort(c.RemoteGoBGP.Address); err != nil {
+			add("remote_gobgp.address: %w", err)
+		}
+		if t := c.RemoteGoBGP.TLS; t != nil && (t.CertFile == "") != (t.KeyFile == "") {
+			add("remote_gobgp.tls: cert_file and key_file must be set together")
+		}
+	}
+	if c.Syslog != nil {
+		switch c.LogOutput {
+		case LogOutputSyslog:
+			if err := c.Syslog.validate(); err != nil {
+				errs = append(errs, err)
+			}
+		case LogOutputJournald:
+			if c.Syslog.Address != "" {
+				add("syslog.address: is not used with log_output: %s", LogOutputJournald)
+			}
+		default:
+			add("syslog: requires log_output: %s or %s", LogOutputSyslog, LogOutputJournald)
+		}
+	}
+	for _, addr := range [][2]string{{"admin_address", c.AdminAddress}, {"probe_address", c.ProbeAddress}} {
+		if addr[1] == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr[1]); err != nil {
+			add("%s: %w", addr[0], err)
+		}
+	}
+	if c.Verification != nil {
+		for i, u := range c.Verification.URLs {
+			if err := validateHTTPURL(u); err != nil {
+				add("verification.urls[%d]: %w", i, err)
+			}
+		}
+	}
+	if c.Zebra != nil && c.FIBBackend != FIBBackendZebra {
+		add("zebra: requires fib_backend: %s", FIBBackendZebra)
+	}
+	if !c.fibManaged() && c.FIBBackend == FIBBackendZebra {
+		add("fib_backend: %s requires manage_fib: true", FIBBackendZebra)
+	}
+	if c.RPKI != nil {
+		if len(c.RPKI.Caches) == 0 {
+			add("rpki.caches: at least one cache is required")
+		}
+		for i, cache := range c.RPKI.Caches {
+			if _, _, err := splitRPKICache(cache); err != nil {
+				add("rpki.caches[%d]: %w", i, err)
+			}
+		}
+	}
+	if c.LLDPDiscovery != nil {
+		if err := c.LLDPDiscovery.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.PeerFlapDampening != nil {
+		if err := c.PeerFlapDampening.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.PeerEvents != nil {
+		if err := c.PeerEvents.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.AuditLog != nil {
+		if err := c.AuditLog.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.MessageCapture != nil {
+		if err := c.MessageCapture.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.RouteFlapDampening != nil {
+		if err := c.RouteFlapDampening.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.PreserveFIBOnExit != nil && c.FIBConflictMode == FIBConflictModeStrict {
+		add("preserve_fib_on_exit: requires fib_conflict_mode: %s to adopt the preserved route", FIBConflictModeAdopt)
+	}
+	if c.Handoff != nil {
+		if err := c.Handoff.validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if c.FIBConflictMode == FIBConflictModeStrict {
+			add("handoff: requires fib_conflict_mode: %s to adopt the route of previous process", FIBConflictModeAdopt)
+		}
+		if c.RemoteGoBGP != nil {
+			add("handoff: is not supported with remote_gobgp")
+		}
+	}
+	if c.FIBPreference != nil {
+		if err := c.FIBPreference.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := c.validateListener(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validateFIBMetrics(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.validatePrefixes(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Hooks != nil {
+		if _, err := compileHooks(c.Hooks); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Policies != nil {
+		if err := c.validatePolicies(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.DefaultAction != nil && !c.strictPolicySets() {
+		add("default_action: requires policy_mode: %s or policies", PolicyModeStrict)
+	}
+	if c.PolicyMode == PolicyModeCustom && c.customPolicies == nil && c.Policies == nil {
+		add("policy_mode: custom requires policies or policy-definitions in gobgp_config")
+	}
+	return errors.Join(errs...)
+}
+
+// Функция validateHealthCheckURL дополнительно к http и https принимает unix socket.
+func validateHealthCheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == unixScheme {
+		_, _, err := parseUnixSocketURL(u)
+		return err
+	}
+	return validateHTTPURL(rawURL)
+}
+
+func validateHTTPURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use http or https scheme", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/log"
+)
+
+const (
+	defaultVerificationDelaySeconds    = 5
+	defaultVerificationIntervalSeconds = 10
+	defaultVerificationTimeoutSeconds  = 3
+	defaultVerificationFailures        = 3
+	verificationTargetParam            = "target"
+)
+
+// Verification задает внешние точки проверки (vantage points), через которые после анонса
+// проверяется, что anycast ip действительно доступен снаружи.
+//
+// Каждый URL запрашивается методом GET с параметром target=<anycast_ip>; ответ 200 означает,
+// что точка проверки видит anycast ip, любой другой ответ или ошибка означает, что не видит.
+type Verification struct {
+	URLs []string `yaml:"urls"`
+	// DelaySeconds это время на распространение анонса до первой проверки, по-умолчанию 5.
+	DelaySeconds    uint32 `yaml:"delay_seconds"`
+	IntervalSeconds uint32 `yaml:"interval_seconds"`
+	TimeoutSeconds  uint32 `yaml:"timeout_seconds"`
+	// MinReachable это сколько точек проверки должны видеть anycast ip, по-умолчанию все.
+	MinReachable uint32 `yaml:"min_reachable"`
+	// FailureThreshold это число неудачных проверок подряд, после которого
+	// anycast ip считается недоступным, по-умолчанию 3.
+	FailureThreshold uint32 `yaml:"failure_threshold"`
+	// WithdrawOnFailure включает режим обслуживания, если anycast ip недоступен снаружи.
+	// Выключить режим обслуживания нужно вручную после разбора причины.
+	WithdrawOnFailure bool `yaml:"withdraw_on_failure"`
+}
+
+// Метод RunVerification проверяет доступность анонсированного anycast ip снаружи.
+func (sp *Speaker) RunVerification(ctx context.Context) error {
+	v := *sp.config.Verification
+	if v.DelaySeconds == 0 {
+		v.DelaySeconds = defaultVerificationDelaySeconds
+	}
+	if v.IntervalSeconds == 0 {
+		v.IntervalSeconds = defaultVerificationIntervalSeconds
+	}
+	if v.TimeoutSeconds == 0 {
+		v.TimeoutSeconds = defaultVerificationTimeoutSeconds
+	}
+	if v.MinReachable == 0 || int(v.MinReachable) > len(v.URLs) {
+		v.MinReachable = uint32(len(v.URLs))
+	}
+	if v.FailureThreshold == 0 {
+		v.FailureThreshold = defaultVerificationFailures
+	}
+	client := &http.Client{Timeout: time.Second * time.Duration(v.TimeoutSeconds)}
+	delay := time.Second * time.Duration(v.DelaySeconds)
+	ticker := time.NewTicker(time.Second * time.Duration(v.IntervalSeconds))
+	defer ticker.Stop()
+	failures := uint32(0)
+	unreachable := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		// Резервный анонс в режиме soft_fail не обязан быть доступен снаружи.
+		if !sp.advertised.Load() || sp.degraded.Load() || time.Since(time.Unix(0, sp.announcedAt.Load())) < delay {
+			// Результат неудачной проверки сохраняется, чтобы после отзыва он был виден в статусе.
+			if !unreachable {
+				sp.verified.Store(nil)
+			}
+			failures = 0
+			continue
+		}
+		reachable, errs := sp.verify(ctx, client, v.URLs)
+		if reachable >= int(v.MinReachable) {
+			if unreachable {
+				sp.logger.Info("anycast ip is reachable from outside again", log.Fields{"reachable": reachable})
+			}
+			failures = 0
+			unreachable = false
+			ok := true
+			sp.verified.Store(&ok)
+			continue
+		}
+		failures++
+		sp.logger.Warn("anycast ip verification failed", log.Fields{"reachable": reachable, "min_reachable": v.MinReachable, "failures": failures, "errors": errs})
+		if failures != v.FailureThreshold {
+			continue
+		}
+		unreachable = true
+		ok := false
+		sp.verified.Store(&ok)
+		sp.logger.Error("anycast ip is announced but not reachable from outside", log.Fields{"anycast_ip": sp.config.AnycastIP})
+		if v.WithdrawOnFailure {
+			if err := sp.SetMaintenance(ctx, true); err != nil {
+				sp.logger.Error("failed to withdraw unreachable anycast ip", log.Fields{"error": err.Error()})
+			}
+		}
+	}
+}
+
+// Метод verify параллельно опрашивает точки проверки и возвращает, сколько из них видят anycast ip.
+func (sp *Speaker) verify(ctx context.Context, client *http.Client, urls []string) (int, []string) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		reachable int
+		errs      []string
+	)
+	for _, rawURL := range urls {
+		wg.Add(1)
+		go func(rawURL string) {
+			defer wg.Done()
+			err := sp.verifyFrom(ctx, client, rawURL)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err.Error())
+				return
+			}
+			reachable++
+		}(rawURL)
+	}
+	wg.Wait()
+	return reachable, errs
+}
+
+func (sp *Speaker) verifyFrom(ctx context.Context, client *http.Client, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%s: parse url error: %w", rawURL, err)
+	}
+	q := u.Query()
+	q.Set(verificationTargetParam, sp.config.AnycastIP)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status code: %d", u.Host, resp.StatusCode)
+	}
+	return nil
+}
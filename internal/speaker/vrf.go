@@ -0,0 +1,70 @@
+package speaker
+
+import (
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/osrg/gobgp/v3/pkg/log"
+	nl "github.com/sir-sukhov/bgp-speaker/internal/netlink"
+)
+
+// rtTableCompat это RT_TABLE_COMPAT: ядро пишет его в rtm_table для таблиц с номером больше 255,
+// сам номер таблицы передается в RTA_TABLE.
+const rtTableCompat = 252
+
+// VRF задает устройство VRF, в таблицу которого speaker ставит маршрут по-умолчанию.
+type VRF struct {
+	Name string `yaml:"name"`
+	// BindSessions привязывает к VRF сессии BGP соседей, у которых не задан bind_interface.
+	BindSessions bool `yaml:"bind_sessions"`
+}
+
+// Метод resolveVRF находит таблицу VRF из опции vrf. Без vrf маршруты ставятся в таблицу main.
+func (sp *Speaker) resolveVRF() error {
+	sp.fibTable = 0
+	sp.vrfIndex = 0
+	if sp.config.VRF == nil {
+		return nil
+	}
+	vrf, err := nl.LookupVRF(sp.config.VRF.Name)
+	if err != nil {
+		return err
+	}
+	sp.logger.Info("installing routes into vrf", log.Fields{"vrf": sp.config.VRF.Name, "table": vrf.Table})
+	sp.fibTable = vrf.Table
+	sp.vrfIndex = vrf.Index
+	return nil
+}
+
+// Метод table возвращает таблицу, в которую speaker ставит маршруты.
+func (sp *Speaker) table() uint32 {
+	if sp.fibTable == 0 {
+		return rtTableMain
+	}
+	return sp.fibTable
+}
+
+// Метод setRouteTable задает таблицу маршрута: rtm_table вмещает только номера до 255.
+func (sp *Speaker) setRouteTable(route *rtnetlink.RouteMessage) {
+	table := sp.table()
+	if table > 255 {
+		route.Table = rtTableCompat
+	} else {
+		route.Table = uint8(table)
+	}
+	route.Attributes.Table = table
+}
+
+// Функция routeTable возвращает номер таблицы маршрута, полученного от ядра.
+func routeTable(route *rtnetlink.RouteMessage) uint32 {
+	if route.Attributes.Table != 0 {
+		return route.Attributes.Table
+	}
+	return uint32(route.Table)
+}
+
+// Метод neighborBindInterface возвращает интерфейс, к которому привязывается сессия соседа n.
+func (sp *Speaker) neighborBindInterface(n Neighbor) string {
+	if n.BindInterface == "" && sp.config.VRF != nil && sp.config.VRF.BindSessions {
+		return sp.config.VRF.Name
+	}
+	return n.BindInterface
+}
@@ -0,0 +1,69 @@
+// Package version содержит сведения о сборке, которые задаются через -ldflags, например:
+//
+//	go build -ldflags "-X github.com/sir-sukhov/bgp-speaker/internal/version.Version=1.2.3 \
+//	  -X github.com/sir-sukhov/bgp-speaker/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/sir-sukhov/bgp-speaker/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Если Commit и BuildDate не заданы, они берутся из сведений о VCS, которые go build
+// встраивает при сборке из git репозитория, и BuildDate тогда это время commit.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const gobgpModule = "github.com/osrg/gobgp/v3"
+
+var (
+	// Version это версия в формате semver.
+	Version = "dev"
+	// Commit это git commit, из которого собран бинарник.
+	Commit = ""
+	// BuildDate это время сборки в формате RFC 3339.
+	BuildDate = ""
+)
+
+// Info это сведения о сборке для команды version и метрики build_info.
+type Info struct {
+	Version      string `json:"version"`
+	Commit       string `json:"commit"`
+	BuildDate    string `json:"build_date"`
+	GoVersion    string `json:"go_version"`
+	GoBGPVersion string `json:"gobgp_version"`
+}
+
+// Get возвращает сведения о сборке, пустые поля означают, что сведения недоступны.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	// go install github.com/sir-sukhov/bgp-speaker@v1.2.3 встраивает версию модуля.
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, dep := range build.Deps {
+		if dep.Path == gobgpModule {
+			info.GoBGPVersion = dep.Version
+			if dep.Replace != nil {
+				info.GoBGPVersion = dep.Replace.Version
+			}
+		}
+	}
+	for _, s := range build.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.BuildDate == "":
+			info.BuildDate = s.Value
+		}
+	}
+	return info
+}
@@ -0,0 +1,172 @@
+// Package speaker позволяет встроить bgp-speaker в другую Go программу: анонс anycast ip
+// по результату проверки здоровья и синхронизация маршрута по-умолчанию в ядро.
+//
+// Пример:
+//
+//	sp, err := speaker.New(
+//		speaker.WithConfig(speaker.Config{
+//			AnycastIP: "10.100.10.100",
+//			ASN:       65100,
+//			Neighbors: []speaker.Neighbor{{Address: "10.0.0.1", ASN: 65101}},
+//		}),
+//		speaker.WithHealthChecker(speaker.HealthCheckerFunc(checkBackend)),
+//		speaker.WithFIB(false),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	if err := sp.Start(ctx); err != nil {
+//		return err
+//	}
+//	...
+//	_ = sp.Drain(ctx)
+//	return sp.Stop(ctx)
+package speaker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sir-sukhov/bgp-speaker/internal/bgp"
+	"github.com/sir-sukhov/bgp-speaker/internal/speaker"
+	"github.com/sirupsen/logrus"
+)
+
+// Config это конфигурация speaker, те же поля, что и в YAML файле.
+type Config = speaker.Config
+
+// Neighbor это BGP сосед из [Config].
+type Neighbor = speaker.Neighbor
+
+// BgpServer это методы gobgp сервера, которые вызывает speaker; им удовлетворяет *server.BgpServer.
+type BgpServer = bgp.Server
+
+// FakeBgpServer это BgpServer в памяти для тестов: пути и политики запоминаются,
+// соседи сразу считаются established, см. [NewFakeBgpServer].
+type FakeBgpServer = bgp.Fake
+
+// NewFakeBgpServer создает пустой [FakeBgpServer].
+func NewFakeBgpServer() *FakeBgpServer {
+	return bgp.NewFake()
+}
+
+// HealthChecker проверяет здоровье сервиса за anycast ip: anycast ip анонсируется,
+// пока Check не возвращает ошибку. Check вызывается раз в секунду.
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// HealthCheckerFunc позволяет использовать функцию как [HealthChecker].
+type HealthCheckerFunc func(ctx context.Context) error
+
+func (f HealthCheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Option задает параметры [New].
+type Option func(*options)
+
+type options struct {
+	config        *Config
+	logger        *logrus.Logger
+	healthChecker HealthChecker
+	fib           *bool
+	bgpServer     BgpServer
+}
+
+// WithConfig задает конфигурацию, обязательная опция.
+func WithConfig(config Config) Option {
+	return func(o *options) {
+		o.config = &config
+	}
+}
+
+// WithLogger задает логгер, по-умолчанию пишется в stderr с уровнем info.
+func WithLogger(logger *logrus.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithHealthChecker заменяет проверку здоровья из конфигурации (health_check_url, consul).
+func WithHealthChecker(checker HealthChecker) Option {
+	return func(o *options) {
+		o.healthChecker = checker
+	}
+}
+
+// WithFIB включает или выключает синхронизацию маршрута по-умолчанию в ядро.
+// По-умолчанию синхронизация включена, если в конфигурации задан metric.
+func WithFIB(enabled bool) Option {
+	return func(o *options) {
+		o.fib = &enabled
+	}
+}
+
+// WithBgpServer задает BGP сервер вместо встроенного gobgp, например, [FakeBgpServer]
+// в тестах. Speaker не останавливает переданный сервер, только вызывает StopBgp.
+func WithBgpServer(s BgpServer) Option {
+	return func(o *options) {
+		o.bgpServer = s
+	}
+}
+
+// Speaker это встраиваемый bgp-speaker.
+type Speaker struct {
+	sp *speaker.Speaker
+}
+
+// New проверяет конфигурацию и создает Speaker, BGP запускается в [Speaker.Start].
+func New(opts ...Option) (*Speaker, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.config == nil {
+		return nil, fmt.Errorf("config is required, use WithConfig")
+	}
+	var logger *speaker.Logger
+	if o.logger != nil {
+		logger = speaker.WrapLogger(o.logger)
+	}
+	sp, err := speaker.New(*o.config, logger)
+	if err != nil {
+		return nil, err
+	}
+	if o.healthChecker != nil {
+		sp.SetHealthCheck(o.healthChecker.Check)
+	}
+	if o.fib != nil {
+		sp.SetFIBEnabled(*o.fib)
+	}
+	if o.bgpServer != nil {
+		sp.SetBgpServer(o.bgpServer)
+	}
+	return &Speaker{sp: sp}, nil
+}
+
+// Start запускает BGP и фоновые задачи, которые работают до отмены ctx или вызова [Speaker.Stop].
+func (s *Speaker) Start(ctx context.Context) error {
+	return s.sp.Start(ctx)
+}
+
+// Wait ждет завершения фоновых задач и возвращает первую ошибку.
+func (s *Speaker) Wait() error {
+	return s.sp.Wait()
+}
+
+// Drain отзывает anycast ip, не разрывая BGP сессии, и включает режим обслуживания.
+func (s *Speaker) Drain(ctx context.Context) error {
+	return s.sp.Drain(ctx)
+}
+
+// Undrain выключает режим обслуживания, anycast ip снова анонсируется, если сервис здоров.
+func (s *Speaker) Undrain(ctx context.Context) error {
+	return s.sp.SetMaintenance(ctx, false)
+}
+
+// Stop отзывает anycast ip, закрывает BGP сессии и удаляет маршрут по-умолчанию из ядра.
+// Повторный вызов Stop и Stop после неудачного Start ничего не делают.
+func (s *Speaker) Stop(ctx context.Context) error {
+	return s.sp.Stop(ctx)
+}